@@ -17,9 +17,18 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// ClusterFinalizer is added to every Cluster by the controller and removed
+// once the Cluster was drained.
+const ClusterFinalizer = "cluster.finalizers"
+
+// ClusterTaintDraining is the NoExecute taint the controller puts on a
+// deleted Cluster so its Worlds move off.
+const ClusterTaintDraining = "common.scope.cluster/draining"
+
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
@@ -31,6 +40,118 @@ type ClusterSpec struct {
 	// Foo is an example field of Cluster. Edit cluster_types.go to remove/update
 	Foo string `json:"foo,omitempty"`
 	ClusterName string `json:"clusterName,omitempty"`
+
+	// Mode is how the controller reaches the Cluster, Push when unset.
+	// +optional
+	Mode ClusterMode `json:"mode,omitempty"`
+
+	// Credentials are used to reach the Cluster.
+	// +optional
+	Credentials *ClusterCredentials `json:"credentials,omitempty"`
+
+	// Taints keep Worlds that do not tolerate them off the Cluster.
+	// NoSchedule taints stop new Worlds from being bound to it, NoExecute
+	// taints also evict the Worlds already there once their toleration
+	// seconds ran out. PreferNoSchedule taints are ignored. The controller
+	// sets timeAdded of NoExecute taints without one.
+	// +optional
+	Taints []corev1.Taint `json:"taints,omitempty"`
+
+	// Lifecycle tunes how the Cluster joins and is drained when deleted.
+	// +optional
+	Lifecycle *ClusterLifecycle `json:"lifecycle,omitempty"`
+
+	// NamespaceMapping names the namespaces Worlds are propagated to in the
+	// Cluster, unless a World has its own mapping.
+	// +optional
+	NamespaceMapping *NamespaceMapping `json:"namespaceMapping,omitempty"`
+}
+
+// ClusterDrainPolicy is what happens to the Worlds of a deleted Cluster.
+// +kubebuilder:validation:Enum=Migrate;Remove
+type ClusterDrainPolicy string
+
+const (
+	// DrainMigrate evicts the Worlds from the Cluster: Worlds with a
+	// placement are rescheduled, the others keep running on their other
+	// Clusters. It is the default.
+	DrainMigrate ClusterDrainPolicy = "Migrate"
+	// DrainRemove deletes the Worlds bound to the Cluster.
+	DrainRemove ClusterDrainPolicy = "Remove"
+)
+
+// ClusterTimeoutPolicy is what happens when a Cluster is not drained in time.
+// +kubebuilder:validation:Enum=Wait;Force
+type ClusterTimeoutPolicy string
+
+const (
+	// TimeoutWait keeps draining and reports the timeout. It is the default.
+	TimeoutWait ClusterTimeoutPolicy = "Wait"
+	// TimeoutForce removes the Cluster with the Worlds still bound to it.
+	TimeoutForce ClusterTimeoutPolicy = "Force"
+)
+
+// ClusterLifecycle tunes the lifecycle of a Cluster.
+type ClusterLifecycle struct {
+	// DrainPolicy is what happens to the Worlds of the Cluster once it is
+	// deleted, Migrate when unset.
+	// +optional
+	DrainPolicy ClusterDrainPolicy `json:"drainPolicy,omitempty"`
+	// JoinTimeout is how long the Cluster may take to join before the
+	// Joined condition reports the timeout, 10m when unset.
+	// +optional
+	JoinTimeout *metav1.Duration `json:"joinTimeout,omitempty"`
+	// DrainTimeout is how long the Worlds may take to leave the deleted
+	// Cluster, 30m when unset.
+	// +optional
+	DrainTimeout *metav1.Duration `json:"drainTimeout,omitempty"`
+	// TimeoutPolicy is what happens once DrainTimeout ran out, Wait when
+	// unset.
+	// +optional
+	TimeoutPolicy ClusterTimeoutPolicy `json:"timeoutPolicy,omitempty"`
+}
+
+// ClusterPhase is where a Cluster is in its lifecycle.
+type ClusterPhase string
+
+const (
+	// ClusterJoining is a new Cluster whose credentials are checked and
+	// whose agent RBAC is installed.
+	ClusterJoining ClusterPhase = "Joining"
+	// ClusterReady is a joined Cluster Worlds can be bound to.
+	ClusterReady ClusterPhase = "Ready"
+	// ClusterDraining is a deleted Cluster whose Worlds are moved off.
+	ClusterDraining ClusterPhase = "Draining"
+	// ClusterRemoved is a drained Cluster about to go away.
+	ClusterRemoved ClusterPhase = "Removed"
+)
+
+// ClusterMode is how the controller reaches a Cluster.
+// +kubebuilder:validation:Enum=Push;Pull
+type ClusterMode string
+
+const (
+	// ClusterPush Clusters are reached by the controller with their
+	// credentials, or are the local cluster without them. It is the default.
+	ClusterPush ClusterMode = "Push"
+	// ClusterPull Clusters cannot be reached from the hub. An agent running
+	// in the Cluster pulls its Worlds from the hub, applies them and reports
+	// status back.
+	ClusterPull ClusterMode = "Pull"
+)
+
+// ClusterCredentials reference the Secrets holding a kubeconfig for the
+// Cluster under the "kubeconfig" key. The Secrets must be in the credentials
+// namespace of the controller and the kubeconfig may only use inline
+// certificates and tokens.
+type ClusterCredentials struct {
+	// SecretRef is the Secret in use.
+	SecretRef corev1.SecretReference `json:"secretRef"`
+	// RotateTo is a Secret with new credentials. The controller checks them
+	// against the Cluster, switches SecretRef to it once they work and
+	// clears RotateTo.
+	// +optional
+	RotateTo *corev1.SecretReference `json:"rotateTo,omitempty"`
 }
 
 // ClusterStatus defines the observed state of Cluster
@@ -38,6 +159,191 @@ type ClusterStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 	Cluster string `json:"cluster,omitempty"`
+
+	// Phase is where the Cluster is in its lifecycle.
+	// +optional
+	Phase ClusterPhase `json:"phase,omitempty"`
+
+	// Credentials describe the credentials in use.
+	// +optional
+	Credentials *ClusterCredentialsStatus `json:"credentials,omitempty"`
+
+	// Health is the health of the Cluster over the probe window.
+	// +optional
+	Health *ClusterHealth `json:"health,omitempty"`
+
+	// Agent is what the agent of a Pull Cluster last reported.
+	// +optional
+	Agent *ClusterAgentStatus `json:"agent,omitempty"`
+
+	// Watch is what the watches on the Cluster last saw.
+	// +optional
+	Watch *ClusterWatchStatus `json:"watch,omitempty"`
+
+	// Inventory is what the Cluster runs, collected on a schedule.
+	// +optional
+	Inventory *ClusterInventory `json:"inventory,omitempty"`
+
+	// Conditions represent the latest available observations of the Cluster's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ClusterCredentialsStatus describes the credentials of a Cluster.
+type ClusterCredentialsStatus struct {
+	// Type is how the credentials authenticate: ClientCertificate, Token,
+	// Exec, AuthProvider, Basic or Unknown.
+	// +optional
+	Type string `json:"type,omitempty"`
+	// ExpirationTime is when the client certificate or token expires, unset
+	// when the kubeconfig does not tell.
+	// +optional
+	ExpirationTime *metav1.Time `json:"expirationTime,omitempty"`
+	// LastRotationTime is when the credentials were last rotated.
+	// +optional
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+	// RotationError is why spec.credentials.rotateTo was not switched to.
+	// +optional
+	RotationError string `json:"rotationError,omitempty"`
+}
+
+// ClusterConditionCredentialsValid is true while the credentials of the
+// Cluster can be read and have not expired.
+const ClusterConditionCredentialsValid = "CredentialsValid"
+
+// ClusterConditionSchedulable is true while new Worlds may be bound to the
+// Cluster. The controller cordons a Cluster by setting it to false when its
+// health score drops.
+const ClusterConditionSchedulable = "Schedulable"
+
+// ClusterConditionJoined is true once the credentials of the Cluster were
+// verified and the agent RBAC was installed.
+const ClusterConditionJoined = "Joined"
+
+// ClusterConditionDrained is set on a deleted Cluster and true once no World
+// runs on it anymore.
+const ClusterConditionDrained = "Drained"
+
+// ClusterHealth is the health of a Cluster computed from the probes of the
+// sliding window.
+type ClusterHealth struct {
+	// Score is the health of the Cluster from 0 to 100.
+	Score int32 `json:"score"`
+	// Samples is the number of probes in the window.
+	Samples int32 `json:"samples"`
+	// APILatency is the mean latency of the API server in the window.
+	// +optional
+	APILatency metav1.Duration `json:"apiLatency,omitempty"`
+	// Nodes and ReadyNodes are the nodes seen by the last successful probe.
+	// +optional
+	Nodes int32 `json:"nodes,omitempty"`
+	// +optional
+	ReadyNodes int32 `json:"readyNodes,omitempty"`
+	// Pods and FailedPods are the pods seen by the last successful probe.
+	// +optional
+	Pods int32 `json:"pods,omitempty"`
+	// +optional
+	FailedPods int32 `json:"failedPods,omitempty"`
+	// LastProbeTime is when the Cluster was last probed.
+	LastProbeTime metav1.Time `json:"lastProbeTime"`
+	// LastProbeError is why the last probe failed, empty when it succeeded.
+	// +optional
+	LastProbeError string `json:"lastProbeError,omitempty"`
+}
+
+// ClusterAgentStatus is what the agent of a Pull Cluster reports with its
+// heartbeats.
+type ClusterAgentStatus struct {
+	// LastHeartbeatTime is when the agent last reported.
+	LastHeartbeatTime metav1.Time `json:"lastHeartbeatTime"`
+	// Nodes and ReadyNodes count the Nodes of the Cluster.
+	// +optional
+	Nodes int32 `json:"nodes,omitempty"`
+	// +optional
+	ReadyNodes int32 `json:"readyNodes,omitempty"`
+	// Worlds is the number of Worlds the agent applied in the Cluster.
+	// +optional
+	Worlds int32 `json:"worlds,omitempty"`
+}
+
+// ClusterWatchStatus is what the controller sees through its long-running
+// watches on the Nodes and propagated Worlds of a Cluster.
+type ClusterWatchStatus struct {
+	// Connected is true while the watches run.
+	Connected bool `json:"connected"`
+	// Since is when Connected last changed.
+	// +optional
+	Since metav1.Time `json:"since,omitempty"`
+	// Nodes and ReadyNodes count the Nodes of the Cluster.
+	// +optional
+	Nodes int32 `json:"nodes,omitempty"`
+	// +optional
+	ReadyNodes int32 `json:"readyNodes,omitempty"`
+	// Worlds and ReadyWorlds count the Worlds propagated to the Cluster,
+	// zero when it has no World CRD.
+	// +optional
+	Worlds int32 `json:"worlds,omitempty"`
+	// +optional
+	ReadyWorlds int32 `json:"readyWorlds,omitempty"`
+	// Reconnects counts how often the watches went down.
+	// +optional
+	Reconnects int32 `json:"reconnects,omitempty"`
+	// LastError is why the watches are down.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+}
+
+// ClusterInventory is what a Cluster runs. Lists are capped so the status
+// stays small; the counts and totals always cover the whole Cluster.
+type ClusterInventory struct {
+	// KubernetesVersion is the version of the API server.
+	// +optional
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+	// Capacity and Allocatable sum the resources of all Nodes.
+	// +optional
+	Capacity corev1.ResourceList `json:"capacity,omitempty"`
+	// +optional
+	Allocatable corev1.ResourceList `json:"allocatable,omitempty"`
+	// NodeCount is the number of Nodes.
+	NodeCount int32 `json:"nodeCount"`
+	// Nodes are the first Nodes by name.
+	// +optional
+	Nodes []NodeInventory `json:"nodes,omitempty"`
+	// CRDCount is the number of CustomResourceDefinitions.
+	CRDCount int32 `json:"crdCount"`
+	// CRDs are the names of the first CustomResourceDefinitions.
+	// +optional
+	CRDs []string `json:"crds,omitempty"`
+	// NamespaceCount is the number of Namespaces.
+	NamespaceCount int32 `json:"namespaceCount"`
+	// Namespaces are the names of the first Namespaces.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Truncated is true when a list was capped.
+	// +optional
+	Truncated bool `json:"truncated,omitempty"`
+	// LastCollectionTime is when the inventory was last collected.
+	LastCollectionTime metav1.Time `json:"lastCollectionTime"`
+	// LastCollectionError is why the last collection failed, empty when it
+	// succeeded. The rest of the inventory is from the last success.
+	// +optional
+	LastCollectionError string `json:"lastCollectionError,omitempty"`
+}
+
+// NodeInventory describes a Node of a Cluster.
+type NodeInventory struct {
+	Name string `json:"name"`
+	// KubeletVersion is the version of the kubelet on the Node.
+	// +optional
+	KubeletVersion string `json:"kubeletVersion,omitempty"`
+	// Ready is the Ready condition of the Node.
+	Ready bool `json:"ready"`
+	// +optional
+	Capacity corev1.ResourceList `json:"capacity,omitempty"`
+	// +optional
+	Allocatable corev1.ResourceList `json:"allocatable,omitempty"`
 }
 
 // 集群级资源 scope=Cluster必须在最后一行且没有shortName
@@ -45,6 +351,14 @@ type ClusterStatus struct {
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="cluster",type="string",JSONPath=".status.cluster"
+// +kubebuilder:printcolumn:name="phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="mode",type="string",priority=1,JSONPath=".spec.mode"
+// +kubebuilder:printcolumn:name="version",type="string",priority=1,JSONPath=".status.inventory.kubernetesVersion"
+// +kubebuilder:printcolumn:name="nodes",type="integer",priority=1,JSONPath=".status.inventory.nodeCount"
+// +kubebuilder:printcolumn:name="connected",type="boolean",priority=1,JSONPath=".status.watch.connected"
+// +kubebuilder:printcolumn:name="score",type="integer",JSONPath=".status.health.score"
+// +kubebuilder:printcolumn:name="credentials expire",type="date",priority=1,JSONPath=".status.credentials.expirationTime"
+// +kubebuilder:printcolumn:name="schedulable",type="string",JSONPath=".status.conditions[?(@.type==\"Schedulable\")].status"
 // +kubebuilder:resource:scope=Cluster
 
 // Cluster is the Schema for the clusters API
@@ -0,0 +1,97 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// NamespaceMappingPolicy is how the namespace of a World is named in the
+// Clusters it is propagated to.
+// +kubebuilder:validation:Enum=Same;Prefix;Map
+type NamespaceMappingPolicy string
+
+const (
+	// NamespaceSame keeps the namespace of the World.
+	NamespaceSame NamespaceMappingPolicy = "Same"
+	// NamespacePrefix puts a prefix in front of the namespace of the World.
+	NamespacePrefix NamespaceMappingPolicy = "Prefix"
+	// NamespaceMap looks the namespace of the World up in a map. Namespaces
+	// not in the map are kept.
+	NamespaceMap NamespaceMappingPolicy = "Map"
+)
+
+// NamespaceMapping names the namespace a World is propagated to in a
+// Cluster. The mapping of a World wins over the one of its Cluster.
+type NamespaceMapping struct {
+	// Policy is how the namespace is named, Same when unset.
+	// +optional
+	Policy NamespaceMappingPolicy `json:"policy,omitempty"`
+	// Prefix is put in front of the namespace by the Prefix policy. It
+	// defaults to the name of the Cluster followed by a dash.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+	// Namespaces maps the namespace of the World to the one in the Cluster
+	// for the Map policy.
+	// +optional
+	Namespaces map[string]string `json:"namespaces,omitempty"`
+}
+
+// Map returns the namespace in the Cluster named cluster for namespace. A
+// nil mapping keeps namespace.
+func (m *NamespaceMapping) Map(namespace, cluster string) string {
+	if m == nil {
+		return namespace
+	}
+	switch m.Policy {
+	case NamespacePrefix:
+		prefix := m.Prefix
+		if prefix == "" {
+			prefix = cluster + "-"
+		}
+		return prefix + namespace
+	case NamespaceMap:
+		if target, ok := m.Namespaces[namespace]; ok {
+			return target
+		}
+	}
+	return namespace
+}
+
+// Validate checks that the mapping can produce valid namespace names.
+func (m *NamespaceMapping) Validate(path *field.Path) field.ErrorList {
+	if m == nil {
+		return nil
+	}
+	var allErrs field.ErrorList
+	if m.Prefix != "" {
+		// 前缀本身不必是合法的名字，但加上一个字符后必须是
+		for _, msg := range validation.IsDNS1123Label(m.Prefix + "x") {
+			allErrs = append(allErrs, field.Invalid(path.Child("prefix"), m.Prefix, msg))
+		}
+	}
+	for source, target := range m.Namespaces {
+		for _, msg := range validation.IsDNS1123Label(target) {
+			allErrs = append(allErrs, field.Invalid(path.Child("namespaces").Key(source), target, msg))
+		}
+	}
+	if m.Policy == NamespaceMap && len(m.Namespaces) == 0 {
+		allErrs = append(allErrs, field.Required(path.Child("namespaces"), "the Map policy needs namespaces"))
+	}
+	return allErrs
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorldClusterStatus is how a World fares on one of its Clusters, as
+// reported by the agent of a Pull Cluster.
+type WorldClusterStatus struct {
+	// Cluster is the name of the Cluster.
+	Cluster string `json:"cluster"`
+	// Namespace is the namespace the World was propagated to in the
+	// Cluster.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Applied is true once the World was applied in the Cluster.
+	Applied bool `json:"applied"`
+	// ObservedGeneration is the generation of the World last applied.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Message is why the World could not be applied.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// LastUpdateTime is when the agent last changed this status.
+	LastUpdateTime metav1.Time `json:"lastUpdateTime"`
+}
+
+// RunsOn reports whether the World runs on the Cluster name: it is bound to
+// it and being provisioned or ready. Pending, Failed and deleted Worlds do
+// not run anywhere.
+func (w *World) RunsOn(name string) bool {
+	if w.DeletionTimestamp != nil || (w.Status.Phase != WorldProvisioning && w.Status.Phase != WorldReady) {
+		return false
+	}
+	for _, cluster := range w.BoundClusters() {
+		if cluster == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetClusterStatus sets the status of the World on s.Cluster, keeping
+// LastUpdateTime when nothing else changed. It reports whether the status
+// changed.
+func (w *World) SetClusterStatus(s WorldClusterStatus) bool {
+	for i := range w.Status.Clusters {
+		current := &w.Status.Clusters[i]
+		if current.Cluster != s.Cluster {
+			continue
+		}
+		s.LastUpdateTime = current.LastUpdateTime
+		if *current == s {
+			return false
+		}
+		s.LastUpdateTime = metav1.Now()
+		*current = s
+		return true
+	}
+	s.LastUpdateTime = metav1.Now()
+	w.Status.Clusters = append(w.Status.Clusters, s)
+	return true
+}
+
+// RemoveClusterStatus removes the status of the World on the Cluster name
+// and reports whether there was one.
+func (w *World) RemoveClusterStatus(name string) bool {
+	for i := range w.Status.Clusters {
+		if w.Status.Clusters[i].Cluster == name {
+			w.Status.Clusters = append(w.Status.Clusters[:i], w.Status.Clusters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorldPhase is the lifecycle phase of a World.
+// +kubebuilder:validation:Enum=Pending;Provisioning;Ready;Terminating;Failed
+type WorldPhase string
+
+const (
+	// WorldPending is a World the controller has seen but not yet set up.
+	WorldPending WorldPhase = "Pending"
+	// WorldProvisioning is a World whose finalizer is in place and whose
+	// status is being initialized.
+	WorldProvisioning WorldPhase = "Provisioning"
+	// WorldReady is a fully set up World.
+	WorldReady WorldPhase = "Ready"
+	// WorldTerminating is a deleted World that is being cleaned up.
+	WorldTerminating WorldPhase = "Terminating"
+	// WorldFailed is a World the controller could not set up. It is retried
+	// from Pending.
+	WorldFailed WorldPhase = "Failed"
+)
+
+// MaxPhaseTransitions is the number of phase transitions kept in the status.
+const MaxPhaseTransitions = 10
+
+// worldPhaseTransitions declares the phases a World may move to from each
+// phase. The empty phase is a World the controller has not seen yet.
+var worldPhaseTransitions = map[WorldPhase][]WorldPhase{
+	"":                {WorldPending, WorldTerminating},
+	WorldPending:      {WorldProvisioning, WorldFailed, WorldTerminating},
+	WorldProvisioning: {WorldReady, WorldFailed, WorldTerminating},
+	WorldReady:        {WorldPending, WorldFailed, WorldTerminating},
+	WorldFailed:       {WorldPending, WorldTerminating},
+	WorldTerminating:  nil,
+}
+
+// WorldPhaseTransition records when a World entered a phase.
+type WorldPhaseTransition struct {
+	Phase              WorldPhase  `json:"phase"`
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// CanTransitionTo reports whether a World in phase p may move to next.
+func (p WorldPhase) CanTransitionTo(next WorldPhase) bool {
+	for _, allowed := range worldPhaseTransitions[p] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// SetPhase moves the status to next and records the transition. Setting the
+// current phase again is a no-op; a transition the state machine does not
+// allow is an error and leaves the status alone.
+func (s *WorldStatus) SetPhase(next WorldPhase, now metav1.Time, message string) error {
+	if s.Phase == next {
+		return nil
+	}
+	if !s.Phase.CanTransitionTo(next) {
+		return fmt.Errorf("world cannot move from phase %q to %q", s.Phase, next)
+	}
+	s.Phase = next
+	s.PhaseTransitions = append(s.PhaseTransitions, WorldPhaseTransition{
+		Phase:              next,
+		LastTransitionTime: now,
+		Message:            message,
+	})
+	if n := len(s.PhaseTransitions); n > MaxPhaseTransitions {
+		s.PhaseTransitions = s.PhaseTransitions[n-MaxPhaseTransitions:]
+	}
+	return nil
+}
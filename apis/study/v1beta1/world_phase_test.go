@@ -0,0 +1,77 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWorldStatusSetPhase(t *testing.T) {
+	tests := []struct {
+		from, to WorldPhase
+		wantErr  bool
+	}{
+		{from: "", to: WorldPending},
+		{from: "", to: WorldReady, wantErr: true},
+		{from: WorldPending, to: WorldProvisioning},
+		{from: WorldPending, to: WorldReady, wantErr: true},
+		{from: WorldProvisioning, to: WorldReady},
+		{from: WorldReady, to: WorldTerminating},
+		{from: WorldFailed, to: WorldPending},
+		{from: WorldFailed, to: WorldReady, wantErr: true},
+		{from: WorldTerminating, to: WorldPending, wantErr: true},
+		{from: WorldReady, to: WorldReady},
+	}
+	for _, tt := range tests {
+		s := WorldStatus{Phase: tt.from}
+		err := s.SetPhase(tt.to, metav1.Now(), "")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q -> %q: error = %v, want error %v", tt.from, tt.to, err, tt.wantErr)
+			continue
+		}
+		want := tt.to
+		if err != nil {
+			want = tt.from
+		}
+		if s.Phase != want {
+			t.Errorf("%q -> %q: phase = %q, want %q", tt.from, tt.to, s.Phase, want)
+		}
+		if tt.from != tt.to && err == nil && len(s.PhaseTransitions) != 1 {
+			t.Errorf("%q -> %q: got %d transitions, want 1", tt.from, tt.to, len(s.PhaseTransitions))
+		}
+	}
+}
+
+func TestWorldStatusSetPhaseKeepsRecentTransitions(t *testing.T) {
+	s := WorldStatus{}
+	phases := []WorldPhase{WorldPending, WorldProvisioning, WorldReady}
+	for i := 0; i < MaxPhaseTransitions; i++ {
+		for _, p := range phases {
+			if err := s.SetPhase(p, metav1.Now(), ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if len(s.PhaseTransitions) != MaxPhaseTransitions {
+		t.Fatalf("got %d transitions, want %d", len(s.PhaseTransitions), MaxPhaseTransitions)
+	}
+	if last := s.PhaseTransitions[MaxPhaseTransitions-1]; last.Phase != WorldReady {
+		t.Errorf("last transition is to %q, want Ready", last.Phase)
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PlacementPolicy is how the scheduler ranks the Clusters a World fits on.
+// +kubebuilder:validation:Enum=Spread;Binpack;Weighted
+type PlacementPolicy string
+
+const (
+	// PlacementSpread prefers the Clusters with the most capacity left.
+	PlacementSpread PlacementPolicy = "Spread"
+	// PlacementBinpack prefers the Clusters with the least capacity left, so
+	// the others stay free for large Worlds.
+	PlacementBinpack PlacementPolicy = "Binpack"
+	// PlacementWeighted prefers the Clusters with the highest weight.
+	PlacementWeighted PlacementPolicy = "Weighted"
+)
+
+// Placement lets the scheduler choose the Clusters of a World.
+type Placement struct {
+	// Policy ranks the Clusters the World fits on. Defaults to Spread.
+	// +optional
+	Policy PlacementPolicy `json:"policy,omitempty"`
+	// NumberOfClusters is how many Clusters to place the World on. Defaults
+	// to 1.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	NumberOfClusters int32 `json:"numberOfClusters,omitempty"`
+	// Requests are the resources the World needs on every Cluster it is
+	// placed on. Clusters whose inventory shows less allocatable left are
+	// not chosen.
+	// +optional
+	Requests corev1.ResourceList `json:"requests,omitempty"`
+	// Affinity attracts the World to Clusters by label.
+	// +optional
+	Affinity *ClusterAffinity `json:"affinity,omitempty"`
+	// AntiAffinity keeps the World away from Clusters by label.
+	// +optional
+	AntiAffinity *ClusterAffinity `json:"antiAffinity,omitempty"`
+	// Weights are the weights of the Weighted policy. Clusters not listed
+	// weigh 0.
+	// +optional
+	Weights []ClusterWeight `json:"weights,omitempty"`
+}
+
+// ClusterAffinity selects Clusters by label. For affinity the required
+// Clusters are the only candidates; for anti-affinity they are excluded.
+type ClusterAffinity struct {
+	// Required is a selector Clusters must match.
+	// +optional
+	Required *metav1.LabelSelector `json:"required,omitempty"`
+	// Preferred selectors add their weight to the score of the Clusters they
+	// match, or subtract it for anti-affinity.
+	// +optional
+	Preferred []WeightedClusterSelector `json:"preferred,omitempty"`
+}
+
+// WeightedClusterSelector is a selector with a weight.
+type WeightedClusterSelector struct {
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	Weight   int32                `json:"weight"`
+	Selector metav1.LabelSelector `json:"selector"`
+}
+
+// ClusterWeight is the weight of a Cluster for the Weighted policy.
+type ClusterWeight struct {
+	Cluster string `json:"cluster"`
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Weight int32 `json:"weight"`
+}
+
+// PlacementStatus is the last decision of the scheduler.
+type PlacementStatus struct {
+	// Clusters are the Clusters the World is placed on.
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+	// Scores are the scores of every Cluster the World fits on, best first.
+	// +optional
+	Scores []ClusterScore `json:"scores,omitempty"`
+	// Unschedulable are the Clusters the World does not fit on and why.
+	// +optional
+	Unschedulable []UnschedulableCluster `json:"unschedulable,omitempty"`
+	// ObservedGeneration is the generation of the World that was scheduled.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// ScheduleTime is when the decision was made.
+	ScheduleTime metav1.Time `json:"scheduleTime"`
+	// Message explains the decision.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ClusterScore breaks down the score of a Cluster. Total is Policy +
+// Affinity + Health/5.
+type ClusterScore struct {
+	Cluster string `json:"cluster"`
+	// Policy is the score from 0 to 100 of the placement policy.
+	Policy int32 `json:"policy"`
+	// Affinity is the sum of the preferred affinity weights the Cluster
+	// matches minus the anti-affinity ones, from -100 to 100.
+	Affinity int32 `json:"affinity"`
+	// Health is the health score of the Cluster, 100 when unknown.
+	Health int32 `json:"health"`
+	Total  int32 `json:"total"`
+}
+
+// UnschedulableCluster is a Cluster a World does not fit on.
+type UnschedulableCluster struct {
+	Cluster string `json:"cluster"`
+	Reason  string `json:"reason"`
+}
+
+// ClusterEviction records a World evicted from a Cluster.
+type ClusterEviction struct {
+	Cluster string `json:"cluster"`
+	// Taint is the taint that evicted the World.
+	Taint corev1.Taint `json:"taint"`
+	// Time is when the World was evicted.
+	Time metav1.Time `json:"time"`
+}
+
+// BoundClusters returns the Clusters the World runs on: the ones the
+// scheduler chose when it has a placement, spec.clusters without the
+// evictions otherwise.
+func (w *World) BoundClusters() []string {
+	if w.Spec.Placement != nil {
+		if w.Status.Placement == nil {
+			return nil
+		}
+		return w.Status.Placement.Clusters
+	}
+	if len(w.Status.Evictions) == 0 {
+		return w.Spec.Clusters
+	}
+	evicted := map[string]bool{}
+	for _, e := range w.Status.Evictions {
+		evicted[e.Cluster] = true
+	}
+	var clusters []string
+	for _, name := range w.Spec.Clusters {
+		if !evicted[name] {
+			clusters = append(clusters, name)
+		}
+	}
+	return clusters
+}
@@ -17,18 +17,48 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// WorldFinalizer is added to every World by the controller and removed once
+// its cleanup is done.
+const WorldFinalizer = "world.finalizers"
+
 // WorldSpec defines the desired state of World
 type WorldSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
 	World string `json:"world,omitempty"`
+
+	// Clusters are the names of the Cluster objects this World is bound to.
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+
+	// Placement lets the scheduler choose the Clusters of the World. With a
+	// placement, Clusters are only the candidates when set.
+	// +optional
+	Placement *Placement `json:"placement,omitempty"`
+
+	// Tolerations let the World run on Clusters with matching taints.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// NamespaceMapping names the namespace the World is propagated to in its
+	// Clusters. It wins over the mapping of the Cluster.
+	// +optional
+	NamespaceMapping *commonv1beta1.NamespaceMapping `json:"namespaceMapping,omitempty"`
+
+	// SyncInterval is how often the controller resyncs the World. It
+	// defaults to the interval the controller is started with.
+	// +optional
+	SyncInterval *metav1.Duration `json:"syncInterval,omitempty"`
 }
 
 // WorldStatus defines the observed state of World
@@ -36,8 +66,48 @@ type WorldStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	War      string      `json:"war,omitempty"`
+	War string `json:"war,omitempty"`
+	// SyncTime is when the World was last synced successfully.
 	SyncTime metav1.Time `json:"syncTime,omitempty"`
+	// LastSyncAttemptTime is when the controller last tried to resync the
+	// World.
+	// +optional
+	LastSyncAttemptTime *metav1.Time `json:"lastSyncAttemptTime,omitempty"`
+	// SyncFailures counts the resyncs that failed in a row.
+	// +optional
+	SyncFailures int32 `json:"syncFailures,omitempty"`
+
+	// Placement is the last decision of the scheduler.
+	// +optional
+	Placement *PlacementStatus `json:"placement,omitempty"`
+
+	// Evictions are the Clusters of spec.clusters the World was evicted
+	// from by NoExecute taints. They count as bound again once the taint is
+	// removed or tolerated.
+	// +optional
+	Evictions []ClusterEviction `json:"evictions,omitempty"`
+
+	// Clusters are the statuses the agents of Pull Clusters reported for
+	// the World.
+	// +optional
+	// +listType=map
+	// +listMapKey=cluster
+	Clusters []WorldClusterStatus `json:"clusters,omitempty"`
+
+	// Phase is where the World is in its lifecycle.
+	// +optional
+	Phase WorldPhase `json:"phase,omitempty"`
+
+	// PhaseTransitions records when the World entered its latest phases,
+	// oldest first.
+	// +optional
+	PhaseTransitions []WorldPhaseTransition `json:"phaseTransitions,omitempty"`
+
+	// Conditions represent the latest available observations of the World's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -46,6 +116,7 @@ type WorldStatus struct {
 // +kubebuilder:resource:shortName=wd
 // +kubebuilder:printcolumn:name="world",type="string",JSONPath=".spec.world"
 // +kubebuilder:printcolumn:name="war",type="string",JSONPath=".status.war"
+// +kubebuilder:printcolumn:name="phase",type="string",JSONPath=".status.phase"
 // +kubebuilder:printcolumn:name="syncTime",type="date",priority=1,JSONPath=".status.syncTime"
 
 // World is the Schema for the worlds API
@@ -17,10 +17,19 @@ limitations under the License.
 package v1beta1
 
 import (
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// MinSyncInterval is the shortest spec.syncInterval a World may ask for.
+const MinSyncInterval = 10 * time.Second
+
 // log is for logging in this package.
 var worldlog = logf.Log.WithName("world-resource")
 
@@ -31,3 +40,62 @@ func (r *World) SetupWebhookWithManager(mgr ctrl.Manager) error {
 }
 
 // TODO(user): EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
+
+// Validate checks the spec of the World. The offline manifest tooling and
+// the periodic resync share it; it is not served as an admission webhook,
+// so Worlds already stored are never rejected by it.
+func (r *World) Validate() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if p := r.Spec.Placement; p != nil {
+		allErrs = append(allErrs, validatePlacement(p, len(r.Spec.Clusters), specPath.Child("placement"))...)
+	}
+
+	allErrs = append(allErrs, r.Spec.NamespaceMapping.Validate(specPath.Child("namespaceMapping"))...)
+
+	if d := r.Spec.SyncInterval; d != nil && d.Duration < MinSyncInterval {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("syncInterval"), d.Duration.String(),
+			fmt.Sprintf("must be at least %s", MinSyncInterval)))
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("World").GroupKind(), r.Name, allErrs)
+}
+
+func validatePlacement(p *Placement, candidates int, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if candidates > 0 && int(p.NumberOfClusters) > candidates {
+		allErrs = append(allErrs, field.Invalid(path.Child("numberOfClusters"), p.NumberOfClusters,
+			"must not exceed the number of spec.clusters"))
+	}
+	for _, f := range []struct {
+		name string
+		a    *ClusterAffinity
+	}{{"affinity", p.Affinity}, {"antiAffinity", p.AntiAffinity}} {
+		name, a := f.name, f.a
+		if a == nil {
+			continue
+		}
+		if a.Required != nil {
+			if _, err := metav1.LabelSelectorAsSelector(a.Required); err != nil {
+				allErrs = append(allErrs, field.Invalid(path.Child(name, "required"), a.Required, err.Error()))
+			}
+		}
+		for i := range a.Preferred {
+			if _, err := metav1.LabelSelectorAsSelector(&a.Preferred[i].Selector); err != nil {
+				allErrs = append(allErrs, field.Invalid(path.Child(name, "preferred").Index(i).Child("selector"), a.Preferred[i].Selector, err.Error()))
+			}
+		}
+	}
+	seen := map[string]bool{}
+	for i, w := range p.Weights {
+		if seen[w.Cluster] {
+			allErrs = append(allErrs, field.Duplicate(path.Child("weights").Index(i).Child("cluster"), w.Cluster))
+		}
+		seen[w.Cluster] = true
+	}
+	return allErrs
+}
@@ -5,14 +5,67 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/conversion"
 )
 
+// ConvertTo converts this World to the Hub version (v1beta1).
 func (src *World) ConvertTo(dstRaw conversion.Hub) error {
 	dst := dstRaw.(*v1beta1.World)
+	dst.ObjectMeta = src.ObjectMeta
+
 	dst.Spec.World = src.Spec.Earth
+	dst.Spec.Clusters = src.Spec.Clusters
+	dst.Spec.SyncInterval = src.Spec.SyncInterval
+	// 调度相关的类型与v1beta1共用，转换无损
+	dst.Spec.Placement = src.Spec.Placement
+	dst.Spec.Tolerations = src.Spec.Tolerations
+	dst.Spec.NamespaceMapping = src.Spec.NamespaceMapping
+
+	dst.Status.War = src.Status.War
+	dst.Status.SyncTime = src.Status.SyncTime
+	dst.Status.LastSyncAttemptTime = src.Status.LastSyncAttemptTime
+	dst.Status.SyncFailures = src.Status.SyncFailures
+	dst.Status.Conditions = src.Status.Conditions
+	dst.Status.Placement = src.Status.Placement
+	dst.Status.Evictions = src.Status.Evictions
+	dst.Status.Clusters = src.Status.Clusters
+	dst.Status.Phase = v1beta1.WorldPhase(src.Status.Phase)
+	dst.Status.PhaseTransitions = nil
+	for _, t := range src.Status.PhaseTransitions {
+		dst.Status.PhaseTransitions = append(dst.Status.PhaseTransitions, v1beta1.WorldPhaseTransition{
+			Phase:              v1beta1.WorldPhase(t.Phase),
+			LastTransitionTime: t.LastTransitionTime,
+			Message:            t.Message,
+		})
+	}
 	return nil
 }
 
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
 func (dst *World) ConvertFrom(srcRaw conversion.Hub) error {
 	src := srcRaw.(*v1beta1.World)
+	dst.ObjectMeta = src.ObjectMeta
+
 	dst.Spec.Earth = src.Spec.World
+	dst.Spec.Clusters = src.Spec.Clusters
+	dst.Spec.SyncInterval = src.Spec.SyncInterval
+	dst.Spec.Placement = src.Spec.Placement
+	dst.Spec.Tolerations = src.Spec.Tolerations
+	dst.Spec.NamespaceMapping = src.Spec.NamespaceMapping
+
+	dst.Status.War = src.Status.War
+	dst.Status.SyncTime = src.Status.SyncTime
+	dst.Status.LastSyncAttemptTime = src.Status.LastSyncAttemptTime
+	dst.Status.SyncFailures = src.Status.SyncFailures
+	dst.Status.Conditions = src.Status.Conditions
+	dst.Status.Placement = src.Status.Placement
+	dst.Status.Evictions = src.Status.Evictions
+	dst.Status.Clusters = src.Status.Clusters
+	dst.Status.Phase = string(src.Status.Phase)
+	dst.Status.PhaseTransitions = nil
+	for _, t := range src.Status.PhaseTransitions {
+		dst.Status.PhaseTransitions = append(dst.Status.PhaseTransitions, WorldPhaseTransition{
+			Phase:              string(t.Phase),
+			LastTransitionTime: t.LastTransitionTime,
+			Message:            t.Message,
+		})
+	}
 	return nil
 }
@@ -0,0 +1,138 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/diff"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	"github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+func newHubWorld() *v1beta1.World {
+	now := metav1.NewTime(time.Date(2022, 5, 1, 8, 0, 0, 0, time.UTC))
+	return &v1beta1.World{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "earth",
+			Namespace:   "default",
+			Labels:      map[string]string{"team": "a"},
+			Annotations: map[string]string{"note": "b"},
+			Finalizers:  []string{v1beta1.WorldFinalizer},
+			Generation:  3,
+		},
+		Spec: v1beta1.WorldSpec{
+			World:        "earth",
+			Clusters:     []string{"member-1", "member-2"},
+			SyncInterval: &metav1.Duration{Duration: time.Minute},
+			Placement:    &v1beta1.Placement{Policy: v1beta1.PlacementSpread, NumberOfClusters: 2},
+			Tolerations: []corev1.Toleration{{
+				Key:      "example.cn/maintenance",
+				Operator: corev1.TolerationOpExists,
+				Effect:   corev1.TaintEffectNoSchedule,
+			}},
+			NamespaceMapping: &commonv1beta1.NamespaceMapping{
+				Policy:     commonv1beta1.NamespaceMap,
+				Namespaces: map[string]string{"default": "team-a"},
+			},
+		},
+		Status: v1beta1.WorldStatus{
+			War:                 "peace",
+			SyncTime:            now,
+			LastSyncAttemptTime: &now,
+			SyncFailures:        2,
+			Conditions: []metav1.Condition{{
+				Type:               "Synced",
+				Status:             metav1.ConditionTrue,
+				Reason:             "Synced",
+				LastTransitionTime: now,
+			}},
+			Placement: &v1beta1.PlacementStatus{
+				Clusters:           []string{"member-1"},
+				ObservedGeneration: 3,
+			},
+			Evictions: []v1beta1.ClusterEviction{{
+				Cluster: "member-2",
+				Taint:   corev1.Taint{Key: "example.cn/unreachable", Effect: corev1.TaintEffectNoExecute},
+				Time:    now,
+			}},
+			Clusters: []v1beta1.WorldClusterStatus{{
+				Cluster:            "member-1",
+				Namespace:          "team-a",
+				Applied:            true,
+				ObservedGeneration: 3,
+			}},
+			Phase: v1beta1.WorldReady,
+			PhaseTransitions: []v1beta1.WorldPhaseTransition{
+				{Phase: v1beta1.WorldPending, LastTransitionTime: now},
+				{Phase: v1beta1.WorldReady, LastTransitionTime: now, Message: "synced"},
+			},
+		},
+	}
+}
+
+func TestWorldConversionRoundTrip(t *testing.T) {
+	hub := newHubWorld()
+
+	spoke := new(World)
+	if err := spoke.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if spoke.Spec.Earth != hub.Spec.World {
+		t.Errorf("spec.earth = %q, want %q", spoke.Spec.Earth, hub.Spec.World)
+	}
+	if spoke.Status.Phase != string(hub.Status.Phase) {
+		t.Errorf("status.phase = %q, want %q", spoke.Status.Phase, hub.Status.Phase)
+	}
+
+	got := new(v1beta1.World)
+	if err := spoke.ConvertTo(got); err != nil {
+		t.Fatal(err)
+	}
+	if !apiequality.Semantic.DeepEqual(hub, got) {
+		t.Errorf("v1beta1 -> v1beta2 -> v1beta1 changed the World:\n%s", diff.ObjectReflectDiff(hub, got))
+	}
+
+	again := new(World)
+	if err := again.ConvertFrom(got); err != nil {
+		t.Fatal(err)
+	}
+	if !apiequality.Semantic.DeepEqual(spoke, again) {
+		t.Errorf("v1beta2 -> v1beta1 -> v1beta2 changed the World:\n%s", diff.ObjectReflectDiff(spoke, again))
+	}
+}
+
+func TestWorldConversionEmpty(t *testing.T) {
+	hub := &v1beta1.World{ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: "default"}}
+
+	spoke := new(World)
+	if err := spoke.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	got := new(v1beta1.World)
+	if err := spoke.ConvertTo(got); err != nil {
+		t.Fatal(err)
+	}
+	if !apiequality.Semantic.DeepEqual(hub, got) {
+		t.Errorf("round trip changed an empty World:\n%s", diff.ObjectReflectDiff(hub, got))
+	}
+}
@@ -17,7 +17,11 @@ limitations under the License.
 package v1beta2
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	"github/antmoveh/kube-develop-tools/apis/study/v1beta1"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -29,16 +33,86 @@ type WorldSpec struct {
 	// Important: Run "make" to regenerate code after modifying this file
 
 	Earth string `json:"earth,omitempty"`
+
+	// Clusters are the names of the Cluster objects this World is bound to.
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+
+	// Placement lets the scheduler choose the Clusters of the World. With a
+	// placement, Clusters are only the candidates when set.
+	// +optional
+	Placement *v1beta1.Placement `json:"placement,omitempty"`
+
+	// Tolerations let the World run on Clusters with matching taints.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// NamespaceMapping names the namespace the World is propagated to in its
+	// Clusters. It wins over the mapping of the Cluster.
+	// +optional
+	NamespaceMapping *commonv1beta1.NamespaceMapping `json:"namespaceMapping,omitempty"`
+
+	// SyncInterval is how often the controller resyncs the World. It
+	// defaults to the interval the controller is started with.
+	// +optional
+	SyncInterval *metav1.Duration `json:"syncInterval,omitempty"`
 }
 
 // WorldStatus defines the observed state of World
 type WorldStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	War string `json:"war,omitempty"`
+	// SyncTime is when the World was last synced successfully.
+	SyncTime metav1.Time `json:"syncTime,omitempty"`
+	// LastSyncAttemptTime is when the controller last tried to resync the
+	// World.
+	// +optional
+	LastSyncAttemptTime *metav1.Time `json:"lastSyncAttemptTime,omitempty"`
+	// SyncFailures counts the resyncs that failed in a row.
+	// +optional
+	SyncFailures int32 `json:"syncFailures,omitempty"`
+
+	// Placement is the last decision of the scheduler.
+	// +optional
+	Placement *v1beta1.PlacementStatus `json:"placement,omitempty"`
+
+	// Evictions are the Clusters of spec.clusters the World was evicted
+	// from by NoExecute taints. They count as bound again once the taint is
+	// removed or tolerated.
+	// +optional
+	Evictions []v1beta1.ClusterEviction `json:"evictions,omitempty"`
+
+	// Clusters are the statuses the agents of Pull Clusters reported for
+	// the World.
+	// +optional
+	// +listType=map
+	// +listMapKey=cluster
+	Clusters []v1beta1.WorldClusterStatus `json:"clusters,omitempty"`
+
+	// Phase is where the World is in its lifecycle.
+	// +optional
+	// +kubebuilder:validation:Enum=Pending;Provisioning;Ready;Terminating;Failed
+	Phase string `json:"phase,omitempty"`
+
+	// PhaseTransitions records when the World entered its latest phases,
+	// oldest first.
+	// +optional
+	PhaseTransitions []WorldPhaseTransition `json:"phaseTransitions,omitempty"`
+
+	// Conditions represent the latest available observations of the World's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="earth",type="string",JSONPath=".spec.earth"
+//+kubebuilder:printcolumn:name="war",type="string",JSONPath=".status.war"
+//+kubebuilder:printcolumn:name="phase",type="string",JSONPath=".status.phase"
 
 // World is the Schema for the worlds API
 type World struct {
@@ -49,6 +123,14 @@ type World struct {
 	Status WorldStatus `json:"status,omitempty"`
 }
 
+// WorldPhaseTransition records when a World entered a phase.
+type WorldPhaseTransition struct {
+	Phase              string      `json:"phase"`
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
 //+kubebuilder:object:root=true
 
 // WorldList contains a list of World
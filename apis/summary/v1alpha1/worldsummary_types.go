@@ -0,0 +1,84 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterHealthState is the health of a Cluster as seen from the Worlds bound
+// to it.
+type ClusterHealthState string
+
+const (
+	// ClusterHealthy is a Cluster whose bound Worlds are all fine.
+	ClusterHealthy ClusterHealthState = "Healthy"
+	// ClusterDegraded is a Cluster with Failed or Stale bound Worlds.
+	ClusterDegraded ClusterHealthState = "Degraded"
+	// ClusterCordoned is a Cluster no new Worlds are bound to because of its
+	// health score.
+	ClusterCordoned ClusterHealthState = "Cordoned"
+	// ClusterPaused is a Cluster whose reconciliation is paused.
+	ClusterPaused ClusterHealthState = "Paused"
+	// ClusterMissing is a Cluster that Worlds are bound to but that does not
+	// exist.
+	ClusterMissing ClusterHealthState = "Missing"
+)
+
+// ClusterHealth summarizes a Cluster the Worlds of a namespace are bound to.
+type ClusterHealth struct {
+	Name   string             `json:"name"`
+	Health ClusterHealthState `json:"health"`
+	// Worlds is the number of Worlds of the namespace bound to the Cluster.
+	Worlds int32 `json:"worlds"`
+	// Unhealthy is the number of those Worlds that are Failed or Stale.
+	Unhealthy int32 `json:"unhealthy,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WorldSummary summarizes the Worlds of the namespace it is named after. It
+// is computed on every read and cannot be written.
+type WorldSummary struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Worlds is the number of Worlds in the namespace.
+	Worlds int32 `json:"worlds"`
+	// Phases counts the Worlds by phase; Worlds the controller has not seen
+	// yet are counted under "Unknown".
+	Phases map[string]int32 `json:"phases,omitempty"`
+	// Paused is the number of paused Worlds.
+	Paused int32 `json:"paused,omitempty"`
+	// Stale is the number of Worlds whose resyncs keep failing.
+	Stale int32 `json:"stale,omitempty"`
+	// Clusters lists the Clusters the Worlds are bound to, sorted by name.
+	Clusters []ClusterHealth `json:"clusters,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WorldSummaryList contains a list of WorldSummary
+type WorldSummaryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WorldSummary `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WorldSummary{}, &WorldSummaryList{})
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command agent runs in a pull-mode member cluster. It applies the Worlds
+// the hub binds to its Cluster and reports heartbeats into the Cluster
+// status, so the hub never needs credentials for the member cluster.
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	// to ensure that exec-entrypoint and run can make use of them.
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/agent"
+)
+
+var (
+	scheme   = runtime.NewScheme()
+	setupLog = ctrl.Log.WithName("setup")
+)
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(studyv1beta1.AddToScheme(scheme))
+	utilruntime.Must(commonv1beta1.AddToScheme(scheme))
+}
+
+func main() {
+	var clusterName, hubKubeconfig, hubNamespaces, metricsAddr, probeAddr string
+	var enableLeaderElection bool
+	var heartbeatInterval = agent.DefaultHeartbeatInterval
+	flag.StringVar(&clusterName, "cluster-name", "", "The name of the Cluster on the hub this agent runs in.")
+	flag.StringVar(&hubKubeconfig, "hub-kubeconfig", "", "The kubeconfig the agent reaches the hub with.")
+	flag.StringVar(&hubNamespaces, "hub-namespaces", "",
+		"Comma separated namespaces on the hub whose Worlds the agent applies. Empty applies the Worlds of every "+
+			"namespace, which needs the agent to read Worlds cluster-wide on the hub.")
+	flag.DurationVar(&heartbeatInterval, "heartbeat-interval", heartbeatInterval, "How often the agent reports to the hub.")
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
+		"Enable leader election for the agent. "+
+			"Enabling this will ensure there is only one active agent.")
+	opts := zap.Options{}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	if clusterName == "" || hubKubeconfig == "" {
+		setupLog.Info("--cluster-name and --hub-kubeconfig are required")
+		os.Exit(1)
+	}
+	hubConfig, err := clientcmd.BuildConfigFromFlags("", hubKubeconfig)
+	if err != nil {
+		setupLog.Error(err, "unable to load the hub kubeconfig")
+		os.Exit(1)
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme:                 scheme,
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: probeAddr,
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       "agent.example.cn",
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+
+	var namespaces []string
+	if hubNamespaces != "" {
+		namespaces = strings.Split(hubNamespaces, ",")
+	}
+	hub, err := cluster.New(hubConfig, func(o *cluster.Options) {
+		o.Scheme = scheme
+		o.NewCache = agent.NewHubCache(clusterName, namespaces)
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to set up the hub cluster")
+		os.Exit(1)
+	}
+	if err := mgr.Add(hub); err != nil {
+		setupLog.Error(err, "unable to add the hub cluster")
+		os.Exit(1)
+	}
+
+	if err := (&agent.WorldReconciler{
+		Hub:         hub.GetClient(),
+		Local:       mgr.GetClient(),
+		LocalReader: mgr.GetAPIReader(),
+		Cluster:     clusterName,
+	}).SetupWithManager(mgr, hub); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "World")
+		os.Exit(1)
+	}
+	if err := mgr.Add(&agent.Heartbeat{
+		Hub:      hub.GetClient(),
+		Local:    mgr.GetClient(),
+		Cluster:  clusterName,
+		Interval: heartbeatInterval,
+	}); err != nil {
+		setupLog.Error(err, "unable to set up the heartbeat")
+		os.Exit(1)
+	}
+
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+
+	setupLog.Info("starting agent", "cluster", clusterName)
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		setupLog.Error(err, "problem running agent")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/meta"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/health"
+	"github/antmoveh/kube-develop-tools/pkg/pause"
+)
+
+func newClustersCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "clusters",
+		Short: "List Clusters with their health and the number of bound Worlds",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, _, err := o.client()
+			if err != nil {
+				return err
+			}
+			ctx := context.Background()
+
+			cus := new(commonscopeclusterv1beta1.ClusterList)
+			if err := c.List(ctx, cus); err != nil {
+				return err
+			}
+			wls := new(studyv1beta1.WorldList)
+			if err := c.List(ctx, wls); err != nil {
+				return err
+			}
+			bound := map[string]int{}
+			for _, wl := range wls.Items {
+				for _, name := range wl.BoundClusters() {
+					bound[name]++
+				}
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 2, ' ', 0)
+			fmt.Fprintf(w, "NAME\tCLUSTER NAME\tHEALTH\tWORLDS\tAGE\n")
+			for i := range cus.Items {
+				cu := &cus.Items[i]
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", cu.Name, cu.Spec.ClusterName, clusterHealth(cu), bound[cu.Name], age(cu.CreationTimestamp))
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// clusterHealth summarizes the status of a Cluster in one word.
+func clusterHealth(cu *commonscopeclusterv1beta1.Cluster) string {
+	if cu.CreationTimestamp.IsZero() {
+		return "Missing"
+	}
+	if meta.IsStatusConditionTrue(cu.Status.Conditions, pause.ConditionType) {
+		return "Paused"
+	}
+	switch phase := cu.Status.Phase; phase {
+	case commonscopeclusterv1beta1.ClusterJoining, commonscopeclusterv1beta1.ClusterDraining, commonscopeclusterv1beta1.ClusterRemoved:
+		return string(phase)
+	}
+	if meta.IsStatusConditionFalse(cu.Status.Conditions, commonscopeclusterv1beta1.ClusterConditionSchedulable) {
+		return "Cordoned"
+	}
+	if meta.IsStatusConditionFalse(cu.Status.Conditions, commonscopeclusterv1beta1.ClusterConditionCredentialsValid) {
+		return "Unhealthy"
+	}
+	// 健康状态来自控制器的探测窗口，而不是某个Ready条件
+	h := cu.Status.Health
+	switch {
+	case h == nil || h.Samples == 0:
+		return "Unknown"
+	case h.LastProbeError != "" || h.Score < health.DefaultCordonBelow:
+		return "Unhealthy"
+	default:
+		return "Healthy"
+	}
+}
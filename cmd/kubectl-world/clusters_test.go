@@ -0,0 +1,81 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/pause"
+)
+
+func TestClusterHealth(t *testing.T) {
+	condition := func(conditionType string, status metav1.ConditionStatus) metav1.Condition {
+		return metav1.Condition{Type: conditionType, Status: status}
+	}
+	healthy := &commonscopeclusterv1beta1.ClusterHealth{Score: 100, Samples: 5}
+
+	tests := []struct {
+		name   string
+		status commonscopeclusterv1beta1.ClusterStatus
+		want   string
+	}{
+		{name: "never probed", want: "Unknown"},
+		{name: "healthy", status: commonscopeclusterv1beta1.ClusterStatus{Health: healthy}, want: "Healthy"},
+		{name: "low score", status: commonscopeclusterv1beta1.ClusterStatus{Health: &commonscopeclusterv1beta1.ClusterHealth{Score: 20, Samples: 5}}, want: "Unhealthy"},
+		{name: "probe failed", status: commonscopeclusterv1beta1.ClusterStatus{Health: &commonscopeclusterv1beta1.ClusterHealth{Score: 90, Samples: 5, LastProbeError: "timeout"}}, want: "Unhealthy"},
+		{
+			name: "invalid credentials",
+			status: commonscopeclusterv1beta1.ClusterStatus{
+				Health:     healthy,
+				Conditions: []metav1.Condition{condition(commonscopeclusterv1beta1.ClusterConditionCredentialsValid, metav1.ConditionFalse)},
+			},
+			want: "Unhealthy",
+		},
+		{
+			name: "cordoned",
+			status: commonscopeclusterv1beta1.ClusterStatus{
+				Health:     healthy,
+				Conditions: []metav1.Condition{condition(commonscopeclusterv1beta1.ClusterConditionSchedulable, metav1.ConditionFalse)},
+			},
+			want: "Cordoned",
+		},
+		{name: "draining", status: commonscopeclusterv1beta1.ClusterStatus{Health: healthy, Phase: commonscopeclusterv1beta1.ClusterDraining}, want: "Draining"},
+		{
+			name: "paused",
+			status: commonscopeclusterv1beta1.ClusterStatus{
+				Phase:      commonscopeclusterv1beta1.ClusterDraining,
+				Conditions: []metav1.Condition{condition(pause.ConditionType, metav1.ConditionTrue)},
+			},
+			want: "Paused",
+		},
+	}
+	for _, tt := range tests {
+		cu := &commonscopeclusterv1beta1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "member-1", CreationTimestamp: metav1.Now()},
+			Status:     tt.status,
+		}
+		if got := clusterHealth(cu); got != tt.want {
+			t.Errorf("%s: clusterHealth() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := clusterHealth(&commonscopeclusterv1beta1.Cluster{}); got != "Missing" {
+		t.Errorf("clusterHealth() of a missing Cluster = %q, want Missing", got)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const manifests = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: untouched
+---
+apiVersion: study.example.cn/v1beta1
+kind: World
+metadata:
+  name: one
+spec:
+  world: hello
+  clusters:
+  - c1
+`
+
+func TestConvert(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "worlds.yaml")
+	if err := os.WriteFile(file, []byte(manifests), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		stdin   string
+		args    []string
+		want    []string
+		notWant []string
+		wantErr string
+	}{{
+		name:    "stdin to v1beta2 by default",
+		stdin:   manifests,
+		args:    []string{"convert"},
+		want:    []string{"apiVersion: study.example.cn/v1beta2", "earth: hello", "name: untouched"},
+		notWant: []string{"world: hello"},
+	}, {
+		name: "file to v1beta1",
+		args: []string{"convert", "-f", file, "--to", "v1beta1"},
+		want: []string{"apiVersion: study.example.cn/v1beta1", "world: hello", "- c1"},
+	}, {
+		name:    "unknown version",
+		stdin:   manifests,
+		args:    []string{"convert", "--to", "v2"},
+		wantErr: "v2",
+	}, {
+		name:    "missing file",
+		args:    []string{"convert", "-f", filepath.Join(t.TempDir(), "missing.yaml")},
+		wantErr: "no such file",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// convert works offline, no client is needed
+			out, err := run(t, nil, tt.stdin, tt.args...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(out, s) {
+					t.Errorf("output does not contain %q:\n%s", s, out)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(out, s) {
+					t.Errorf("output contains %q:\n%s", s, out)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+func newDescribeCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "describe NAME",
+		Short: "Show a World with its conditions and bound Clusters",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, namespace, err := o.client()
+			if err != nil {
+				return err
+			}
+			ctx := context.Background()
+
+			wl := new(studyv1beta1.World)
+			if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: args[0]}, wl); err != nil {
+				return err
+			}
+
+			clusters := make([]*commonscopeclusterv1beta1.Cluster, 0, len(wl.BoundClusters()))
+			for _, name := range wl.BoundClusters() {
+				cu := new(commonscopeclusterv1beta1.Cluster)
+				if err := c.Get(ctx, types.NamespacedName{Name: name}, cu); err != nil {
+					if !apierrs.IsNotFound(err) {
+						return err
+					}
+					cu.Name = name
+				}
+				clusters = append(clusters, cu)
+			}
+			return describeWorld(cmd.OutOrStdout(), wl, clusters)
+		},
+	}
+}
+
+func describeWorld(out io.Writer, wl *studyv1beta1.World, clusters []*commonscopeclusterv1beta1.Cluster) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", wl.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", wl.Namespace)
+	fmt.Fprintf(w, "Finalizers:\t%s\n", strings.Join(wl.Finalizers, ", "))
+	if wl.DeletionTimestamp != nil {
+		fmt.Fprintf(w, "Deleting Since:\t%s\n", wl.DeletionTimestamp.Format(time.RFC3339))
+	}
+	fmt.Fprintf(w, "Spec:\n")
+	fmt.Fprintf(w, "  World:\t%s\n", wl.Spec.World)
+	if wl.Spec.SyncInterval != nil {
+		fmt.Fprintf(w, "  Sync Interval:\t%s\n", wl.Spec.SyncInterval.Duration)
+	}
+	fmt.Fprintf(w, "Status:\n")
+	fmt.Fprintf(w, "  Phase:\t%s\n", wl.Status.Phase)
+	fmt.Fprintf(w, "  War:\t%s\n", wl.Status.War)
+	fmt.Fprintf(w, "  Sync Time:\t%s\n", formatTime(wl.Status.SyncTime))
+	if wl.Status.SyncFailures > 0 {
+		fmt.Fprintf(w, "  Sync Failures:\t%d\n", wl.Status.SyncFailures)
+	}
+
+	fmt.Fprintf(w, "Phase Transitions:\n")
+	if len(wl.Status.PhaseTransitions) == 0 {
+		fmt.Fprintf(w, "  <none>\n")
+	} else {
+		fmt.Fprintf(w, "  PHASE\tAGE\tMESSAGE\n")
+		for _, t := range wl.Status.PhaseTransitions {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", t.Phase, age(t.LastTransitionTime), t.Message)
+		}
+	}
+
+	fmt.Fprintf(w, "Conditions:\n")
+	if len(wl.Status.Conditions) == 0 {
+		fmt.Fprintf(w, "  <none>\n")
+	} else {
+		fmt.Fprintf(w, "  TYPE\tSTATUS\tREASON\tAGE\tMESSAGE\n")
+		for _, cond := range wl.Status.Conditions {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", cond.Type, cond.Status, cond.Reason, age(cond.LastTransitionTime), cond.Message)
+		}
+	}
+
+	if p := wl.Status.Placement; wl.Spec.Placement != nil && p != nil {
+		fmt.Fprintf(w, "Placement:\n")
+		fmt.Fprintf(w, "  Scheduled:\t%s\n", formatTime(p.ScheduleTime))
+		fmt.Fprintf(w, "  Message:\t%s\n", p.Message)
+		if len(p.Scores) > 0 {
+			fmt.Fprintf(w, "  CLUSTER\tPOLICY\tAFFINITY\tHEALTH\tTOTAL\n")
+			for _, sc := range p.Scores {
+				fmt.Fprintf(w, "  %s\t%d\t%d\t%d\t%d\n", sc.Cluster, sc.Policy, sc.Affinity, sc.Health, sc.Total)
+			}
+		}
+		for _, u := range p.Unschedulable {
+			fmt.Fprintf(w, "  %s\tunschedulable: %s\n", u.Cluster, u.Reason)
+		}
+	}
+
+	fmt.Fprintf(w, "Clusters:\n")
+	if len(clusters) == 0 {
+		fmt.Fprintf(w, "  <none>\n")
+	} else {
+		fmt.Fprintf(w, "  NAME\tCLUSTER NAME\tHEALTH\n")
+		for _, cu := range clusters {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", cu.Name, cu.Spec.ClusterName, clusterHealth(cu))
+		}
+	}
+	return w.Flush()
+}
+
+func formatTime(t metav1.Time) string {
+	if t.IsZero() {
+		return "<unset>"
+	}
+	return fmt.Sprintf("%s (%s ago)", t.Format(time.RFC3339), age(t))
+}
+
+func age(t metav1.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(t.Time))
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+func TestDescribe(t *testing.T) {
+	world := &studyv1beta1.World{
+		ObjectMeta: metav1.ObjectMeta{Name: "earth", Namespace: "default", Finalizers: []string{studyv1beta1.WorldFinalizer}},
+		Spec:       studyv1beta1.WorldSpec{World: "earth", Clusters: []string{"member-1", "gone"}},
+		Status: studyv1beta1.WorldStatus{
+			Phase: studyv1beta1.WorldReady,
+			Conditions: []metav1.Condition{{
+				Type:   "Synced",
+				Status: metav1.ConditionTrue,
+				Reason: "SyncSucceeded",
+			}},
+		},
+	}
+	member := &commonscopeclusterv1beta1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "member-1", CreationTimestamp: metav1.Now()},
+		Spec:       commonscopeclusterv1beta1.ClusterSpec{ClusterName: "beijing"},
+		Status: commonscopeclusterv1beta1.ClusterStatus{
+			Health: &commonscopeclusterv1beta1.ClusterHealth{Score: 100, Samples: 3},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		objs    []client.Object
+		args    []string
+		want    []string
+		wantErr string
+	}{{
+		name: "world with clusters",
+		objs: []client.Object{world, member},
+		args: []string{"describe", "earth"},
+		want: []string{
+			"Name:", "earth",
+			"Finalizers:", studyv1beta1.WorldFinalizer,
+			"Phase:", "Ready",
+			"Synced", "SyncSucceeded",
+			"member-1", "beijing", "Healthy",
+			"gone", "Missing",
+		},
+	}, {
+		name: "world without status",
+		objs: []client.Object{&studyv1beta1.World{ObjectMeta: metav1.ObjectMeta{Name: "moon", Namespace: "default"}}},
+		args: []string{"describe", "moon"},
+		want: []string{"Conditions:\n  <none>", "Clusters:\n  <none>"},
+	}, {
+		name:    "world in another namespace",
+		objs:    []client.Object{world},
+		args:    []string{"describe", "-n", "other", "earth"},
+		wantErr: "not found",
+	}, {
+		name:    "missing name",
+		args:    []string{"describe"},
+		wantErr: "accepts 1 arg",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := run(t, newFakeClient(tt.objs...), "", tt.args...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(out, s) {
+					t.Errorf("output does not contain %q:\n%s", s, out)
+				}
+			}
+		})
+	}
+}
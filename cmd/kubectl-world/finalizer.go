@@ -0,0 +1,70 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+func newRemoveFinalizerCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove-finalizer NAME",
+		Short: "Force-remove the " + studyv1beta1.WorldFinalizer + " finalizer from a World",
+		Long: "Force-remove the " + studyv1beta1.WorldFinalizer + " finalizer from a World that is stuck deleting.\n" +
+			"The controller's cleanup for the World is skipped.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, namespace, err := o.client()
+			if err != nil {
+				return err
+			}
+			ctx := context.Background()
+
+			wl := new(studyv1beta1.World)
+			if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: args[0]}, wl); err != nil {
+				return err
+			}
+
+			finalizers := make([]string, 0, len(wl.Finalizers))
+			for _, f := range wl.Finalizers {
+				if f != studyv1beta1.WorldFinalizer {
+					finalizers = append(finalizers, f)
+				}
+			}
+			if len(finalizers) == len(wl.Finalizers) {
+				fmt.Fprintf(cmd.OutOrStdout(), "world/%s has no %s finalizer\n", wl.Name, studyv1beta1.WorldFinalizer)
+				return nil
+			}
+
+			// 使用乐观锁，避免覆盖并发修改的finalizers
+			patch := client.MergeFromWithOptions(wl.DeepCopy(), client.MergeFromWithOptimisticLock{})
+			wl.Finalizers = finalizers
+			if err := c.Patch(ctx, wl, patch); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "world/%s finalizer %s removed\n", wl.Name, studyv1beta1.WorldFinalizer)
+			return nil
+		},
+	}
+}
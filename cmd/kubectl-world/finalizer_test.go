@@ -0,0 +1,87 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+func TestRemoveFinalizer(t *testing.T) {
+	tests := []struct {
+		name           string
+		finalizers     []string
+		patchErr       error
+		wantFinalizers []string
+		wantOut        string
+		wantErr        string
+	}{{
+		name:           "removes only the world finalizer",
+		finalizers:     []string{"other.example.cn/keep", studyv1beta1.WorldFinalizer},
+		wantFinalizers: []string{"other.example.cn/keep"},
+		wantOut:        "finalizer " + studyv1beta1.WorldFinalizer + " removed",
+	}, {
+		name:           "no world finalizer",
+		finalizers:     []string{"other.example.cn/keep"},
+		wantFinalizers: []string{"other.example.cn/keep"},
+		wantOut:        "has no " + studyv1beta1.WorldFinalizer + " finalizer",
+	}, {
+		name:           "patch fails",
+		finalizers:     []string{studyv1beta1.WorldFinalizer},
+		patchErr:       errors.New("conflict"),
+		wantFinalizers: []string{studyv1beta1.WorldFinalizer},
+		wantErr:        "conflict",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wl := &studyv1beta1.World{ObjectMeta: metav1.ObjectMeta{Name: "earth", Namespace: "default", Finalizers: tt.finalizers}}
+			c := newFakeClient(wl)
+			if tt.patchErr != nil {
+				c.InjectError(testutil.VerbPatch, testutil.AlwaysError(tt.patchErr))
+			}
+
+			out, err := run(t, c, "", "remove-finalizer", "earth")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if !strings.Contains(out, tt.wantOut) {
+				t.Errorf("output = %q, want %q", out, tt.wantOut)
+			}
+
+			c.ClearErrors()
+			got := new(studyv1beta1.World)
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(wl), got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Finalizers, tt.wantFinalizers) {
+				t.Errorf("finalizers = %v, want %v", got.Finalizers, tt.wantFinalizers)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-world is a kubectl plugin for day to day World and Cluster
+// operations. Put the binary on the PATH and run `kubectl world`.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	studyv1beta2 "github/antmoveh/kube-develop-tools/apis/study/v1beta2"
+	"github/antmoveh/kube-develop-tools/pkg/manifestcmd"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(studyv1beta1.AddToScheme(scheme))
+	utilruntime.Must(studyv1beta2.AddToScheme(scheme))
+	utilruntime.Must(commonscopeclusterv1beta1.AddToScheme(scheme))
+}
+
+// options holds the connection flags shared by every subcommand.
+type options struct {
+	kubeconfig string
+	context    string
+	namespace  string
+
+	// c replaces the client built from the kubeconfig, tests set it.
+	c client.Client
+}
+
+// client returns a client for the selected kubeconfig context and the
+// namespace to work in.
+func (o *options) client() (client.Client, string, error) {
+	if o.c != nil {
+		return o.c, o.namespace, nil
+	}
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = o.kubeconfig
+	cc := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: o.context})
+
+	cfg, err := cc.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	namespace := o.namespace
+	if namespace == "" {
+		if namespace, _, err = cc.Namespace(); err != nil {
+			return nil, "", err
+		}
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, "", err
+	}
+	return c, namespace, nil
+}
+
+func newRootCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "kubectl-world",
+		Short:         "Operate World and Cluster resources",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	cmd.PersistentFlags().StringVar(&o.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use.")
+	cmd.PersistentFlags().StringVar(&o.context, "context", "", "The name of the kubeconfig context to use.")
+	cmd.PersistentFlags().StringVarP(&o.namespace, "namespace", "n", "", "The namespace of the World.")
+
+	cmd.AddCommand(
+		newDescribeCommand(o),
+		manifestcmd.NewConvertCommand(),
+		newRemoveFinalizerCommand(o),
+		newPauseCommand(o, true),
+		newPauseCommand(o, false),
+		newClustersCommand(o),
+	)
+	return cmd
+}
+
+func main() {
+	if err := newRootCommand(&options{}).Execute(); err != nil {
+		if err != manifestcmd.ErrFailed {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(1)
+	}
+}
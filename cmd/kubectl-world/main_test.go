@@ -0,0 +1,45 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+// run executes the plugin with args against c and returns what it printed.
+func run(t *testing.T, c client.Client, in string, args ...string) (string, error) {
+	t.Helper()
+	cmd := newRootCommand(&options{c: c})
+	out := new(bytes.Buffer)
+	cmd.SetIn(strings.NewReader(in))
+	cmd.SetOut(out)
+	cmd.SetErr(out)
+	// 后面的 -n 会覆盖这个默认的命名空间
+	cmd.SetArgs(append([]string{"-n", "default"}, args...))
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func newFakeClient(objs ...client.Object) *testutil.FakeClient {
+	return testutil.NewFakeClient(scheme, objs...)
+}
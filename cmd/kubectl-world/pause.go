@@ -0,0 +1,86 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/pause"
+)
+
+// newPauseCommand builds the pause command, or the resume command when
+// paused is false.
+func newPauseCommand(o *options, paused bool) *cobra.Command {
+	verb, short := "pause", "Stop the controllers from reconciling a World or Cluster"
+	if !paused {
+		verb, short = "resume", "Let the controllers reconcile a paused World or Cluster again"
+	}
+
+	return &cobra.Command{
+		Use:       verb + " (world|cluster) NAME",
+		Short:     short,
+		Args:      cobra.ExactArgs(2),
+		ValidArgs: []string{"world", "cluster"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, namespace, err := o.client()
+			if err != nil {
+				return err
+			}
+
+			var obj client.Object
+			key := types.NamespacedName{Name: args[1]}
+			switch args[0] {
+			case "world":
+				obj = new(studyv1beta1.World)
+				key.Namespace = namespace
+			case "cluster":
+				obj = new(commonscopeclusterv1beta1.Cluster)
+			default:
+				return fmt.Errorf("unknown kind %q, expected world or cluster", args[0])
+			}
+
+			ctx := context.Background()
+			if err := c.Get(ctx, key, obj); err != nil {
+				return err
+			}
+
+			patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+			annotations := obj.GetAnnotations()
+			if paused {
+				if annotations == nil {
+					annotations = map[string]string{}
+				}
+				annotations[pause.Annotation] = "true"
+			} else {
+				delete(annotations, pause.Annotation)
+			}
+			obj.SetAnnotations(annotations)
+			if err := c.Patch(ctx, obj, patch); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s/%s %sd\n", args[0], args[1], verb)
+			return nil
+		},
+	}
+}
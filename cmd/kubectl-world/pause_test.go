@@ -0,0 +1,105 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/pause"
+)
+
+func TestPause(t *testing.T) {
+	paused := map[string]string{pause.Annotation: "true", "keep": "me"}
+
+	tests := []struct {
+		name       string
+		obj        client.Object
+		args       []string
+		wantPaused bool
+		wantOut    string
+		wantErr    string
+	}{{
+		name:       "pause world",
+		obj:        &studyv1beta1.World{ObjectMeta: metav1.ObjectMeta{Name: "earth", Namespace: "default"}},
+		args:       []string{"pause", "world", "earth"},
+		wantPaused: true,
+		wantOut:    "world/earth paused",
+	}, {
+		name:    "resume world",
+		obj:     &studyv1beta1.World{ObjectMeta: metav1.ObjectMeta{Name: "earth", Namespace: "default", Annotations: paused}},
+		args:    []string{"resume", "world", "earth"},
+		wantOut: "world/earth resumed",
+	}, {
+		name:       "pause cluster",
+		obj:        &commonscopeclusterv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "member-1"}},
+		args:       []string{"pause", "cluster", "member-1"},
+		wantPaused: true,
+		wantOut:    "cluster/member-1 paused",
+	}, {
+		name:    "resume cluster",
+		obj:     &commonscopeclusterv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "member-1", Annotations: paused}},
+		args:    []string{"resume", "cluster", "member-1"},
+		wantOut: "cluster/member-1 resumed",
+	}, {
+		name:    "unknown kind",
+		obj:     &studyv1beta1.World{ObjectMeta: metav1.ObjectMeta{Name: "earth", Namespace: "default"}},
+		args:    []string{"pause", "node", "earth"},
+		wantErr: "unknown kind",
+	}, {
+		name:    "missing world",
+		obj:     &studyv1beta1.World{ObjectMeta: metav1.ObjectMeta{Name: "earth", Namespace: "default"}},
+		args:    []string{"pause", "world", "moon"},
+		wantErr: "not found",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeClient(tt.obj)
+			out, err := run(t, c, "", tt.args...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out, tt.wantOut) {
+				t.Errorf("output = %q, want %q", out, tt.wantOut)
+			}
+
+			got := reflect.New(reflect.TypeOf(tt.obj).Elem()).Interface().(client.Object)
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(tt.obj), got); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := got.GetAnnotations()[pause.Annotation]; ok != tt.wantPaused {
+				t.Errorf("paused = %v, want %v", ok, tt.wantPaused)
+			}
+			if tt.obj.GetAnnotations()["keep"] != got.GetAnnotations()["keep"] {
+				t.Errorf("other annotations changed: %v", got.GetAnnotations())
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command world-apiserver serves the read-only worldsummaries of the
+// summary.example.cn group behind the kube-apiserver aggregation layer.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	// to ensure that exec-entrypoint and run can make use of them.
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/apiserver"
+)
+
+var (
+	scheme   = runtime.NewScheme()
+	setupLog = ctrl.Log.WithName("setup")
+)
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(studyv1beta1.AddToScheme(scheme))
+	utilruntime.Must(commonv1beta1.AddToScheme(scheme))
+}
+
+func main() {
+	var bindAddr, certDir, metricsAddr, probeAddr string
+	flag.StringVar(&bindAddr, "secure-bind-address", ":8443", "The address the aggregated API binds to.")
+	flag.StringVar(&certDir, "cert-dir", "/tmp/k8s-apiserver/serving-certs",
+		"The directory holding the serving certificate, tls.crt and tls.key.")
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	opts := zap.Options{}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme:                 scheme,
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: probeAddr,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+
+	// Start the World and Cluster informers with the manager rather than on
+	// the first request.
+	for _, obj := range []client.Object{&studyv1beta1.World{}, &commonv1beta1.Cluster{}} {
+		if _, err := mgr.GetCache().GetInformer(context.Background(), obj); err != nil {
+			setupLog.Error(err, "unable to set up informer", "type", fmt.Sprintf("%T", obj))
+			os.Exit(1)
+		}
+	}
+
+	if err := mgr.Add(&apiserver.Server{
+		Addr:       bindAddr,
+		CertDir:    certDir,
+		Reader:     mgr.GetCache(),
+		APIReader:  mgr.GetAPIReader(),
+		Authorizer: &apiserver.SubjectAccessReviewer{Client: mgr.GetClient()},
+	}); err != nil {
+		setupLog.Error(err, "unable to set up the API server")
+		os.Exit(1)
+	}
+
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+
+	setupLog.Info("starting world-apiserver")
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		setupLog.Error(err, "problem running world-apiserver")
+		os.Exit(1)
+	}
+}
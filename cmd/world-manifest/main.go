@@ -0,0 +1,45 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// world-manifest validates and converts World manifests without a cluster,
+// so CI can check a GitOps repository with the rules of the API types.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github/antmoveh/kube-develop-tools/pkg/manifestcmd"
+)
+
+func main() {
+	cmd := &cobra.Command{
+		Use:           "world-manifest",
+		Short:         "Validate and convert World manifests offline",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	cmd.AddCommand(manifestcmd.NewValidateCommand(), manifestcmd.NewConvertCommand())
+
+	if err := cmd.Execute(); err != nil {
+		if err != manifestcmd.ErrFailed {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(1)
+	}
+}
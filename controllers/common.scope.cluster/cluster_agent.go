@@ -0,0 +1,53 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commonscopecluster
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/agent"
+	"github/antmoveh/kube-develop-tools/pkg/health"
+)
+
+// ReasonAgentNotConnected is the reason of a pull-mode Cluster whose agent
+// has not sent a heartbeat recently.
+const ReasonAgentNotConnected = "AgentNotConnected"
+
+// agentHeartbeatTimeout is how old the last heartbeat of a pull-mode
+// Cluster may be before its agent counts as disconnected.
+const agentHeartbeatTimeout = 3 * agent.DefaultHeartbeatInterval
+
+// isPull tells whether the agent of cu pulls its Worlds from the hub.
+func isPull(cu *commonscopeclusterv1beta1.Cluster) bool {
+	return cu.Spec.Mode == commonscopeclusterv1beta1.ClusterPull
+}
+
+// agentSample turns the last heartbeat of a pull-mode Cluster into a health
+// sample. A missing or stale heartbeat is an error.
+func agentSample(cu *commonscopeclusterv1beta1.Cluster, now time.Time) (health.Sample, error) {
+	a := cu.Status.Agent
+	if a == nil || a.LastHeartbeatTime.IsZero() {
+		return health.Sample{}, errors.New("the agent has not sent a heartbeat")
+	}
+	if age := now.Sub(a.LastHeartbeatTime.Time); age > agentHeartbeatTimeout {
+		return health.Sample{}, fmt.Errorf("the last heartbeat of the agent was %s ago", age.Round(time.Second))
+	}
+	return health.Sample{Nodes: a.Nodes, ReadyNodes: a.ReadyNodes}, nil
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commonscopecluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/health"
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+func TestClusterPull(t *testing.T) {
+	cluster := func(heartbeat *commonscopeclusterv1beta1.ClusterAgentStatus) *commonscopeclusterv1beta1.Cluster {
+		return &commonscopeclusterv1beta1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "alpha"},
+			Spec:       commonscopeclusterv1beta1.ClusterSpec{Mode: commonscopeclusterv1beta1.ClusterPull},
+			Status:     commonscopeclusterv1beta1.ClusterStatus{Agent: heartbeat},
+		}
+	}
+	beat := func(ago time.Duration) *commonscopeclusterv1beta1.ClusterAgentStatus {
+		return &commonscopeclusterv1beta1.ClusterAgentStatus{
+			LastHeartbeatTime: metav1.NewTime(time.Now().Add(-ago)),
+			Nodes:             4,
+			ReadyNodes:        3,
+			Worlds:            2,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		cu         *commonscopeclusterv1beta1.Cluster
+		wantPhase  commonscopeclusterv1beta1.ClusterPhase
+		wantReason string
+		wantNodes  int32
+		wantErr    bool
+	}{
+		{
+			name:       "no heartbeat",
+			cu:         cluster(nil),
+			wantPhase:  commonscopeclusterv1beta1.ClusterJoining,
+			wantReason: ReasonAgentNotConnected,
+			wantErr:    true,
+		},
+		{
+			name:       "stale heartbeat",
+			cu:         cluster(beat(time.Hour)),
+			wantPhase:  commonscopeclusterv1beta1.ClusterJoining,
+			wantReason: ReasonAgentNotConnected,
+			wantErr:    true,
+		},
+		{
+			name:       "fresh heartbeat",
+			cu:         cluster(beat(time.Second)),
+			wantPhase:  commonscopeclusterv1beta1.ClusterReady,
+			wantReason: ReasonJoined,
+			wantNodes:  4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testutil.NewFakeClient(nil, tt.cu)
+			agent := &fakeAgent{}
+			r := &ClusterReconciler{
+				Client:   c,
+				Scheme:   c.Scheme(),
+				Recorder: testutil.NewEventRecorder(),
+				Agent:    agent,
+				Health:   health.NewMonitor(nil),
+				VerifyCredentials: func(context.Context, *rest.Config) error {
+					t.Error("verified the credentials of a pull-mode Cluster")
+					return nil
+				},
+			}
+
+			key := client.ObjectKey{Name: "alpha"}
+			if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if len(agent.installed) != 0 {
+				t.Errorf("installed the agent into %v", agent.installed)
+			}
+
+			cu := new(commonscopeclusterv1beta1.Cluster)
+			if err := c.Get(context.Background(), key, cu); err != nil {
+				t.Fatal(err)
+			}
+			if cu.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %s, want %s", cu.Status.Phase, tt.wantPhase)
+			}
+			if cond := meta.FindStatusCondition(cu.Status.Conditions, commonscopeclusterv1beta1.ClusterConditionJoined); cond == nil || cond.Reason != tt.wantReason {
+				t.Errorf("Joined condition = %+v, want reason %s", cond, tt.wantReason)
+			}
+			h := cu.Status.Health
+			if h == nil {
+				t.Fatal("health is not set")
+			}
+			if (h.LastProbeError != "") != tt.wantErr || h.Nodes != tt.wantNodes {
+				t.Errorf("health = %+v, want nodes %d and error %v", h, tt.wantNodes, tt.wantErr)
+			}
+			if cu.Status.Inventory != nil || cu.Status.Watch != nil {
+				t.Errorf("inventory = %+v, watch = %+v, want neither", cu.Status.Inventory, cu.Status.Watch)
+			}
+		})
+	}
+}
@@ -19,16 +19,31 @@ package commonscopecluster
 import (
 	"context"
 	"fmt"
+	"github/antmoveh/kube-develop-tools/pkg/agent"
+	"github/antmoveh/kube-develop-tools/pkg/credentials"
+	"github/antmoveh/kube-develop-tools/pkg/debug"
+	"github/antmoveh/kube-develop-tools/pkg/health"
+	"github/antmoveh/kube-develop-tools/pkg/inventory"
+	"github/antmoveh/kube-develop-tools/pkg/logging"
+	"github/antmoveh/kube-develop-tools/pkg/pause"
+	"github/antmoveh/kube-develop-tools/pkg/remote"
+	"github/antmoveh/kube-develop-tools/pkg/tracing"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 	"time"
 
@@ -38,6 +53,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
 )
 
 // ClusterReconciler reconciles a Cluster object
@@ -45,11 +61,48 @@ type ClusterReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+	// Pause decides whether a Cluster is paused. A nil Pause only honors the
+	// pause annotation.
+	Pause *pause.Checker
+	// LogLevels holds the runtime log level of the controller. A nil
+	// LogLevels logs with the manager logger.
+	LogLevels *logging.Levels
+	// Queues tracks the workqueue of the controller for the debug server. A
+	// nil Queues leaves it alone.
+	Queues *debug.Queues
+	// Health scores the Clusters and cordons the unhealthy ones. A nil
+	// Health leaves them schedulable.
+	Health *health.Monitor
+	// ExpiryWarning is how long before their credentials expire Clusters
+	// are warned about, credentials.DefaultExpiryWarning when zero.
+	ExpiryWarning time.Duration
+	// VerifyCredentials checks new credentials before they are rotated to,
+	// credentials.Verify when nil.
+	VerifyCredentials credentials.Verifier
+	// CredentialsNamespace is the namespace the credential Secrets must be
+	// in. Empty accepts Secrets of any namespace.
+	CredentialsNamespace string
+	// Secrets reads and watches the credential Secrets, a cache of
+	// CredentialsNamespace only. A nil Secrets reads them through Client and
+	// does not watch them.
+	Secrets cache.Cache
+	// Inventory collects what the Clusters run on a schedule. A nil
+	// Inventory leaves status.inventory unset.
+	Inventory *inventory.Collector
+	// Agent installs the agent RBAC in joining push-mode Clusters and
+	// removes it from drained ones. A nil Agent only verifies the
+	// credentials. Pull-mode Clusters run the agent themselves.
+	Agent agent.Installer
+	// Remote watches the Nodes and Worlds of the Clusters and enqueues them
+	// when what it sees changes. A nil Remote leaves status.watch unset.
+	Remote *remote.Watcher
 }
 
 //+kubebuilder:rbac:groups=common,resources=clusters,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=common,resources=clusters/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=common,resources=clusters/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",namespace=system,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -61,39 +114,112 @@ type ClusterReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.10.0/pkg/reconcile
 func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+	logger := log.FromContext(ctx)
 
 	cu := new(commonscopeclusterv1beta1.Cluster)
 	if err := r.Client.Get(ctx, req.NamespacedName, cu); err != nil {
 		if !apierrs.IsNotFound(err) {
 			return ctrl.Result{}, err
 		}
+		pause.Track("Cluster", req.NamespacedName, false)
+		r.Health.Forget(req.Name)
+		r.Remote.Stop(req.Name)
 		return ctrl.Result{}, nil
 	}
+	orig := cu.DeepCopy()
 
+	paused, reason, err := r.Pause.IsPaused(ctx, cu)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	pause.Track("Cluster", req.NamespacedName, paused)
+	transition := pause.SetCondition(&cu.Status.Conditions, paused, reason, cu.Generation)
+	switch transition {
+	case pause.Paused:
+		r.Recorder.Event(cu, corev1.EventTypeNormal, pause.ConditionType, "reconciliation paused: "+reason)
+	case pause.Resumed:
+		r.Recorder.Event(cu, corev1.EventTypeNormal, pause.ReasonResumed, "reconciliation resumed")
+	}
+	if paused {
+		logger.Info("reconciliation paused", "reason", reason)
+		if transition != pause.Unchanged {
+			return ctrl.Result{}, r.Client.Status().Update(ctx, cu)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if cu.DeletionTimestamp != nil {
+		return r.drain(ctx, cu)
+	}
+	if err := r.addFinalizer(ctx, cu); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.stampTaints(ctx, cu); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var result ctrl.Result
+	var remote *rest.Config
+	pull := isPull(cu)
+	if pull {
+		// 拉模式下hub不访问成员集群，凭证、监听和清单都由agent负责
+		cu.Status.Credentials = nil
+		meta.RemoveStatusCondition(&cu.Status.Conditions, commonscopeclusterv1beta1.ClusterConditionCredentialsValid)
+	} else if cu.Spec.Credentials != nil {
+		cfg, recheck, err := r.reconcileCredentials(ctx, cu)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		remote, result.RequeueAfter = cfg, recheck
+	} else {
+		cu.Status.Credentials = nil
+		meta.RemoveStatusCondition(&cu.Status.Conditions, commonscopeclusterv1beta1.ClusterConditionCredentialsValid)
+	}
+	switch {
+	case r.Remote != nil && !pull:
+		r.watchRemote(cu, remote)
+	case r.Remote != nil:
+		r.Remote.Stop(cu.Name)
+		fallthrough
+	default:
+		cu.Status.Watch = nil
+	}
+	if r.Health != nil {
+		result.RequeueAfter = sooner(result.RequeueAfter, r.Health.RequeueAfter())
+		r.updateHealth(ctx, cu)
+	}
+	if r.Inventory != nil && !pull {
+		result.RequeueAfter = sooner(result.RequeueAfter, r.collectInventory(ctx, cu, remote))
+	} else {
+		cu.Status.Inventory = nil
+	}
+	result.RequeueAfter = sooner(result.RequeueAfter, r.join(ctx, cu, remote))
+
+	// 状态未变化时不再写回，避免周期性requeue时产生多余的事件和更新
+	if equality.Semantic.DeepEqual(orig.Status, cu.Status) {
+		return result, nil
+	}
 	r.Recorder.Event(cu, corev1.EventTypeNormal, "UpdateCluster", fmt.Sprintf("update cluster status %s", time.Now().Format("2006-01-02T15:04:05.000Z")))
 	cu.Status.Cluster = rand.String(5)
-	err := r.Client.Status().Update(ctx, cu)
-	if err != nil {
-		// log
+	if err := r.Client.Status().Update(ctx, cu); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return result, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	// 创建联合索引，这样可以通过索引获取符合该索引条件的pod列表
 	ctx := context.Background()
-	err := mgr.GetFieldIndexer().IndexField(ctx, &corev1.Pod{}, "combinedIndex", func(object client.Object) []string {
-		//combinedIndex := fmt.Sprintf("%s-%s", object.(*corev1.Pod).Spec.SchedulerName, object.(*corev1.Pod).Spec.NodeName)
-		combinedIndex := fmt.Sprintf("%s-%s", object.(*corev1.Pod).Spec.SchedulerName, "")
-		return []string{combinedIndex}
-	})
+	err := mgr.GetFieldIndexer().IndexField(ctx, &corev1.Pod{}, combinedIndexField, combinedIndex)
 
 	if err != nil {
 		return err
 	}
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &commonscopeclusterv1beta1.Cluster{}, secretRefIndexField, secretRefs); err != nil {
+		return err
+	}
 
 	pred := predicate.Funcs{
 		CreateFunc: func(event.CreateEvent) bool { return true },
@@ -104,14 +230,54 @@ func (r *ClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		GenericFunc: func(event.GenericEvent) bool { return true },
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		WithEventFilter(pred).
 		For(&commonscopeclusterv1beta1.Cluster{}, nodePredicateFn).
 		WithOptions(controller.Options{
 			RateLimiter: workqueue.NewItemFastSlowRateLimiter(10*time.Second, 60*time.Second, 5),
 		}).
 		Watches(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestForObject{}, podPredicateFn()).
-		Complete(r)
+		Watches(&source.Kind{Type: &studyv1beta1.World{}}, handler.EnqueueRequestsFromMapFunc(r.requestsForWorld))
+	if r.Secrets != nil {
+		b = b.Watches(source.NewKindWithCache(&corev1.Secret{}, r.Secrets), handler.EnqueueRequestsFromMapFunc(r.requestsForSecret))
+	}
+	if src := r.Pause.Source(); src != nil {
+		b = b.Watches(src, handler.EnqueueRequestsFromMapFunc(r.requestsForPauseConfigMap))
+	}
+	if r.Remote != nil {
+		b = b.Watches(r.Remote.Source(), &handler.EnqueueRequestForObject{})
+	}
+	c, err := b.Build(logging.NewReconciler("cluster", r.LogLevels, tracing.NewReconciler("cluster", r)))
+	if err != nil {
+		return err
+	}
+	return r.Queues.Track("cluster", c)
+}
+
+// combinedIndexField indexes Pods by scheduler and node name.
+const combinedIndexField = "combinedIndex"
+
+func combinedIndex(object client.Object) []string {
+	//combinedIndex := fmt.Sprintf("%s-%s", object.(*corev1.Pod).Spec.SchedulerName, object.(*corev1.Pod).Spec.NodeName)
+	combinedIndex := fmt.Sprintf("%s-%s", object.(*corev1.Pod).Spec.SchedulerName, "")
+	return []string{combinedIndex}
+}
+
+// requestsForPauseConfigMap enqueues every Cluster when the global pause
+// ConfigMap changes, so pausing and resuming take effect immediately.
+func (r *ClusterReconciler) requestsForPauseConfigMap(obj client.Object) []reconcile.Request {
+	if !r.Pause.IsConfigMap(obj) {
+		return nil
+	}
+	cus := new(commonscopeclusterv1beta1.ClusterList)
+	if err := r.Client.List(context.Background(), cus); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(cus.Items))
+	for _, cu := range cus.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: cu.Name}})
+	}
+	return requests
 }
 
 var nodePredicateFn = builder.WithPredicates(
@@ -136,7 +302,12 @@ var nodePredicateFn = builder.WithPredicates(
 			}
 			return false
 		},
-		UpdateFunc:  func(event.UpdateEvent) bool { return false },
+		// 只关心暂停注解、spec的变化和删除
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.ObjectOld.GetAnnotations()[pause.Annotation] != e.ObjectNew.GetAnnotations()[pause.Annotation] ||
+				e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() ||
+				(e.ObjectOld.GetDeletionTimestamp() == nil) != (e.ObjectNew.GetDeletionTimestamp() == nil)
+		},
 		GenericFunc: func(event.GenericEvent) bool { return false },
 	})
 
@@ -177,7 +348,7 @@ func (r *ClusterReconciler) getPod(ctx context.Context) error {
 		return err
 	}
 	for _, p := range podList.Items {
-		fmt.Println("%s/%s", p.Namespace, p.Name)
+		fmt.Printf("%s/%s\n", p.Namespace, p.Name)
 	}
 	return nil
 }
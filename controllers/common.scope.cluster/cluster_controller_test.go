@@ -0,0 +1,77 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commonscopecluster
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+var _ = Describe("Cluster controller", func() {
+	const (
+		timeout  = 10 * time.Second
+		interval = 250 * time.Millisecond
+	)
+
+	getCluster := func(key client.ObjectKey) func() (string, error) {
+		return func() (string, error) {
+			cu := new(commonscopeclusterv1beta1.Cluster)
+			err := k8sClient.Get(ctx, key, cu)
+			return cu.Status.Cluster, err
+		}
+	}
+
+	It("updates the status of Clusters with a cluster name", func() {
+		cu := &commonscopeclusterv1beta1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "alpha"},
+			Spec:       commonscopeclusterv1beta1.ClusterSpec{ClusterName: "alpha"},
+		}
+		Expect(k8sClient.Create(ctx, cu)).To(Succeed())
+		defer func() { Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, cu))).To(Succeed()) }()
+
+		Eventually(getCluster(client.ObjectKeyFromObject(cu)), timeout, interval).ShouldNot(BeEmpty())
+
+		Eventually(func() ([]string, error) {
+			events, err := testutil.EventsFor(ctx, k8sClient, cu)
+			reasons := make([]string, 0, len(events))
+			for _, e := range events {
+				if e.Type == corev1.EventTypeNormal {
+					reasons = append(reasons, e.Reason)
+				}
+			}
+			return reasons, err
+		}, timeout, interval).Should(ContainElement("UpdateCluster"))
+	})
+
+	It("ignores Clusters without a cluster name", func() {
+		cu := &commonscopeclusterv1beta1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "beta"},
+		}
+		Expect(k8sClient.Create(ctx, cu)).To(Succeed())
+		defer func() { Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, cu))).To(Succeed()) }()
+
+		Consistently(getCluster(client.ObjectKeyFromObject(cu)), 2*time.Second, interval).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,247 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commonscopecluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/credentials"
+)
+
+const (
+	// ReasonCredentialsValid is the reason of credentials that work.
+	ReasonCredentialsValid = "Valid"
+	// ReasonCredentialsExpiring is the reason and event of credentials that
+	// expire within the warning period.
+	ReasonCredentialsExpiring = "CredentialsExpiring"
+	// ReasonCredentialsExpired is the reason and event of expired credentials.
+	ReasonCredentialsExpired = "CredentialsExpired"
+	// ReasonSecretNotFound is the reason of credentials whose Secret is
+	// missing.
+	ReasonSecretNotFound = "SecretNotFound"
+	// ReasonSecretForbidden is the reason of credentials whose Secret is
+	// outside the credentials namespace.
+	ReasonSecretForbidden = "SecretForbidden"
+	// ReasonCredentialsInvalid is the reason of a kubeconfig that cannot be
+	// parsed.
+	ReasonCredentialsInvalid = "InvalidCredentials"
+	// ReasonCredentialsRotated is the event of a completed rotation.
+	ReasonCredentialsRotated = "CredentialsRotated"
+	// ReasonRotationFailed is the event of new credentials that did not work.
+	ReasonRotationFailed = "RotationFailed"
+
+	// secretRefIndexField indexes Clusters by the Secrets they reference.
+	secretRefIndexField = "spec.credentials.secretRefs"
+)
+
+//+kubebuilder:rbac:groups="",namespace=system,resources=secrets,verbs=get;list;watch
+
+// reconcileCredentials rotates the credentials of cu when asked to, records
+// when they expire and returns the config to reach the Cluster with, nil
+// when the credentials are unusable, and when they should be checked again.
+func (r *ClusterReconciler) reconcileCredentials(ctx context.Context, cu *commonscopeclusterv1beta1.Cluster) (*rest.Config, time.Duration, error) {
+	if cu.Status.Credentials == nil {
+		cu.Status.Credentials = &commonscopeclusterv1beta1.ClusterCredentialsStatus{}
+	}
+	if cu.Spec.Credentials.RotateTo != nil {
+		if err := r.rotateCredentials(ctx, cu); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	info, reason, err := r.loadCredentials(ctx, cu.Spec.Credentials.SecretRef)
+	if err != nil {
+		return nil, 0, err
+	}
+	if reason != "" {
+		cu.Status.Credentials.Type, cu.Status.Credentials.ExpirationTime = "", nil
+		r.setCredentialsCondition(cu, metav1.ConditionFalse, reason, info.message)
+		return nil, 0, nil
+	}
+
+	cu.Status.Credentials.Type = string(info.Type)
+	cu.Status.Credentials.ExpirationTime = nil
+	if info.Expiry == nil {
+		r.setCredentialsCondition(cu, metav1.ConditionTrue, ReasonCredentialsValid, "credentials do not expire or their expiry is unknown")
+		return info.Config, 0, nil
+	}
+	expiry := metav1.NewTime(*info.Expiry)
+	cu.Status.Credentials.ExpirationTime = &expiry
+
+	now := time.Now()
+	warnAt := info.Expiry.Add(-r.expiryWarning())
+	switch {
+	case !now.Before(*info.Expiry):
+		r.setCredentialsCondition(cu, metav1.ConditionFalse, ReasonCredentialsExpired,
+			fmt.Sprintf("%s credentials expired at %s", info.Type, expiry.UTC().Format(time.RFC3339)))
+		return nil, 0, nil
+	case !now.Before(warnAt):
+		r.setCredentialsCondition(cu, metav1.ConditionTrue, ReasonCredentialsExpiring,
+			fmt.Sprintf("%s credentials expire at %s, rotate them with spec.credentials.rotateTo", info.Type, expiry.UTC().Format(time.RFC3339)))
+		return info.Config, info.Expiry.Sub(now), nil
+	default:
+		r.setCredentialsCondition(cu, metav1.ConditionTrue, ReasonCredentialsValid,
+			fmt.Sprintf("%s credentials expire at %s", info.Type, expiry.UTC().Format(time.RFC3339)))
+		return info.Config, warnAt.Sub(now), nil
+	}
+}
+
+// rotateCredentials switches cu to the credentials of spec.credentials.rotateTo
+// once they were checked against the Cluster. Credentials that do not work
+// are left in rotateTo and the current ones stay in use.
+func (r *ClusterReconciler) rotateCredentials(ctx context.Context, cu *commonscopeclusterv1beta1.Cluster) error {
+	next := *cu.Spec.Credentials.RotateTo
+	info, reason, err := r.loadCredentials(ctx, next)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		if info.Expiry != nil && !time.Now().Before(*info.Expiry) {
+			info.message = fmt.Sprintf("credentials expired at %s", info.Expiry.UTC().Format(time.RFC3339))
+		} else if err := r.verifier()(ctx, info.Config); err != nil {
+			info.message = fmt.Sprintf("credentials do not work: %v", err)
+		} else {
+			info.message = ""
+		}
+	}
+	if info.message != "" {
+		msg := fmt.Sprintf("not rotating to secret %s/%s: %s", next.Namespace, next.Name, info.message)
+		if cu.Status.Credentials.RotationError != msg {
+			r.Recorder.Event(cu, corev1.EventTypeWarning, ReasonRotationFailed, msg)
+		}
+		cu.Status.Credentials.RotationError = msg
+		return nil
+	}
+
+	orig := cu.DeepCopy()
+	cu.Spec.Credentials.SecretRef = next
+	cu.Spec.Credentials.RotateTo = nil
+	if err := r.Client.Patch(ctx, cu, client.MergeFrom(orig)); err != nil {
+		return err
+	}
+	// Patch 会用服务端返回的对象覆盖status，保留本次调谐的修改
+	cu.Status = orig.Status
+	now := metav1.Now()
+	cu.Status.Credentials.LastRotationTime = &now
+	cu.Status.Credentials.RotationError = ""
+	r.Recorder.Event(cu, corev1.EventTypeNormal, ReasonCredentialsRotated,
+		fmt.Sprintf("rotated to the credentials of secret %s/%s", next.Namespace, next.Name))
+	log.FromContext(ctx).Info("credentials rotated", "secret", next.Namespace+"/"+next.Name)
+	return nil
+}
+
+// loadedCredentials are parsed credentials, or why they could not be.
+type loadedCredentials struct {
+	*credentials.Info
+	message string
+}
+
+// loadCredentials reads and parses the Secret ref. A missing or forbidden
+// Secret or a bad kubeconfig returns the condition reason; only API errors
+// are returned as errors.
+func (r *ClusterReconciler) loadCredentials(ctx context.Context, ref corev1.SecretReference) (loadedCredentials, string, error) {
+	if r.CredentialsNamespace != "" && ref.Namespace != r.CredentialsNamespace {
+		return loadedCredentials{message: fmt.Sprintf("secret %s/%s is not in the credentials namespace %s", ref.Namespace, ref.Name, r.CredentialsNamespace)}, ReasonSecretForbidden, nil
+	}
+	var reader client.Reader = r.Client
+	if r.Secrets != nil {
+		reader = r.Secrets
+	}
+	secret := new(corev1.Secret)
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+		if apierrs.IsNotFound(err) {
+			return loadedCredentials{message: fmt.Sprintf("secret %s/%s does not exist", ref.Namespace, ref.Name)}, ReasonSecretNotFound, nil
+		}
+		return loadedCredentials{}, "", err
+	}
+	info, err := credentials.FromSecret(secret)
+	if err != nil {
+		return loadedCredentials{message: err.Error()}, ReasonCredentialsInvalid, nil
+	}
+	return loadedCredentials{Info: info}, "", nil
+}
+
+// setCredentialsCondition sets the CredentialsValid condition and warns when
+// the credentials start expiring or stop working.
+func (r *ClusterReconciler) setCredentialsCondition(cu *commonscopeclusterv1beta1.Cluster, status metav1.ConditionStatus, reason, message string) {
+	current := meta.FindStatusCondition(cu.Status.Conditions, commonscopeclusterv1beta1.ClusterConditionCredentialsValid)
+	if (current == nil || current.Reason != reason) && reason != ReasonCredentialsValid {
+		r.Recorder.Event(cu, corev1.EventTypeWarning, reason, message)
+	}
+	meta.SetStatusCondition(&cu.Status.Conditions, metav1.Condition{
+		Type:               commonscopeclusterv1beta1.ClusterConditionCredentialsValid,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: cu.Generation,
+	})
+}
+
+func (r *ClusterReconciler) expiryWarning() time.Duration {
+	if r.ExpiryWarning > 0 {
+		return r.ExpiryWarning
+	}
+	return credentials.DefaultExpiryWarning
+}
+
+func (r *ClusterReconciler) verifier() credentials.Verifier {
+	if r.VerifyCredentials != nil {
+		return r.VerifyCredentials
+	}
+	return credentials.Verify
+}
+
+// secretRefs indexes a Cluster by the Secrets it references, as
+// namespace/name.
+func secretRefs(object client.Object) []string {
+	cu := object.(*commonscopeclusterv1beta1.Cluster)
+	if cu.Spec.Credentials == nil {
+		return nil
+	}
+	refs := []string{cu.Spec.Credentials.SecretRef.Namespace + "/" + cu.Spec.Credentials.SecretRef.Name}
+	if next := cu.Spec.Credentials.RotateTo; next != nil {
+		refs = append(refs, next.Namespace+"/"+next.Name)
+	}
+	return refs
+}
+
+// requestsForSecret enqueues the Clusters referencing a Secret, so a
+// replaced kubeconfig is picked up right away.
+func (r *ClusterReconciler) requestsForSecret(obj client.Object) []reconcile.Request {
+	cus := new(commonscopeclusterv1beta1.ClusterList)
+	if err := r.Client.List(context.Background(), cus, client.MatchingFields{secretRefIndexField: obj.GetNamespace() + "/" + obj.GetName()}); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(cus.Items))
+	for _, cu := range cus.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: cu.Name}})
+	}
+	return requests
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commonscopecluster
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/credentials"
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+// tokenSecret returns a Secret holding a kubeconfig whose token expires at exp.
+func tokenSecret(name string, exp time.Time) *corev1.Secret {
+	enc := base64.RawURLEncoding.EncodeToString
+	token := enc([]byte(`{"alg":"RS256"}`)) + "." + enc([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix()))) + ".c2ln"
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+- name: alpha
+  cluster:
+    server: https://alpha.example
+users:
+- name: admin
+  user:
+    token: ` + token + `
+contexts:
+- name: alpha
+  context:
+    cluster: alpha
+    user: admin
+current-context: alpha
+`
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "clusters"},
+		Data:       map[string][]byte{credentials.KubeconfigKey: []byte(kubeconfig)},
+	}
+}
+
+func TestClusterCredentials(t *testing.T) {
+	now := time.Now()
+	ref := func(name string) corev1.SecretReference {
+		return corev1.SecretReference{Namespace: "clusters", Name: name}
+	}
+	cluster := func(rotateTo string) *commonscopeclusterv1beta1.Cluster {
+		cu := &commonscopeclusterv1beta1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "alpha"},
+			Spec: commonscopeclusterv1beta1.ClusterSpec{
+				ClusterName: "alpha",
+				Credentials: &commonscopeclusterv1beta1.ClusterCredentials{SecretRef: ref("current")},
+			},
+		}
+		if rotateTo != "" {
+			next := ref(rotateTo)
+			cu.Spec.Credentials.RotateTo = &next
+		}
+		return cu
+	}
+	errRefused := errors.New("connection refused")
+
+	tests := []struct {
+		name       string
+		objs       []client.Object
+		namespace  string
+		verifyErr  error
+		wantReason string
+		wantSecret string
+		wantRotate bool
+		wantError  bool
+		reasons    []string
+	}{
+		{
+			name:       "valid",
+			objs:       []client.Object{cluster(""), tokenSecret("current", now.Add(30*24*time.Hour))},
+			wantReason: ReasonCredentialsValid,
+			wantSecret: "current",
+			reasons:    []string{"UpdateCluster"},
+		},
+		{
+			name:       "expiring",
+			objs:       []client.Object{cluster(""), tokenSecret("current", now.Add(24*time.Hour))},
+			wantReason: ReasonCredentialsExpiring,
+			wantSecret: "current",
+			reasons:    []string{ReasonCredentialsExpiring, "UpdateCluster"},
+		},
+		{
+			name:       "expired",
+			objs:       []client.Object{cluster(""), tokenSecret("current", now.Add(-time.Hour))},
+			wantReason: ReasonCredentialsExpired,
+			wantSecret: "current",
+			reasons:    []string{ReasonCredentialsExpired, "UpdateCluster"},
+		},
+		{
+			name:       "secret not found",
+			objs:       []client.Object{cluster("")},
+			wantReason: ReasonSecretNotFound,
+			wantSecret: "current",
+			reasons:    []string{ReasonSecretNotFound, "UpdateCluster"},
+		},
+		{
+			name:       "secret outside the credentials namespace",
+			objs:       []client.Object{cluster(""), tokenSecret("current", now.Add(30*24*time.Hour))},
+			namespace:  "kube-develop-tools-system",
+			wantReason: ReasonSecretForbidden,
+			wantSecret: "current",
+			reasons:    []string{ReasonSecretForbidden, "UpdateCluster"},
+		},
+		{
+			name: "rotates to working credentials",
+			objs: []client.Object{cluster("next"),
+				tokenSecret("current", now.Add(time.Hour)), tokenSecret("next", now.Add(90*24*time.Hour))},
+			namespace:  "clusters",
+			wantReason: ReasonCredentialsValid,
+			wantSecret: "next",
+			wantRotate: true,
+			reasons:    []string{ReasonCredentialsRotated, "UpdateCluster"},
+		},
+		{
+			name: "keeps the current credentials when the new ones fail",
+			objs: []client.Object{cluster("next"),
+				tokenSecret("current", now.Add(30*24*time.Hour)), tokenSecret("next", now.Add(90*24*time.Hour))},
+			verifyErr:  errRefused,
+			wantReason: ReasonCredentialsValid,
+			wantSecret: "current",
+			wantError:  true,
+			reasons:    []string{ReasonRotationFailed, "UpdateCluster"},
+		},
+		{
+			name: "does not rotate to expired credentials",
+			objs: []client.Object{cluster("next"),
+				tokenSecret("current", now.Add(30*24*time.Hour)), tokenSecret("next", now.Add(-time.Hour))},
+			wantReason: ReasonCredentialsValid,
+			wantSecret: "current",
+			wantError:  true,
+			reasons:    []string{ReasonRotationFailed, "UpdateCluster"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testutil.NewFakeClient(nil, tt.objs...)
+			recorder := testutil.NewEventRecorder()
+			r := &ClusterReconciler{
+				Client:   c,
+				Scheme:   c.Scheme(),
+				Recorder: recorder,
+
+				CredentialsNamespace: tt.namespace,
+				VerifyCredentials: func(_ context.Context, cfg *rest.Config) error {
+					if cfg.Host != "https://alpha.example" {
+						t.Errorf("verified host = %s", cfg.Host)
+					}
+					return tt.verifyErr
+				},
+			}
+
+			key := client.ObjectKey{Name: "alpha"}
+			res, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if reasons := recorder.Reasons(); !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("event reasons = %v, want %v", reasons, tt.reasons)
+			}
+
+			cu := new(commonscopeclusterv1beta1.Cluster)
+			if err := c.Get(context.Background(), key, cu); err != nil {
+				t.Fatal(err)
+			}
+			cond := meta.FindStatusCondition(cu.Status.Conditions, commonscopeclusterv1beta1.ClusterConditionCredentialsValid)
+			if cond == nil || cond.Reason != tt.wantReason {
+				t.Errorf("CredentialsValid = %v, want reason %s", cond, tt.wantReason)
+			}
+			if got := cu.Spec.Credentials.SecretRef.Name; got != tt.wantSecret {
+				t.Errorf("secretRef = %s, want %s", got, tt.wantSecret)
+			}
+			if tt.wantError != (cu.Spec.Credentials.RotateTo != nil) {
+				t.Errorf("rotateTo = %v, want it kept only when rotation failed", cu.Spec.Credentials.RotateTo)
+			}
+			status := cu.Status.Credentials
+			if tt.wantRotate != (status.LastRotationTime != nil) || tt.wantError != (status.RotationError != "") {
+				t.Errorf("credentials status = %+v", status)
+			}
+			if tt.wantReason == ReasonCredentialsValid && (res.RequeueAfter <= 0 || status.ExpirationTime == nil) {
+				t.Errorf("requeue after = %s, expiration = %v, want a recheck before expiry", res.RequeueAfter, status.ExpirationTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commonscopecluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/metrics"
+)
+
+const (
+	// ReasonHealthy is the reason of the Schedulable condition of a Cluster
+	// that was never cordoned.
+	ReasonHealthy = "Healthy"
+	// ReasonCordoned is the reason of the Schedulable condition and the event
+	// of a Cluster cordoned because of its health score.
+	ReasonCordoned = "Cordoned"
+	// ReasonUncordoned is the reason of a Cluster whose score recovered.
+	ReasonUncordoned = "Uncordoned"
+)
+
+// updateHealth probes cu, records its health and cordons or uncordons it.
+func (r *ClusterReconciler) updateHealth(ctx context.Context, cu *commonscopeclusterv1beta1.Cluster) {
+	var h *commonscopeclusterv1beta1.ClusterHealth
+	if isPull(cu) {
+		// 拉模式的集群由agent上报心跳，控制器不直接访问它
+		s, err := agentSample(cu, time.Now())
+		s.Err = err
+		h = r.Health.Observe(cu.Name, s)
+	} else {
+		h = r.Health.Probe(ctx, cu)
+	}
+	cu.Status.Health = h
+	if h == nil {
+		// 无法判断健康状况时保持原有的Schedulable条件
+		return
+	}
+
+	current := meta.FindStatusCondition(cu.Status.Conditions, commonscopeclusterv1beta1.ClusterConditionSchedulable)
+	schedulable := current == nil || current.Status == metav1.ConditionTrue
+	next := r.Health.Schedulable(schedulable, h)
+	if next {
+		metrics.ClusterCordoned.WithLabelValues(cu.Name).Set(0)
+	} else {
+		metrics.ClusterCordoned.WithLabelValues(cu.Name).Set(1)
+	}
+	if current != nil && next == schedulable {
+		return
+	}
+
+	cond := metav1.Condition{
+		Type:               commonscopeclusterv1beta1.ClusterConditionSchedulable,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonHealthy,
+		Message:            fmt.Sprintf("health score is %d", h.Score),
+		ObservedGeneration: cu.Generation,
+	}
+	switch {
+	case !next:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = ReasonCordoned
+		cond.Message = fmt.Sprintf("health score %d fell below %d, new Worlds are not bound to the Cluster", h.Score, r.Health.CordonBelow)
+		r.Recorder.Event(cu, corev1.EventTypeWarning, ReasonCordoned, cond.Message)
+		log.FromContext(ctx).Info("cluster cordoned", "score", h.Score)
+	case current != nil:
+		cond.Reason = ReasonUncordoned
+		cond.Message = fmt.Sprintf("health score recovered to %d", h.Score)
+		r.Recorder.Event(cu, corev1.EventTypeNormal, ReasonUncordoned, cond.Message)
+		log.FromContext(ctx).Info("cluster uncordoned", "score", h.Score)
+	}
+	meta.SetStatusCondition(&cu.Status.Conditions, cond)
+}
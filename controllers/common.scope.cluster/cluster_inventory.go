@@ -0,0 +1,66 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commonscopecluster
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/inventory"
+)
+
+// ReasonInventoryFailed is the event of an inventory that could not be
+// collected.
+const ReasonInventoryFailed = "InventoryFailed"
+
+// collectInventory refreshes the inventory of cu when it is due and returns
+// when it is due next. Clusters with credentials are collected with them,
+// remote being nil when they are unusable; the others from the local cluster.
+func (r *ClusterReconciler) collectInventory(ctx context.Context, cu *commonscopeclusterv1beta1.Cluster, remote *rest.Config) time.Duration {
+	if remaining := r.Inventory.Due(cu.Status.Inventory); remaining > 0 {
+		return remaining
+	}
+
+	prev := cu.Status.Inventory
+	if cu.Spec.Credentials != nil && remote == nil {
+		cu.Status.Inventory = inventory.Failed(prev, metav1.Now(), "the credentials of the Cluster are not usable")
+	} else {
+		cu.Status.Inventory = r.Inventory.Collect(ctx, remote, prev)
+	}
+
+	if msg := cu.Status.Inventory.LastCollectionError; msg != "" {
+		if prev == nil || prev.LastCollectionError != msg {
+			r.Recorder.Event(cu, corev1.EventTypeWarning, ReasonInventoryFailed, msg)
+		}
+		log.FromContext(ctx).Info("inventory collection failed", "error", msg)
+	}
+	return r.Inventory.Due(cu.Status.Inventory)
+}
+
+// sooner returns the shorter of two requeue delays, zero meaning none.
+func sooner(a, b time.Duration) time.Duration {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commonscopecluster
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/inventory"
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+// recordingSource records the hosts it reads from.
+type recordingSource struct {
+	hosts []string
+}
+
+func (s *recordingSource) Read(_ context.Context, cfg *rest.Config) (*inventory.Snapshot, error) {
+	s.hosts = append(s.hosts, cfg.Host)
+	return &inventory.Snapshot{Version: "v1.22.1", Namespaces: []string{"default"}}, nil
+}
+
+func TestClusterInventory(t *testing.T) {
+	cluster := func(secret string, inv *commonscopeclusterv1beta1.ClusterInventory) *commonscopeclusterv1beta1.Cluster {
+		cu := &commonscopeclusterv1beta1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "alpha"},
+			Spec:       commonscopeclusterv1beta1.ClusterSpec{ClusterName: "alpha"},
+			Status:     commonscopeclusterv1beta1.ClusterStatus{Inventory: inv},
+		}
+		if secret != "" {
+			cu.Spec.Credentials = &commonscopeclusterv1beta1.ClusterCredentials{
+				SecretRef: corev1.SecretReference{Namespace: "clusters", Name: secret},
+			}
+		}
+		return cu
+	}
+	recent := &commonscopeclusterv1beta1.ClusterInventory{KubernetesVersion: "v1.21.0", LastCollectionTime: metav1.Now()}
+
+	tests := []struct {
+		name        string
+		objs        []client.Object
+		wantHosts   []string
+		wantVersion string
+		wantError   bool
+		reasons     []string
+	}{
+		{
+			name:        "collects the local cluster",
+			objs:        []client.Object{cluster("", nil)},
+			wantHosts:   []string{"https://local.example"},
+			wantVersion: "v1.22.1",
+			reasons:     []string{"UpdateCluster"},
+		},
+		{
+			name:        "collects with the credentials",
+			objs:        []client.Object{cluster("current", nil), tokenSecret("current", time.Now().Add(30*24*time.Hour))},
+			wantHosts:   []string{"https://alpha.example"},
+			wantVersion: "v1.22.1",
+			reasons:     []string{"UpdateCluster"},
+		},
+		{
+			name:      "unusable credentials",
+			objs:      []client.Object{cluster("current", nil)},
+			wantError: true,
+			reasons:   []string{ReasonSecretNotFound, ReasonInventoryFailed, "UpdateCluster"},
+		},
+		{
+			name:        "not due",
+			objs:        []client.Object{cluster("", recent)},
+			wantVersion: "v1.21.0",
+			reasons:     []string{"UpdateCluster"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testutil.NewFakeClient(nil, tt.objs...)
+			recorder := testutil.NewEventRecorder()
+			source := &recordingSource{}
+			r := &ClusterReconciler{
+				Client:    c,
+				Scheme:    c.Scheme(),
+				Recorder:  recorder,
+				Inventory: inventory.NewCollector(source, &rest.Config{Host: "https://local.example"}),
+
+				VerifyCredentials: func(context.Context, *rest.Config) error { return nil },
+			}
+
+			key := client.ObjectKey{Name: "alpha"}
+			res, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if res.RequeueAfter <= 0 || res.RequeueAfter > inventory.DefaultInterval {
+				t.Errorf("requeue after = %s, want the next collection", res.RequeueAfter)
+			}
+			if !reflect.DeepEqual(source.hosts, tt.wantHosts) {
+				t.Errorf("read from %v, want %v", source.hosts, tt.wantHosts)
+			}
+			if reasons := recorder.Reasons(); !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("event reasons = %v, want %v", reasons, tt.reasons)
+			}
+
+			cu := new(commonscopeclusterv1beta1.Cluster)
+			if err := c.Get(context.Background(), key, cu); err != nil {
+				t.Fatal(err)
+			}
+			inv := cu.Status.Inventory
+			if inv == nil || inv.KubernetesVersion != tt.wantVersion || tt.wantError != (inv.LastCollectionError != "") {
+				t.Errorf("inventory = %+v", inv)
+			}
+		})
+	}
+}
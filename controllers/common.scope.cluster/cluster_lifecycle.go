@@ -0,0 +1,360 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commonscopecluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+const (
+	// ReasonJoined is the reason and event of a Cluster that joined.
+	ReasonJoined = "Joined"
+	// ReasonCredentialsUnusable is the reason of a Cluster that cannot join
+	// because its credentials are missing, invalid or expired.
+	ReasonCredentialsUnusable = "CredentialsUnusable"
+	// ReasonUnreachable is the reason of a Cluster whose API server did not
+	// accept the credentials.
+	ReasonUnreachable = "Unreachable"
+	// ReasonAgentInstallFailed is the reason of a Cluster the agent RBAC
+	// could not be installed in.
+	ReasonAgentInstallFailed = "AgentInstallFailed"
+	// ReasonJoinTimeout is the reason and event of a Cluster that did not
+	// join within its join timeout.
+	ReasonJoinTimeout = "JoinTimeout"
+	// ReasonDraining is the reason and event of a deleted Cluster whose
+	// Worlds are moved off.
+	ReasonDraining = "Draining"
+	// ReasonDrained is the reason of a Cluster no World runs on anymore.
+	ReasonDrained = "Drained"
+	// ReasonDrainTimeout is the reason and event of a Cluster that was not
+	// drained within its drain timeout.
+	ReasonDrainTimeout = "DrainTimeout"
+	// ReasonWorldRemoved is the event of a World deleted by the Remove
+	// drain policy.
+	ReasonWorldRemoved = "WorldRemoved"
+	// ReasonRemoved is the event of a drained Cluster whose finalizer was
+	// removed.
+	ReasonRemoved = "Removed"
+
+	// DefaultJoinTimeout is how long a Cluster may take to join.
+	DefaultJoinTimeout = 10 * time.Minute
+	// DefaultDrainTimeout is how long the Worlds may take to leave a
+	// deleted Cluster.
+	DefaultDrainTimeout = 30 * time.Minute
+
+	// lifecycleRetryInterval is how often joining and draining are checked
+	// again.
+	lifecycleRetryInterval = 10 * time.Second
+)
+
+//+kubebuilder:rbac:groups=study.example.cn,resources=worlds,verbs=get;list;watch;delete
+
+// addFinalizer adds the Cluster finalizer to cu, keeping the status of this
+// reconcile.
+func (r *ClusterReconciler) addFinalizer(ctx context.Context, cu *commonscopeclusterv1beta1.Cluster) error {
+	if controllerutil.ContainsFinalizer(cu, commonscopeclusterv1beta1.ClusterFinalizer) {
+		return nil
+	}
+	orig := cu.DeepCopy()
+	controllerutil.AddFinalizer(cu, commonscopeclusterv1beta1.ClusterFinalizer)
+	if err := r.Client.Patch(ctx, cu, client.MergeFromWithOptions(orig, client.MergeFromWithOptimisticLock{})); err != nil {
+		return err
+	}
+	cu.Status = orig.Status
+	return nil
+}
+
+// join moves a new Cluster to Ready once its credentials were verified
+// against it and the agent RBAC was installed, and returns when to try
+// again otherwise. Clusters without credentials are the local cluster and
+// join right away.
+func (r *ClusterReconciler) join(ctx context.Context, cu *commonscopeclusterv1beta1.Cluster, remote *rest.Config) time.Duration {
+	if cu.Status.Phase == commonscopeclusterv1beta1.ClusterReady {
+		return 0
+	}
+	cu.Status.Phase = commonscopeclusterv1beta1.ClusterJoining
+
+	reason, message := r.joinCluster(ctx, cu, remote)
+	if reason == "" {
+		cu.Status.Phase = commonscopeclusterv1beta1.ClusterReady
+		setCondition(cu, commonscopeclusterv1beta1.ClusterConditionJoined, metav1.ConditionTrue, ReasonJoined, message)
+		log.FromContext(ctx).Info("cluster joined")
+		return 0
+	}
+
+	// Joined条件在第一次失败时置为False，之后原因变化不会改变lastTransitionTime
+	current := meta.FindStatusCondition(cu.Status.Conditions, commonscopeclusterv1beta1.ClusterConditionJoined)
+	timeout := r.joinTimeout(cu)
+	if current != nil && current.Status == metav1.ConditionFalse && time.Since(current.LastTransitionTime.Time) >= timeout {
+		message = fmt.Sprintf("not joined within %s: %s", timeout, message)
+		if current.Reason != ReasonJoinTimeout {
+			r.Recorder.Event(cu, corev1.EventTypeWarning, ReasonJoinTimeout, message)
+		}
+		reason = ReasonJoinTimeout
+	}
+	setCondition(cu, commonscopeclusterv1beta1.ClusterConditionJoined, metav1.ConditionFalse, reason, message)
+	return lifecycleRetryInterval
+}
+
+// joinCluster runs the join steps and returns why one failed, an empty
+// reason once all passed.
+func (r *ClusterReconciler) joinCluster(ctx context.Context, cu *commonscopeclusterv1beta1.Cluster, remote *rest.Config) (string, string) {
+	if isPull(cu) {
+		if _, err := agentSample(cu, time.Now()); err != nil {
+			return ReasonAgentNotConnected, err.Error()
+		}
+		return "", "the agent sent a heartbeat"
+	}
+	if cu.Spec.Credentials == nil {
+		return "", "the local cluster joined"
+	}
+	if remote == nil {
+		message := "credentials are unusable"
+		if cond := meta.FindStatusCondition(cu.Status.Conditions, commonscopeclusterv1beta1.ClusterConditionCredentialsValid); cond != nil {
+			message = cond.Message
+		}
+		return ReasonCredentialsUnusable, message
+	}
+	if err := r.verifier()(ctx, remote); err != nil {
+		return ReasonUnreachable, fmt.Sprintf("credentials do not work: %v", err)
+	}
+	if r.Agent == nil {
+		return "", "credentials verified"
+	}
+	if err := r.Agent.Install(ctx, remote); err != nil {
+		return ReasonAgentInstallFailed, fmt.Sprintf("installing the agent RBAC: %v", err)
+	}
+	return "", "credentials verified and agent RBAC installed"
+}
+
+// drain moves the Worlds off a deleted Cluster as its drain policy says and
+// removes its finalizer once none is left, or once the drain timeout ran
+// out under the Force timeout policy.
+func (r *ClusterReconciler) drain(ctx context.Context, cu *commonscopeclusterv1beta1.Cluster) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(cu, commonscopeclusterv1beta1.ClusterFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	logger := log.FromContext(ctx)
+	lifecycle := cu.Spec.Lifecycle
+	if lifecycle == nil {
+		lifecycle = &commonscopeclusterv1beta1.ClusterLifecycle{}
+	}
+	policy := lifecycle.DrainPolicy
+	if policy == "" {
+		policy = commonscopeclusterv1beta1.DrainMigrate
+	}
+
+	if err := r.taintDraining(ctx, cu); err != nil {
+		return ctrl.Result{}, err
+	}
+	worlds, err := r.boundWorlds(ctx, cu.Name)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if cu.Status.Phase != commonscopeclusterv1beta1.ClusterDraining && cu.Status.Phase != commonscopeclusterv1beta1.ClusterRemoved {
+		cu.Status.Phase = commonscopeclusterv1beta1.ClusterDraining
+		r.Recorder.Event(cu, corev1.EventTypeNormal, ReasonDraining,
+			fmt.Sprintf("draining %d Worlds with the %s policy", len(worlds), policy))
+		logger.Info("draining cluster", "worlds", len(worlds), "policy", policy)
+	}
+
+	if policy == commonscopeclusterv1beta1.DrainRemove {
+		for _, key := range worlds {
+			wl := &studyv1beta1.World{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+			if err := r.Client.Delete(ctx, wl); client.IgnoreNotFound(err) != nil {
+				return ctrl.Result{}, err
+			}
+			r.Recorder.Event(cu, corev1.EventTypeNormal, ReasonWorldRemoved, fmt.Sprintf("deleted World %s", key))
+		}
+		worlds = nil
+	}
+
+	if len(worlds) > 0 {
+		timeout := DefaultDrainTimeout
+		if lifecycle.DrainTimeout != nil {
+			timeout = lifecycle.DrainTimeout.Duration
+		}
+		message := fmt.Sprintf("waiting for %d Worlds to leave: %s", len(worlds), worldNames(worlds))
+		if time.Since(cu.DeletionTimestamp.Time) < timeout {
+			setCondition(cu, commonscopeclusterv1beta1.ClusterConditionDrained, metav1.ConditionFalse, ReasonDraining, message)
+			return ctrl.Result{RequeueAfter: lifecycleRetryInterval}, r.Client.Status().Update(ctx, cu)
+		}
+		if lifecycle.TimeoutPolicy != commonscopeclusterv1beta1.TimeoutForce {
+			message = fmt.Sprintf("not drained within %s, %s", timeout, message)
+			if !isConditionReason(cu, commonscopeclusterv1beta1.ClusterConditionDrained, ReasonDrainTimeout) {
+				r.Recorder.Event(cu, corev1.EventTypeWarning, ReasonDrainTimeout, message)
+			}
+			setCondition(cu, commonscopeclusterv1beta1.ClusterConditionDrained, metav1.ConditionFalse, ReasonDrainTimeout, message)
+			return ctrl.Result{RequeueAfter: lifecycleRetryInterval}, r.Client.Status().Update(ctx, cu)
+		}
+		message = fmt.Sprintf("not drained within %s, removing the Cluster with %d Worlds left: %s", timeout, len(worlds), worldNames(worlds))
+		r.Recorder.Event(cu, corev1.EventTypeWarning, ReasonDrainTimeout, message)
+		setCondition(cu, commonscopeclusterv1beta1.ClusterConditionDrained, metav1.ConditionFalse, ReasonDrainTimeout, message)
+	} else {
+		setCondition(cu, commonscopeclusterv1beta1.ClusterConditionDrained, metav1.ConditionTrue, ReasonDrained, "no World runs on the Cluster")
+	}
+
+	r.Remote.Stop(cu.Name)
+	r.uninstallAgent(ctx, cu)
+	cu.Status.Phase = commonscopeclusterv1beta1.ClusterRemoved
+	if err := r.Client.Status().Update(ctx, cu); err != nil {
+		return ctrl.Result{}, err
+	}
+	orig := cu.DeepCopy()
+	controllerutil.RemoveFinalizer(cu, commonscopeclusterv1beta1.ClusterFinalizer)
+	if err := r.Client.Patch(ctx, cu, client.MergeFromWithOptions(orig, client.MergeFromWithOptimisticLock{})); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	r.Health.Forget(cu.Name)
+	r.Recorder.Event(cu, corev1.EventTypeNormal, ReasonRemoved, "cluster drained and removed")
+	logger.Info("cluster removed")
+	return ctrl.Result{}, nil
+}
+
+// taintDraining puts the draining NoExecute taint on cu so the Worlds that
+// do not tolerate it are evicted right away.
+func (r *ClusterReconciler) taintDraining(ctx context.Context, cu *commonscopeclusterv1beta1.Cluster) error {
+	for _, taint := range cu.Spec.Taints {
+		if taint.Key == commonscopeclusterv1beta1.ClusterTaintDraining {
+			return nil
+		}
+	}
+	orig := cu.DeepCopy()
+	cu.Spec.Taints = append(cu.Spec.Taints, corev1.Taint{
+		Key:       commonscopeclusterv1beta1.ClusterTaintDraining,
+		Effect:    corev1.TaintEffectNoExecute,
+		TimeAdded: cu.DeletionTimestamp,
+	})
+	if err := r.Client.Patch(ctx, cu, client.MergeFromWithOptions(orig, client.MergeFromWithOptimisticLock{})); err != nil {
+		return err
+	}
+	cu.Status = orig.Status
+	return nil
+}
+
+// boundWorlds returns the Worlds running on the Cluster name, sorted.
+func (r *ClusterReconciler) boundWorlds(ctx context.Context, name string) ([]types.NamespacedName, error) {
+	wls := new(studyv1beta1.WorldList)
+	if err := r.Client.List(ctx, wls); err != nil {
+		return nil, err
+	}
+	var worlds []types.NamespacedName
+	for i := range wls.Items {
+		if wl := &wls.Items[i]; wl.RunsOn(name) {
+			worlds = append(worlds, client.ObjectKeyFromObject(wl))
+		}
+	}
+	sort.Slice(worlds, func(i, j int) bool { return worlds[i].String() < worlds[j].String() })
+	return worlds, nil
+}
+
+// uninstallAgent removes the agent RBAC from a Cluster that goes away. A
+// Cluster that cannot be reached keeps it; removing the Cluster does not
+// wait for that.
+func (r *ClusterReconciler) uninstallAgent(ctx context.Context, cu *commonscopeclusterv1beta1.Cluster) {
+	if r.Agent == nil || cu.Spec.Credentials == nil || isPull(cu) {
+		return
+	}
+	info, reason, err := r.loadCredentials(ctx, cu.Spec.Credentials.SecretRef)
+	if err == nil && reason == "" {
+		err = r.Agent.Uninstall(ctx, info.Config)
+	} else if err == nil {
+		err = errors.New(info.message)
+	}
+	if err != nil {
+		log.FromContext(ctx).Error(err, "leaving the agent RBAC behind")
+	}
+}
+
+// requestsForWorld enqueues the deleted Clusters a World refers to, so a
+// draining Cluster notices when its last World left.
+func (r *ClusterReconciler) requestsForWorld(obj client.Object) []reconcile.Request {
+	wl, ok := obj.(*studyv1beta1.World)
+	if !ok {
+		return nil
+	}
+	names := append([]string{}, wl.Spec.Clusters...)
+	if wl.Status.Placement != nil {
+		names = append(names, wl.Status.Placement.Clusters...)
+	}
+	for _, eviction := range wl.Status.Evictions {
+		names = append(names, eviction.Cluster)
+	}
+	var requests []reconcile.Request
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		cu := new(commonscopeclusterv1beta1.Cluster)
+		if err := r.Client.Get(context.Background(), types.NamespacedName{Name: name}, cu); err != nil || cu.DeletionTimestamp == nil {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+	}
+	return requests
+}
+
+func worldNames(worlds []types.NamespacedName) string {
+	names := make([]string, 0, len(worlds))
+	for _, key := range worlds {
+		names = append(names, key.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+func (r *ClusterReconciler) joinTimeout(cu *commonscopeclusterv1beta1.Cluster) time.Duration {
+	if l := cu.Spec.Lifecycle; l != nil && l.JoinTimeout != nil {
+		return l.JoinTimeout.Duration
+	}
+	return DefaultJoinTimeout
+}
+
+func setCondition(cu *commonscopeclusterv1beta1.Cluster, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&cu.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: cu.Generation,
+	})
+}
+
+func isConditionReason(cu *commonscopeclusterv1beta1.Cluster, conditionType, reason string) bool {
+	cond := meta.FindStatusCondition(cu.Status.Conditions, conditionType)
+	return cond != nil && cond.Reason == reason
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commonscopecluster
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+// fakeAgent records the Clusters it installed into and removed from.
+type fakeAgent struct {
+	err                    error
+	installed, uninstalled []string
+}
+
+func (a *fakeAgent) Install(_ context.Context, cfg *rest.Config) error {
+	a.installed = append(a.installed, cfg.Host)
+	return a.err
+}
+
+func (a *fakeAgent) Uninstall(_ context.Context, cfg *rest.Config) error {
+	a.uninstalled = append(a.uninstalled, cfg.Host)
+	return a.err
+}
+
+func TestClusterJoin(t *testing.T) {
+	cluster := func(secret string, joined *metav1.Condition) *commonscopeclusterv1beta1.Cluster {
+		cu := &commonscopeclusterv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "alpha"}}
+		if secret != "" {
+			cu.Spec.Credentials = &commonscopeclusterv1beta1.ClusterCredentials{
+				SecretRef: corev1.SecretReference{Namespace: "clusters", Name: secret},
+			}
+		}
+		if joined != nil {
+			cu.Status.Phase = commonscopeclusterv1beta1.ClusterJoining
+			cu.Status.Conditions = []metav1.Condition{*joined}
+		}
+		return cu
+	}
+	valid := tokenSecret("current", time.Now().Add(30*24*time.Hour))
+	longAgo := &metav1.Condition{
+		Type:               commonscopeclusterv1beta1.ClusterConditionJoined,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonUnreachable,
+		LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Hour)),
+	}
+
+	tests := []struct {
+		name          string
+		objs          []client.Object
+		verifyErr     error
+		agentErr      error
+		wantPhase     commonscopeclusterv1beta1.ClusterPhase
+		wantReason    string
+		wantInstalled []string
+		reasons       []string
+	}{
+		{
+			name:       "local cluster",
+			objs:       []client.Object{cluster("", nil)},
+			wantPhase:  commonscopeclusterv1beta1.ClusterReady,
+			wantReason: ReasonJoined,
+			reasons:    []string{"UpdateCluster"},
+		},
+		{
+			name:          "installs the agent",
+			objs:          []client.Object{cluster("current", nil), valid},
+			wantPhase:     commonscopeclusterv1beta1.ClusterReady,
+			wantReason:    ReasonJoined,
+			wantInstalled: []string{"https://alpha.example"},
+			reasons:       []string{"UpdateCluster"},
+		},
+		{
+			name:       "unusable credentials",
+			objs:       []client.Object{cluster("current", nil)},
+			wantPhase:  commonscopeclusterv1beta1.ClusterJoining,
+			wantReason: ReasonCredentialsUnusable,
+			reasons:    []string{ReasonSecretNotFound, "UpdateCluster"},
+		},
+		{
+			name:       "unreachable",
+			objs:       []client.Object{cluster("current", nil), valid},
+			verifyErr:  errors.New("connection refused"),
+			wantPhase:  commonscopeclusterv1beta1.ClusterJoining,
+			wantReason: ReasonUnreachable,
+			reasons:    []string{"UpdateCluster"},
+		},
+		{
+			name:          "agent install fails",
+			objs:          []client.Object{cluster("current", nil), valid},
+			agentErr:      errors.New("forbidden"),
+			wantPhase:     commonscopeclusterv1beta1.ClusterJoining,
+			wantReason:    ReasonAgentInstallFailed,
+			wantInstalled: []string{"https://alpha.example"},
+			reasons:       []string{"UpdateCluster"},
+		},
+		{
+			name:       "times out",
+			objs:       []client.Object{cluster("current", longAgo), valid},
+			verifyErr:  errors.New("connection refused"),
+			wantPhase:  commonscopeclusterv1beta1.ClusterJoining,
+			wantReason: ReasonJoinTimeout,
+			reasons:    []string{ReasonJoinTimeout, "UpdateCluster"},
+		},
+		{
+			name:          "joins after a timeout",
+			objs:          []client.Object{cluster("current", longAgo), valid},
+			wantPhase:     commonscopeclusterv1beta1.ClusterReady,
+			wantReason:    ReasonJoined,
+			wantInstalled: []string{"https://alpha.example"},
+			reasons:       []string{"UpdateCluster"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testutil.NewFakeClient(nil, tt.objs...)
+			recorder := testutil.NewEventRecorder()
+			agent := &fakeAgent{err: tt.agentErr}
+			r := &ClusterReconciler{
+				Client:            c,
+				Scheme:            c.Scheme(),
+				Recorder:          recorder,
+				Agent:             agent,
+				VerifyCredentials: func(context.Context, *rest.Config) error { return tt.verifyErr },
+			}
+
+			key := client.ObjectKey{Name: "alpha"}
+			res, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if joining := tt.wantPhase == commonscopeclusterv1beta1.ClusterJoining; joining != (res.RequeueAfter == lifecycleRetryInterval) {
+				t.Errorf("requeue after = %s", res.RequeueAfter)
+			}
+			if !reflect.DeepEqual(agent.installed, tt.wantInstalled) {
+				t.Errorf("installed into %v, want %v", agent.installed, tt.wantInstalled)
+			}
+			if reasons := recorder.Reasons(); !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("event reasons = %v, want %v", reasons, tt.reasons)
+			}
+
+			cu := new(commonscopeclusterv1beta1.Cluster)
+			if err := c.Get(context.Background(), key, cu); err != nil {
+				t.Fatal(err)
+			}
+			if cu.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %s, want %s", cu.Status.Phase, tt.wantPhase)
+			}
+			if cond := meta.FindStatusCondition(cu.Status.Conditions, commonscopeclusterv1beta1.ClusterConditionJoined); cond == nil || cond.Reason != tt.wantReason {
+				t.Errorf("Joined condition = %+v, want reason %s", cond, tt.wantReason)
+			}
+			if len(cu.Finalizers) != 1 || cu.Finalizers[0] != commonscopeclusterv1beta1.ClusterFinalizer {
+				t.Errorf("finalizers = %v, want the Cluster finalizer", cu.Finalizers)
+			}
+		})
+	}
+}
+
+func TestClusterDrain(t *testing.T) {
+	deleted := func(since time.Duration, lifecycle *commonscopeclusterv1beta1.ClusterLifecycle) *commonscopeclusterv1beta1.Cluster {
+		at := metav1.NewTime(time.Now().Add(-since))
+		return &commonscopeclusterv1beta1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "alpha", DeletionTimestamp: &at, Finalizers: []string{commonscopeclusterv1beta1.ClusterFinalizer}},
+			Spec: commonscopeclusterv1beta1.ClusterSpec{
+				Lifecycle: lifecycle,
+				Credentials: &commonscopeclusterv1beta1.ClusterCredentials{
+					SecretRef: corev1.SecretReference{Namespace: "clusters", Name: "current"},
+				},
+			},
+			Status: commonscopeclusterv1beta1.ClusterStatus{Phase: commonscopeclusterv1beta1.ClusterReady},
+		}
+	}
+	world := &studyv1beta1.World{
+		ObjectMeta: metav1.ObjectMeta{Name: "earth", Namespace: "default"},
+		Spec:       studyv1beta1.WorldSpec{World: "hello", Clusters: []string{"alpha"}},
+		Status:     studyv1beta1.WorldStatus{Phase: studyv1beta1.WorldReady},
+	}
+	evicted := world.DeepCopy()
+	evicted.Status.Evictions = []studyv1beta1.ClusterEviction{{Cluster: "alpha"}}
+	valid := tokenSecret("current", time.Now().Add(30*24*time.Hour))
+
+	tests := []struct {
+		name        string
+		objs        []client.Object
+		wantRemoved bool
+		wantReason  string
+		reasons     []string
+	}{
+		{
+			name:       "waits for the Worlds",
+			objs:       []client.Object{deleted(time.Minute, nil), world, valid},
+			wantReason: ReasonDraining,
+			reasons:    []string{ReasonDraining},
+		},
+		{
+			name:        "removes once the Worlds were evicted",
+			objs:        []client.Object{deleted(time.Minute, nil), evicted, valid},
+			wantRemoved: true,
+			reasons:     []string{ReasonDraining, ReasonRemoved},
+		},
+		{
+			name:       "reports the timeout",
+			objs:       []client.Object{deleted(time.Hour, nil), world, valid},
+			wantReason: ReasonDrainTimeout,
+			reasons:    []string{ReasonDraining, ReasonDrainTimeout},
+		},
+		{
+			name:        "forces the removal",
+			objs:        []client.Object{deleted(time.Hour, &commonscopeclusterv1beta1.ClusterLifecycle{TimeoutPolicy: commonscopeclusterv1beta1.TimeoutForce}), world, valid},
+			wantRemoved: true,
+			reasons:     []string{ReasonDraining, ReasonDrainTimeout, ReasonRemoved},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testutil.NewFakeClient(nil, tt.objs...)
+			recorder := testutil.NewEventRecorder()
+			agent := &fakeAgent{}
+			r := &ClusterReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder, Agent: agent}
+
+			key := client.ObjectKey{Name: "alpha"}
+			if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if reasons := recorder.Reasons(); !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("event reasons = %v, want %v", reasons, tt.reasons)
+			}
+			if removed := len(agent.uninstalled) > 0; removed != tt.wantRemoved {
+				t.Errorf("agent uninstalled from %v, want removed %v", agent.uninstalled, tt.wantRemoved)
+			}
+
+			cu := new(commonscopeclusterv1beta1.Cluster)
+			if err := c.Get(context.Background(), key, cu); err != nil {
+				if tt.wantRemoved && client.IgnoreNotFound(err) == nil {
+					return
+				}
+				t.Fatal(err)
+			}
+			if tt.wantRemoved {
+				if len(cu.Finalizers) != 0 || cu.Status.Phase != commonscopeclusterv1beta1.ClusterRemoved {
+					t.Errorf("finalizers %v, phase %s, want removed", cu.Finalizers, cu.Status.Phase)
+				}
+				return
+			}
+			if cu.Status.Phase != commonscopeclusterv1beta1.ClusterDraining {
+				t.Errorf("phase = %s, want Draining", cu.Status.Phase)
+			}
+			if cond := meta.FindStatusCondition(cu.Status.Conditions, commonscopeclusterv1beta1.ClusterConditionDrained); cond == nil || cond.Reason != tt.wantReason {
+				t.Errorf("Drained condition = %+v, want reason %s", cond, tt.wantReason)
+			}
+			if n := len(cu.Spec.Taints); n != 1 || cu.Spec.Taints[0].Key != commonscopeclusterv1beta1.ClusterTaintDraining {
+				t.Errorf("taints = %+v, want the draining taint", cu.Spec.Taints)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commonscopecluster
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/health"
+	"github/antmoveh/kube-develop-tools/pkg/pause"
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+// TestClusterReconcile runs single reconciles against a fake client. The
+// envtest suite covers the controller end to end.
+func TestClusterReconcile(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	cluster := func(annotations map[string]string) *commonscopeclusterv1beta1.Cluster {
+		return &commonscopeclusterv1beta1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "alpha", Annotations: annotations},
+			Spec:       commonscopeclusterv1beta1.ClusterSpec{ClusterName: "alpha"},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		objs    []client.Object
+		errors  map[testutil.Verb]error
+		wantErr error
+		check   func(t *testing.T, cu *commonscopeclusterv1beta1.Cluster)
+		reasons []string
+	}{
+		{
+			name: "not found",
+		},
+		{
+			name:    "get fails",
+			objs:    []client.Object{cluster(nil)},
+			errors:  map[testutil.Verb]error{testutil.VerbGet: errBoom},
+			wantErr: errBoom,
+		},
+		{
+			name: "updates the status",
+			objs: []client.Object{cluster(nil)},
+			check: func(t *testing.T, cu *commonscopeclusterv1beta1.Cluster) {
+				if len(cu.Status.Cluster) != 5 {
+					t.Errorf("status.cluster = %q, want 5 random characters", cu.Status.Cluster)
+				}
+			},
+			reasons: []string{"UpdateCluster"},
+		},
+		{
+			name: "paused by annotation",
+			objs: []client.Object{cluster(map[string]string{pause.Annotation: "true"})},
+			check: func(t *testing.T, cu *commonscopeclusterv1beta1.Cluster) {
+				if !meta.IsStatusConditionTrue(cu.Status.Conditions, pause.ConditionType) {
+					t.Errorf("conditions = %v, want Paused", cu.Status.Conditions)
+				}
+				if cu.Status.Cluster != "" {
+					t.Errorf("status.cluster = %q, want it unset while paused", cu.Status.Cluster)
+				}
+			},
+			reasons: []string{pause.ConditionType},
+		},
+		{
+			name: "stamps NoExecute taints",
+			objs: []client.Object{func() client.Object {
+				cu := cluster(nil)
+				cu.Spec.Taints = []corev1.Taint{
+					{Key: "maintenance", Effect: corev1.TaintEffectNoExecute},
+					{Key: "gpu", Effect: corev1.TaintEffectNoSchedule},
+				}
+				return cu
+			}()},
+			check: func(t *testing.T, cu *commonscopeclusterv1beta1.Cluster) {
+				if len(cu.Spec.Taints) != 2 || cu.Spec.Taints[0].TimeAdded == nil || cu.Spec.Taints[1].TimeAdded != nil {
+					t.Errorf("taints = %+v, want timeAdded on the NoExecute taint only", cu.Spec.Taints)
+				}
+				if len(cu.Status.Cluster) != 5 {
+					t.Errorf("status.cluster = %q, want the status updated after the patch", cu.Status.Cluster)
+				}
+			},
+			reasons: []string{"UpdateCluster"},
+		},
+		{
+			name:    "paused status update fails",
+			objs:    []client.Object{cluster(map[string]string{pause.Annotation: "true"})},
+			errors:  map[testutil.Verb]error{testutil.VerbStatusUpdate: errBoom},
+			wantErr: errBoom,
+			reasons: []string{pause.ConditionType},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testutil.NewFakeClient(nil, tt.objs...)
+			for verb, err := range tt.errors {
+				c.InjectError(verb, testutil.AlwaysError(err))
+			}
+			recorder := testutil.NewEventRecorder()
+			r := &ClusterReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+
+			key := client.ObjectKey{Name: "alpha"}
+			if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Reconcile() error = %v, want %v", err, tt.wantErr)
+			}
+			if reasons := recorder.Reasons(); len(reasons)+len(tt.reasons) > 0 && !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("event reasons = %v, want %v", reasons, tt.reasons)
+			}
+
+			if tt.check != nil {
+				c.ClearErrors()
+				cu := new(commonscopeclusterv1beta1.Cluster)
+				if err := c.Get(context.Background(), key, cu); err != nil {
+					t.Fatal(err)
+				}
+				tt.check(t, cu)
+			}
+		})
+	}
+}
+
+// TestClusterReconcileUnchanged checks that a periodic requeue which changes
+// nothing neither writes the status nor emits an event.
+func TestClusterReconcileUnchanged(t *testing.T) {
+	c := testutil.NewFakeClient(nil, &commonscopeclusterv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "alpha"}})
+	recorder := testutil.NewEventRecorder()
+	r := &ClusterReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+	key := client.ObjectKey{Name: "alpha"}
+
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatal(err)
+	}
+	before := new(commonscopeclusterv1beta1.Cluster)
+	if err := c.Get(context.Background(), key, before); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatal(err)
+	}
+	after := new(commonscopeclusterv1beta1.Cluster)
+	if err := c.Get(context.Background(), key, after); err != nil {
+		t.Fatal(err)
+	}
+	if after.ResourceVersion != before.ResourceVersion || after.Status.Cluster != before.Status.Cluster {
+		t.Errorf("status written again: resourceVersion %s -> %s, status.cluster %q -> %q",
+			before.ResourceVersion, after.ResourceVersion, before.Status.Cluster, after.Status.Cluster)
+	}
+	if want := []string{"UpdateCluster"}; !reflect.DeepEqual(recorder.Reasons(), want) {
+		t.Errorf("reasons = %v, want %v", recorder.Reasons(), want)
+	}
+}
+
+func TestCombinedIndex(t *testing.T) {
+	pod := func(name, scheduler string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PodSpec{SchedulerName: scheduler},
+		}
+	}
+	ctx := context.Background()
+	c := testutil.NewFakeClient(nil, pod("a", "default-scheduler"), pod("b", "volcano"))
+	if err := c.IndexField(ctx, &corev1.Pod{}, combinedIndexField, combinedIndex); err != nil {
+		t.Fatal(err)
+	}
+
+	pods := new(corev1.PodList)
+	if err := c.List(ctx, pods, client.MatchingFields{combinedIndexField: "volcano-"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(pods.Items) != 1 || pods.Items[0].Name != "b" {
+		t.Errorf("got %d pods, want only b", len(pods.Items))
+	}
+}
+
+// scriptedProber returns its samples in order.
+type scriptedProber struct {
+	samples []health.Sample
+}
+
+func (p *scriptedProber) Probe(context.Context, *commonscopeclusterv1beta1.Cluster) (health.Sample, error) {
+	s := p.samples[0]
+	p.samples = p.samples[1:]
+	return s, s.Err
+}
+
+func TestClusterCordon(t *testing.T) {
+	down := health.Sample{Err: errors.New("unreachable")}
+	up := health.Sample{Nodes: 1, ReadyNodes: 1}
+	prober := &scriptedProber{samples: []health.Sample{up, down, down, down, down, up, up, up, up, up, up, up, up, up}}
+	monitor := health.NewMonitor(prober)
+	// 每次调谐都到了探测间隔
+	monitor.Interval = time.Nanosecond
+
+	c := testutil.NewFakeClient(nil, &commonscopeclusterv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "alpha"}})
+	recorder := testutil.NewEventRecorder()
+	r := &ClusterReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder, Health: monitor}
+	key := client.ObjectKey{Name: "alpha"}
+
+	// 每次调谐探测一次，记录Schedulable条件的变化
+	var states []metav1.ConditionStatus
+	var scores []int32
+	for range prober.samples {
+		result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.RequeueAfter != monitor.Interval {
+			t.Errorf("RequeueAfter = %v, want %v", result.RequeueAfter, monitor.Interval)
+		}
+		cu := new(commonscopeclusterv1beta1.Cluster)
+		if err := c.Get(context.Background(), key, cu); err != nil {
+			t.Fatal(err)
+		}
+		cond := meta.FindStatusCondition(cu.Status.Conditions, commonscopeclusterv1beta1.ClusterConditionSchedulable)
+		if cond == nil || cu.Status.Health == nil {
+			t.Fatalf("status = %+v, want health and a Schedulable condition", cu.Status)
+		}
+		if n := len(states); n == 0 || states[n-1] != cond.Status {
+			states = append(states, cond.Status)
+		}
+		scores = append(scores, cu.Status.Health.Score)
+	}
+
+	want := []metav1.ConditionStatus{metav1.ConditionTrue, metav1.ConditionFalse, metav1.ConditionTrue}
+	if !reflect.DeepEqual(states, want) {
+		t.Errorf("schedulable transitions = %v, want %v (scores %v)", states, want, scores)
+	}
+	var cordons []string
+	for _, reason := range recorder.Reasons() {
+		if reason != "UpdateCluster" {
+			cordons = append(cordons, reason)
+		}
+	}
+	if want := []string{ReasonCordoned, ReasonUncordoned}; !reflect.DeepEqual(cordons, want) {
+		t.Errorf("cordon events = %v, want %v", cordons, want)
+	}
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commonscopecluster
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files of the reconcile scenarios with the actual outcome.")
+
+// TestClusterScenarios runs the scenarios in testdata/scenarios. Run
+// go test ./controllers/common.scope.cluster -run TestClusterScenarios -update
+// to rewrite the golden files after changing the reconciler.
+func TestClusterScenarios(t *testing.T) {
+	testutil.RunScenarios(t, filepath.Join("testdata", "scenarios"), *update,
+		func(c client.Client, recorder record.EventRecorder) reconcile.Reconciler {
+			return &ClusterReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+		},
+		"objects[].status.cluster",
+		"objects[].status.conditions[].lastTransitionTime",
+		"events[].message",
+	)
+}
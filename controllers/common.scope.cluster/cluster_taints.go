@@ -0,0 +1,50 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commonscopecluster
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+)
+
+// stampTaints sets timeAdded of the NoExecute taints of cu without one, as
+// the grace periods of the Worlds tolerating them count from it.
+func (r *ClusterReconciler) stampTaints(ctx context.Context, cu *commonscopeclusterv1beta1.Cluster) error {
+	orig := cu.DeepCopy()
+	now := metav1.Now()
+	changed := false
+	for i := range cu.Spec.Taints {
+		if taint := &cu.Spec.Taints[i]; taint.Effect == corev1.TaintEffectNoExecute && taint.TimeAdded == nil {
+			taint.TimeAdded = &now
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	// 合并补丁会整体替换taints列表，用乐观锁避免覆盖并发的修改
+	if err := r.Client.Patch(ctx, cu, client.MergeFromWithOptions(orig, client.MergeFromWithOptimisticLock{})); err != nil {
+		return err
+	}
+	cu.Status = orig.Status
+	return nil
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commonscopecluster
+
+import (
+	"k8s.io/client-go/rest"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+)
+
+// watchRemote keeps the watches on cu running with the config it is reached
+// with, the local one for Clusters without credentials, and records what
+// they saw.
+func (r *ClusterReconciler) watchRemote(cu *commonscopeclusterv1beta1.Cluster, remote *rest.Config) {
+	if cu.Spec.Credentials != nil && remote == nil {
+		// 凭证不可用时停止监听，凭证恢复后重新开始
+		r.Remote.Stop(cu.Name)
+		cu.Status.Watch = nil
+		return
+	}
+	r.Remote.Watch(cu.Name, remote)
+	if s, ok := r.Remote.Status(cu.Name); ok {
+		cu.Status.Watch = s.ClusterStatus()
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commonscopecluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/health"
+	"github/antmoveh/kube-develop-tools/pkg/remote"
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+func TestClusterWatch(t *testing.T) {
+	ready := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "n1"},
+		Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}},
+	}
+	var hosts []string
+	watcher := remote.NewWatcher(&rest.Config{Host: "https://local.example"}, func(cfg *rest.Config) (kubernetes.Interface, dynamic.Interface, error) {
+		hosts = append(hosts, cfg.Host)
+		return kubefake.NewSimpleClientset(ready), dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{studyv1beta1.GroupVersion.WithResource("worlds"): "WorldList"}), nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = watcher.Start(ctx) }()
+
+	cu := &commonscopeclusterv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "alpha"}}
+	c := testutil.NewFakeClient(nil, cu)
+	monitor := health.NewMonitor(watcher)
+	// 每次调谐都采样，不等探测间隔
+	monitor.Interval = time.Nanosecond
+	r := &ClusterReconciler{Client: c, Scheme: c.Scheme(), Recorder: testutil.NewEventRecorder(), Remote: watcher, Health: monitor}
+	key := client.ObjectKey{Name: "alpha"}
+	reconcile := func() *commonscopeclusterv1beta1.Cluster {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatal(err)
+		}
+		got := new(commonscopeclusterv1beta1.Cluster)
+		if err := c.Get(ctx, key, got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	reconcile()
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watches did not connect")
+	case <-connected(watcher):
+	}
+	got := reconcile()
+	if w := got.Status.Watch; w == nil || !w.Connected || w.Nodes != 1 || w.ReadyNodes != 1 {
+		t.Errorf("status.watch = %+v, want connected with 1 ready node", w)
+	}
+	if h := got.Status.Health; h == nil || h.ReadyNodes != 1 || h.LastProbeError != "" {
+		t.Errorf("status.health = %+v, want it from the watches", h)
+	}
+	if len(hosts) != 1 || hosts[0] != "https://local.example" {
+		t.Errorf("watched %v, want the local cluster once", hosts)
+	}
+
+	// 凭证不可用时停止监听
+	got.Spec.Credentials = &commonscopeclusterv1beta1.ClusterCredentials{SecretRef: corev1.SecretReference{Namespace: "clusters", Name: "missing"}}
+	if err := c.Update(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if got := reconcile(); got.Status.Watch != nil {
+		t.Errorf("status.watch = %+v, want it unset without usable credentials", got.Status.Watch)
+	}
+	if _, ok := watcher.Status("alpha"); ok {
+		t.Error("still watching without usable credentials")
+	}
+}
+
+// connected is closed once the watches on alpha connected.
+func connected(w *remote.Watcher) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if s, ok := w.Status("alpha"); ok && s.Connected {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	return done
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+const (
+	// ConditionScheduled is true once the World is bound to its Clusters.
+	ConditionScheduled = "Scheduled"
+	// ReasonBound is the reason of a World bound to its Clusters.
+	ReasonBound = "Bound"
+	// ReasonClusterCordoned is the reason of a World waiting for cordoned
+	// Clusters.
+	ReasonClusterCordoned = "ClusterCordoned"
+
+	// cordonRetryInterval is how often a World waiting for cordoned Clusters
+	// checks them again.
+	cordonRetryInterval = 30 * time.Second
+)
+
+// cordonedClusters returns the Clusters of wl that are cordoned. Missing
+// Clusters are left to validateWorld.
+func (r *WorldReconciler) cordonedClusters(ctx context.Context, wl *studyv1beta1.World) ([]string, error) {
+	var cordoned []string
+	for _, name := range wl.Spec.Clusters {
+		cu := new(commonscopeclusterv1beta1.Cluster)
+		if err := r.Client.Get(ctx, client.ObjectKey{Name: name}, cu); err != nil {
+			if apierrs.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if meta.IsStatusConditionFalse(cu.Status.Conditions, commonscopeclusterv1beta1.ClusterConditionSchedulable) {
+			cordoned = append(cordoned, name)
+		}
+	}
+	return cordoned, nil
+}
+
+// waitForClusters keeps a new World Pending while Clusters it is bound to are
+// cordoned or tainted, reason telling which. Worlds already bound keep
+// running on them.
+func (r *WorldReconciler) waitForClusters(ctx context.Context, wl *studyv1beta1.World, reason string, clusters []string) (ctrl.Result, error) {
+	what := "cordoned"
+	if reason == ReasonClusterTainted {
+		what = "tainted"
+	}
+	msg := fmt.Sprintf("waiting for %s clusters: %s", what, strings.Join(clusters, ", "))
+	if c := meta.FindStatusCondition(wl.Status.Conditions, ConditionScheduled); c == nil || c.Status != metav1.ConditionFalse || c.Message != msg {
+		meta.SetStatusCondition(&wl.Status.Conditions, metav1.Condition{
+			Type:               ConditionScheduled,
+			Status:             metav1.ConditionFalse,
+			Reason:             reason,
+			Message:            msg,
+			ObservedGeneration: wl.Generation,
+		})
+		r.Recorder.Event(wl, corev1.EventTypeWarning, reason, msg)
+		if err := r.Client.Status().Update(ctx, wl); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: cordonRetryInterval}, nil
+}
+
+// setScheduled marks wl bound to its Clusters; the next status write saves
+// it.
+func setScheduled(wl *studyv1beta1.World, message string) {
+	meta.SetStatusCondition(&wl.Status.Conditions, metav1.Condition{
+		Type:               ConditionScheduled,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonBound,
+		Message:            message,
+		ObservedGeneration: wl.Generation,
+	})
+}
@@ -18,9 +18,24 @@ package controllers
 
 import (
 	"context"
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
 	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/debug"
+	"github/antmoveh/kube-develop-tools/pkg/logging"
+	"github/antmoveh/kube-develop-tools/pkg/pause"
+	"github/antmoveh/kube-develop-tools/pkg/scheduler"
+	"github/antmoveh/kube-develop-tools/pkg/sharding"
+	"github/antmoveh/kube-develop-tools/pkg/tracing"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"time"
 
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -32,16 +47,44 @@ import (
 // WorldReconciler reconciles a World object
 type WorldReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Pause decides whether a World is paused. A nil Pause only honors the
+	// pause annotation.
+	Pause *pause.Checker
+	// SyncInterval is the resync interval of Worlds without
+	// spec.syncInterval, DefaultSyncInterval when zero.
+	SyncInterval time.Duration
+	// SyncJitter is the largest fraction of the interval added to each
+	// resync so Worlds spread out over time.
+	SyncJitter float64
+	// StaleAfter is the number of failed resyncs in a row after which a
+	// World is marked stale, DefaultStaleAfter when zero.
+	StaleAfter int32
+	// Shard decides which Worlds this replica reconciles. A nil Shard
+	// reconciles every World.
+	Shard *sharding.Coordinator
+	// LogLevels holds the runtime log level of the controller. A nil
+	// LogLevels logs with the manager logger.
+	LogLevels *logging.Levels
+	// Queues tracks the workqueue of the controller for the debug server. A
+	// nil Queues leaves it alone.
+	Queues *debug.Queues
+	// Scheduler places the Worlds with a placement. A nil Scheduler uses the
+	// default filters.
+	Scheduler *scheduler.Scheduler
+	// DryRun is set when the client only records the writes. The phase of a
+	// World then never advances, so the reconciler does not requeue to walk
+	// through the phases and only reconciles on events and resyncs.
+	DryRun bool
 }
 
-const (
-	wf = "world.finalizers"
-)
-
 //+kubebuilder:rbac:groups=study.example.cn,resources=worlds,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=study.example.cn,resources=worlds/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=study.example.cn,resources=worlds/finalizers,verbs=update
+//+kubebuilder:rbac:groups=common.scope.cluster,resources=clusters,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",namespace=system,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -52,7 +95,11 @@ const (
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.10.0/pkg/reconcile
-func (r *WorldReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *WorldReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	if r.DryRun {
+		// 试运行时阶段不会落盘，requeue只会一遍遍记录同样的变化
+		defer func() { result.Requeue = false }()
+	}
 	logger := log.FromContext(ctx)
 	// your logic here
 	wl := new(studyv1beta1.World)
@@ -60,51 +107,253 @@ func (r *WorldReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		if !apierrs.IsNotFound(err) {
 			return ctrl.Result{}, err
 		}
+		pause.Track("World", req.NamespacedName, false)
+		return ctrl.Result{}, nil
+	}
+
+	if !r.Shard.Owns(wl) {
+		// 分片已经转移到其他副本
+		return ctrl.Result{}, nil
+	}
+
+	paused, reason, err := r.Pause.IsPaused(ctx, wl)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	pause.Track("World", req.NamespacedName, paused)
+	transition := pause.SetCondition(&wl.Status.Conditions, paused, reason, wl.Generation)
+	switch transition {
+	case pause.Paused:
+		r.Recorder.Event(wl, corev1.EventTypeNormal, pause.ConditionType, "reconciliation paused: "+reason)
+	case pause.Resumed:
+		r.Recorder.Event(wl, corev1.EventTypeNormal, pause.ReasonResumed, "reconciliation resumed")
+	}
+	if transition != pause.Unchanged {
+		if err := r.Client.Status().Update(ctx, wl); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if paused {
+		logger.Info("reconciliation paused", "reason", reason)
 		return ctrl.Result{}, nil
 	}
 
-	if wl.ObjectMeta.DeletionTimestamp == nil {
-		if !containsString(wl.Finalizers, wf) {
+	if wl.ObjectMeta.DeletionTimestamp != nil {
+		return r.terminate(ctx, wl)
+	}
+
+	switch wl.Status.Phase {
+	case "":
+		return ctrl.Result{Requeue: true}, r.setPhase(ctx, wl, studyv1beta1.WorldPending, "")
+	case studyv1beta1.WorldPending:
+		var scheduled string
+		if wl.Spec.Placement != nil {
+			var current []string
+			if p := wl.Status.Placement; p != nil && p.ObservedGeneration == wl.Generation {
+				current = p.Clusters
+			}
+			previous := wl.Status.Placement.DeepCopy()
+			placed, err := r.schedule(ctx, wl, current)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if !placed {
+				if samePlacement(previous, wl.Status.Placement) {
+					return ctrl.Result{RequeueAfter: cordonRetryInterval}, nil
+				}
+				return ctrl.Result{RequeueAfter: cordonRetryInterval}, r.Client.Status().Update(ctx, wl)
+			}
+			scheduled = wl.Status.Placement.Message
+			r.Recorder.Event(wl, corev1.EventTypeNormal, ReasonScheduled, scheduled)
+		} else {
+			cordoned, err := r.cordonedClusters(ctx, wl)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if len(cordoned) > 0 {
+				return r.waitForClusters(ctx, wl, ReasonClusterCordoned, cordoned)
+			}
+			tainted, err := r.taintedClusters(ctx, wl)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if len(tainted) > 0 {
+				return r.waitForClusters(ctx, wl, ReasonClusterTainted, tainted)
+			}
+			wl.Status.Evictions = nil
+		}
+		if !containsString(wl.Finalizers, studyv1beta1.WorldFinalizer) {
 			lv2 := wl.DeepCopy()
-			lv2.Finalizers = append(lv2.Finalizers, wf)
-			patch := client.MergeFrom(wl)
+			lv2.Finalizers = append(lv2.Finalizers, studyv1beta1.WorldFinalizer)
+			// 乐观锁：分片重新分配时两个副本可能短暂地同时处理同一个World
+			patch := client.MergeFromWithOptions(wl, client.MergeFromWithOptimisticLock{})
 			if err := r.Patch(ctx, lv2, patch); err != nil {
-				return ctrl.Result{}, err
+				return r.fail(ctx, wl, err)
 			}
 			logger.Info("add finalizer")
-			return ctrl.Result{Requeue: true}, nil
+			wl = lv2
 		}
-
+		setScheduled(wl, scheduled)
+		return ctrl.Result{Requeue: true}, r.setPhase(ctx, wl, studyv1beta1.WorldProvisioning, "")
+	case studyv1beta1.WorldProvisioning:
 		if wl.Status.War == "" {
 			wl.Status.War = rand.String(8)
 			wl.Status.SyncTime = metav1.Now()
-
-			err := r.Client.Status().Update(ctx, wl)
-			if err != nil {
-				logger.Error(err, "update status failed")
+		}
+		return ctrl.Result{}, r.setPhase(ctx, wl, studyv1beta1.WorldReady, "")
+	case studyv1beta1.WorldReady:
+		// 终结器被人为移除后重新走一遍初始化
+		if !containsString(wl.Finalizers, studyv1beta1.WorldFinalizer) {
+			return ctrl.Result{Requeue: true}, r.setPhase(ctx, wl, studyv1beta1.WorldPending, "finalizer is missing")
+		}
+		evicted, nextEviction, changed, err := r.evict(ctx, wl)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if wl.Spec.Placement != nil {
+			reason := ""
+			if len(evicted) > 0 {
+				reason = evictionMessage(evicted)
+			} else if reason, err = r.rescheduleReason(ctx, wl); err != nil {
+				return ctrl.Result{}, err
+			}
+			if reason != "" {
+				result, placed, err := r.reschedule(ctx, wl, reason)
+				if err != nil || !placed {
+					return result, err
+				}
+			}
+		} else if changed {
+			if len(wl.Spec.Clusters) > 0 && len(wl.BoundClusters()) == 0 {
+				return ctrl.Result{Requeue: true}, r.setPhase(ctx, wl, studyv1beta1.WorldPending, evictionMessage(evicted))
+			}
+			if err := r.Client.Status().Update(ctx, wl); err != nil {
+				return ctrl.Result{}, err
 			}
-			return ctrl.Result{}, nil
 		}
+		result, err := r.resync(ctx, wl)
+		result.RequeueAfter = sooner(result.RequeueAfter, nextEviction)
+		return result, err
+	case studyv1beta1.WorldFailed:
+		if wait := failedRetryInterval - time.Since(lastPhaseTransition(wl).Time); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		return ctrl.Result{Requeue: true}, r.setPhase(ctx, wl, studyv1beta1.WorldPending, "retrying")
 	}
+	return ctrl.Result{}, nil
+}
 
-	if !containsString(wl.Finalizers, wf) {
+// failedRetryInterval is how long a World stays Failed before it is set up
+// again.
+const failedRetryInterval = time.Minute
+
+// terminate moves a deleted World to Terminating and removes its finalizer.
+func (r *WorldReconciler) terminate(ctx context.Context, wl *studyv1beta1.World) (ctrl.Result, error) {
+	if !containsString(wl.Finalizers, studyv1beta1.WorldFinalizer) {
 		return ctrl.Result{}, nil
 	}
+	if err := r.setPhase(ctx, wl, studyv1beta1.WorldTerminating, ""); err != nil {
+		return ctrl.Result{}, err
+	}
 
 	lv2 := wl.DeepCopy()
-	lv2.Finalizers = sliceRemoveString(lv2.Finalizers, wf)
-	patch := client.MergeFrom(wl)
+	lv2.Finalizers = sliceRemoveString(lv2.Finalizers, studyv1beta1.WorldFinalizer)
+	patch := client.MergeFromWithOptions(wl, client.MergeFromWithOptimisticLock{})
 	if err := r.Patch(ctx, lv2, patch); err != nil {
 		return ctrl.Result{}, err
 	}
 	return ctrl.Result{}, nil
 }
 
+// fail moves wl to Failed when err will not go away by retrying, and returns
+// err otherwise so the request is retried with backoff.
+func (r *WorldReconciler) fail(ctx context.Context, wl *studyv1beta1.World, err error) (ctrl.Result, error) {
+	if !apierrs.IsInvalid(err) && !apierrs.IsForbidden(err) && !apierrs.IsBadRequest(err) {
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Event(wl, corev1.EventTypeWarning, string(studyv1beta1.WorldFailed), err.Error())
+	if err := r.setPhase(ctx, wl, studyv1beta1.WorldFailed, err.Error()); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: failedRetryInterval}, nil
+}
+
+// setPhase moves wl to phase and writes the status.
+func (r *WorldReconciler) setPhase(ctx context.Context, wl *studyv1beta1.World, phase studyv1beta1.WorldPhase, message string) error {
+	if wl.Status.Phase == phase {
+		return nil
+	}
+	from := wl.Status.Phase
+	if err := wl.Status.SetPhase(phase, metav1.Now(), message); err != nil {
+		return err
+	}
+	if err := r.Client.Status().Update(ctx, wl); err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("phase changed", "from", from, "to", phase)
+	return nil
+}
+
+func lastPhaseTransition(wl *studyv1beta1.World) metav1.Time {
+	if n := len(wl.Status.PhaseTransitions); n > 0 {
+		return wl.Status.PhaseTransitions[n-1].LastTransitionTime
+	}
+	return metav1.Time{}
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *WorldReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&studyv1beta1.World{}).
-		Complete(r)
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &studyv1beta1.World{}, clusterIndexField, worldClusters); err != nil {
+		return err
+	}
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&studyv1beta1.World{}, builder.WithPredicates(r.Shard.Predicate())).
+		Watches(&source.Kind{Type: &commonscopeclusterv1beta1.Cluster{}}, handler.EnqueueRequestsFromMapFunc(r.requestsForCluster),
+			builder.WithPredicates(clusterSchedulingChanged))
+	if src := r.Pause.Source(); src != nil {
+		b = b.Watches(src, handler.EnqueueRequestsFromMapFunc(r.requestsForPauseConfigMap))
+	}
+	if r.Shard != nil {
+		b = b.Watches(&source.Channel{Source: r.Shard.Subscribe()}, handler.EnqueueRequestsFromMapFunc(r.requestsForOwnedWorlds))
+	}
+	c, err := b.Build(logging.NewReconciler("world", r.LogLevels, tracing.NewReconciler("world", r)))
+	if err != nil {
+		return err
+	}
+	return r.Queues.Track("world", c)
+}
+
+// requestsForOwnedWorlds enqueues the Worlds this replica owns after the
+// shards were rebalanced, since their events were filtered out before.
+func (r *WorldReconciler) requestsForOwnedWorlds(client.Object) []reconcile.Request {
+	wls := new(studyv1beta1.WorldList)
+	if err := r.Client.List(context.Background(), wls); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(wls.Items))
+	for i := range wls.Items {
+		if r.Shard.Owns(&wls.Items[i]) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&wls.Items[i])})
+		}
+	}
+	return requests
+}
+
+// requestsForPauseConfigMap enqueues every World when the global pause
+// ConfigMap changes, so pausing and resuming take effect immediately.
+func (r *WorldReconciler) requestsForPauseConfigMap(obj client.Object) []reconcile.Request {
+	if !r.Pause.IsConfigMap(obj) {
+		return nil
+	}
+	wls := new(studyv1beta1.WorldList)
+	if err := r.Client.List(context.Background(), wls); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(wls.Items))
+	for _, wl := range wls.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: wl.Namespace, Name: wl.Name}})
+	}
+	return requests
 }
 
 func containsString(slice []string, s string) bool {
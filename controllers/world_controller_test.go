@@ -0,0 +1,170 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	studyv1beta2 "github/antmoveh/kube-develop-tools/apis/study/v1beta2"
+	"github/antmoveh/kube-develop-tools/pkg/pause"
+)
+
+var _ = Describe("World controller", func() {
+	const (
+		timeout  = 10 * time.Second
+		interval = 250 * time.Millisecond
+	)
+
+	var namespace string
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "world-" + rand.String(5)}}
+		Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+		namespace = ns.Name
+	})
+
+	newWorld := func(name string) *studyv1beta1.World {
+		return &studyv1beta1.World{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       studyv1beta1.WorldSpec{World: "hello"},
+		}
+	}
+
+	getWorld := func(key types.NamespacedName) func() (*studyv1beta1.World, error) {
+		return func() (*studyv1beta1.World, error) {
+			wl := new(studyv1beta1.World)
+			err := k8sClient.Get(ctx, key, wl)
+			return wl, err
+		}
+	}
+
+	It("adds the finalizer and initializes the status", func() {
+		wl := newWorld("earth")
+		Expect(k8sClient.Create(ctx, wl)).To(Succeed())
+		key := client.ObjectKeyFromObject(wl)
+
+		Eventually(func() ([]string, error) {
+			got, err := getWorld(key)()
+			return got.Finalizers, err
+		}, timeout, interval).Should(ContainElement(studyv1beta1.WorldFinalizer))
+
+		Eventually(func() (string, error) {
+			got, err := getWorld(key)()
+			return got.Status.War, err
+		}, timeout, interval).ShouldNot(BeEmpty())
+
+		Eventually(func() (studyv1beta1.WorldPhase, error) {
+			got, err := getWorld(key)()
+			return got.Status.Phase, err
+		}, timeout, interval).Should(Equal(studyv1beta1.WorldReady))
+
+		got, err := getWorld(key)()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(got.Status.SyncTime.IsZero()).To(BeFalse())
+		phases := make([]studyv1beta1.WorldPhase, 0, len(got.Status.PhaseTransitions))
+		for _, t := range got.Status.PhaseTransitions {
+			phases = append(phases, t.Phase)
+		}
+		Expect(phases).To(Equal([]studyv1beta1.WorldPhase{studyv1beta1.WorldPending, studyv1beta1.WorldProvisioning, studyv1beta1.WorldReady}))
+	})
+
+	It("serves the World at v1beta2 through the conversion webhook", func() {
+		wl := newWorld("mars")
+		Expect(k8sClient.Create(ctx, wl)).To(Succeed())
+		key := client.ObjectKeyFromObject(wl)
+
+		Eventually(func() (string, error) {
+			got, err := getWorld(key)()
+			return got.Status.War, err
+		}, timeout, interval).ShouldNot(BeEmpty())
+		war := getWorldOrFail(key).Status.War
+
+		v2 := new(studyv1beta2.World)
+		Expect(k8sClient.Get(ctx, key, v2)).To(Succeed())
+		Expect(v2.Spec.Earth).To(Equal("hello"))
+		Expect(v2.Status.War).To(Equal(war))
+		Expect(v2.Finalizers).To(ContainElement(studyv1beta1.WorldFinalizer))
+	})
+
+	It("reconciles Worlds created at v1beta2", func() {
+		v2 := &studyv1beta2.World{
+			ObjectMeta: metav1.ObjectMeta{Name: "venus", Namespace: namespace},
+			Spec:       studyv1beta2.WorldSpec{Earth: "hello"},
+		}
+		Expect(k8sClient.Create(ctx, v2)).To(Succeed())
+		key := client.ObjectKeyFromObject(v2)
+
+		Eventually(func() (string, error) {
+			got, err := getWorld(key)()
+			return got.Status.War, err
+		}, timeout, interval).ShouldNot(BeEmpty())
+		Expect(getWorldOrFail(key).Spec.World).To(Equal("hello"))
+	})
+
+	It("removes the finalizer when the World is deleted", func() {
+		wl := newWorld("pluto")
+		Expect(k8sClient.Create(ctx, wl)).To(Succeed())
+		key := client.ObjectKeyFromObject(wl)
+
+		Eventually(func() ([]string, error) {
+			got, err := getWorld(key)()
+			return got.Finalizers, err
+		}, timeout, interval).Should(ContainElement(studyv1beta1.WorldFinalizer))
+
+		Expect(k8sClient.Delete(ctx, wl)).To(Succeed())
+		Eventually(func() bool {
+			_, err := getWorld(key)()
+			return apierrs.IsNotFound(err)
+		}, timeout, interval).Should(BeTrue())
+	})
+
+	It("leaves paused Worlds alone", func() {
+		wl := newWorld("saturn")
+		wl.Annotations = map[string]string{pause.Annotation: "true"}
+		Expect(k8sClient.Create(ctx, wl)).To(Succeed())
+		key := client.ObjectKeyFromObject(wl)
+
+		Eventually(func() (bool, error) {
+			got, err := getWorld(key)()
+			return meta.IsStatusConditionTrue(got.Status.Conditions, pause.ConditionType), err
+		}, timeout, interval).Should(BeTrue())
+
+		Consistently(func() ([]string, error) {
+			got, err := getWorld(key)()
+			return got.Finalizers, err
+		}, 2*time.Second, interval).ShouldNot(ContainElement(studyv1beta1.WorldFinalizer))
+		Expect(getWorldOrFail(key).Status.War).To(BeEmpty())
+	})
+})
+
+// getWorldOrFail reads the v1beta1 World at key and fails the spec on error.
+func getWorldOrFail(key types.NamespacedName) *studyv1beta1.World {
+	wl := new(studyv1beta1.World)
+	ExpectWithOffset(1, k8sClient.Get(ctx, key, wl)).To(Succeed())
+	return wl
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/scheduler"
+)
+
+const (
+	// ReasonScheduled is the event of a World the scheduler placed.
+	ReasonScheduled = "Scheduled"
+	// ReasonRescheduled is the event of a World the scheduler moved.
+	ReasonRescheduled = "Rescheduled"
+	// ReasonUnschedulable is the reason and event of a World that fits on no
+	// Cluster.
+	ReasonUnschedulable = "Unschedulable"
+
+	// clusterIndexField indexes Worlds by the Clusters they are or may be
+	// bound to.
+	clusterIndexField = "spec.clusters,status.placement.clusters"
+)
+
+// schedule places wl by its placement and records the decision in its
+// status. It returns false when wl fits on no Cluster; the caller writes the
+// status either way.
+func (r *WorldReconciler) schedule(ctx context.Context, wl *studyv1beta1.World, current []string) (bool, error) {
+	candidates, err := r.placementCandidates(ctx, wl)
+	if err != nil {
+		return false, err
+	}
+	s := r.Scheduler
+	if s == nil {
+		s = &scheduler.Scheduler{}
+	}
+	decision, err := s.Schedule(wl, candidates, current)
+	wl.Status.Placement = decision
+	if errors.Is(err, scheduler.ErrUnschedulable) {
+		msg := unschedulableMessage(decision)
+		if c := meta.FindStatusCondition(wl.Status.Conditions, ConditionScheduled); c == nil || c.Message != msg {
+			r.Recorder.Event(wl, corev1.EventTypeWarning, ReasonUnschedulable, msg)
+		}
+		meta.SetStatusCondition(&wl.Status.Conditions, metav1.Condition{
+			Type:               ConditionScheduled,
+			Status:             metav1.ConditionFalse,
+			Reason:             ReasonUnschedulable,
+			Message:            msg,
+			ObservedGeneration: wl.Generation,
+		})
+		return false, nil
+	}
+	return true, err
+}
+
+func unschedulableMessage(decision *studyv1beta1.PlacementStatus) string {
+	reasons := make([]string, 0, len(decision.Unschedulable))
+	for _, u := range decision.Unschedulable {
+		reasons = append(reasons, u.Cluster+": "+u.Reason)
+	}
+	if len(reasons) == 0 {
+		return decision.Message
+	}
+	return decision.Message + ": " + strings.Join(reasons, "; ")
+}
+
+// placementCandidates returns the Clusters wl may be placed on, with the
+// resources the other Worlds placed on them request.
+func (r *WorldReconciler) placementCandidates(ctx context.Context, wl *studyv1beta1.World) ([]scheduler.Candidate, error) {
+	cus := new(commonscopeclusterv1beta1.ClusterList)
+	if err := r.Client.List(ctx, cus); err != nil {
+		return nil, err
+	}
+	wls := new(studyv1beta1.WorldList)
+	if err := r.Client.List(ctx, wls); err != nil {
+		return nil, err
+	}
+	reserved := map[string]corev1.ResourceList{}
+	for i := range wls.Items {
+		other := &wls.Items[i]
+		if other.UID == wl.UID || other.Spec.Placement == nil || other.Status.Placement == nil {
+			continue
+		}
+		for _, name := range other.Status.Placement.Clusters {
+			if reserved[name] == nil {
+				reserved[name] = corev1.ResourceList{}
+			}
+			for resource, q := range other.Spec.Placement.Requests {
+				total := reserved[name][resource]
+				total.Add(q)
+				reserved[name][resource] = total
+			}
+		}
+	}
+
+	candidates := make([]scheduler.Candidate, 0, len(cus.Items))
+	for i := range cus.Items {
+		cu := &cus.Items[i]
+		if len(wl.Spec.Clusters) > 0 && !containsString(wl.Spec.Clusters, cu.Name) {
+			continue
+		}
+		candidates = append(candidates, scheduler.Candidate{Cluster: cu, Reserved: reserved[cu.Name]})
+	}
+	return candidates, nil
+}
+
+// rescheduleReason returns why the placement of a Ready World must be
+// decided again, empty when it still holds.
+func (r *WorldReconciler) rescheduleReason(ctx context.Context, wl *studyv1beta1.World) (string, error) {
+	placement := wl.Status.Placement
+	switch {
+	case placement == nil:
+		return "the world was not scheduled", nil
+	case placement.ObservedGeneration != wl.Generation:
+		return "the placement changed", nil
+	}
+	for _, name := range placement.Clusters {
+		cu := new(commonscopeclusterv1beta1.Cluster)
+		if err := r.Client.Get(ctx, client.ObjectKey{Name: name}, cu); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return "", err
+			}
+			return fmt.Sprintf("cluster %s does not exist", name), nil
+		}
+		// 只有集群自身的状况变化才触发重新调度，容量与亲和性只影响新的决定
+		for _, filter := range []scheduler.Filter{scheduler.Terminating, scheduler.Cordoned} {
+			if reason := filter(wl, &scheduler.Candidate{Cluster: cu}); reason != "" {
+				return fmt.Sprintf("cluster %s: %s", name, reason), nil
+			}
+		}
+	}
+	want := wl.Spec.Placement.NumberOfClusters
+	if want == 0 {
+		want = 1
+	}
+	if int32(len(placement.Clusters)) < want {
+		return fmt.Sprintf("placed on %d of %d clusters", len(placement.Clusters), want), nil
+	}
+	return "", nil
+}
+
+// reschedule decides the placement of a Ready World again, keeping the
+// Clusters it still fits on. The status is only written when the decision
+// changed, and a World that fits nowhere goes back to Pending.
+func (r *WorldReconciler) reschedule(ctx context.Context, wl *studyv1beta1.World, reason string) (ctrl.Result, bool, error) {
+	var current []string
+	if wl.Status.Placement != nil {
+		current = wl.Status.Placement.Clusters
+	}
+	previous := wl.Status.Placement.DeepCopy()
+	placed, err := r.schedule(ctx, wl, current)
+	if err != nil {
+		return ctrl.Result{}, false, err
+	}
+	if !placed {
+		return ctrl.Result{RequeueAfter: cordonRetryInterval}, false, r.setPhase(ctx, wl, studyv1beta1.WorldPending, reason)
+	}
+	if previous != nil && previous.ObservedGeneration == wl.Generation && reflect.DeepEqual(previous.Clusters, wl.Status.Placement.Clusters) {
+		// 决定没有变化，不必写入status
+		wl.Status.Placement = previous
+		return ctrl.Result{}, true, nil
+	}
+	r.Recorder.Event(wl, corev1.EventTypeNormal, ReasonRescheduled, fmt.Sprintf("%s, %s", reason, wl.Status.Placement.Message))
+	setScheduled(wl, wl.Status.Placement.Message)
+	if err := r.Client.Status().Update(ctx, wl); err != nil {
+		return ctrl.Result{}, false, err
+	}
+	return ctrl.Result{}, true, nil
+}
+
+// samePlacement reports whether two decisions place and reject the same
+// Clusters for the same reasons, so writing the newer one changes nothing
+// but the scores and time.
+func samePlacement(a, b *studyv1beta1.PlacementStatus) bool {
+	return a != nil && b != nil && a.ObservedGeneration == b.ObservedGeneration && a.Message == b.Message &&
+		reflect.DeepEqual(a.Clusters, b.Clusters) && reflect.DeepEqual(a.Unschedulable, b.Unschedulable)
+}
+
+// worldClusters indexes a World by spec.clusters and the Clusters it is
+// placed on.
+func worldClusters(object client.Object) []string {
+	wl := object.(*studyv1beta1.World)
+	clusters := append([]string(nil), wl.Spec.Clusters...)
+	if wl.Status.Placement != nil {
+		for _, name := range wl.Status.Placement.Clusters {
+			if !containsString(clusters, name) {
+				clusters = append(clusters, name)
+			}
+		}
+	}
+	return clusters
+}
+
+// requestsForCluster enqueues the Worlds bound to a Cluster, so they are
+// rescheduled or evicted once it is cordoned, tainted or deleted.
+func (r *WorldReconciler) requestsForCluster(obj client.Object) []reconcile.Request {
+	wls := new(studyv1beta1.WorldList)
+	if err := r.Client.List(context.Background(), wls, client.MatchingFields{clusterIndexField: obj.GetName()}); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(wls.Items))
+	for i := range wls.Items {
+		if r.Shard.Owns(&wls.Items[i]) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&wls.Items[i])})
+		}
+	}
+	return requests
+}
+
+// clusterSchedulingChanged passes the Cluster events that can move Worlds
+// off a Cluster: cordoning, deletion and spec changes such as taints.
+var clusterSchedulingChanged = predicate.Funcs{
+	CreateFunc: func(event.CreateEvent) bool { return false },
+	DeleteFunc: func(event.DeleteEvent) bool { return true },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		schedulable := func(obj client.Object) bool {
+			cu := obj.(*commonscopeclusterv1beta1.Cluster)
+			return !meta.IsStatusConditionFalse(cu.Status.Conditions, commonscopeclusterv1beta1.ClusterConditionSchedulable)
+		}
+		return schedulable(e.ObjectOld) != schedulable(e.ObjectNew) ||
+			e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() ||
+			(e.ObjectOld.GetDeletionTimestamp() == nil) != (e.ObjectNew.GetDeletionTimestamp() == nil)
+	},
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+func placementCluster(name string, cordoned bool) *commonscopeclusterv1beta1.Cluster {
+	cu := &commonscopeclusterv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if cordoned {
+		cu.Status.Conditions = []metav1.Condition{{
+			Type:   commonscopeclusterv1beta1.ClusterConditionSchedulable,
+			Status: metav1.ConditionFalse,
+			Reason: "Cordoned",
+		}}
+	}
+	return cu
+}
+
+func TestWorldPlacement(t *testing.T) {
+	placed := func(phase studyv1beta1.WorldPhase, clusters ...string) func(*studyv1beta1.World) {
+		return func(wl *studyv1beta1.World) {
+			wl.Status.Phase = phase
+			if clusters != nil {
+				wl.Status.Placement = &studyv1beta1.PlacementStatus{Clusters: clusters, ObservedGeneration: wl.Generation}
+			}
+		}
+	}
+	world := func(mutate func(*studyv1beta1.World)) *studyv1beta1.World {
+		wl := &studyv1beta1.World{
+			ObjectMeta: metav1.ObjectMeta{Name: "earth", Namespace: "default", Generation: 1, Finalizers: []string{studyv1beta1.WorldFinalizer}},
+			Spec: studyv1beta1.WorldSpec{
+				World:     "hello",
+				Placement: &studyv1beta1.Placement{Policy: studyv1beta1.PlacementWeighted, Weights: []studyv1beta1.ClusterWeight{{Cluster: "beta", Weight: 50}}},
+			},
+			Status: studyv1beta1.WorldStatus{War: "existing", SyncTime: metav1.Now()},
+		}
+		mutate(wl)
+		return wl
+	}
+
+	tests := []struct {
+		name      string
+		objs      []client.Object
+		want      ctrl.Result
+		wantPhase studyv1beta1.WorldPhase
+		wantPlace []string
+		reasons   []string
+	}{
+		{
+			name:      "places a pending World",
+			objs:      []client.Object{world(placed(studyv1beta1.WorldPending)), placementCluster("alpha", false), placementCluster("beta", false)},
+			want:      ctrl.Result{Requeue: true},
+			wantPhase: studyv1beta1.WorldProvisioning,
+			wantPlace: []string{"beta"},
+			reasons:   []string{ReasonScheduled},
+		},
+		{
+			name: "only places on the listed Clusters",
+			objs: []client.Object{world(func(wl *studyv1beta1.World) {
+				wl.Spec.Clusters = []string{"alpha"}
+				placed(studyv1beta1.WorldPending)(wl)
+			}), placementCluster("alpha", false), placementCluster("beta", false)},
+			want:      ctrl.Result{Requeue: true},
+			wantPhase: studyv1beta1.WorldProvisioning,
+			wantPlace: []string{"alpha"},
+			reasons:   []string{ReasonScheduled},
+		},
+		{
+			name:      "keeps an unschedulable World pending",
+			objs:      []client.Object{world(placed(studyv1beta1.WorldPending)), placementCluster("alpha", true)},
+			want:      ctrl.Result{RequeueAfter: cordonRetryInterval},
+			wantPhase: studyv1beta1.WorldPending,
+			reasons:   []string{ReasonUnschedulable},
+		},
+		{
+			name:      "keeps a placement that holds",
+			objs:      []client.Object{world(placed(studyv1beta1.WorldReady, "alpha")), placementCluster("alpha", false), placementCluster("beta", false)},
+			wantPhase: studyv1beta1.WorldReady,
+			wantPlace: []string{"alpha"},
+		},
+		{
+			name:      "moves off a cordoned Cluster",
+			objs:      []client.Object{world(placed(studyv1beta1.WorldReady, "beta")), placementCluster("alpha", false), placementCluster("beta", true)},
+			wantPhase: studyv1beta1.WorldReady,
+			wantPlace: []string{"alpha"},
+			reasons:   []string{ReasonRescheduled},
+		},
+		{
+			name:      "moves off a deleted Cluster",
+			objs:      []client.Object{world(placed(studyv1beta1.WorldReady, "gone")), placementCluster("alpha", false)},
+			wantPhase: studyv1beta1.WorldReady,
+			wantPlace: []string{"alpha"},
+			reasons:   []string{ReasonRescheduled},
+		},
+		{
+			name:      "goes back to pending when no Cluster fits",
+			objs:      []client.Object{world(placed(studyv1beta1.WorldReady, "alpha")), placementCluster("alpha", true)},
+			want:      ctrl.Result{RequeueAfter: cordonRetryInterval},
+			wantPhase: studyv1beta1.WorldPending,
+			reasons:   []string{ReasonUnschedulable},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testutil.NewFakeClient(nil, tt.objs...)
+			recorder := testutil.NewEventRecorder()
+			r := &WorldReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+
+			key := client.ObjectKey{Namespace: "default", Name: "earth"}
+			got, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			// Ready Worlds requeue for their next resync.
+			if tt.wantPhase != studyv1beta1.WorldReady && got != tt.want {
+				t.Errorf("Reconcile() = %+v, want %+v", got, tt.want)
+			}
+			if reasons := recorder.Reasons(); len(reasons)+len(tt.reasons) > 0 && !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("event reasons = %v, want %v", reasons, tt.reasons)
+			}
+
+			wl := new(studyv1beta1.World)
+			if err := c.Get(context.Background(), key, wl); err != nil {
+				t.Fatal(err)
+			}
+			if wl.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %s, want %s", wl.Status.Phase, tt.wantPhase)
+			}
+			if got := wl.BoundClusters(); !reflect.DeepEqual(got, tt.wantPlace) {
+				t.Errorf("placed on %v, want %v", got, tt.wantPlace)
+			}
+			scheduled := meta.FindStatusCondition(wl.Status.Conditions, ConditionScheduled)
+			if tt.wantPlace == nil && (scheduled == nil || scheduled.Reason != ReasonUnschedulable) {
+				t.Errorf("Scheduled = %+v, want Unschedulable", scheduled)
+			}
+		})
+	}
+}
+
+func TestRequestsForCluster(t *testing.T) {
+	world := func(name string, clusters ...string) *studyv1beta1.World {
+		return &studyv1beta1.World{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       studyv1beta1.WorldSpec{Placement: &studyv1beta1.Placement{}},
+			Status: studyv1beta1.WorldStatus{Placement: &studyv1beta1.PlacementStatus{
+				Clusters:     clusters,
+				ScheduleTime: metav1.NewTime(time.Now()),
+			}},
+		}
+	}
+	c := testutil.NewFakeClient(nil, world("a", "alpha"), world("b", "alpha", "beta"), world("c", "beta"))
+	if err := c.IndexField(context.Background(), &studyv1beta1.World{}, clusterIndexField, worldClusters); err != nil {
+		t.Fatal(err)
+	}
+	r := &WorldReconciler{Client: c}
+
+	got := r.requestsForCluster(placementCluster("alpha", true))
+	want := []reconcile.Request{
+		{NamespacedName: client.ObjectKey{Namespace: "default", Name: "a"}},
+		{NamespacedName: client.ObjectKey{Namespace: "default", Name: "b"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("requestsForCluster() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,359 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/dryrun"
+	"github/antmoveh/kube-develop-tools/pkg/pause"
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+// TestWorldReconcile runs single reconciles against a fake client. The
+// envtest suite covers the controller end to end.
+func TestWorldReconcile(t *testing.T) {
+	errBoom := errors.New("boom")
+	now := metav1.Now()
+
+	world := func(mutate func(*studyv1beta1.World)) *studyv1beta1.World {
+		wl := &studyv1beta1.World{
+			ObjectMeta: metav1.ObjectMeta{Name: "earth", Namespace: "default"},
+			Spec:       studyv1beta1.WorldSpec{World: "hello"},
+		}
+		if mutate != nil {
+			mutate(wl)
+		}
+		return wl
+	}
+
+	tests := []struct {
+		name    string
+		objs    []client.Object
+		errors  map[testutil.Verb]error
+		want    ctrl.Result
+		wantErr error
+		// check inspects the stored World, which must still exist.
+		check    func(t *testing.T, wl *studyv1beta1.World)
+		wantGone bool
+		reasons  []string
+	}{
+		{
+			name:     "not found",
+			wantGone: true,
+		},
+		{
+			name:    "get fails",
+			objs:    []client.Object{world(nil)},
+			errors:  map[testutil.Verb]error{testutil.VerbGet: errBoom},
+			wantErr: errBoom,
+			check: func(t *testing.T, wl *studyv1beta1.World) {
+				if len(wl.Finalizers) != 0 {
+					t.Errorf("finalizers = %v, want none", wl.Finalizers)
+				}
+			},
+		},
+		{
+			name: "new World is pending",
+			objs: []client.Object{world(nil)},
+			want: ctrl.Result{Requeue: true},
+			check: func(t *testing.T, wl *studyv1beta1.World) {
+				if wl.Status.Phase != studyv1beta1.WorldPending {
+					t.Errorf("phase = %q, want Pending", wl.Status.Phase)
+				}
+				if len(wl.Status.PhaseTransitions) != 1 || wl.Status.PhaseTransitions[0].LastTransitionTime.IsZero() {
+					t.Errorf("phase transitions = %v, want one for Pending", wl.Status.PhaseTransitions)
+				}
+				if len(wl.Finalizers) != 0 {
+					t.Errorf("finalizers = %v, want none until the next reconcile", wl.Finalizers)
+				}
+			},
+		},
+		{
+			name: "adds the finalizer",
+			objs: []client.Object{world(func(wl *studyv1beta1.World) {
+				wl.Status.Phase = studyv1beta1.WorldPending
+			})},
+			want: ctrl.Result{Requeue: true},
+			check: func(t *testing.T, wl *studyv1beta1.World) {
+				if !reflect.DeepEqual(wl.Finalizers, []string{studyv1beta1.WorldFinalizer}) {
+					t.Errorf("finalizers = %v", wl.Finalizers)
+				}
+				if wl.Status.Phase != studyv1beta1.WorldProvisioning {
+					t.Errorf("phase = %q, want Provisioning", wl.Status.Phase)
+				}
+				if wl.Status.War != "" {
+					t.Errorf("war = %q, want it unset until the next reconcile", wl.Status.War)
+				}
+			},
+		},
+		{
+			name: "waits for a cordoned Cluster",
+			objs: []client.Object{
+				world(func(wl *studyv1beta1.World) {
+					wl.Spec.Clusters = []string{"alpha"}
+					wl.Status.Phase = studyv1beta1.WorldPending
+				}),
+				&commonscopeclusterv1beta1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "alpha"},
+					Status: commonscopeclusterv1beta1.ClusterStatus{Conditions: []metav1.Condition{{
+						Type:   commonscopeclusterv1beta1.ClusterConditionSchedulable,
+						Status: metav1.ConditionFalse,
+						Reason: "Cordoned",
+					}}},
+				},
+			},
+			want: ctrl.Result{RequeueAfter: cordonRetryInterval},
+			check: func(t *testing.T, wl *studyv1beta1.World) {
+				if wl.Status.Phase != studyv1beta1.WorldPending || len(wl.Finalizers) != 0 {
+					t.Errorf("phase = %q, finalizers = %v, want Pending without finalizer", wl.Status.Phase, wl.Finalizers)
+				}
+				if !meta.IsStatusConditionFalse(wl.Status.Conditions, ConditionScheduled) {
+					t.Errorf("conditions = %v, want Scheduled false", wl.Status.Conditions)
+				}
+			},
+			reasons: []string{ReasonClusterCordoned},
+		},
+		{
+			name: "patch fails",
+			objs: []client.Object{world(func(wl *studyv1beta1.World) {
+				wl.Status.Phase = studyv1beta1.WorldPending
+			})},
+			errors:  map[testutil.Verb]error{testutil.VerbPatch: errBoom},
+			wantErr: errBoom,
+		},
+		{
+			name: "patch is forbidden",
+			objs: []client.Object{world(func(wl *studyv1beta1.World) {
+				wl.Status.Phase = studyv1beta1.WorldPending
+			})},
+			errors: map[testutil.Verb]error{
+				testutil.VerbPatch: apierrs.NewForbidden(studyv1beta1.GroupVersion.WithResource("worlds").GroupResource(), "earth", errBoom),
+			},
+			want: ctrl.Result{RequeueAfter: failedRetryInterval},
+			check: func(t *testing.T, wl *studyv1beta1.World) {
+				if wl.Status.Phase != studyv1beta1.WorldFailed {
+					t.Errorf("phase = %q, want Failed", wl.Status.Phase)
+				}
+			},
+			reasons: []string{string(studyv1beta1.WorldFailed)},
+		},
+		{
+			name: "retries a failed World",
+			objs: []client.Object{world(func(wl *studyv1beta1.World) {
+				wl.Status.Phase = studyv1beta1.WorldFailed
+				wl.Status.PhaseTransitions = []studyv1beta1.WorldPhaseTransition{{
+					Phase:              studyv1beta1.WorldFailed,
+					LastTransitionTime: metav1.NewTime(now.Add(-2 * failedRetryInterval)),
+				}}
+			})},
+			want: ctrl.Result{Requeue: true},
+			check: func(t *testing.T, wl *studyv1beta1.World) {
+				if wl.Status.Phase != studyv1beta1.WorldPending {
+					t.Errorf("phase = %q, want Pending", wl.Status.Phase)
+				}
+			},
+		},
+		{
+			name: "initializes the status",
+			objs: []client.Object{world(func(wl *studyv1beta1.World) {
+				wl.Finalizers = []string{studyv1beta1.WorldFinalizer}
+				wl.Status.Phase = studyv1beta1.WorldProvisioning
+			})},
+			check: func(t *testing.T, wl *studyv1beta1.World) {
+				if len(wl.Status.War) != 8 {
+					t.Errorf("war = %q, want 8 random characters", wl.Status.War)
+				}
+				if wl.Status.SyncTime.IsZero() {
+					t.Error("syncTime is not set")
+				}
+				if wl.Status.Phase != studyv1beta1.WorldReady {
+					t.Errorf("phase = %q, want Ready", wl.Status.Phase)
+				}
+			},
+		},
+		{
+			name: "keeps an initialized status",
+			objs: []client.Object{world(func(wl *studyv1beta1.World) {
+				wl.Finalizers = []string{studyv1beta1.WorldFinalizer}
+				wl.Status.Phase = studyv1beta1.WorldProvisioning
+				wl.Status.War = "existing"
+			})},
+			check: func(t *testing.T, wl *studyv1beta1.World) {
+				if wl.Status.War != "existing" {
+					t.Errorf("war = %q, want existing", wl.Status.War)
+				}
+			},
+		},
+		{
+			name: "sets up a Ready World without finalizer again",
+			objs: []client.Object{world(func(wl *studyv1beta1.World) {
+				wl.Status.Phase = studyv1beta1.WorldReady
+			})},
+			want: ctrl.Result{Requeue: true},
+			check: func(t *testing.T, wl *studyv1beta1.World) {
+				if wl.Status.Phase != studyv1beta1.WorldPending {
+					t.Errorf("phase = %q, want Pending", wl.Status.Phase)
+				}
+			},
+		},
+		{
+			name: "removes the finalizer on deletion",
+			objs: []client.Object{world(func(wl *studyv1beta1.World) {
+				wl.Finalizers = []string{studyv1beta1.WorldFinalizer}
+				wl.DeletionTimestamp = &now
+			})},
+			wantGone: true,
+		},
+		{
+			name: "paused by annotation",
+			objs: []client.Object{world(func(wl *studyv1beta1.World) {
+				wl.Annotations = map[string]string{pause.Annotation: "true"}
+			})},
+			check: func(t *testing.T, wl *studyv1beta1.World) {
+				if !meta.IsStatusConditionTrue(wl.Status.Conditions, pause.ConditionType) {
+					t.Errorf("conditions = %v, want Paused", wl.Status.Conditions)
+				}
+				if len(wl.Finalizers) != 0 {
+					t.Errorf("finalizers = %v, want none while paused", wl.Finalizers)
+				}
+			},
+			reasons: []string{pause.ConditionType},
+		},
+		{
+			name: "paused status update fails",
+			objs: []client.Object{world(func(wl *studyv1beta1.World) {
+				wl.Annotations = map[string]string{pause.Annotation: "true"}
+			})},
+			errors:  map[testutil.Verb]error{testutil.VerbStatusUpdate: errBoom},
+			wantErr: errBoom,
+			reasons: []string{pause.ConditionType},
+		},
+		{
+			name: "resumed",
+			objs: []client.Object{world(func(wl *studyv1beta1.World) {
+				wl.Finalizers = []string{studyv1beta1.WorldFinalizer}
+				wl.Status.Phase = studyv1beta1.WorldProvisioning
+				meta.SetStatusCondition(&wl.Status.Conditions, metav1.Condition{
+					Type:   pause.ConditionType,
+					Status: metav1.ConditionTrue,
+					Reason: pause.ReasonAnnotation,
+				})
+			})},
+			check: func(t *testing.T, wl *studyv1beta1.World) {
+				if meta.IsStatusConditionTrue(wl.Status.Conditions, pause.ConditionType) {
+					t.Errorf("conditions = %v, want not Paused", wl.Status.Conditions)
+				}
+				if wl.Status.War == "" {
+					t.Error("war is not set after resuming")
+				}
+			},
+			reasons: []string{pause.ReasonResumed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testutil.NewFakeClient(nil, tt.objs...)
+			for verb, err := range tt.errors {
+				c.InjectError(verb, testutil.AlwaysError(err))
+			}
+			recorder := testutil.NewEventRecorder()
+			r := &WorldReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+
+			key := client.ObjectKey{Namespace: "default", Name: "earth"}
+			got, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Reconcile() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Reconcile() = %+v, want %+v", got, tt.want)
+			}
+			if reasons := recorder.Reasons(); len(reasons)+len(tt.reasons) > 0 && !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("event reasons = %v, want %v", reasons, tt.reasons)
+			}
+
+			c.ClearErrors()
+			wl := new(studyv1beta1.World)
+			if err := c.Get(context.Background(), key, wl); err != nil {
+				if !apierrs.IsNotFound(err) {
+					t.Fatal(err)
+				}
+				if !tt.wantGone {
+					t.Fatal("World is gone")
+				}
+				return
+			}
+			if tt.wantGone {
+				t.Fatal("World still exists")
+			}
+			if tt.check != nil {
+				tt.check(t, wl)
+			}
+		})
+	}
+}
+
+// TestWorldReconcileDryRun checks that a World whose writes are only
+// recorded is not requeued over and over to walk through phases that never
+// persist.
+func TestWorldReconcileDryRun(t *testing.T) {
+	wl := &studyv1beta1.World{
+		ObjectMeta: metav1.ObjectMeta{Name: "earth", Namespace: "default"},
+		Spec:       studyv1beta1.WorldSpec{World: "hello"},
+	}
+	backend := testutil.NewFakeClient(nil, wl)
+	store := dryrun.NewStore()
+	r := &WorldReconciler{
+		Client:   dryrun.NewClient(backend, store),
+		Scheme:   backend.Scheme(),
+		Recorder: testutil.NewEventRecorder(),
+		DryRun:   true,
+	}
+
+	for i := 0; i < 3; i++ {
+		res, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(wl)})
+		if err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		if res.Requeue {
+			t.Fatalf("reconcile #%d requeued a dry-run World", i+1)
+		}
+	}
+
+	got := new(studyv1beta1.World)
+	if err := backend.Get(context.Background(), client.ObjectKeyFromObject(wl), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.Phase != "" {
+		t.Errorf("phase = %q, want the dry-run write not persisted", got.Status.Phase)
+	}
+	if summary := store.Summary(); len(summary) != 1 || len(summary[0].Changes) == 0 {
+		t.Errorf("summary = %+v, want the status updates of the World", summary)
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+const (
+	// DefaultSyncInterval is the resync interval of Worlds without
+	// spec.syncInterval when the reconciler does not set one.
+	DefaultSyncInterval = 10 * time.Minute
+	// DefaultStaleAfter is the number of failed resyncs in a row after which
+	// a World is marked stale when the reconciler does not set one.
+	DefaultStaleAfter = 3
+
+	// ConditionStale is true when the World could not be resynced for a while.
+	ConditionStale = "Stale"
+	// ReasonSynced is the reason of a World that resynced successfully.
+	ReasonSynced = "Synced"
+	// ReasonResyncFailed is the reason of a World whose resync failed.
+	ReasonResyncFailed = "ResyncFailed"
+
+	// resyncRetryInterval is how long the first retry of a failed resync
+	// waits. Every further failure doubles it, up to the sync interval.
+	resyncRetryInterval = 10 * time.Second
+)
+
+// syncInterval returns the resync interval of wl.
+func (r *WorldReconciler) syncInterval(wl *studyv1beta1.World) time.Duration {
+	if wl.Spec.SyncInterval != nil && wl.Spec.SyncInterval.Duration > 0 {
+		// 没有准入校验拦截过短的间隔，这里兜底
+		if wl.Spec.SyncInterval.Duration < studyv1beta1.MinSyncInterval {
+			return studyv1beta1.MinSyncInterval
+		}
+		return wl.Spec.SyncInterval.Duration
+	}
+	if r.SyncInterval > 0 {
+		return r.SyncInterval
+	}
+	return DefaultSyncInterval
+}
+
+func (r *WorldReconciler) staleAfter() int32 {
+	if r.StaleAfter > 0 {
+		return r.StaleAfter
+	}
+	return DefaultStaleAfter
+}
+
+// nextResync returns how long to wait before resyncing wl, 0 when it is due.
+func (r *WorldReconciler) nextResync(wl *studyv1beta1.World, now time.Time) time.Duration {
+	interval := r.syncInterval(wl)
+	last, delay := wl.Status.SyncTime.Time, interval
+	if wl.Status.SyncFailures > 0 && wl.Status.LastSyncAttemptTime != nil {
+		last, delay = wl.Status.LastSyncAttemptTime.Time, resyncRetryInterval<<uint(wl.Status.SyncFailures-1)
+		if delay <= 0 || delay > interval {
+			delay = interval
+		}
+	}
+	if remaining := last.Add(delay).Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// resync re-validates a Ready World once its sync interval passed, refreshes
+// SyncTime and marks the World stale when resyncing keeps failing. The
+// requeue is jittered so Worlds created together do not resync together.
+func (r *WorldReconciler) resync(ctx context.Context, wl *studyv1beta1.World) (ctrl.Result, error) {
+	now := metav1.Now()
+	if remaining := r.nextResync(wl, now.Time); remaining > 0 {
+		return ctrl.Result{RequeueAfter: r.jitter(remaining)}, nil
+	}
+
+	wl.Status.LastSyncAttemptTime = &now
+	syncErr := r.validateWorld(ctx, wl)
+	if syncErr == nil {
+		wl.Status.SyncTime = now
+		wl.Status.SyncFailures = 0
+		if wl.Status.War == "" {
+			wl.Status.War = rand.String(8)
+		}
+		meta.SetStatusCondition(&wl.Status.Conditions, metav1.Condition{
+			Type:               ConditionStale,
+			Status:             metav1.ConditionFalse,
+			Reason:             ReasonSynced,
+			Message:            "the World was resynced",
+			ObservedGeneration: wl.Generation,
+		})
+	} else {
+		wl.Status.SyncFailures++
+		r.Recorder.Event(wl, corev1.EventTypeWarning, ReasonResyncFailed, syncErr.Error())
+		if wl.Status.SyncFailures >= r.staleAfter() {
+			meta.SetStatusCondition(&wl.Status.Conditions, metav1.Condition{
+				Type:               ConditionStale,
+				Status:             metav1.ConditionTrue,
+				Reason:             ReasonResyncFailed,
+				Message:            fmt.Sprintf("%d resyncs failed in a row, last: %v", wl.Status.SyncFailures, syncErr),
+				ObservedGeneration: wl.Generation,
+			})
+		}
+	}
+	if err := r.Client.Status().Update(ctx, wl); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if syncErr != nil {
+		log.FromContext(ctx).Error(syncErr, "resync failed", "failures", wl.Status.SyncFailures)
+	}
+	return ctrl.Result{RequeueAfter: r.jitter(r.nextResync(wl, now.Time))}, nil
+}
+
+func (r *WorldReconciler) jitter(d time.Duration) time.Duration {
+	if r.SyncJitter <= 0 {
+		return d
+	}
+	return wait.Jitter(d, r.SyncJitter)
+}
+
+// validateWorld checks that wl passes World.Validate, which no admission
+// webhook enforces, and that the Clusters it is bound to exist.
+func (r *WorldReconciler) validateWorld(ctx context.Context, wl *studyv1beta1.World) error {
+	if err := wl.Validate(); err != nil {
+		return err
+	}
+	for _, name := range wl.Spec.Clusters {
+		cu := new(commonscopeclusterv1beta1.Cluster)
+		if err := r.Client.Get(ctx, client.ObjectKey{Name: name}, cu); err != nil {
+			if apierrs.IsNotFound(err) {
+				return fmt.Errorf("cluster %q does not exist", name)
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+func TestWorldResync(t *testing.T) {
+	ago := func(d time.Duration) metav1.Time { return metav1.NewTime(time.Now().Add(-d)) }
+	cluster := &commonscopeclusterv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "alpha"}}
+
+	ready := func(mutate func(*studyv1beta1.World)) *studyv1beta1.World {
+		wl := &studyv1beta1.World{
+			ObjectMeta: metav1.ObjectMeta{Name: "earth", Namespace: "default", Finalizers: []string{studyv1beta1.WorldFinalizer}},
+			Spec:       studyv1beta1.WorldSpec{World: "hello", Clusters: []string{"alpha"}},
+			Status: studyv1beta1.WorldStatus{
+				Phase:    studyv1beta1.WorldReady,
+				War:      "existing",
+				SyncTime: ago(time.Hour),
+			},
+		}
+		if mutate != nil {
+			mutate(wl)
+		}
+		return wl
+	}
+
+	tests := []struct {
+		name         string
+		objs         []client.Object
+		minRequeue   time.Duration
+		maxRequeue   time.Duration
+		wantSynced   bool
+		wantFailures int32
+		wantStale    metav1.ConditionStatus
+	}{
+		{
+			name:       "not due yet",
+			objs:       []client.Object{cluster, ready(func(wl *studyv1beta1.World) { wl.Status.SyncTime = ago(time.Minute) })},
+			minRequeue: 8 * time.Minute,
+			maxRequeue: 9 * time.Minute,
+		},
+		{
+			name: "per-World interval",
+			objs: []client.Object{cluster, ready(func(wl *studyv1beta1.World) {
+				wl.Spec.SyncInterval = &metav1.Duration{Duration: 30 * time.Minute}
+				wl.Status.SyncTime = ago(time.Minute)
+			})},
+			minRequeue: 29 * time.Minute,
+			maxRequeue: 30 * time.Minute,
+		},
+		{
+			name:       "resyncs",
+			objs:       []client.Object{cluster, ready(nil)},
+			minRequeue: 10 * time.Minute,
+			maxRequeue: 10 * time.Minute,
+			wantSynced: true,
+			wantStale:  metav1.ConditionFalse,
+		},
+		{
+			name:         "missing cluster",
+			objs:         []client.Object{ready(nil)},
+			minRequeue:   resyncRetryInterval,
+			maxRequeue:   resyncRetryInterval,
+			wantFailures: 1,
+		},
+		{
+			name: "invalid World",
+			objs: []client.Object{cluster, ready(func(wl *studyv1beta1.World) {
+				wl.Spec.SyncInterval = &metav1.Duration{Duration: time.Second}
+			})},
+			minRequeue:   studyv1beta1.MinSyncInterval,
+			maxRequeue:   studyv1beta1.MinSyncInterval,
+			wantFailures: 1,
+		},
+		{
+			name: "stale after repeated failures",
+			objs: []client.Object{ready(func(wl *studyv1beta1.World) {
+				wl.Status.SyncFailures = 2
+				wl.Status.LastSyncAttemptTime = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+			})},
+			minRequeue:   4 * resyncRetryInterval,
+			maxRequeue:   4 * resyncRetryInterval,
+			wantFailures: 3,
+			wantStale:    metav1.ConditionTrue,
+		},
+		{
+			name: "backs off after a failure",
+			objs: []client.Object{ready(func(wl *studyv1beta1.World) {
+				wl.Status.SyncFailures = 2
+				wl.Status.LastSyncAttemptTime = &metav1.Time{Time: time.Now()}
+			})},
+			minRequeue:   resyncRetryInterval,
+			maxRequeue:   2 * resyncRetryInterval,
+			wantFailures: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testutil.NewFakeClient(nil, tt.objs...)
+			r := &WorldReconciler{Client: c, Scheme: c.Scheme(), Recorder: testutil.NewEventRecorder()}
+
+			key := client.ObjectKey{Namespace: "default", Name: "earth"}
+			got, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if got.RequeueAfter < tt.minRequeue-time.Second || got.RequeueAfter > tt.maxRequeue {
+				t.Errorf("RequeueAfter = %s, want between %s and %s", got.RequeueAfter, tt.minRequeue, tt.maxRequeue)
+			}
+
+			wl := new(studyv1beta1.World)
+			if err := c.Get(context.Background(), key, wl); err != nil {
+				t.Fatal(err)
+			}
+			if synced := time.Since(wl.Status.SyncTime.Time) < time.Minute; synced != tt.wantSynced {
+				t.Errorf("syncTime = %s, want refreshed %v", wl.Status.SyncTime, tt.wantSynced)
+			}
+			if wl.Status.SyncFailures != tt.wantFailures {
+				t.Errorf("syncFailures = %d, want %d", wl.Status.SyncFailures, tt.wantFailures)
+			}
+			stale := metav1.ConditionStatus("")
+			if cond := meta.FindStatusCondition(wl.Status.Conditions, ConditionStale); cond != nil {
+				stale = cond.Status
+			}
+			if stale != tt.wantStale {
+				t.Errorf("Stale condition = %q, want %q", stale, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestWorldResyncJitter(t *testing.T) {
+	r := &WorldReconciler{SyncJitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if d := r.jitter(time.Minute); d < time.Minute || d > 90*time.Second {
+			t.Fatalf("jitter(1m) = %s, want between 1m and 1m30s", d)
+		}
+	}
+	if d := (&WorldReconciler{}).jitter(time.Minute); d != time.Minute {
+		t.Errorf("jitter(1m) without jitter = %s", d)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/scheduler"
+)
+
+const (
+	// ReasonClusterTainted is the reason of a World waiting for Clusters
+	// with taints it does not tolerate.
+	ReasonClusterTainted = "ClusterTainted"
+	// ReasonEvicted is the event of a World evicted from a Cluster.
+	ReasonEvicted = "Evicted"
+	// ReasonWorldEvicted is the event of a Cluster a World was evicted from.
+	ReasonWorldEvicted = "WorldEvicted"
+	// ReasonReadmitted is the event of a World that may run on a Cluster it
+	// was evicted from again.
+	ReasonReadmitted = "Readmitted"
+)
+
+// taintedClusters returns the Clusters of spec.clusters with NoSchedule or
+// NoExecute taints wl does not tolerate, with the taint.
+func (r *WorldReconciler) taintedClusters(ctx context.Context, wl *studyv1beta1.World) ([]string, error) {
+	var tainted []string
+	for _, name := range wl.Spec.Clusters {
+		cu := new(commonscopeclusterv1beta1.Cluster)
+		if err := r.Client.Get(ctx, client.ObjectKey{Name: name}, cu); err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				continue
+			}
+			return nil, err
+		}
+		if taint, ok := scheduler.UntoleratedTaint(cu.Spec.Taints, wl.Spec.Tolerations,
+			corev1.TaintEffectNoSchedule, corev1.TaintEffectNoExecute); ok {
+			tainted = append(tainted, fmt.Sprintf("%s (%s)", name, taint.ToString()))
+		}
+	}
+	return tainted, nil
+}
+
+// evict evicts wl from the Clusters it is bound to whose NoExecute taints it
+// no longer tolerates, with an event on both. Worlds bound by spec.clusters
+// record the eviction in their status and are readmitted once the taint is
+// gone; Worlds with a placement are rescheduled by the caller. It returns
+// the Clusters wl was evicted from, when the next toleration runs out and
+// whether the status changed.
+func (r *WorldReconciler) evict(ctx context.Context, wl *studyv1beta1.World) ([]string, time.Duration, bool, error) {
+	now := metav1.Now()
+	var evicted []string
+	var next time.Duration
+	changed := false
+
+	if wl.Spec.Placement == nil {
+		kept := wl.Status.Evictions[:0]
+		for _, e := range wl.Status.Evictions {
+			cu, err := r.getCluster(ctx, e.Cluster)
+			if err != nil {
+				return nil, 0, false, err
+			}
+			if cu != nil && containsString(wl.Spec.Clusters, e.Cluster) {
+				if at, _, ok := scheduler.EvictionTime(cu.Spec.Taints, wl.Spec.Tolerations); ok && !now.Time.Before(at) {
+					kept = append(kept, e)
+					continue
+				}
+				r.Recorder.Event(wl, corev1.EventTypeNormal, ReasonReadmitted, fmt.Sprintf("may run on cluster %s again", e.Cluster))
+			}
+			changed = true
+		}
+		wl.Status.Evictions = kept
+		if len(wl.Status.Evictions) == 0 {
+			wl.Status.Evictions = nil
+		}
+	}
+
+	for _, name := range wl.BoundClusters() {
+		cu, err := r.getCluster(ctx, name)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		if cu == nil {
+			continue
+		}
+		at, taint, ok := scheduler.EvictionTime(cu.Spec.Taints, wl.Spec.Tolerations)
+		if !ok {
+			continue
+		}
+		if remaining := at.Sub(now.Time); remaining > 0 {
+			if next == 0 || remaining < next {
+				next = remaining
+			}
+			continue
+		}
+		evicted = append(evicted, name)
+		r.Recorder.Event(wl, corev1.EventTypeWarning, ReasonEvicted,
+			fmt.Sprintf("evicted from cluster %s by the taint %s", name, taint.ToString()))
+		r.Recorder.Event(cu, corev1.EventTypeWarning, ReasonWorldEvicted,
+			fmt.Sprintf("evicted world %s/%s by the taint %s", wl.Namespace, wl.Name, taint.ToString()))
+		if wl.Spec.Placement == nil {
+			wl.Status.Evictions = append(wl.Status.Evictions, studyv1beta1.ClusterEviction{Cluster: name, Taint: taint, Time: now})
+			changed = true
+		}
+	}
+	return evicted, next, changed, nil
+}
+
+// evictionMessage describes the Clusters a World was evicted from.
+func evictionMessage(evicted []string) string {
+	return "evicted from clusters " + strings.Join(evicted, ", ")
+}
+
+// getCluster returns the Cluster name, nil when it does not exist.
+func (r *WorldReconciler) getCluster(ctx context.Context, name string) (*commonscopeclusterv1beta1.Cluster, error) {
+	cu := new(commonscopeclusterv1beta1.Cluster)
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: name}, cu); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	return cu, nil
+}
+
+// sooner returns the shorter of two requeue delays, zero meaning none.
+func sooner(a, b time.Duration) time.Duration {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+func TestWorldTaints(t *testing.T) {
+	hourAgo := metav1.NewTime(time.Now().Add(-time.Hour))
+	minuteAgo := metav1.NewTime(time.Now().Add(-time.Minute))
+	tainted := func(name string, effect corev1.TaintEffect, added *metav1.Time) *commonscopeclusterv1beta1.Cluster {
+		cu := placementCluster(name, false)
+		cu.Spec.Taints = []corev1.Taint{{Key: "maintenance", Effect: effect, TimeAdded: added}}
+		return cu
+	}
+	grace := int64(300)
+	tolerateFor := []corev1.Toleration{{Key: "maintenance", Operator: corev1.TolerationOpExists, TolerationSeconds: &grace}}
+
+	world := func(phase studyv1beta1.WorldPhase, mutate func(*studyv1beta1.World)) *studyv1beta1.World {
+		wl := &studyv1beta1.World{
+			ObjectMeta: metav1.ObjectMeta{Name: "earth", Namespace: "default", Generation: 1, Finalizers: []string{studyv1beta1.WorldFinalizer}},
+			Spec:       studyv1beta1.WorldSpec{World: "hello", Clusters: []string{"alpha", "beta"}},
+			Status:     studyv1beta1.WorldStatus{Phase: phase, War: "existing", SyncTime: metav1.Now()},
+		}
+		if mutate != nil {
+			mutate(wl)
+		}
+		return wl
+	}
+
+	tests := []struct {
+		name      string
+		objs      []client.Object
+		wantPhase studyv1beta1.WorldPhase
+		wantBound []string
+		// wantRequeue bounds the requeue of a World in its grace period.
+		wantRequeue time.Duration
+		reasons     []string
+	}{
+		{
+			name:      "waits for a NoSchedule taint",
+			objs:      []client.Object{world(studyv1beta1.WorldPending, nil), tainted("alpha", corev1.TaintEffectNoSchedule, nil), placementCluster("beta", false)},
+			wantPhase: studyv1beta1.WorldPending,
+			wantBound: []string{"alpha", "beta"},
+			reasons:   []string{ReasonClusterTainted},
+		},
+		{
+			name: "binds when the taint is tolerated",
+			objs: []client.Object{
+				world(studyv1beta1.WorldPending, func(wl *studyv1beta1.World) {
+					wl.Spec.Tolerations = []corev1.Toleration{{Key: "maintenance", Operator: corev1.TolerationOpExists}}
+				}),
+				tainted("alpha", corev1.TaintEffectNoSchedule, nil), placementCluster("beta", false),
+			},
+			wantPhase: studyv1beta1.WorldProvisioning,
+			wantBound: []string{"alpha", "beta"},
+		},
+		{
+			name:      "NoSchedule keeps a Ready World",
+			objs:      []client.Object{world(studyv1beta1.WorldReady, nil), tainted("alpha", corev1.TaintEffectNoSchedule, nil), placementCluster("beta", false)},
+			wantPhase: studyv1beta1.WorldReady,
+			wantBound: []string{"alpha", "beta"},
+		},
+		{
+			name:      "NoExecute evicts a Ready World",
+			objs:      []client.Object{world(studyv1beta1.WorldReady, nil), tainted("alpha", corev1.TaintEffectNoExecute, &hourAgo), placementCluster("beta", false)},
+			wantPhase: studyv1beta1.WorldReady,
+			wantBound: []string{"beta"},
+			reasons:   []string{ReasonEvicted, ReasonWorldEvicted},
+		},
+		{
+			name:        "tolerates NoExecute for the grace period",
+			objs:        []client.Object{world(studyv1beta1.WorldReady, func(wl *studyv1beta1.World) { wl.Spec.Tolerations = tolerateFor }), tainted("alpha", corev1.TaintEffectNoExecute, &minuteAgo), placementCluster("beta", false)},
+			wantPhase:   studyv1beta1.WorldReady,
+			wantBound:   []string{"alpha", "beta"},
+			wantRequeue: 4 * time.Minute,
+		},
+		{
+			name:      "evicts once the grace period ran out",
+			objs:      []client.Object{world(studyv1beta1.WorldReady, func(wl *studyv1beta1.World) { wl.Spec.Tolerations = tolerateFor }), tainted("alpha", corev1.TaintEffectNoExecute, &hourAgo), placementCluster("beta", false)},
+			wantPhase: studyv1beta1.WorldReady,
+			wantBound: []string{"beta"},
+			reasons:   []string{ReasonEvicted, ReasonWorldEvicted},
+		},
+		{
+			name: "goes back to pending when evicted everywhere",
+			objs: []client.Object{
+				world(studyv1beta1.WorldReady, func(wl *studyv1beta1.World) { wl.Spec.Clusters = []string{"alpha"} }),
+				tainted("alpha", corev1.TaintEffectNoExecute, &hourAgo),
+			},
+			wantPhase: studyv1beta1.WorldPending,
+			reasons:   []string{ReasonEvicted, ReasonWorldEvicted},
+		},
+		{
+			name: "readmits once the taint is gone",
+			objs: []client.Object{
+				world(studyv1beta1.WorldReady, func(wl *studyv1beta1.World) {
+					wl.Status.Evictions = []studyv1beta1.ClusterEviction{{Cluster: "alpha", Time: hourAgo}}
+				}),
+				placementCluster("alpha", false), placementCluster("beta", false),
+			},
+			wantPhase: studyv1beta1.WorldReady,
+			wantBound: []string{"alpha", "beta"},
+			reasons:   []string{ReasonReadmitted},
+		},
+		{
+			name: "reschedules a placed World",
+			objs: []client.Object{
+				world(studyv1beta1.WorldReady, func(wl *studyv1beta1.World) {
+					wl.Spec.Clusters = nil
+					wl.Spec.Placement = &studyv1beta1.Placement{}
+					wl.Status.Placement = &studyv1beta1.PlacementStatus{Clusters: []string{"alpha"}, ObservedGeneration: 1}
+				}),
+				tainted("alpha", corev1.TaintEffectNoExecute, &hourAgo), placementCluster("beta", false),
+			},
+			wantPhase: studyv1beta1.WorldReady,
+			wantBound: []string{"beta"},
+			reasons:   []string{ReasonEvicted, ReasonWorldEvicted, ReasonRescheduled},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testutil.NewFakeClient(nil, tt.objs...)
+			recorder := testutil.NewEventRecorder()
+			r := &WorldReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+
+			key := client.ObjectKey{Namespace: "default", Name: "earth"}
+			got, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if tt.wantRequeue > 0 && (got.RequeueAfter <= 0 || got.RequeueAfter > tt.wantRequeue) {
+				t.Errorf("requeue after = %s, want at most %s", got.RequeueAfter, tt.wantRequeue)
+			}
+			if reasons := recorder.Reasons(); len(reasons)+len(tt.reasons) > 0 && !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("event reasons = %v, want %v", reasons, tt.reasons)
+			}
+
+			wl := new(studyv1beta1.World)
+			if err := c.Get(context.Background(), key, wl); err != nil {
+				t.Fatal(err)
+			}
+			if wl.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %s, want %s", wl.Status.Phase, tt.wantPhase)
+			}
+			if got := wl.BoundClusters(); !reflect.DeepEqual(got, tt.wantBound) {
+				t.Errorf("bound to %v, want %v; evictions %+v", got, tt.wantBound, wl.Status.Evictions)
+			}
+			if tt.wantPhase == studyv1beta1.WorldPending && meta.IsStatusConditionTrue(wl.Status.Conditions, ConditionScheduled) {
+				t.Errorf("conditions = %v, want not Scheduled", wl.Status.Conditions)
+			}
+		})
+	}
+}
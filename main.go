@@ -17,8 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
 
@@ -26,10 +31,17 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
@@ -37,6 +49,19 @@ import (
 	studyv1beta2 "github/antmoveh/kube-develop-tools/apis/study/v1beta2"
 	"github/antmoveh/kube-develop-tools/controllers"
 	commonscopeclustercontrollers "github/antmoveh/kube-develop-tools/controllers/common.scope.cluster"
+	"github/antmoveh/kube-develop-tools/pkg/agent"
+	"github/antmoveh/kube-develop-tools/pkg/audit"
+	"github/antmoveh/kube-develop-tools/pkg/credentials"
+	"github/antmoveh/kube-develop-tools/pkg/debug"
+	"github/antmoveh/kube-develop-tools/pkg/dryrun"
+	"github/antmoveh/kube-develop-tools/pkg/election"
+	"github/antmoveh/kube-develop-tools/pkg/health"
+	"github/antmoveh/kube-develop-tools/pkg/inventory"
+	"github/antmoveh/kube-develop-tools/pkg/logging"
+	"github/antmoveh/kube-develop-tools/pkg/pause"
+	"github/antmoveh/kube-develop-tools/pkg/remote"
+	"github/antmoveh/kube-develop-tools/pkg/sharding"
+	"github/antmoveh/kube-develop-tools/pkg/tracing"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -58,47 +83,455 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var pauseConfigMap string
+	var dryRun bool
+	var leaderElectionNamespace, leaderElectionID, leaderElectionLock string
+	var leaseDuration, renewDeadline, retryPeriod time.Duration
+	var shards int
+	var shardIdentity, shardGroup string
+	var worldSyncInterval time.Duration
+	var worldSyncJitter float64
+	var worldStaleAfter int
+	var auditLog string
+	var auditMaxSize, auditMaxBackups int
+	var auditSampleRate float64
+	var traceOpts tracing.Options
+	var logLevelConfigMap string
+	var debugAddr, debugTokenFile string
+	var clusterProbeInterval, clusterHealthWindow time.Duration
+	var clusterCordonScore, clusterUncordonScore int
+	var credentialExpiryWarning time.Duration
+	var clusterInventoryInterval time.Duration
+	var clusterInventoryMaxItems int
+	var agentNamespace string
+	var credentialsNamespace string
+	var clusterWatch bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
-	opts := zap.Options{
-		Development: true,
-	}
+	flag.StringVar(&leaderElectionNamespace, "leader-election-namespace", "",
+		"The namespace of the leader election lock. Defaults to the namespace the manager runs in.")
+	flag.StringVar(&leaderElectionID, "leader-election-id", "fd454bdf.example.cn",
+		"The name of the leader election lock.")
+	flag.StringVar(&leaderElectionLock, "leader-election-resource-lock", resourcelock.ConfigMapsLeasesResourceLock,
+		"The resource lock used for leader election, leases or configmapsleases.")
+	flag.DurationVar(&leaseDuration, "leader-election-lease-duration", 15*time.Second,
+		"How long non-leader replicas wait before trying to take over an unrenewed lease.")
+	flag.DurationVar(&renewDeadline, "leader-election-renew-deadline", 10*time.Second,
+		"How long the leader keeps trying to renew its lease before it gives up leading.")
+	flag.DurationVar(&retryPeriod, "leader-election-retry-period", 2*time.Second,
+		"How long replicas wait between leader election attempts.")
+	flag.IntVar(&shards, "shards", 0,
+		"Split the Worlds into this many shards and share them with the other replicas of the shard group "+
+			"instead of electing a leader. The member leases live in the leader election namespace and use the "+
+			"leader election lease duration and retry period. The Cluster controller is not sharded; it runs on "+
+			"the replica that holds the <leader-election-id>-clusters lease. 0 disables sharding.")
+	flag.StringVar(&shardIdentity, "shard-identity", "",
+		"The unique name of this replica in the shard group. Defaults to the host name.")
+	flag.StringVar(&shardGroup, "shard-group", "kube-develop-tools",
+		"The name of the replicas that share the shards.")
+	flag.StringVar(&pauseConfigMap, "pause-configmap", "kube-develop-tools-system/kube-develop-tools-pause",
+		"The namespace/name of the ConfigMap that pauses all reconciliation when its \"paused\" key is \"true\". "+
+			"Only the ConfigMaps of its namespace are cached, and the shipped RBAC only lets the manager read "+
+			"ConfigMaps in its own namespace. Set to empty to disable the global pause.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Log the writes the controllers would make instead of sending them. "+
+			"Pending changes are listed on the /dryrun path of the metrics endpoint.")
+	flag.DurationVar(&worldSyncInterval, "world-sync-interval", controllers.DefaultSyncInterval,
+		"How often Worlds without spec.syncInterval are resynced.")
+	flag.Float64Var(&worldSyncJitter, "world-sync-jitter", 0.1,
+		"The largest fraction of the sync interval added at random to each World resync.")
+	flag.IntVar(&worldStaleAfter, "world-stale-after", controllers.DefaultStaleAfter,
+		"The number of failed World resyncs in a row after which the World is marked Stale.")
+	flag.StringVar(&auditLog, "audit-log", "",
+		"The file the controllers write an audit record of every write to, one JSON object per line. "+
+			"\"-\" writes to stdout, empty disables auditing.")
+	flag.IntVar(&auditMaxSize, "audit-log-max-size", 100,
+		"The size in megabytes after which the audit log file is rotated.")
+	flag.IntVar(&auditMaxBackups, "audit-log-max-backups", 5,
+		"The number of rotated audit log files kept.")
+	flag.Float64Var(&auditSampleRate, "audit-sample-rate", 1,
+		"The share of successful writes that are audited, from 0 to 1. Failed writes and deletions are always audited.")
+	flag.StringVar(&traceOpts.Endpoint, "otlp-endpoint", "",
+		"The host:port of the OTLP gRPC collector the reconcile traces are sent to. Empty disables tracing.")
+	flag.BoolVar(&traceOpts.Insecure, "otlp-insecure", false,
+		"Connect to the OTLP collector without TLS.")
+	flag.Float64Var(&traceOpts.SampleRatio, "trace-sample-ratio", 1,
+		"The share of reconciles that are traced, from 0 to 1.")
+	flag.StringVar(&traceOpts.ServiceName, "trace-service-name", "kube-develop-tools",
+		"The service name the traces are reported under.")
+	flag.StringVar(&logLevelConfigMap, "log-level-configmap", "",
+		"The namespace/name of the ConfigMap that sets the log level per controller, keyed by controller name "+
+			"or \"default\". The levels are listed on the /loglevel path of the metrics endpoint and can be "+
+			"changed with PUT on /debug/loglevel of the debug endpoints.")
+	flag.StringVar(&debugAddr, "debug-bind-address", "",
+		"The address the debug endpoints (pprof, workqueues, cache counts, configuration and log levels under /debug/) "+
+			"bind to. Empty disables them.")
+	flag.StringVar(&debugTokenFile, "debug-token-file", "",
+		"A file holding the bearer token the debug endpoints require. Without it they are open to anyone "+
+			"who can reach the debug address.")
+	flag.DurationVar(&clusterProbeInterval, "cluster-probe-interval", health.DefaultInterval,
+		"How often the Clusters are probed for their health score. 0 disables health scoring and cordoning.")
+	flag.DurationVar(&clusterHealthWindow, "cluster-health-window", health.DefaultWindow,
+		"How far back probes count towards the health score of a Cluster.")
+	flag.IntVar(&clusterCordonScore, "cluster-cordon-score", health.DefaultCordonBelow,
+		"The health score from 0 to 100 under which a Cluster is cordoned and no new Worlds are bound to it.")
+	flag.IntVar(&clusterUncordonScore, "cluster-uncordon-score", health.DefaultUncordonAbove,
+		"The health score a cordoned Cluster must reach again to be uncordoned. Must be above --cluster-cordon-score.")
+	flag.DurationVar(&credentialExpiryWarning, "credential-expiry-warning", credentials.DefaultExpiryWarning,
+		"How long before their Cluster credentials expire to start warning.")
+	flag.DurationVar(&clusterInventoryInterval, "cluster-inventory-interval", inventory.DefaultInterval,
+		"How often the inventory of the Clusters is collected. 0 disables inventory collection.")
+	flag.IntVar(&clusterInventoryMaxItems, "cluster-inventory-max-items", inventory.DefaultMaxItems,
+		"The most Nodes, CRDs and Namespaces listed in the inventory of a Cluster. Counts and totals are not capped.")
+	flag.BoolVar(&clusterWatch, "cluster-watch", true,
+		"Watch the Nodes and Worlds of the Clusters and score their health from what the watches see instead of "+
+			"probing the API server. Without the watches only Clusters without credentials, which stand for the local "+
+			"cluster, are scored; the health of the others stays unknown.")
+	flag.StringVar(&agentNamespace, "agent-namespace", agent.DefaultNamespace,
+		"The namespace the agent RBAC is installed in when a Cluster joins.")
+	flag.StringVar(&credentialsNamespace, "credentials-namespace", "kube-develop-tools-system",
+		"The only namespace the kubeconfig Secrets of the Clusters may be in. Secrets are cached and watched there only.")
+	// Production defaults: JSON, info and sampling. --zap-devel switches back
+	// to the console encoder.
+	opts := zap.Options{}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if err := election.ValidateResourceLock(leaderElectionLock); err != nil {
+		setupLog.Error(err, "invalid --leader-election-resource-lock")
+		os.Exit(1)
+	}
+	if err := election.ValidateTimings(leaseDuration, renewDeadline, retryPeriod); err != nil {
+		setupLog.Error(err, "invalid leader election timings")
+		os.Exit(1)
+	}
+	if leaderElectionNamespace == "" {
+		leaderElectionNamespace = election.InClusterNamespace()
+	}
+	if shards > 0 && enableLeaderElection {
+		setupLog.Error(nil, "--shards and --leader-elect are exclusive, sharded replicas all run at once")
+		os.Exit(1)
+	}
+	if dryRun {
+		// A dry-run manager runs next to production and must not take its lease.
+		leaderElectionID = "dry-run." + leaderElectionID
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), traceOpts)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			setupLog.Error(err, "unable to flush traces")
+		}
+	}()
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "fd454bdf.example.cn",
+		Scheme:                     scheme,
+		MetricsBindAddress:         metricsAddr,
+		Port:                       9443,
+		HealthProbeBindAddress:     probeAddr,
+		LeaderElection:             enableLeaderElection,
+		LeaderElectionNamespace:    leaderElectionNamespace,
+		LeaderElectionID:           leaderElectionID,
+		LeaderElectionResourceLock: leaderElectionLock,
+		LeaseDuration:              &leaseDuration,
+		RenewDeadline:              &renewDeadline,
+		RetryPeriod:                &retryPeriod,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	leaderTracker := &election.Tracker{
+		Elected:  mgr.Elected(),
+		Reader:   mgr.GetAPIReader(),
+		Recorder: mgr.GetEventRecorderFor("leader-election"),
+	}
+	if enableLeaderElection {
+		leaderTracker.Lease = types.NamespacedName{Namespace: leaderElectionNamespace, Name: leaderElectionID}
+	}
+	if err := mgr.Add(leaderTracker); err != nil {
+		setupLog.Error(err, "unable to set up leader election tracking")
+		os.Exit(1)
+	}
+	if err := mgr.AddMetricsExtraHandler("/leader", leaderTracker); err != nil {
+		setupLog.Error(err, "unable to set up leader endpoint")
+		os.Exit(1)
+	}
+
+	var shardCoordinator *sharding.Coordinator
+	if shards > 0 {
+		if shardIdentity == "" {
+			if shardIdentity, err = os.Hostname(); err != nil {
+				setupLog.Error(err, "unable to determine the shard identity")
+				os.Exit(1)
+			}
+		}
+		if dryRun {
+			shardGroup = "dry-run." + shardGroup
+		}
+		shardCoordinator = &sharding.Coordinator{
+			Client:        mgr.GetClient(),
+			Reader:        mgr.GetAPIReader(),
+			Namespace:     leaderElectionNamespace,
+			Group:         shardGroup,
+			Identity:      shardIdentity,
+			Shards:        shards,
+			LeaseDuration: leaseDuration,
+			RenewPeriod:   retryPeriod,
+		}
+		if err := shardCoordinator.Validate(); err != nil {
+			setupLog.Error(err, "invalid sharding settings")
+			os.Exit(1)
+		}
+		if err := mgr.Add(shardCoordinator); err != nil {
+			setupLog.Error(err, "unable to set up sharding")
+			os.Exit(1)
+		}
+		if err := mgr.AddMetricsExtraHandler("/shards", shardCoordinator); err != nil {
+			setupLog.Error(err, "unable to set up shards endpoint")
+			os.Exit(1)
+		}
+	}
+
+	// 分片时管理器不做选主，Cluster一侧由单独的租约保证只有一个副本运行
+	clusterMgr := ctrl.Manager(mgr)
+	if shards > 0 {
+		clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			setupLog.Error(err, "unable to create the Cluster election client")
+			os.Exit(1)
+		}
+		lock, err := resourcelock.New(leaderElectionLock, leaderElectionNamespace, leaderElectionID+"-clusters",
+			clientset.CoreV1(), clientset.CoordinationV1(), resourcelock.ResourceLockConfig{
+				Identity:      shardIdentity,
+				EventRecorder: mgr.GetEventRecorderFor("cluster-election"),
+			})
+		if err != nil {
+			setupLog.Error(err, "unable to create the Cluster election lock")
+			os.Exit(1)
+		}
+		clusterGate := &election.Gate{
+			Lock:          lock,
+			LeaseDuration: leaseDuration,
+			RenewDeadline: renewDeadline,
+			RetryPeriod:   retryPeriod,
+		}
+		if err := mgr.Add(clusterGate); err != nil {
+			setupLog.Error(err, "unable to set up the Cluster election")
+			os.Exit(1)
+		}
+		clusterMgr = clusterGate.Manager(mgr)
+	}
+
+	cl := mgr.GetClient()
+	worldRecorder := mgr.GetEventRecorderFor("world-recorder")
+	clusterRecorder := mgr.GetEventRecorderFor("cluster-recorder")
+	var agentInstaller agent.Installer = agent.RBAC{Namespace: agentNamespace}
+	if dryRun {
+		store := dryrun.NewStore()
+		cl = dryrun.NewClient(cl, store)
+		// agent的RBAC直接写入成员集群，不经过dry-run客户端
+		agentInstaller = dryrun.NewInstaller(store, mgr.GetScheme(), agent.RBAC{Namespace: agentNamespace}.Objects)
+		worldRecorder = dryrun.NewEventRecorder(store, mgr.GetScheme())
+		clusterRecorder = dryrun.NewEventRecorder(store, mgr.GetScheme())
+		if err := mgr.AddMetricsExtraHandler("/dryrun", store); err != nil {
+			setupLog.Error(err, "unable to set up dry-run summary endpoint")
+			os.Exit(1)
+		}
+		setupLog.Info("dry-run mode enabled, writes will not be persisted")
+	}
+
+	cl = tracing.NewClient(cl)
+	worldClient, clusterClient := cl, cl
+	if auditLog != "" {
+		if auditSampleRate < 0 || auditSampleRate > 1 {
+			setupLog.Error(nil, "invalid --audit-sample-rate, expected a value from 0 to 1", "value", auditSampleRate)
+			os.Exit(1)
+		}
+		auditor := &audit.Auditor{Sink: os.Stdout, SampleRate: auditSampleRate}
+		if auditLog != "-" {
+			sink := &audit.RotatingFile{
+				Path:       auditLog,
+				MaxSize:    int64(auditMaxSize) << 20,
+				MaxBackups: auditMaxBackups,
+			}
+			defer sink.Close()
+			auditor.Sink = sink
+		}
+		worldAudit, clusterAudit := audit.NewClient(cl, auditor, "world"), audit.NewClient(cl, auditor, "cluster")
+		worldAudit.DryRun, clusterAudit.DryRun = dryRun, dryRun
+		worldClient, clusterClient = worldAudit, clusterAudit
+	}
+
+	// The pause and log level ConfigMaps and the credential Secrets are
+	// watched through caches of their namespaces only, not through an
+	// informer over every ConfigMap or Secret.
+	configCaches := &namespacedCaches{mgr: mgr}
+	logLevels := logging.NewLevels(opts)
+	if logLevelConfigMap != "" {
+		parts := strings.SplitN(logLevelConfigMap, "/", 2)
+		if len(parts) != 2 {
+			setupLog.Error(nil, "invalid --log-level-configmap, expected namespace/name", "value", logLevelConfigMap)
+			os.Exit(1)
+		}
+		logLevels.ConfigMap = types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+		if logLevels.Informers, err = configCaches.get(parts[0]); err != nil {
+			setupLog.Error(err, "unable to set up the log level ConfigMap cache")
+			os.Exit(1)
+		}
+	}
+	if err := mgr.Add(logLevels); err != nil {
+		setupLog.Error(err, "unable to set up log levels")
+		os.Exit(1)
+	}
+	// 指标端口没有认证，只提供只读的日志级别
+	if err := mgr.AddMetricsExtraHandler("/loglevel", logLevels.ReadOnly()); err != nil {
+		setupLog.Error(err, "unable to set up log level endpoint")
+		os.Exit(1)
+	}
+
+	var queues *debug.Queues
+	if debugAddr != "" {
+		queues = debug.NewQueues()
+		config := map[string]string{}
+		flag.VisitAll(func(f *flag.Flag) { config[f.Name] = f.Value.String() })
+		debugServer := &debug.Server{
+			Addr:   debugAddr,
+			Queues: queues,
+			Cache:  mgr.GetCache(),
+			Scheme: mgr.GetScheme(),
+			Lists: []client.ObjectList{
+				&studyv1beta1.WorldList{},
+				&commonscopeclusterv1beta1.ClusterList{},
+				&corev1.PodList{},
+			},
+			Config: config,
+			Handlers: map[string]http.Handler{
+				"/debug/loglevel": logLevels,
+			},
+		}
+		if debugTokenFile != "" {
+			token, err := ioutil.ReadFile(debugTokenFile)
+			if err != nil {
+				setupLog.Error(err, "unable to read --debug-token-file")
+				os.Exit(1)
+			}
+			debugServer.Token = strings.TrimSpace(string(token))
+		} else {
+			setupLog.Info("the debug endpoints are not protected, set --debug-token-file to require a token")
+		}
+		if err := mgr.Add(debugServer); err != nil {
+			setupLog.Error(err, "unable to set up the debug server")
+			os.Exit(1)
+		}
+	}
+
+	var remoteWatcher *remote.Watcher
+	var prober health.Prober = &health.LocalProber{Reader: mgr.GetClient(), APIReader: mgr.GetAPIReader()}
+	if clusterWatch {
+		remoteWatcher = remote.NewWatcher(mgr.GetConfig(), nil)
+		if clusterProbeInterval > 0 {
+			remoteWatcher.LatencyInterval = clusterProbeInterval
+		}
+		if err := clusterMgr.Add(remoteWatcher); err != nil {
+			setupLog.Error(err, "unable to set up the cluster watches")
+			os.Exit(1)
+		}
+		prober = remoteWatcher
+	}
+
+	var healthMonitor *health.Monitor
+	if clusterProbeInterval > 0 {
+		if clusterCordonScore < 0 || clusterUncordonScore > 100 || clusterCordonScore >= clusterUncordonScore {
+			setupLog.Error(nil, "invalid cluster health scores, expected 0 <= --cluster-cordon-score < --cluster-uncordon-score <= 100",
+				"cordon", clusterCordonScore, "uncordon", clusterUncordonScore)
+			os.Exit(1)
+		}
+		healthMonitor = health.NewMonitor(prober)
+		healthMonitor.Interval = clusterProbeInterval
+		healthMonitor.Window = clusterHealthWindow
+		healthMonitor.CordonBelow = int32(clusterCordonScore)
+		healthMonitor.UncordonAbove = int32(clusterUncordonScore)
+	}
+
+	var inventoryCollector *inventory.Collector
+	if clusterInventoryInterval > 0 {
+		inventoryCollector = inventory.NewCollector(inventory.APISource{}, mgr.GetConfig())
+		inventoryCollector.Interval = clusterInventoryInterval
+		inventoryCollector.MaxItems = clusterInventoryMaxItems
+	}
+
+	pauseChecker := &pause.Checker{Reader: mgr.GetClient()}
+	if pauseConfigMap != "" {
+		parts := strings.SplitN(pauseConfigMap, "/", 2)
+		if len(parts) != 2 {
+			setupLog.Error(nil, "invalid --pause-configmap, expected namespace/name", "value", pauseConfigMap)
+			os.Exit(1)
+		}
+		pauseChecker.ConfigMap = types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+		configCache, err := configCaches.get(parts[0])
+		if err != nil {
+			setupLog.Error(err, "unable to set up the pause ConfigMap cache")
+			os.Exit(1)
+		}
+		pauseChecker.Reader, pauseChecker.Cache = configCache, configCache
+	}
+
+	secretCache, err := configCaches.get(credentialsNamespace)
+	if err != nil {
+		setupLog.Error(err, "unable to set up the credentials Secret cache")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.WorldReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:       worldClient,
+		Scheme:       mgr.GetScheme(),
+		Recorder:     worldRecorder,
+		Pause:        pauseChecker,
+		SyncInterval: worldSyncInterval,
+		SyncJitter:   worldSyncJitter,
+		StaleAfter:   int32(worldStaleAfter),
+		Shard:        shardCoordinator,
+		DryRun:       dryRun,
+		LogLevels:    logLevels,
+		Queues:       queues,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "World")
 		os.Exit(1)
 	}
 	if err = (&commonscopeclustercontrollers.ClusterReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("cluster-recorder"),
-	}).SetupWithManager(mgr); err != nil {
+		Client:    clusterClient,
+		Scheme:    mgr.GetScheme(),
+		Recorder:  clusterRecorder,
+		Pause:     pauseChecker,
+		LogLevels: logLevels,
+		Queues:    queues,
+		Health:    healthMonitor,
+
+		ExpiryWarning:        credentialExpiryWarning,
+		CredentialsNamespace: credentialsNamespace,
+		Secrets:              secretCache,
+		Inventory:            inventoryCollector,
+		Agent:                agentInstaller,
+		Remote:               remoteWatcher,
+	}).SetupWithManager(clusterMgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Cluster")
 		os.Exit(1)
 	}
+	// The World webhook reuses the traced conversion webhook registered first.
+	mgr.GetWebhookServer().Register("/convert", &tracing.ConversionWebhook{})
 	if err = (&studyv1beta1.World{}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "World")
 		os.Exit(1)
@@ -120,3 +553,32 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// namespacedCaches hands out one cache per namespace for the objects the
+// manager only needs from a few namespaces, and starts them with it.
+type namespacedCaches struct {
+	mgr    ctrl.Manager
+	caches map[string]cache.Cache
+}
+
+func (n *namespacedCaches) get(namespace string) (cache.Cache, error) {
+	if c, ok := n.caches[namespace]; ok {
+		return c, nil
+	}
+	// 以cluster的形式加入manager，缓存会在所有副本上、在控制器之前启动
+	c, err := cluster.New(n.mgr.GetConfig(), func(o *cluster.Options) {
+		o.Scheme = n.mgr.GetScheme()
+		o.Namespace = namespace
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := n.mgr.Add(c); err != nil {
+		return nil, err
+	}
+	if n.caches == nil {
+		n.caches = map[string]cache.Cache{}
+	}
+	n.caches[namespace] = c.GetCache()
+	return c.GetCache(), nil
+}
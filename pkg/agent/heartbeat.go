@@ -0,0 +1,111 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+// DefaultHeartbeatInterval is how often the agent reports to the hub.
+const DefaultHeartbeatInterval = 30 * time.Second
+
+// Heartbeat reports the Nodes and applied Worlds of the member cluster into
+// the status of its Cluster on the hub.
+type Heartbeat struct {
+	// Hub writes the Cluster status.
+	Hub client.Client
+	// Local lists the Nodes and Worlds of the member cluster.
+	Local client.Reader
+	// Cluster is the name of the Cluster the agent runs in.
+	Cluster string
+	// Interval is how often to report, DefaultHeartbeatInterval when zero.
+	Interval time.Duration
+
+	now func() time.Time
+}
+
+// Start implements manager.Runnable. Failed heartbeats are logged and
+// retried at the next interval.
+func (h *Heartbeat) Start(ctx context.Context) error {
+	interval := h.Interval
+	if interval <= 0 {
+		interval = DefaultHeartbeatInterval
+	}
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := h.beat(ctx); err != nil {
+			log.FromContext(ctx).Error(err, "heartbeat failed", "cluster", h.Cluster)
+		}
+	}, interval)
+	return nil
+}
+
+// beat writes status.agent of the Cluster. It patches nothing else, so it
+// never overwrites what the hub controller writes.
+func (h *Heartbeat) beat(ctx context.Context) error {
+	status := commonv1beta1.ClusterAgentStatus{LastHeartbeatTime: metav1.NewTime(h.clock())}
+	nodes := &corev1.NodeList{}
+	if err := h.Local.List(ctx, nodes); err != nil {
+		return err
+	}
+	for i := range nodes.Items {
+		status.Nodes++
+		if nodeReady(&nodes.Items[i]) {
+			status.ReadyNodes++
+		}
+	}
+	worlds := &studyv1beta1.WorldList{}
+	if err := h.Local.List(ctx, worlds, client.MatchingLabels{ClusterLabel: h.Cluster}); err != nil {
+		return err
+	}
+	status.Worlds = int32(len(worlds.Items))
+
+	patch, err := json.Marshal(map[string]interface{}{"status": map[string]interface{}{"agent": status}})
+	if err != nil {
+		return err
+	}
+	cu := &commonv1beta1.Cluster{}
+	cu.Name = h.Cluster
+	return h.Hub.Status().Patch(ctx, cu, client.RawPatch(types.MergePatchType, patch))
+}
+
+func (h *Heartbeat) clock() time.Time {
+	if h.now != nil {
+		return h.now()
+	}
+	return time.Now()
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+func TestHeartbeat(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	node := func(name string, ready corev1.ConditionStatus) *corev1.Node {
+		n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		n.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}}
+		return n
+	}
+	world := func(name string, labels map[string]string) *studyv1beta1.World {
+		return &studyv1beta1.World{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Labels: labels}}
+	}
+
+	cu := &commonv1beta1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "alpha"},
+		Spec:       commonv1beta1.ClusterSpec{Mode: commonv1beta1.ClusterPull},
+		Status:     commonv1beta1.ClusterStatus{Phase: commonv1beta1.ClusterJoining},
+	}
+	hub := testutil.NewFakeClient(nil, cu)
+	local := testutil.NewFakeClient(nil,
+		node("n1", corev1.ConditionTrue),
+		node("n2", corev1.ConditionFalse),
+		world("earth", map[string]string{ClusterLabel: "alpha"}),
+		world("mars", nil),
+	)
+	h := &Heartbeat{Hub: hub, Local: local, Cluster: "alpha", now: func() time.Time { return now }}
+	if err := h.beat(ctx); err != nil {
+		t.Fatalf("beat() error = %v", err)
+	}
+
+	got := new(commonv1beta1.Cluster)
+	if err := hub.Get(ctx, client.ObjectKey{Name: "alpha"}, got); err != nil {
+		t.Fatal(err)
+	}
+	a := got.Status.Agent
+	if a == nil || !a.LastHeartbeatTime.Time.Equal(now) || a.Nodes != 2 || a.ReadyNodes != 1 || a.Worlds != 1 {
+		t.Errorf("status.agent = %+v", a)
+	}
+	// 心跳只写status.agent
+	if got.Status.Phase != commonv1beta1.ClusterJoining {
+		t.Errorf("phase = %s, want it untouched", got.Status.Phase)
+	}
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+)
+
+// NewHubCache returns the cache of the hub for the agent of cluster. It
+// holds the Cluster of the agent only and the Worlds of namespaces, every
+// namespace when empty, so the hub can grant the agent its own Cluster by
+// resourceNames and the Worlds by namespaced Roles.
+func NewHubCache(cluster string, namespaces []string) cache.NewCacheFunc {
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		opts.SelectorsByObject = cache.SelectorsByObject{
+			&commonv1beta1.Cluster{}: {Field: fields.OneTermEqualSelector("metadata.name", cluster)},
+		}
+		if len(namespaces) == 0 {
+			return cache.New(config, opts)
+		}
+		return cache.MultiNamespacedCacheBuilder(namespaces)(config, opts)
+	}
+}
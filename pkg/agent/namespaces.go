@@ -0,0 +1,97 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+// targetNamespace returns the namespace wl is propagated to in the Cluster
+// cu, following the mapping of wl or else the one of cu.
+func targetNamespace(wl *studyv1beta1.World, cu *commonv1beta1.Cluster) string {
+	mapping := wl.Spec.NamespaceMapping
+	if mapping == nil {
+		mapping = cu.Spec.NamespaceMapping
+	}
+	return mapping.Map(wl.Namespace, cu.Name)
+}
+
+// ensureNamespace creates the namespace name in the member cluster when it
+// is missing, labeled as created by the agent so it can be removed again.
+func (r *WorldReconciler) ensureNamespace(ctx context.Context, name string) error {
+	ns := new(corev1.Namespace)
+	err := r.Local.Get(ctx, client.ObjectKey{Name: name}, ns)
+	switch {
+	case err == nil && ns.DeletionTimestamp != nil:
+		return fmt.Errorf("namespace %s is being deleted", name)
+	case err == nil:
+		return nil
+	case !apierrs.IsNotFound(err):
+		return err
+	}
+	ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   name,
+		Labels: map[string]string{ManagedByLabel: managedBy, ClusterLabel: r.Cluster},
+	}}
+	if err := r.Local.Create(ctx, ns); err != nil && !apierrs.IsAlreadyExists(err) {
+		return err
+	}
+	log.FromContext(ctx).Info("namespace created", "namespace", name)
+	return nil
+}
+
+// cleanupNamespace deletes the namespace name once no World is left in it,
+// if the agent created it. removed is the World just deleted from it. The
+// Worlds are listed through LocalReader, since the cache may not hold a World
+// just created in the namespace yet.
+func (r *WorldReconciler) cleanupNamespace(ctx context.Context, name, removed string) error {
+	ns := new(corev1.Namespace)
+	if err := r.Local.Get(ctx, client.ObjectKey{Name: name}, ns); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if ns.DeletionTimestamp != nil || ns.Labels[ManagedByLabel] != managedBy || ns.Labels[ClusterLabel] != r.Cluster {
+		return nil
+	}
+	var reader client.Reader = r.Local
+	if r.LocalReader != nil {
+		reader = r.LocalReader
+	}
+	worlds := &studyv1beta1.WorldList{}
+	if err := reader.List(ctx, worlds, client.InNamespace(name)); err != nil {
+		return err
+	}
+	for i := range worlds.Items {
+		if w := &worlds.Items[i]; w.Name != removed && w.DeletionTimestamp == nil {
+			return nil
+		}
+	}
+	if err := r.Local.Delete(ctx, ns); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	log.FromContext(ctx).Info("namespace removed", "namespace", name)
+	return nil
+}
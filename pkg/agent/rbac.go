@@ -0,0 +1,169 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package agent holds what the agent running in member Clusters needs.
+package agent
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+const (
+	// DefaultNamespace is where the agent runs in member Clusters.
+	DefaultNamespace = "kube-develop-tools-agent"
+	// Name names the ServiceAccount, ClusterRole and ClusterRoleBinding of
+	// the agent.
+	Name = "kube-develop-tools-agent"
+	// ManagedByLabel marks the objects installed for the agent.
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	managedBy      = "kube-develop-tools"
+	// DefaultTimeout bounds installing or removing the agent RBAC.
+	DefaultTimeout = 30 * time.Second
+)
+
+// Installer installs and removes what the agent needs in a member Cluster.
+type Installer interface {
+	Install(ctx context.Context, cfg *rest.Config) error
+	Uninstall(ctx context.Context, cfg *rest.Config) error
+}
+
+// RBAC installs the Namespace, ServiceAccount, ClusterRole and
+// ClusterRoleBinding of the agent.
+type RBAC struct {
+	// Namespace is where the agent runs, DefaultNamespace when empty.
+	Namespace string
+	// Timeout bounds an Install or Uninstall, DefaultTimeout when zero.
+	Timeout time.Duration
+}
+
+var _ Installer = RBAC{}
+
+// Install creates or updates the agent RBAC in the Cluster of cfg.
+func (a RBAC) Install(ctx context.Context, cfg *rest.Config) error {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout())
+	defer cancel()
+	c, err := a.client(cfg)
+	if err != nil {
+		return err
+	}
+	return a.install(ctx, c)
+}
+
+// Uninstall deletes the agent RBAC from the Cluster of cfg, Namespace last.
+func (a RBAC) Uninstall(ctx context.Context, cfg *rest.Config) error {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout())
+	defer cancel()
+	c, err := a.client(cfg)
+	if err != nil {
+		return err
+	}
+	return a.uninstall(ctx, c)
+}
+
+// client connects to the Cluster of cfg. Building the REST mapper reads the
+// discovery API without a context, so the timeout is set on the client too.
+func (a RBAC) client(cfg *rest.Config) (client.Client, error) {
+	cfg = rest.CopyConfig(cfg)
+	cfg.Timeout = a.timeout()
+	return client.New(cfg, client.Options{Scheme: clientgoscheme.Scheme})
+}
+
+func (a RBAC) install(ctx context.Context, c client.Client) error {
+	for _, want := range a.Objects() {
+		obj := want.DeepCopyObject().(client.Object)
+		if _, err := controllerutil.CreateOrUpdate(ctx, c, obj, func() error {
+			obj.SetLabels(want.GetLabels())
+			switch o := obj.(type) {
+			case *rbacv1.ClusterRole:
+				o.Rules = want.(*rbacv1.ClusterRole).Rules
+			case *rbacv1.ClusterRoleBinding:
+				// roleRef不可修改，名字固定所以不会变化
+				o.RoleRef = want.(*rbacv1.ClusterRoleBinding).RoleRef
+				o.Subjects = want.(*rbacv1.ClusterRoleBinding).Subjects
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a RBAC) uninstall(ctx context.Context, c client.Client) error {
+	objs := a.Objects()
+	for i := len(objs) - 1; i >= 0; i-- {
+		if err := c.Delete(ctx, objs[i]); err != nil && !apierrs.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Objects are what Install creates, in order.
+func (a RBAC) Objects() []client.Object {
+	namespace := a.namespace()
+	labels := map[string]string{ManagedByLabel: managedBy}
+	meta := func(namespace string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: Name, Namespace: namespace, Labels: labels}
+	}
+	return []client.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace, Labels: labels}},
+		&corev1.ServiceAccount{ObjectMeta: meta(namespace)},
+		&rbacv1.ClusterRole{
+			ObjectMeta: meta(""),
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+				{APIGroups: []string{""}, Resources: []string{"nodes", "pods"}, Verbs: []string{"get", "list", "watch"}},
+				{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "patch"}},
+				{APIGroups: []string{"apiextensions.k8s.io"}, Resources: []string{"customresourcedefinitions"}, Verbs: []string{"get", "list", "watch"}},
+				{APIGroups: []string{studyv1beta1.GroupVersion.Group}, Resources: []string{"worlds"}, Verbs: []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+				{APIGroups: []string{"coordination.k8s.io"}, Resources: []string{"leases"}, Verbs: []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+			},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: meta(""),
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: Name},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: Name, Namespace: namespace}},
+		},
+	}
+}
+
+func (a RBAC) namespace() string {
+	if a.Namespace != "" {
+		return a.Namespace
+	}
+	return DefaultNamespace
+}
+
+func (a RBAC) timeout() time.Duration {
+	if a.Timeout > 0 {
+		return a.Timeout
+	}
+	return DefaultTimeout
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+func TestRBAC(t *testing.T) {
+	ctx := context.Background()
+	a := RBAC{Namespace: "agents"}
+	stale := &rbacv1.ClusterRole{}
+	stale.Name = Name
+	c := testutil.NewFakeClient(nil, stale)
+
+	// 重复安装是幂等的，并会修正已有对象
+	for i := 0; i < 2; i++ {
+		if err := a.install(ctx, c); err != nil {
+			t.Fatalf("install #%d: %v", i+1, err)
+		}
+	}
+	for _, obj := range a.Objects() {
+		got := obj.DeepCopyObject().(client.Object)
+		if err := c.Get(ctx, client.ObjectKeyFromObject(obj), got); err != nil {
+			t.Fatalf("%T %s not installed: %v", obj, obj.GetName(), err)
+		}
+		if got.GetLabels()[ManagedByLabel] != managedBy {
+			t.Errorf("%T labels = %v", got, got.GetLabels())
+		}
+	}
+	role := new(rbacv1.ClusterRole)
+	if err := c.Get(ctx, client.ObjectKey{Name: Name}, role); err != nil {
+		t.Fatal(err)
+	}
+	if len(role.Rules) == 0 {
+		t.Error("stale ClusterRole was not updated")
+	}
+	binding := new(rbacv1.ClusterRoleBinding)
+	if err := c.Get(ctx, client.ObjectKey{Name: Name}, binding); err != nil {
+		t.Fatal(err)
+	}
+	if len(binding.Subjects) != 1 || binding.Subjects[0].Namespace != "agents" {
+		t.Errorf("subjects = %+v, want the agent ServiceAccount in agents", binding.Subjects)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := a.uninstall(ctx, c); err != nil {
+			t.Fatalf("uninstall #%d: %v", i+1, err)
+		}
+	}
+	for _, obj := range a.Objects() {
+		if err := c.Get(ctx, client.ObjectKeyFromObject(obj), obj.DeepCopyObject().(client.Object)); !apierrs.IsNotFound(err) {
+			t.Errorf("%T %s still there: %v", obj, obj.GetName(), err)
+		}
+	}
+}
+
+func TestRBACInstallTimeout(t *testing.T) {
+	// 成员集群的API server不响应
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	a := RBAC{Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	if err := a.Install(context.Background(), &rest.Config{Host: server.URL}); err == nil {
+		t.Error("Install() succeeded against a hanging API server")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Install() returned after %s, want it bounded by the timeout", elapsed)
+	}
+}
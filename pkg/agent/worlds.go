@@ -0,0 +1,214 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+const (
+	// ClusterLabel marks the Worlds and namespaces the agent created with
+	// the name of its Cluster.
+	ClusterLabel = "example.cn/cluster"
+	// WorldNamespaceLabel holds the namespace on the hub of the World an
+	// applied copy was made from, which the namespace mapping may rename.
+	WorldNamespaceLabel = "example.cn/world-namespace"
+)
+
+// WorldReconciler applies the Worlds of the hub that run on its Cluster to
+// the member cluster the agent runs in, removes the ones that stopped
+// running there, and reports how that went into the World status on the
+// hub. The copies go to the namespace the namespace mapping names, which is
+// created when missing and removed with the last World in it.
+type WorldReconciler struct {
+	// Hub reads the Worlds from the hub and writes their status.
+	Hub client.Client
+	// Local applies the Worlds in the member cluster.
+	Local client.Client
+	// LocalReader reads the member cluster without a cache, to find the
+	// Worlds left in a namespace before removing it. Local when nil.
+	LocalReader client.Reader
+	// Cluster is the name of the Cluster the agent runs in.
+	Cluster string
+}
+
+// Reconcile applies or removes the World of req.
+func (r *WorldReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	wl := new(studyv1beta1.World)
+	if err := r.Hub.Get(ctx, req.NamespacedName, wl); err != nil {
+		if !apierrs.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.remove(ctx, req.NamespacedName, "")
+	}
+
+	orig := wl.DeepCopy()
+	var applyErr error
+	if wl.RunsOn(r.Cluster) {
+		status := studyv1beta1.WorldClusterStatus{Cluster: r.Cluster, Applied: true, ObservedGeneration: wl.Generation}
+		status.Namespace, applyErr = r.apply(ctx, wl)
+		if applyErr != nil {
+			status.Applied, status.Message = false, applyErr.Error()
+		}
+		if !wl.SetClusterStatus(status) {
+			return ctrl.Result{}, applyErr
+		}
+	} else {
+		if err := r.remove(ctx, req.NamespacedName, ""); err != nil {
+			return ctrl.Result{}, err
+		}
+		if !wl.RemoveClusterStatus(r.Cluster) {
+			return ctrl.Result{}, nil
+		}
+	}
+	// 多个成员集群的agent会同时写status.clusters，用乐观锁避免互相覆盖
+	if err := r.Hub.Status().Patch(ctx, wl, client.MergeFromWithOptions(orig, client.MergeFromWithOptimisticLock{})); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, applyErr
+}
+
+// apply creates or updates the copy of wl in the member cluster and returns
+// the namespace it went to. The copy is bound to no Cluster. Copies left in
+// other namespaces by an earlier mapping are removed.
+func (r *WorldReconciler) apply(ctx context.Context, wl *studyv1beta1.World) (string, error) {
+	cu := new(commonv1beta1.Cluster)
+	if err := r.Hub.Get(ctx, client.ObjectKey{Name: r.Cluster}, cu); err != nil {
+		return "", err
+	}
+	namespace := targetNamespace(wl, cu)
+	if err := r.ensureNamespace(ctx, namespace); err != nil {
+		return namespace, err
+	}
+
+	local := &studyv1beta1.World{}
+	local.Namespace, local.Name = namespace, wl.Name
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Local, local, func() error {
+		if local.ResourceVersion != "" && local.Labels[ClusterLabel] != r.Cluster {
+			return fmt.Errorf("world %s/%s exists in the cluster and is not managed by the agent", local.Namespace, local.Name)
+		}
+		// 不同namespace的World映射到同一个namespace时，不抢占已有的副本
+		if owner, ok := local.Labels[WorldNamespaceLabel]; local.ResourceVersion != "" && ok && owner != wl.Namespace {
+			return fmt.Errorf("world %s/%s in the cluster is the copy of world %s/%s on the hub", local.Namespace, local.Name, owner, local.Name)
+		}
+		if local.Labels == nil {
+			local.Labels = map[string]string{}
+		}
+		local.Labels[ClusterLabel] = r.Cluster
+		local.Labels[WorldNamespaceLabel] = wl.Namespace
+		local.Spec = *wl.Spec.DeepCopy()
+		local.Spec.Clusters, local.Spec.Placement, local.Spec.Tolerations = nil, nil, nil
+		local.Spec.NamespaceMapping = nil
+		return nil
+	})
+	if err != nil {
+		return namespace, err
+	}
+	if result != controllerutil.OperationResultNone {
+		log.FromContext(ctx).Info("world applied", "namespace", namespace, "operation", result)
+	}
+	return namespace, r.remove(ctx, client.ObjectKeyFromObject(wl), namespace)
+}
+
+// remove deletes the copies of the World key the agent applied, except the
+// one in keep, and the namespaces they leave empty.
+func (r *WorldReconciler) remove(ctx context.Context, key types.NamespacedName, keep string) error {
+	copies := &studyv1beta1.WorldList{}
+	if err := r.Local.List(ctx, copies, client.MatchingLabels{ClusterLabel: r.Cluster, WorldNamespaceLabel: key.Namespace}); err != nil {
+		return err
+	}
+	for i := range copies.Items {
+		local := &copies.Items[i]
+		if local.Name != key.Name || local.Namespace == keep {
+			continue
+		}
+		if err := r.Local.Delete(ctx, local); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		log.FromContext(ctx).Info("world removed", "namespace", local.Namespace)
+		if err := r.cleanupNamespace(ctx, local.Namespace, local.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requestsForCopy enqueues the World on the hub an applied copy was made
+// from.
+func (r *WorldReconciler) requestsForCopy(obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+	if labels[ClusterLabel] != r.Cluster {
+		return nil
+	}
+	namespace, ok := labels[WorldNamespaceLabel]
+	if !ok {
+		namespace = obj.GetNamespace()
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: obj.GetName()}}}
+}
+
+// requestsForCluster enqueues the Worlds running on the Cluster of the
+// agent when it changes, so a new namespace mapping takes effect.
+func (r *WorldReconciler) requestsForCluster(obj client.Object) []reconcile.Request {
+	if obj.GetName() != r.Cluster {
+		return nil
+	}
+	worlds := &studyv1beta1.WorldList{}
+	if err := r.Hub.List(context.Background(), worlds); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range worlds.Items {
+		if wl := &worlds.Items[i]; wl.RunsOn(r.Cluster) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(wl)})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager watches the Worlds and the Cluster of the agent on hub,
+// and the copies in the member cluster of mgr so changes made to them are
+// undone.
+func (r *WorldReconciler) SetupWithManager(mgr ctrl.Manager, hub cluster.Cluster) error {
+	c, err := controller.New("agent-world", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+	if err := c.Watch(source.NewKindWithCache(&studyv1beta1.World{}, hub.GetCache()), &handler.EnqueueRequestForObject{}); err != nil {
+		return err
+	}
+	if err := c.Watch(source.NewKindWithCache(&commonv1beta1.Cluster{}, hub.GetCache()), handler.EnqueueRequestsFromMapFunc(r.requestsForCluster)); err != nil {
+		return err
+	}
+	return c.Watch(&source.Kind{Type: &studyv1beta1.World{}}, handler.EnqueueRequestsFromMapFunc(r.requestsForCopy))
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/testutil"
+)
+
+func TestWorldReconciler(t *testing.T) {
+	key := client.ObjectKey{Namespace: "default", Name: "earth"}
+	world := func(phase studyv1beta1.WorldPhase, clusters ...string) *studyv1beta1.World {
+		return &studyv1beta1.World{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Spec: studyv1beta1.WorldSpec{
+				World:       "hello",
+				Clusters:    clusters,
+				Tolerations: []corev1.Toleration{{Key: "maintenance", Operator: corev1.TolerationOpExists}},
+			},
+			Status: studyv1beta1.WorldStatus{Phase: phase},
+		}
+	}
+	mapped := func(w *studyv1beta1.World, m *commonv1beta1.NamespaceMapping) *studyv1beta1.World {
+		w.Spec.NamespaceMapping = m
+		return w
+	}
+	reported := func(w *studyv1beta1.World) *studyv1beta1.World {
+		w.Status.Clusters = []studyv1beta1.WorldClusterStatus{{Cluster: "alpha", Applied: true}}
+		return w
+	}
+	cluster := func(m *commonv1beta1.NamespaceMapping) *commonv1beta1.Cluster {
+		return &commonv1beta1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "alpha"},
+			Spec:       commonv1beta1.ClusterSpec{NamespaceMapping: m},
+		}
+	}
+	applied := func(namespace string, labels map[string]string) *studyv1beta1.World {
+		return &studyv1beta1.World{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: key.Name, Labels: labels},
+			Spec:       studyv1beta1.WorldSpec{World: "stale"},
+		}
+	}
+	namespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	managed := map[string]string{ClusterLabel: "alpha", WorldNamespaceLabel: "default"}
+	created := map[string]string{ManagedByLabel: managedBy, ClusterLabel: "alpha"}
+	prefix := &commonv1beta1.NamespaceMapping{Policy: commonv1beta1.NamespacePrefix}
+	explicit := &commonv1beta1.NamespaceMapping{Policy: commonv1beta1.NamespaceMap, Namespaces: map[string]string{"default": "earthlings"}}
+
+	tests := []struct {
+		name  string
+		hub   []client.Object
+		local []client.Object
+		// uncached are local objects the cache has not seen yet.
+		uncached []client.Object
+		wantErr  bool
+		// wantWorld is the spec.world of the copy in wantNamespace, empty
+		// when no copy should be left.
+		wantNamespace string
+		wantWorld     string
+		// wantNamespaces are the namespaces that should exist afterwards,
+		// goneNamespaces the ones that should not.
+		wantNamespaces []string
+		goneNamespaces []string
+		wantStatus     *studyv1beta1.WorldClusterStatus
+	}{
+		{
+			name:           "applies a World bound to the Cluster",
+			hub:            []client.Object{cluster(nil), world(studyv1beta1.WorldReady, "alpha", "beta")},
+			local:          []client.Object{namespace("default", nil)},
+			wantNamespace:  "default",
+			wantWorld:      "hello",
+			wantNamespaces: []string{"default"},
+			wantStatus:     &studyv1beta1.WorldClusterStatus{Cluster: "alpha", Namespace: "default", Applied: true},
+		},
+		{
+			name:          "updates the applied copy",
+			hub:           []client.Object{cluster(nil), world(studyv1beta1.WorldReady, "alpha")},
+			local:         []client.Object{namespace("default", nil), applied("default", managed)},
+			wantNamespace: "default",
+			wantWorld:     "hello",
+			wantStatus:    &studyv1beta1.WorldClusterStatus{Cluster: "alpha", Namespace: "default", Applied: true},
+		},
+		{
+			name:          "leaves an unmanaged World alone",
+			hub:           []client.Object{cluster(nil), world(studyv1beta1.WorldReady, "alpha")},
+			local:         []client.Object{namespace("default", nil), applied("default", nil)},
+			wantErr:       true,
+			wantNamespace: "default",
+			wantWorld:     "stale",
+			wantStatus:    &studyv1beta1.WorldClusterStatus{Cluster: "alpha", Namespace: "default"},
+		},
+		{
+			name: "leaves the copy of a World of another namespace alone",
+			hub:  []client.Object{cluster(nil), mapped(world(studyv1beta1.WorldReady, "alpha"), explicit)},
+			local: []client.Object{
+				namespace("earthlings", created),
+				applied("earthlings", map[string]string{ClusterLabel: "alpha", WorldNamespaceLabel: "other"}),
+			},
+			wantErr:        true,
+			wantNamespace:  "earthlings",
+			wantWorld:      "stale",
+			wantNamespaces: []string{"earthlings"},
+			wantStatus:     &studyv1beta1.WorldClusterStatus{Cluster: "alpha", Namespace: "earthlings"},
+		},
+		{
+			name:           "prefixes the namespace by the Cluster mapping",
+			hub:            []client.Object{cluster(prefix), world(studyv1beta1.WorldReady, "alpha")},
+			wantNamespace:  "alpha-default",
+			wantWorld:      "hello",
+			wantNamespaces: []string{"alpha-default"},
+			wantStatus:     &studyv1beta1.WorldClusterStatus{Cluster: "alpha", Namespace: "alpha-default", Applied: true},
+		},
+		{
+			name:           "the World mapping wins",
+			hub:            []client.Object{cluster(prefix), mapped(world(studyv1beta1.WorldReady, "alpha"), explicit)},
+			wantNamespace:  "earthlings",
+			wantWorld:      "hello",
+			wantNamespaces: []string{"earthlings"},
+			wantStatus:     &studyv1beta1.WorldClusterStatus{Cluster: "alpha", Namespace: "earthlings", Applied: true},
+		},
+		{
+			name: "moves the copy when the mapping changes",
+			hub:  []client.Object{cluster(nil), mapped(world(studyv1beta1.WorldReady, "alpha"), explicit)},
+			local: []client.Object{
+				namespace("alpha-default", created), applied("alpha-default", managed),
+			},
+			wantNamespace:  "earthlings",
+			wantWorld:      "hello",
+			wantNamespaces: []string{"earthlings"},
+			goneNamespaces: []string{"alpha-default"},
+			wantStatus:     &studyv1beta1.WorldClusterStatus{Cluster: "alpha", Namespace: "earthlings", Applied: true},
+		},
+		{
+			name: "removes a World bound elsewhere and its namespace",
+			hub:  []client.Object{cluster(prefix), reported(world(studyv1beta1.WorldReady, "beta"))},
+			local: []client.Object{
+				namespace("alpha-default", created), applied("alpha-default", managed),
+			},
+			goneNamespaces: []string{"alpha-default"},
+		},
+		{
+			name: "keeps a namespace other Worlds are in",
+			hub:  []client.Object{cluster(prefix), reported(world(studyv1beta1.WorldPending, "alpha"))},
+			local: []client.Object{
+				namespace("alpha-default", created), applied("alpha-default", managed),
+				&studyv1beta1.World{ObjectMeta: metav1.ObjectMeta{Namespace: "alpha-default", Name: "mars"}},
+			},
+			wantNamespaces: []string{"alpha-default"},
+		},
+		{
+			name: "keeps a namespace with a World the cache has not seen",
+			hub:  []client.Object{cluster(prefix), reported(world(studyv1beta1.WorldPending, "alpha"))},
+			local: []client.Object{
+				namespace("alpha-default", created), applied("alpha-default", managed),
+			},
+			uncached:       []client.Object{&studyv1beta1.World{ObjectMeta: metav1.ObjectMeta{Namespace: "alpha-default", Name: "mars"}}},
+			wantNamespaces: []string{"alpha-default"},
+		},
+		{
+			name:           "keeps a namespace it did not create",
+			local:          []client.Object{namespace("default", nil), applied("default", managed)},
+			wantNamespaces: []string{"default"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			hub := testutil.NewFakeClient(nil, tt.hub...)
+			local := testutil.NewFakeClient(nil, tt.local...)
+			r := &WorldReconciler{Hub: hub, Local: local, Cluster: "alpha"}
+			if tt.uncached != nil {
+				r.LocalReader = testutil.NewFakeClient(nil, append(tt.local, tt.uncached...)...)
+			}
+
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reconcile() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			copies := &studyv1beta1.WorldList{}
+			if err := local.List(ctx, copies); err != nil {
+				t.Fatal(err)
+			}
+			found := false
+			for _, got := range copies.Items {
+				if got.Name != key.Name {
+					continue
+				}
+				if got.Namespace != tt.wantNamespace || tt.wantWorld == "" {
+					t.Errorf("copy left in %s", got.Namespace)
+					continue
+				}
+				found = true
+				if got.Spec.World != tt.wantWorld || len(got.Spec.Clusters) != 0 || len(got.Spec.Tolerations) != 0 || got.Spec.NamespaceMapping != nil {
+					t.Errorf("local spec = %+v, want world %q bound to no Cluster", got.Spec, tt.wantWorld)
+				}
+			}
+			if tt.wantWorld != "" && !found {
+				t.Errorf("no copy in %s", tt.wantNamespace)
+			}
+			for _, name := range tt.wantNamespaces {
+				if err := local.Get(ctx, client.ObjectKey{Name: name}, new(corev1.Namespace)); err != nil {
+					t.Errorf("namespace %s: %v", name, err)
+				}
+			}
+			for _, name := range tt.goneNamespaces {
+				if err := local.Get(ctx, client.ObjectKey{Name: name}, new(corev1.Namespace)); !apierrs.IsNotFound(err) {
+					t.Errorf("namespace %s still there: %v", name, err)
+				}
+			}
+
+			wl := new(studyv1beta1.World)
+			if err := hub.Get(ctx, key, wl); apierrs.IsNotFound(err) {
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.wantStatus == nil && len(wl.Status.Clusters) != 0:
+				t.Errorf("status.clusters = %+v, want none", wl.Status.Clusters)
+			case tt.wantStatus != nil && (len(wl.Status.Clusters) != 1 ||
+				wl.Status.Clusters[0].Cluster != tt.wantStatus.Cluster ||
+				wl.Status.Clusters[0].Namespace != tt.wantStatus.Namespace ||
+				wl.Status.Clusters[0].Applied != tt.wantStatus.Applied):
+				t.Errorf("status.clusters = %+v, want %+v", wl.Status.Clusters, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestNamespaceMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping *commonv1beta1.NamespaceMapping
+		want    string
+	}{
+		{name: "no mapping", want: "default"},
+		{name: "same", mapping: &commonv1beta1.NamespaceMapping{Policy: commonv1beta1.NamespaceSame, Prefix: "x-"}, want: "default"},
+		{name: "cluster prefix", mapping: &commonv1beta1.NamespaceMapping{Policy: commonv1beta1.NamespacePrefix}, want: "alpha-default"},
+		{name: "explicit prefix", mapping: &commonv1beta1.NamespaceMapping{Policy: commonv1beta1.NamespacePrefix, Prefix: "team-"}, want: "team-default"},
+		{
+			name:    "mapped",
+			mapping: &commonv1beta1.NamespaceMapping{Policy: commonv1beta1.NamespaceMap, Namespaces: map[string]string{"default": "earthlings"}},
+			want:    "earthlings",
+		},
+		{
+			name:    "not in the map",
+			mapping: &commonv1beta1.NamespaceMapping{Policy: commonv1beta1.NamespaceMap, Namespaces: map[string]string{"other": "earthlings"}},
+			want:    "default",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.mapping.Map("default", "alpha"); got != tt.want {
+				t.Errorf("Map() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	summaryv1alpha1 "github/antmoveh/kube-develop-tools/apis/summary/v1alpha1"
+)
+
+type fakeAuth struct{}
+
+func (fakeAuth) Authenticate(r *http.Request) (*UserInfo, error) {
+	if name := r.Header.Get("X-Remote-User"); name != "" {
+		return &UserInfo{Name: name}, nil
+	}
+	return nil, errors.New("anonymous")
+}
+
+// allowVerbs lets "admin" do anything and "viewer" only get.
+type allowVerbs struct{}
+
+func (allowVerbs) Authorize(_ context.Context, user *UserInfo, verb, _ string) (bool, string, error) {
+	return user.Name == "admin" || verb == "get", "", nil
+}
+
+func newServer(t *testing.T) http.Handler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(studyv1beta1.AddToScheme(scheme))
+	utilruntime.Must(commonv1beta1.AddToScheme(scheme))
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&studyv1beta1.World{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "w1"},
+			Spec:       studyv1beta1.WorldSpec{Clusters: []string{"c1"}},
+			Status:     studyv1beta1.WorldStatus{Phase: studyv1beta1.WorldReady},
+		},
+		&studyv1beta1.World{ObjectMeta: metav1.ObjectMeta{Namespace: "b", Name: "w1"}},
+		&commonv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c1"}},
+	).Build()
+	s := &Server{Reader: reader, Authorizer: allowVerbs{}}
+	return s.Handler(fakeAuth{})
+}
+
+func do(h http.Handler, method, path, user, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if user != "" {
+		req.Header.Set("X-Remote-User", user)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer(t *testing.T) {
+	h := newServer(t)
+	base := "/apis/summary.example.cn/v1alpha1/worldsummaries"
+
+	for _, tc := range []struct {
+		method, path, user string
+		code               int
+	}{
+		{http.MethodGet, base, "", http.StatusUnauthorized},
+		{http.MethodGet, base, "viewer", http.StatusForbidden},
+		{http.MethodGet, base + "?watch=true", "admin", http.StatusMethodNotAllowed},
+		{http.MethodDelete, base + "/a", "admin", http.StatusMethodNotAllowed},
+		{http.MethodGet, base + "/missing", "viewer", http.StatusNotFound},
+		{http.MethodGet, base + "?fieldSelector=spec.x%3D1%3D", "admin", http.StatusBadRequest},
+		{http.MethodGet, "/apis/summary.example.cn", "viewer", http.StatusOK},
+		{http.MethodGet, "/apis/summary.example.cn/v1alpha1", "viewer", http.StatusOK},
+	} {
+		if rec := do(h, tc.method, tc.path, tc.user, ""); rec.Code != tc.code {
+			t.Errorf("%s %s as %q = %d, want %d: %s", tc.method, tc.path, tc.user, rec.Code, tc.code, rec.Body)
+		}
+	}
+
+	rec := do(h, http.MethodGet, base+"/a", "viewer", "")
+	ws := &summaryv1alpha1.WorldSummary{}
+	if err := json.Unmarshal(rec.Body.Bytes(), ws); err != nil {
+		t.Fatal(err)
+	}
+	if ws.Kind != "WorldSummary" || ws.Name != "a" || ws.Worlds != 1 || len(ws.Clusters) != 1 ||
+		ws.Clusters[0].Health != summaryv1alpha1.ClusterHealthy {
+		t.Errorf("worldsummary a = %+v", ws)
+	}
+
+	rec = do(h, http.MethodGet, base+"?fieldSelector=metadata.name%3Db", "admin", "")
+	list := &summaryv1alpha1.WorldSummaryList{}
+	if err := json.Unmarshal(rec.Body.Bytes(), list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != "b" || list.Items[0].Phases["Unknown"] != 1 {
+		t.Errorf("selected worldsummaries = %+v", list.Items)
+	}
+
+	rec = do(h, http.MethodGet, base, "admin", "application/json;as=Table;v=v1;g=meta.k8s.io,application/json")
+	table := &metav1.Table{}
+	if err := json.Unmarshal(rec.Body.Bytes(), table); err != nil {
+		t.Fatal(err)
+	}
+	if table.Kind != "Table" || len(table.Rows) != 2 || table.Rows[0].Cells[0] != "a" || table.Rows[0].Cells[2] != float64(1) {
+		t.Errorf("table = %+v", table)
+	}
+}
+
+func TestRequestHeader(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "front-proxy-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: AuthenticationConfigMap.Namespace, Name: AuthenticationConfigMap.Name},
+		Data: map[string]string{
+			"requestheader-client-ca-file":       string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+			"requestheader-allowed-names":        `["front-proxy-client"]`,
+			"requestheader-username-headers":     `["X-Remote-User"]`,
+			"requestheader-group-headers":        `["X-Remote-Group"]`,
+			"requestheader-extra-headers-prefix": `["X-Remote-Extra-"]`,
+		},
+	}
+	authn, err := LoadRequestHeader(context.Background(), fake.NewClientBuilder().WithObjects(cm).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(cn string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Remote-User", "jane")
+		req.Header.Add("X-Remote-Group", "dev")
+		req.Header.Add("X-Remote-Group", "system:authenticated")
+		req.Header.Set("X-Remote-Extra-Scopes%2fA", "x")
+		if cn != "" {
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}}}
+		}
+		return req
+	}
+
+	user, err := authn.Authenticate(request("front-proxy-client"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "jane" || len(user.Groups) != 2 || user.Extra["scopes/a"][0] != "x" {
+		t.Errorf("user = %+v", user)
+	}
+	if _, err := authn.Authenticate(request("someone")); err == nil {
+		t.Error("a client certificate that is not allowed was accepted")
+	}
+	if _, err := authn.Authenticate(request("")); err == nil {
+		t.Error("a request without a client certificate was accepted")
+	}
+}
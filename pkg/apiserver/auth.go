@@ -0,0 +1,186 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	summaryv1alpha1 "github/antmoveh/kube-develop-tools/apis/summary/v1alpha1"
+)
+
+// AuthenticationConfigMap is where the kube-apiserver publishes how it
+// authenticates the requests it proxies to aggregated API servers.
+var AuthenticationConfigMap = types.NamespacedName{Namespace: "kube-system", Name: "extension-apiserver-authentication"}
+
+// UserInfo is the user a proxied request is made for.
+type UserInfo struct {
+	Name   string
+	Groups []string
+	Extra  map[string][]string
+}
+
+// Authenticator returns the user of a request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*UserInfo, error)
+}
+
+// Authorizer decides whether a user may use verb on the named worldsummary,
+// or on all of them when name is empty. reason explains a denial.
+type Authorizer interface {
+	Authorize(ctx context.Context, user *UserInfo, verb, name string) (allowed bool, reason string, err error)
+}
+
+// RequestHeader authenticates the requests the kube-apiserver proxies: they
+// come with a front-proxy client certificate and carry the user in headers.
+type RequestHeader struct {
+	// ClientCAs verify the front-proxy client certificate.
+	ClientCAs *x509.CertPool
+	// AllowedNames are the common names the client certificate may have,
+	// any name when empty.
+	AllowedNames        []string
+	UsernameHeaders     []string
+	GroupHeaders        []string
+	ExtraHeaderPrefixes []string
+}
+
+var _ Authenticator = &RequestHeader{}
+
+// LoadRequestHeader reads the front-proxy settings from
+// AuthenticationConfigMap.
+func LoadRequestHeader(ctx context.Context, reader client.Reader) (*RequestHeader, error) {
+	cm := &corev1.ConfigMap{}
+	if err := reader.Get(ctx, AuthenticationConfigMap, cm); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", AuthenticationConfigMap, err)
+	}
+	a := &RequestHeader{ClientCAs: x509.NewCertPool()}
+	if !a.ClientCAs.AppendCertsFromPEM([]byte(cm.Data["requestheader-client-ca-file"])) {
+		return nil, fmt.Errorf("%s has no requestheader-client-ca-file, is the aggregation layer enabled?", AuthenticationConfigMap)
+	}
+	for key, into := range map[string]*[]string{
+		"requestheader-allowed-names":        &a.AllowedNames,
+		"requestheader-username-headers":     &a.UsernameHeaders,
+		"requestheader-group-headers":        &a.GroupHeaders,
+		"requestheader-extra-headers-prefix": &a.ExtraHeaderPrefixes,
+	} {
+		if v := cm.Data[key]; v != "" {
+			if err := json.Unmarshal([]byte(v), into); err != nil {
+				return nil, fmt.Errorf("invalid %s in %s: %w", key, AuthenticationConfigMap, err)
+			}
+		}
+	}
+	if len(a.UsernameHeaders) == 0 {
+		return nil, fmt.Errorf("%s has no requestheader-username-headers", AuthenticationConfigMap)
+	}
+	return a, nil
+}
+
+// Authenticate implements Authenticator. The client certificate must have
+// been verified against ClientCAs during the TLS handshake.
+func (a *RequestHeader) Authenticate(r *http.Request) (*UserInfo, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, errors.New("no verified front-proxy client certificate")
+	}
+	if cn := r.TLS.VerifiedChains[0][0].Subject.CommonName; len(a.AllowedNames) > 0 && !contains(a.AllowedNames, cn) {
+		return nil, fmt.Errorf("client certificate %q is not an allowed front proxy", cn)
+	}
+
+	user := &UserInfo{}
+	for _, h := range a.UsernameHeaders {
+		if user.Name = strings.TrimSpace(r.Header.Get(h)); user.Name != "" {
+			break
+		}
+	}
+	if user.Name == "" {
+		return nil, errors.New("no user in the request headers")
+	}
+	for _, h := range a.GroupHeaders {
+		user.Groups = append(user.Groups, r.Header.Values(h)...)
+	}
+	for h, values := range r.Header {
+		for _, prefix := range a.ExtraHeaderPrefixes {
+			if !strings.HasPrefix(strings.ToLower(h), strings.ToLower(prefix)) {
+				continue
+			}
+			// 额外字段的键在请求头中是转义过的
+			key, err := url.PathUnescape(strings.ToLower(h[len(prefix):]))
+			if err != nil {
+				key = strings.ToLower(h[len(prefix):])
+			}
+			if user.Extra == nil {
+				user.Extra = map[string][]string{}
+			}
+			user.Extra[key] = append(user.Extra[key], values...)
+		}
+	}
+	return user, nil
+}
+
+// SubjectAccessReviewer asks the kube-apiserver whether the user may read
+// worldsummaries, so they are granted with regular RBAC.
+type SubjectAccessReviewer struct {
+	Client client.Client
+}
+
+var _ Authorizer = &SubjectAccessReviewer{}
+
+// Authorize implements Authorizer.
+func (a *SubjectAccessReviewer) Authorize(ctx context.Context, user *UserInfo, verb, name string) (bool, string, error) {
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Name,
+			Groups: user.Groups,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Group:    summaryv1alpha1.GroupVersion.Group,
+				Version:  summaryv1alpha1.GroupVersion.Version,
+				Resource: Resource,
+				Verb:     verb,
+				Name:     name,
+			},
+		},
+	}
+	if len(user.Extra) > 0 {
+		review.Spec.Extra = map[string]authorizationv1.ExtraValue{}
+		for k, v := range user.Extra {
+			review.Spec.Extra[k] = v
+		}
+	}
+	if err := a.Client.Create(ctx, review); err != nil {
+		return false, "", err
+	}
+	return review.Status.Allowed, review.Status.Reason, nil
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,351 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package apiserver serves the read-only summary.example.cn API behind the
+// kube-apiserver aggregation layer. Nothing is stored: every request is
+// answered from the World and Cluster cache. It only serves what kubectl and
+// the aggregator need, get and list with discovery, so it does without the
+// k8s.io/apiserver machinery.
+package apiserver
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	summaryv1alpha1 "github/antmoveh/kube-develop-tools/apis/summary/v1alpha1"
+	"github/antmoveh/kube-develop-tools/pkg/summary"
+)
+
+// Resource is the plural name worldsummaries are served under.
+const Resource = "worldsummaries"
+
+var (
+	groupPath   = "/apis/" + summaryv1alpha1.GroupVersion.Group
+	versionPath = "/apis/" + summaryv1alpha1.GroupVersion.String()
+	summaryKind = summaryv1alpha1.GroupVersion.WithKind("WorldSummary")
+)
+
+// Server is the aggregated API server.
+type Server struct {
+	// Addr is the address the server binds to.
+	Addr string
+	// CertDir holds the serving certificate, tls.crt and tls.key.
+	CertDir string
+	// Reader reads the Worlds and Clusters, usually from the manager cache.
+	Reader client.Reader
+	// APIReader reads AuthenticationConfigMap when the server starts.
+	APIReader  client.Reader
+	Authorizer Authorizer
+}
+
+var _ manager.Runnable = &Server{}
+var _ manager.LeaderElectionRunnable = &Server{}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable; every
+// replica serves requests.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves until ctx is done.
+func (s *Server) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("apiserver")
+	authn, err := LoadRequestHeader(ctx, s.APIReader)
+	if err != nil {
+		return err
+	}
+	watcher, err := certwatcher.New(filepath.Join(s.CertDir, "tls.crt"), filepath.Join(s.CertDir, "tls.key"))
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := watcher.Start(ctx); err != nil {
+			logger.Error(err, "serving certificate watcher failed")
+		}
+	}()
+
+	ln, err := tls.Listen("tcp", s.Addr, &tls.Config{
+		GetCertificate: watcher.GetCertificate,
+		ClientCAs:      authn.ClientCAs,
+		ClientAuth:     tls.VerifyClientCertIfGiven,
+		MinVersion:     tls.VersionTLS12,
+	})
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: s.Handler(authn)}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.Error(err, "unable to shut the API server down")
+		}
+	}()
+	logger.Info("serving aggregated API", "address", ln.Addr().String(), "groupVersion", summaryv1alpha1.GroupVersion.String())
+	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Handler returns the API behind authn. Discovery is open to every
+// authenticated user, worldsummaries go through the Authorizer.
+func (s *Server) Handler(authn Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := authn.Authenticate(r)
+		if err != nil {
+			writeError(w, apierrors.NewUnauthorized(err.Error()))
+			return
+		}
+		if r.Method != http.MethodGet {
+			writeError(w, apierrors.NewMethodNotSupported(summaryv1alpha1.Resource(Resource), strings.ToLower(r.Method)))
+			return
+		}
+
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		switch {
+		case path == groupPath:
+			writeJSON(w, http.StatusOK, apiGroup())
+		case path == versionPath:
+			writeJSON(w, http.StatusOK, apiResources())
+		case path == versionPath+"/"+Resource:
+			s.list(w, r, user)
+		case strings.HasPrefix(path, versionPath+"/"+Resource+"/") && !strings.Contains(path[len(versionPath+"/"+Resource+"/"):], "/"):
+			s.get(w, r, user, path[len(versionPath+"/"+Resource+"/"):])
+		default:
+			writeError(w, apierrors.NewNotFound(summaryv1alpha1.Resource(Resource), ""))
+		}
+	})
+}
+
+func apiGroup() *metav1.APIGroup {
+	version := metav1.GroupVersionForDiscovery{
+		GroupVersion: summaryv1alpha1.GroupVersion.String(),
+		Version:      summaryv1alpha1.GroupVersion.Version,
+	}
+	return &metav1.APIGroup{
+		TypeMeta:         metav1.TypeMeta{APIVersion: "v1", Kind: "APIGroup"},
+		Name:             summaryv1alpha1.GroupVersion.Group,
+		Versions:         []metav1.GroupVersionForDiscovery{version},
+		PreferredVersion: version,
+	}
+}
+
+func apiResources() *metav1.APIResourceList {
+	return &metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{APIVersion: "v1", Kind: "APIResourceList"},
+		GroupVersion: summaryv1alpha1.GroupVersion.String(),
+		APIResources: []metav1.APIResource{{
+			Name:         Resource,
+			SingularName: "worldsummary",
+			Namespaced:   false,
+			Kind:         summaryKind.Kind,
+			Verbs:        metav1.Verbs{"get", "list"},
+		}},
+	}
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, user *UserInfo, verb, name string) bool {
+	allowed, reason, err := s.Authorizer.Authorize(r.Context(), user, verb, name)
+	if err != nil {
+		writeError(w, apierrors.NewInternalError(fmt.Errorf("authorizing the request: %w", err)))
+		return false
+	}
+	if !allowed {
+		if reason == "" {
+			reason = fmt.Sprintf("user %q cannot %s resource %q in API group %q", user.Name, verb, Resource, summaryv1alpha1.GroupVersion.Group)
+		}
+		writeError(w, apierrors.NewForbidden(summaryv1alpha1.Resource(Resource), name, errors.New(reason)))
+		return false
+	}
+	return true
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, user *UserInfo, name string) {
+	if !s.authorize(w, r, user, "get", name) {
+		return
+	}
+	summaries, err := s.summarize(r.Context(), client.InNamespace(name))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ws, ok := summaries[name]
+	if !ok {
+		writeError(w, apierrors.NewNotFound(summaryv1alpha1.Resource(Resource), name))
+		return
+	}
+	if wantsTable(r) {
+		writeTable(w, []summaryv1alpha1.WorldSummary{*ws})
+		return
+	}
+	writeJSON(w, http.StatusOK, ws)
+}
+
+// list serves every summary. Summaries carry no labels, so only the
+// metadata.name field selector can narrow the list.
+func (s *Server) list(w http.ResponseWriter, r *http.Request, user *UserInfo) {
+	query := r.URL.Query()
+	verb := "list"
+	if query.Get("watch") == "true" || query.Get("watch") == "1" {
+		verb = "watch"
+	}
+	if !s.authorize(w, r, user, verb, "") {
+		return
+	}
+	if verb == "watch" {
+		writeError(w, apierrors.NewMethodNotSupported(summaryv1alpha1.Resource(Resource), verb))
+		return
+	}
+	labelSelector, err := labels.Parse(query.Get("labelSelector"))
+	if err != nil {
+		writeError(w, apierrors.NewBadRequest(err.Error()))
+		return
+	}
+	fieldSelector, err := fields.ParseSelector(query.Get("fieldSelector"))
+	if err != nil {
+		writeError(w, apierrors.NewBadRequest(err.Error()))
+		return
+	}
+
+	summaries, err := s.summarize(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	list := &summaryv1alpha1.WorldSummaryList{
+		TypeMeta: metav1.TypeMeta{APIVersion: summaryv1alpha1.GroupVersion.String(), Kind: "WorldSummaryList"},
+		Items:    []summaryv1alpha1.WorldSummary{},
+	}
+	for name, ws := range summaries {
+		if labelSelector.Matches(labels.Set(ws.Labels)) && fieldSelector.Matches(fields.Set{"metadata.name": name}) {
+			list.Items = append(list.Items, *ws)
+		}
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	if wantsTable(r) {
+		writeTable(w, list.Items)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) summarize(ctx context.Context, opts ...client.ListOption) (map[string]*summaryv1alpha1.WorldSummary, error) {
+	worlds := &studyv1beta1.WorldList{}
+	if err := s.Reader.List(ctx, worlds, opts...); err != nil {
+		return nil, apierrors.NewInternalError(fmt.Errorf("listing Worlds: %w", err))
+	}
+	clusters := &commonv1beta1.ClusterList{}
+	if err := s.Reader.List(ctx, clusters); err != nil {
+		return nil, apierrors.NewInternalError(fmt.Errorf("listing Clusters: %w", err))
+	}
+	summaries := summary.Summarize(worlds.Items, clusters.Items)
+	for _, ws := range summaries {
+		ws.SetGroupVersionKind(summaryKind)
+	}
+	return summaries, nil
+}
+
+// wantsTable reports whether the client, usually kubectl get, asked for a
+// meta.k8s.io/v1 Table.
+func wantsTable(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && params["as"] == "Table" && params["v"] == "v1" && params["g"] == "meta.k8s.io" {
+			return true
+		}
+	}
+	return false
+}
+
+func writeTable(w http.ResponseWriter, summaries []summaryv1alpha1.WorldSummary) {
+	table := &metav1.Table{
+		TypeMeta: metav1.TypeMeta{APIVersion: "meta.k8s.io/v1", Kind: "Table"},
+		ColumnDefinitions: []metav1.TableColumnDefinition{
+			{Name: "Name", Type: "string", Format: "name", Description: "Namespace of the Worlds"},
+			{Name: "Worlds", Type: "integer", Description: "Number of Worlds"},
+			{Name: "Ready", Type: "integer", Description: "Worlds in the Ready phase"},
+			{Name: "Failed", Type: "integer", Description: "Worlds in the Failed phase"},
+			{Name: "Stale", Type: "integer", Description: "Worlds whose resyncs keep failing"},
+			{Name: "Paused", Type: "integer", Description: "Paused Worlds"},
+			{Name: "Unhealthy Clusters", Type: "integer", Description: "Bound Clusters that are not Healthy"},
+		},
+		Rows: []metav1.TableRow{},
+	}
+	for i := range summaries {
+		ws := &summaries[i]
+		var unhealthy int32
+		for _, c := range ws.Clusters {
+			if c.Health != summaryv1alpha1.ClusterHealthy {
+				unhealthy++
+			}
+		}
+		raw, err := json.Marshal(ws)
+		if err != nil {
+			writeError(w, apierrors.NewInternalError(err))
+			return
+		}
+		table.Rows = append(table.Rows, metav1.TableRow{
+			Cells: []interface{}{
+				ws.Name, ws.Worlds,
+				ws.Phases[string(studyv1beta1.WorldReady)], ws.Phases[string(studyv1beta1.WorldFailed)],
+				ws.Stale, ws.Paused, unhealthy,
+			},
+			Object: runtime.RawExtension{Raw: raw},
+		})
+	}
+	writeJSON(w, http.StatusOK, table)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		status = apierrors.NewInternalError(err)
+	}
+	s := status.Status()
+	s.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Status"}
+	writeJSON(w, int(s.Code), &s)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Log.WithName("apiserver").Error(err, "unable to write response")
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit records every write the controllers make. Reconcilers talk to
+// the API server through a Client that writes one JSON line per mutation to
+// an Auditor, so an unexpected change can be traced back to the controller
+// and the reconcile that made it.
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Redacted replaces the values of redacted fields.
+const Redacted = "<redacted>"
+
+// DefaultRedactions hides the data of Secrets.
+var DefaultRedactions = map[schema.GroupKind][]string{
+	{Kind: "Secret"}: {"data", "stringData"},
+}
+
+// ObjectRef identifies the object a record is about.
+type ObjectRef struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name,omitempty"`
+}
+
+// Record is one write made by a controller.
+type Record struct {
+	Time        time.Time `json:"time"`
+	Controller  string    `json:"controller"`
+	ReconcileID string    `json:"reconcileID,omitempty"`
+	// Verb is create, update, patch, delete or deleteAllOf.
+	Verb        string    `json:"verb"`
+	Subresource string    `json:"subresource,omitempty"`
+	Object      ObjectRef `json:"object"`
+	// Diff is a JSON merge patch for updates, the patch body for patches and
+	// the full object for creates.
+	Diff  json.RawMessage `json:"diff,omitempty"`
+	Error string          `json:"error,omitempty"`
+	// DryRun is set when the write was not persisted because the manager
+	// runs in dry-run mode.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// Auditor writes records as JSON lines to Sink. It is safe for concurrent
+// use.
+type Auditor struct {
+	// Sink receives one JSON line per record, usually os.Stdout or a
+	// RotatingFile.
+	Sink io.Writer
+	// SampleRate is the share of successful writes that are recorded, from 0
+	// to 1. Failed writes and deletions are always recorded.
+	SampleRate float64
+	// Redactions lists per kind the fields, as dot separated paths, whose
+	// values are hidden. Nil means DefaultRedactions.
+	Redactions map[schema.GroupKind][]string
+
+	mu sync.Mutex
+}
+
+// Write redacts and writes rec unless sampling drops it.
+func (a *Auditor) Write(rec Record) error {
+	if !a.sampled(rec) {
+		return nil
+	}
+	gvk := schema.FromAPIVersionAndKind(rec.Object.APIVersion, rec.Object.Kind)
+	rec.Diff = a.redact(gvk.GroupKind(), rec.Diff)
+
+	line, err := marshal(rec)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.Sink.Write(line)
+	return err
+}
+
+func (a *Auditor) sampled(rec Record) bool {
+	if rec.Error != "" || rec.Verb == "delete" || rec.Verb == "deleteAllOf" || a.SampleRate >= 1 {
+		return true
+	}
+	return a.SampleRate > 0 && rand.Float64() < a.SampleRate
+}
+
+// redact replaces the values below every redacted field of the kind with
+// Redacted. The keys stay, so the record still shows which ones changed.
+func (a *Auditor) redact(gk schema.GroupKind, diff json.RawMessage) json.RawMessage {
+	redactions := a.Redactions
+	if redactions == nil {
+		redactions = DefaultRedactions
+	}
+	fields := redactions[gk]
+	if len(fields) == 0 || len(diff) == 0 {
+		return diff
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(diff, &doc); err != nil {
+		// Never write what we cannot inspect.
+		return json.RawMessage(`"` + Redacted + `"`)
+	}
+	for _, field := range fields {
+		redactField(doc, strings.Split(field, "."))
+	}
+	out, err := marshal(doc)
+	if err != nil {
+		return json.RawMessage(`"` + Redacted + `"`)
+	}
+	return bytes.TrimSuffix(out, []byte("\n"))
+}
+
+// marshal encodes v as a JSON line without escaping HTML, which would turn
+// Redacted into unreadable escapes.
+func marshal(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func redactField(doc map[string]interface{}, path []string) {
+	v, ok := doc[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) > 1 {
+		if child, ok := v.(map[string]interface{}); ok {
+			redactField(child, path[1:])
+		}
+		return
+	}
+	if values, ok := v.(map[string]interface{}); ok {
+		for k, value := range values {
+			// A null value deletes the key in a merge patch, keep it visible.
+			if value != nil {
+				values[k] = Redacted
+			}
+		}
+		return
+	}
+	if v != nil {
+		doc[path[0]] = Redacted
+	}
+}
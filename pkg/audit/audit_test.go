@@ -0,0 +1,185 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github/antmoveh/kube-develop-tools/pkg/logging"
+)
+
+func readRecords(t *testing.T, buf *bytes.Buffer) []Record {
+	t.Helper()
+	var records []Record
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid audit line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestClientRecordsWrites(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cm"},
+		Data:       map[string]string{"key": "old"},
+	}
+	buf := new(bytes.Buffer)
+	c := NewClient(fake.NewClientBuilder().WithObjects(cm).Build(), &Auditor{Sink: buf, SampleRate: 1}, "world")
+	ctx := logging.WithReconcileID(context.Background())
+
+	update := new(corev1.ConfigMap)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(cm), update); err != nil {
+		t.Fatal(err)
+	}
+	update.Data["key"] = "new"
+	if err := c.Update(ctx, update); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, update); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, update); err == nil {
+		t.Fatal("deleting a missing object succeeded")
+	}
+
+	records := readRecords(t, buf)
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3: %+v", len(records), records)
+	}
+	want := ObjectRef{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "cm"}
+	for _, rec := range records {
+		if rec.Controller != "world" || rec.ReconcileID != logging.ReconcileID(ctx) || rec.Object != want {
+			t.Errorf("unexpected record %+v", rec)
+		}
+	}
+	if rec := records[0]; rec.Verb != "update" || string(rec.Diff) != `{"data":{"key":"new"}}` {
+		t.Errorf("update record = %s %s", rec.Verb, rec.Diff)
+	}
+	if rec := records[2]; rec.Verb != "delete" || rec.Error == "" {
+		t.Errorf("failed delete record = %+v", rec)
+	}
+}
+
+func TestClientMarksDryRunWrites(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cm"}}
+	buf := new(bytes.Buffer)
+	c := NewClient(fake.NewClientBuilder().Build(), &Auditor{Sink: buf, SampleRate: 1}, "world")
+	if err := c.Create(context.Background(), cm.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	c.DryRun = true
+	if err := c.Status().Update(context.Background(), cm.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 2 ||
+		strings.Contains(lines[0], `"dryRun"`) || !strings.Contains(lines[1], `"dryRun":true`) {
+		t.Fatalf("want only the second record marked dryRun:\n%s", buf.String())
+	}
+}
+
+func TestAuditorRedactsSecrets(t *testing.T) {
+	buf := new(bytes.Buffer)
+	a := &Auditor{Sink: buf, SampleRate: 1}
+	if err := a.Write(Record{
+		Verb:   "patch",
+		Object: ObjectRef{APIVersion: "v1", Kind: "Secret", Name: "s"},
+		Diff:   json.RawMessage(`{"data":{"password":"c2VjcmV0","old":null},"metadata":{"labels":{"a":"b"}}}`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	records := readRecords(t, buf)
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	diff := string(records[0].Diff)
+	if strings.Contains(diff, "c2VjcmV0") {
+		t.Errorf("secret data was written: %s", diff)
+	}
+	if want := `{"data":{"old":null,"password":"<redacted>"},"metadata":{"labels":{"a":"b"}}}`; diff != want {
+		t.Errorf("diff = %s, want %s", diff, want)
+	}
+}
+
+func TestAuditorSampling(t *testing.T) {
+	buf := new(bytes.Buffer)
+	a := &Auditor{Sink: buf, SampleRate: 0}
+	ref := ObjectRef{Kind: "World", Name: "w"}
+	for _, rec := range []Record{
+		{Verb: "update", Object: ref},
+		{Verb: "update", Object: ref, Error: "conflict"},
+		{Verb: "delete", Object: ref},
+	} {
+		if err := a.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records := readRecords(t, buf)
+	if len(records) != 2 || records[0].Error != "conflict" || records[1].Verb != "delete" {
+		t.Errorf("sampled records = %+v, want only the failure and the deletion", records)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	f := &RotatingFile{Path: path, MaxSize: 10, MaxBackups: 2}
+	defer f.Close()
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for file, want := range map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	} {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), data, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more backups than MaxBackups were kept")
+	}
+}
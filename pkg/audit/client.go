@@ -0,0 +1,148 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github/antmoveh/kube-develop-tools/pkg/internal/objdiff"
+	"github/antmoveh/kube-develop-tools/pkg/logging"
+)
+
+var auditlog = logf.Log.WithName("audit")
+
+// Client wraps a client.Client and records every write it passes on. Reads
+// go through unchanged.
+type Client struct {
+	client.Client
+	// DryRun marks every record as a dry-run write. Set it when the wrapped
+	// client only records writes instead of sending them.
+	DryRun bool
+
+	auditor    *Auditor
+	controller string
+	log        logr.Logger
+}
+
+var _ client.Client = &Client{}
+
+// NewClient returns a client that audits the writes of controller to c.
+func NewClient(c client.Client, auditor *Auditor, controller string) *Client {
+	return &Client{Client: c, auditor: auditor, controller: controller, log: auditlog}
+}
+
+// Create records the created object.
+func (c *Client) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	diff, _ := json.Marshal(obj)
+	err := c.Client.Create(ctx, obj, opts...)
+	c.record(ctx, obj, "create", "", diff, err)
+	return err
+}
+
+// Update records the difference to the stored object.
+func (c *Client) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	diff := objdiff.MergePatch(ctx, c.Client, obj)
+	err := c.Client.Update(ctx, obj, opts...)
+	c.record(ctx, obj, "update", "", diff, err)
+	return err
+}
+
+// Patch records the patch body.
+func (c *Client) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	diff := objdiff.PatchData(obj, patch)
+	err := c.Client.Patch(ctx, obj, patch, opts...)
+	c.record(ctx, obj, "patch", "", diff, err)
+	return err
+}
+
+// Delete records the deletion.
+func (c *Client) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	err := c.Client.Delete(ctx, obj, opts...)
+	c.record(ctx, obj, "delete", "", nil, err)
+	return err
+}
+
+// DeleteAllOf records the collection deletion.
+func (c *Client) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	err := c.Client.DeleteAllOf(ctx, obj, opts...)
+	c.record(ctx, obj, "deleteAllOf", "", nil, err)
+	return err
+}
+
+// Status returns a status writer that records status changes the same way.
+func (c *Client) Status() client.StatusWriter {
+	return &statusWriter{client: c}
+}
+
+type statusWriter struct {
+	client *Client
+}
+
+func (sw *statusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	diff := objdiff.MergePatch(ctx, sw.client.Client, obj)
+	err := sw.client.Client.Status().Update(ctx, obj, opts...)
+	sw.client.record(ctx, obj, "update", "status", diff, err)
+	return err
+}
+
+func (sw *statusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	diff := objdiff.PatchData(obj, patch)
+	err := sw.client.Client.Status().Patch(ctx, obj, patch, opts...)
+	sw.client.record(ctx, obj, "patch", "status", diff, err)
+	return err
+}
+
+// record writes the audit record of a write. Failing to audit never fails
+// the write itself.
+func (c *Client) record(ctx context.Context, obj client.Object, verb, subresource string, diff []byte, err error) {
+	rec := Record{
+		Time:        time.Now(),
+		Controller:  c.controller,
+		ReconcileID: logging.ReconcileID(ctx),
+		Verb:        verb,
+		Subresource: subresource,
+		Object:      c.objectRef(obj),
+		Diff:        diff,
+		DryRun:      c.DryRun,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	if werr := c.auditor.Write(rec); werr != nil {
+		c.log.Error(werr, "unable to write audit record", "object", rec.Object, "verb", verb)
+	}
+}
+
+func (c *Client) objectRef(obj client.Object) ObjectRef {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if found, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		gvk = found
+	}
+	return ObjectRef{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package credentials reads the kubeconfig a Cluster references, finds out
+// when its client certificate or token expires and checks that it works.
+package credentials
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// KubeconfigKey is the key of the Secret holding the kubeconfig.
+	KubeconfigKey = "kubeconfig"
+
+	// DefaultExpiryWarning is how long before the credentials expire the
+	// controller starts warning about it.
+	DefaultExpiryWarning = 7 * 24 * time.Hour
+
+	// verifyTimeout bounds the request that checks new credentials.
+	verifyTimeout = 10 * time.Second
+)
+
+// Type is how the kubeconfig authenticates.
+type Type string
+
+const (
+	ClientCertificate Type = "ClientCertificate"
+	Token             Type = "Token"
+	Unknown           Type = "Unknown"
+)
+
+// Info describes the credentials of a kubeconfig.
+type Info struct {
+	Type Type
+	// Expiry is when the client certificate or the token expires, nil when
+	// it cannot be told from the kubeconfig.
+	Expiry *time.Time
+	// Config is the client configuration of the kubeconfig.
+	Config *rest.Config
+}
+
+// FromSecret parses the kubeconfig of secret.
+func FromSecret(secret *corev1.Secret) (*Info, error) {
+	data, ok := secret.Data[KubeconfigKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no %q key", secret.Namespace, secret.Name, KubeconfigKey)
+	}
+	return Parse(data)
+}
+
+// Parse parses a kubeconfig and the credentials of its current context.
+// Only credentials inlined in the kubeconfig are accepted: the controller
+// reads kubeconfigs anyone allowed to write the Secret controls, and exec
+// plugins, auth providers and file references would run commands or read
+// files on the controller host.
+func Parse(data []byte) (*Info, error) {
+	cfg, err := clientcmd.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig: %w", err)
+	}
+	// 没有设置current-context时使用唯一的context
+	if cfg.CurrentContext == "" && len(cfg.Contexts) == 1 {
+		for name := range cfg.Contexts {
+			cfg.CurrentContext = name
+		}
+	}
+	kctx, ok := cfg.Contexts[cfg.CurrentContext]
+	if !ok {
+		return nil, fmt.Errorf("kubeconfig has no context %q", cfg.CurrentContext)
+	}
+	cluster, ok := cfg.Clusters[kctx.Cluster]
+	if !ok {
+		return nil, fmt.Errorf("kubeconfig has no cluster %q", kctx.Cluster)
+	}
+	authInfo, ok := cfg.AuthInfos[kctx.AuthInfo]
+	if !ok {
+		return nil, fmt.Errorf("kubeconfig has no user %q", kctx.AuthInfo)
+	}
+	if err := inlineOnly(cluster, authInfo); err != nil {
+		return nil, err
+	}
+	if cluster.Server == "" {
+		return nil, fmt.Errorf("kubeconfig cluster %q has no server", kctx.Cluster)
+	}
+
+	info := &Info{}
+	if info.Type, info.Expiry, err = expiry(authInfo); err != nil {
+		return nil, err
+	}
+	// 不经过clientcmd，只使用内联的证书和token
+	info.Config = &rest.Config{
+		Host:        cluster.Server,
+		BearerToken: authInfo.Token,
+		TLSClientConfig: rest.TLSClientConfig{
+			Insecure:   cluster.InsecureSkipTLSVerify,
+			ServerName: cluster.TLSServerName,
+			CAData:     cluster.CertificateAuthorityData,
+			CertData:   authInfo.ClientCertificateData,
+			KeyData:    authInfo.ClientKeyData,
+		},
+	}
+	return info, nil
+}
+
+// inlineOnly rejects the kubeconfig fields that run commands, read files or
+// that the client configuration built by Parse would ignore.
+func inlineOnly(cluster *clientcmdapi.Cluster, authInfo *clientcmdapi.AuthInfo) error {
+	var fields []string
+	for field, set := range map[string]bool{
+		"certificate-authority": cluster.CertificateAuthority != "",
+		"proxy-url":             cluster.ProxyURL != "",
+		"exec":                  authInfo.Exec != nil,
+		"auth-provider":         authInfo.AuthProvider != nil,
+		"tokenFile":             authInfo.TokenFile != "",
+		"client-certificate":    authInfo.ClientCertificate != "",
+		"client-key":            authInfo.ClientKey != "",
+		"username":              authInfo.Username != "" || authInfo.Password != "",
+		"as":                    authInfo.Impersonate != "" || len(authInfo.ImpersonateGroups) > 0 || len(authInfo.ImpersonateUserExtra) > 0,
+	} {
+		if set {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	sort.Strings(fields)
+	return fmt.Errorf("kubeconfig uses %s, only inline certificates and tokens are supported", strings.Join(fields, ", "))
+}
+
+func expiry(authInfo *clientcmdapi.AuthInfo) (Type, *time.Time, error) {
+	switch {
+	case len(authInfo.ClientCertificateData) > 0:
+		block, _ := pem.Decode(authInfo.ClientCertificateData)
+		if block == nil {
+			return ClientCertificate, nil, errors.New("client-certificate-data is not PEM encoded")
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return ClientCertificate, nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		return ClientCertificate, &cert.NotAfter, nil
+	case authInfo.Token != "":
+		return Token, tokenExpiry(authInfo.Token), nil
+	}
+	return Unknown, nil, nil
+}
+
+// tokenExpiry returns the exp claim of a JWT, nil for tokens that are not
+// JWTs or never expire. The signature is not checked, only the API server
+// can do that.
+func tokenExpiry(token string) *time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil
+	}
+	var claims struct {
+		Exp *json.Number `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == nil {
+		return nil
+	}
+	exp, err := claims.Exp.Int64()
+	if err != nil {
+		return nil
+	}
+	t := time.Unix(exp, 0)
+	return &t
+}
+
+// Verifier checks that a client configuration can reach its cluster.
+type Verifier func(ctx context.Context, cfg *rest.Config) error
+
+// Verify asks the API server of cfg for the core API versions, which needs
+// an authenticated user unlike /version.
+func Verify(ctx context.Context, cfg *rest.Config) error {
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+	cfg = rest.CopyConfig(cfg)
+	cfg.Timeout = verifyTimeout
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = cs.Discovery().RESTClient().Get().AbsPath("/api").Do(ctx).Raw()
+	return err
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// kubeconfig returns a kubeconfig for https://cluster.example authenticating
+// with the given user fields, written by hand because the serializer of this
+// client-go version cannot encode a clientcmdapi.Config on current Go.
+func kubeconfig(user string) []byte {
+	return []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: c
+  cluster:
+    server: https://cluster.example
+users:
+- name: u
+  user:
+` + user + `
+contexts:
+- name: ctx
+  context:
+    cluster: c
+    user: u
+current-context: ctx
+`)
+}
+
+func clientCert(t *testing.T, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "admin"},
+		NotBefore:    notAfter.Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func b64(data []byte) string {
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func jwt(payload string) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"RS256"}`)) + "." + enc([]byte(payload)) + ".c2ln"
+}
+
+func TestParse(t *testing.T) {
+	notAfter := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tc := range []struct {
+		name     string
+		user     string
+		wantType Type
+		want     *time.Time
+	}{
+		{
+			name:     "client certificate",
+			user:     "    client-certificate-data: " + b64(clientCert(t, notAfter)) + "\n    client-key-data: " + b64([]byte("key")),
+			wantType: ClientCertificate,
+			want:     &notAfter,
+		},
+		{
+			name:     "service account token",
+			user:     "    token: " + jwt(`{"sub":"sa","exp":1893553445}`),
+			wantType: Token,
+			want:     &notAfter,
+		},
+		{
+			name:     "token without expiry",
+			user:     "    token: " + jwt(`{"sub":"sa"}`),
+			wantType: Token,
+		},
+		{
+			name:     "opaque token",
+			user:     "    token: abcdef.0123456789abcdef",
+			wantType: Token,
+		},
+	} {
+		info, err := Parse(kubeconfig(tc.user))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if info.Type != tc.wantType || info.Config.Host != "https://cluster.example" {
+			t.Errorf("%s: type = %s, host = %s", tc.name, info.Type, info.Config.Host)
+		}
+		if (info.Expiry == nil) != (tc.want == nil) || (tc.want != nil && !info.Expiry.Equal(*tc.want)) {
+			t.Errorf("%s: expiry = %v, want %v", tc.name, info.Expiry, tc.want)
+		}
+	}
+}
+
+func TestParseRejectsExternalCredentials(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"exec plugin":        kubeconfig("    exec:\n      apiVersion: client.authentication.k8s.io/v1beta1\n      command: aws"),
+		"auth provider":      kubeconfig("    auth-provider:\n      name: gcp"),
+		"token file":         kubeconfig("    tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token"),
+		"client certificate": kubeconfig("    client-certificate: /etc/kubernetes/pki/admin.crt"),
+		"client key":         kubeconfig("    client-certificate-data: " + b64(clientCert(t, time.Now().Add(time.Hour))) + "\n    client-key: /etc/kubernetes/pki/admin.key"),
+		"certificate authority": []byte(strings.Replace(string(kubeconfig("    token: abc")),
+			"server: https://cluster.example", "server: https://cluster.example\n    certificate-authority: /etc/kubernetes/pki/ca.crt", 1)),
+	} {
+		if info, err := Parse(data); err == nil {
+			t.Errorf("%s: Parse() = %+v, want an error", name, info)
+		}
+	}
+}
+
+func TestParseInlineConfig(t *testing.T) {
+	cert := clientCert(t, time.Now().Add(time.Hour))
+	data := []byte(strings.Replace(string(kubeconfig("    client-certificate-data: "+b64(cert)+"\n    client-key-data: "+b64([]byte("key")))),
+		"server: https://cluster.example", "server: https://cluster.example\n    certificate-authority-data: "+b64([]byte("ca")), 1))
+	info, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tls := info.Config.TLSClientConfig
+	if string(tls.CAData) != "ca" || string(tls.CertData) != string(cert) || string(tls.KeyData) != "key" || tls.CAFile != "" || tls.CertFile != "" || info.Config.ExecProvider != nil {
+		t.Errorf("Parse() config = %+v", info.Config)
+	}
+}
+
+func TestFromSecretErrors(t *testing.T) {
+	for name, secret := range map[string]*corev1.Secret{
+		"no kubeconfig key": {Data: map[string][]byte{"token": []byte("x")}},
+		"not a kubeconfig":  {Data: map[string][]byte{KubeconfigKey: []byte("{")}},
+		"bad certificate":   {Data: map[string][]byte{KubeconfigKey: kubeconfig("    client-certificate-data: " + b64([]byte("nope")))}},
+	} {
+		if _, err := FromSecret(secret); err == nil {
+			t.Errorf("%s: FromSecret() succeeded", name)
+		}
+	}
+}
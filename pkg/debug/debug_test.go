@@ -0,0 +1,178 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// fakeController has the MakeQueue field of the controller-runtime
+// controller.
+type fakeController struct {
+	MakeQueue func() workqueue.RateLimitingInterface
+}
+
+func TestQueues(t *testing.T) {
+	c := &fakeController{MakeQueue: func() workqueue.RateLimitingInterface {
+		return workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	}}
+	queues := NewQueues()
+	if err := queues.track("world", c); err != nil {
+		t.Fatal(err)
+	}
+	q := c.MakeQueue()
+	defer q.ShutDown()
+
+	a := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "a"}}
+	b := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "b"}}
+	q.Add(a)
+	q.Add(b)
+	q.AddAfter(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "c"}}, time.Hour)
+	item, _ := q.Get()
+	if item != a {
+		t.Fatalf("got %v, want %v", item, a)
+	}
+
+	dump := queues.Dump()["world"]
+	if dump.Length != 1 || len(dump.Queued) != 1 || dump.Queued[0].Key != "ns/b" {
+		t.Errorf("queued = %+v", dump)
+	}
+	if len(dump.Processing) != 1 || dump.Processing[0].Key != "ns/a" {
+		t.Errorf("processing = %+v", dump.Processing)
+	}
+	if len(dump.Waiting) != 1 || dump.Waiting[0].Key != "ns/c" || dump.Waiting[0].ReadyAt == nil {
+		t.Errorf("waiting = %+v", dump.Waiting)
+	}
+
+	q.Done(a)
+	if dump := queues.Dump()["world"]; len(dump.Processing) != 0 {
+		t.Errorf("processing after Done = %+v", dump.Processing)
+	}
+
+	if err := queues.Track("cluster", nil); err == nil {
+		t.Error("tracking a controller without MakeQueue succeeded")
+	}
+	var none *Queues
+	if err := none.Track("world", nil); err != nil || len(none.Dump()) != 0 {
+		t.Error("a nil Queues does not ignore controllers")
+	}
+}
+
+// TestTrackController guards against controller-runtime upgrades that drop
+// the MakeQueue field Track relies on.
+func TestTrackController(t *testing.T) {
+	mgr, err := manager.New(&rest.Config{Host: "http://127.0.0.1:0"}, manager.Options{
+		MetricsBindAddress: "0",
+		MapperProvider: func(*rest.Config) (meta.RESTMapper, error) {
+			return meta.NewDefaultRESTMapper(nil), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := controller.NewUnmanaged("world", mgr, controller.Options{
+		Reconciler: reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+			return reconcile.Result{}, nil
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewQueues().Track("world", c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer(t *testing.T) {
+	cms := []client.Object{
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "b"}},
+	}
+	s := &Server{
+		Token:  "secret",
+		Cache:  fake.NewClientBuilder().WithObjects(cms...).Build(),
+		Scheme: scheme.Scheme,
+		Lists:  []client.ObjectList{&corev1.ConfigMapList{}, &corev1.PodList{}},
+		Config: map[string]string{"shards": "0"},
+		Handlers: map[string]http.Handler{
+			"/debug/extra": http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}),
+		},
+	}
+	h := s.Handler()
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("/debug/config", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("request without token got %d", rec.Code)
+	}
+	if rec := get("/debug/config", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("request with a wrong token got %d", rec.Code)
+	}
+	if rec := get("/debug/extra", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("extra handler without token got %d", rec.Code)
+	}
+	if rec := get("/debug/extra", "secret"); rec.Code != http.StatusOK {
+		t.Errorf("extra handler got %d", rec.Code)
+	}
+	if rec := get("/debug/pprof/", "secret"); rec.Code != http.StatusOK {
+		t.Errorf("pprof index got %d", rec.Code)
+	}
+
+	rec := get("/debug/cache", "secret")
+	counts := map[string]interface{}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &counts); err != nil {
+		t.Fatal(err)
+	}
+	if counts["/v1, Kind=ConfigMap"] != float64(2) || counts["/v1, Kind=Pod"] != float64(0) {
+		t.Errorf("cache counts = %v", counts)
+	}
+
+	rec = get("/debug/config", "secret")
+	config := map[string]string{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config["shards"] != "0" {
+		t.Errorf("config = %v", config)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package debug
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+)
+
+// Queues tracks the workqueues of the controllers so their contents can be
+// dumped. A nil Queues tracks nothing.
+type Queues struct {
+	mu     sync.Mutex
+	queues map[string]*trackedQueue
+}
+
+// NewQueues returns an empty Queues.
+func NewQueues() *Queues {
+	return &Queues{queues: map[string]*trackedQueue{}}
+}
+
+var makeQueueType = reflect.TypeOf(func() workqueue.RateLimitingInterface { return nil })
+
+// Track wraps the workqueue c creates when it starts. controller-runtime
+// keeps the queue to itself, so Track replaces the MakeQueue function of the
+// controller it returns; it must be called before the manager starts.
+func (q *Queues) Track(name string, c controller.Controller) error {
+	return q.track(name, c)
+}
+
+func (q *Queues) track(name string, c interface{}) error {
+	if q == nil {
+		return nil
+	}
+	v := reflect.ValueOf(c)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot track the queue of controller %s of type %T", name, c)
+	}
+	field := v.Elem().FieldByName("MakeQueue")
+	if !field.IsValid() || field.Type() != makeQueueType || !field.CanSet() || field.IsNil() {
+		return fmt.Errorf("cannot track the queue of controller %s, %T has no MakeQueue", name, c)
+	}
+	makeQueue := field.Interface().(func() workqueue.RateLimitingInterface)
+	field.Set(reflect.ValueOf(func() workqueue.RateLimitingInterface {
+		tq := newTrackedQueue(makeQueue())
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.queues[name] = tq
+		return tq
+	}))
+	return nil
+}
+
+// QueueItem is an item of a workqueue.
+type QueueItem struct {
+	Key string `json:"key"`
+	// Since is when the item entered its current state.
+	Since time.Time `json:"since"`
+	// ReadyAt is when a delayed item is added back, unset for items that
+	// wait on the rate limiter.
+	ReadyAt *time.Time `json:"readyAt,omitempty"`
+	// Requeues is the number of rate limited retries of the item.
+	Requeues int `json:"requeues,omitempty"`
+}
+
+// QueueDump is the content of a workqueue.
+type QueueDump struct {
+	// Length is the number of items ready to be processed.
+	Length     int         `json:"length"`
+	Queued     []QueueItem `json:"queued"`
+	Processing []QueueItem `json:"processing"`
+	Waiting    []QueueItem `json:"waiting"`
+}
+
+// Dump returns the content of every tracked workqueue by controller.
+func (q *Queues) Dump() map[string]QueueDump {
+	dumps := map[string]QueueDump{}
+	if q == nil {
+		return dumps
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for name, tq := range q.queues {
+		dumps[name] = tq.dump()
+	}
+	return dumps
+}
+
+// trackedQueue records the items passing through a workqueue. Items the
+// delaying queue adds back on its own only show up once they are picked.
+type trackedQueue struct {
+	workqueue.RateLimitingInterface
+
+	mu         sync.Mutex
+	queued     map[interface{}]time.Time
+	processing map[interface{}]time.Time
+	waiting    map[interface{}]QueueItem
+}
+
+func newTrackedQueue(q workqueue.RateLimitingInterface) *trackedQueue {
+	return &trackedQueue{
+		RateLimitingInterface: q,
+		queued:                map[interface{}]time.Time{},
+		processing:            map[interface{}]time.Time{},
+		waiting:               map[interface{}]QueueItem{},
+	}
+}
+
+func (q *trackedQueue) Add(item interface{}) {
+	q.mu.Lock()
+	if _, ok := q.queued[item]; !ok {
+		q.queued[item] = time.Now()
+	}
+	q.mu.Unlock()
+	q.RateLimitingInterface.Add(item)
+}
+
+func (q *trackedQueue) AddAfter(item interface{}, d time.Duration) {
+	if d <= 0 {
+		q.Add(item)
+		return
+	}
+	now := time.Now()
+	readyAt := now.Add(d)
+	q.mu.Lock()
+	if w, ok := q.waiting[item]; !ok || w.ReadyAt == nil || readyAt.Before(*w.ReadyAt) {
+		q.waiting[item] = QueueItem{Since: now, ReadyAt: &readyAt}
+	}
+	q.mu.Unlock()
+	q.RateLimitingInterface.AddAfter(item, d)
+}
+
+func (q *trackedQueue) AddRateLimited(item interface{}) {
+	q.mu.Lock()
+	q.waiting[item] = QueueItem{Since: time.Now()}
+	q.mu.Unlock()
+	q.RateLimitingInterface.AddRateLimited(item)
+}
+
+func (q *trackedQueue) Get() (interface{}, bool) {
+	item, shutdown := q.RateLimitingInterface.Get()
+	if shutdown {
+		return item, shutdown
+	}
+	q.mu.Lock()
+	delete(q.queued, item)
+	delete(q.waiting, item)
+	q.processing[item] = time.Now()
+	q.mu.Unlock()
+	return item, shutdown
+}
+
+func (q *trackedQueue) Done(item interface{}) {
+	q.mu.Lock()
+	delete(q.processing, item)
+	q.mu.Unlock()
+	q.RateLimitingInterface.Done(item)
+}
+
+func (q *trackedQueue) dump() QueueDump {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	d := QueueDump{
+		Length:     q.Len(),
+		Queued:     make([]QueueItem, 0, len(q.queued)),
+		Processing: make([]QueueItem, 0, len(q.processing)),
+		Waiting:    make([]QueueItem, 0, len(q.waiting)),
+	}
+	for item, since := range q.queued {
+		d.Queued = append(d.Queued, QueueItem{Key: fmt.Sprint(item), Since: since})
+	}
+	for item, since := range q.processing {
+		d.Processing = append(d.Processing, QueueItem{Key: fmt.Sprint(item), Since: since})
+	}
+	for item, w := range q.waiting {
+		w.Key = fmt.Sprint(item)
+		w.Requeues = q.NumRequeues(item)
+		d.Waiting = append(d.Waiting, w)
+	}
+	for _, items := range [][]QueueItem{d.Queued, d.Processing, d.Waiting} {
+		sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	}
+	return d
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package debug serves the endpoints used to investigate a running manager:
+// pprof, the workqueue contents per controller, the cache object counts per
+// kind and the effective configuration. It listens on its own address so it
+// can stay off the metrics port.
+package debug
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Server is the debug server.
+type Server struct {
+	// Addr is the address the server binds to.
+	Addr string
+	// Token, when set, must be sent as a bearer token with every request.
+	Token string
+	// Queues holds the tracked controller workqueues.
+	Queues *Queues
+	// Cache is read to count the objects of Lists. It should be the manager
+	// cache, and Lists should only hold kinds it already watches, or the
+	// first count starts a new informer.
+	Cache  client.Reader
+	Scheme *runtime.Scheme
+	Lists  []client.ObjectList
+	// Config is the effective configuration, usually the flag values.
+	Config map[string]string
+	// Handlers are served by path next to the debug endpoints, behind the
+	// same token check. Endpoints that change the manager belong here.
+	Handlers map[string]http.Handler
+}
+
+var _ manager.Runnable = &Server{}
+var _ manager.LeaderElectionRunnable = &Server{}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable; every
+// replica can be debugged.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves until ctx is done.
+func (s *Server) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("debug")
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: s.Handler()}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.Error(err, "unable to shut the debug server down")
+		}
+	}()
+	logger.Info("serving debug endpoints", "address", ln.Addr().String(), "auth", s.Token != "")
+	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Handler returns the debug endpoints behind the token check.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/workqueues", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, s.Queues.Dump())
+	})
+	mux.HandleFunc("/debug/cache", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.cacheCounts(r.Context()))
+	})
+	mux.HandleFunc("/debug/config", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, s.Config)
+	})
+	for path, h := range s.Handlers {
+		mux.Handle(path, h)
+	}
+	return s.authorize(mux)
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	if s.Token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cacheCounts lists every kind of Lists from the cache and returns the number
+// of objects, or the error, by group/version/kind.
+func (s *Server) cacheCounts(ctx context.Context) map[string]interface{} {
+	counts := map[string]interface{}{}
+	for _, list := range s.Lists {
+		list = list.DeepCopyObject().(client.ObjectList)
+		key := "unknown"
+		if gvk, err := apiutil.GVKForObject(list, s.Scheme); err == nil {
+			key = strings.TrimSuffix(gvk.String(), "List")
+		}
+		if err := s.Cache.List(ctx, list); err != nil {
+			counts[key] = err.Error()
+			continue
+		}
+		counts[key] = meta.LenList(list)
+	}
+	return counts
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
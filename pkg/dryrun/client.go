@@ -0,0 +1,154 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dryrun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github/antmoveh/kube-develop-tools/pkg/internal/objdiff"
+)
+
+var dryrunlog = logf.Log.WithName("dry-run")
+
+// Client wraps a client.Client so that reads go through unchanged and every
+// write is turned into a server-side dry-run request recorded in a Store.
+type Client struct {
+	client.Client
+	store *Store
+	log   logr.Logger
+}
+
+var _ client.Client = &Client{}
+
+// NewClient returns a dry-run client backed by c.
+func NewClient(c client.Client, store *Store) *Client {
+	return &Client{Client: c, store: store, log: dryrunlog}
+}
+
+// Create records obj and sends it as a dry-run create.
+func (c *Client) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	diff, _ := json.Marshal(obj)
+	err := c.Client.Create(ctx, obj, append(opts, client.DryRunAll)...)
+	return c.record(obj, "create", "", string(diff), err)
+}
+
+// Update records the difference to the stored object and sends a dry-run update.
+func (c *Client) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	diff := string(objdiff.MergePatch(ctx, c.Client, obj))
+	err := c.Client.Update(ctx, obj, append(opts, client.DryRunAll)...)
+	return c.record(obj, "update", "", diff, err)
+}
+
+// Patch records the patch body and sends it as a dry-run patch.
+func (c *Client) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	diff := string(objdiff.PatchData(obj, patch))
+	err := c.Client.Patch(ctx, obj, patch, append(opts, client.DryRunAll)...)
+	return c.record(obj, "patch", "", diff, err)
+}
+
+// Delete records the deletion and sends it as a dry-run delete.
+func (c *Client) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	err := c.Client.Delete(ctx, obj, append(opts, client.DryRunAll)...)
+	return c.record(obj, "delete", "", "", err)
+}
+
+// DeleteAllOf records the collection deletion and sends it as a dry-run request.
+func (c *Client) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	err := c.Client.DeleteAllOf(ctx, obj, append(opts, client.DryRunAll)...)
+	return c.record(obj, "deleteAllOf", "", "", err)
+}
+
+// Status returns a status writer that records status changes the same way.
+func (c *Client) Status() client.StatusWriter {
+	return &statusWriter{client: c}
+}
+
+type statusWriter struct {
+	client *Client
+}
+
+func (sw *statusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	diff := string(objdiff.MergePatch(ctx, sw.client.Client, obj))
+	err := sw.client.Client.Status().Update(ctx, obj, append(opts, client.DryRunAll)...)
+	return sw.client.record(obj, "update", "status", diff, err)
+}
+
+func (sw *statusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	diff := string(objdiff.PatchData(obj, patch))
+	err := sw.client.Client.Status().Patch(ctx, obj, patch, append(opts, client.DryRunAll)...)
+	return sw.client.record(obj, "patch", "status", diff, err)
+}
+
+// record stores the change and decides which error the caller sees. Servers
+// that do not support dry-run are tolerated: the change is kept but not
+// validated.
+func (c *Client) record(obj client.Object, verb, subresource, diff string, err error) error {
+	change := Change{
+		Time:            time.Now(),
+		Verb:            verb,
+		Subresource:     subresource,
+		Diff:            diff,
+		ServerValidated: err == nil,
+	}
+	if err != nil && dryRunUnsupported(err) {
+		err = nil
+	}
+	if err != nil {
+		change.Error = err.Error()
+	}
+
+	ref := ObjectRef(obj, c.Scheme())
+	c.store.Record(ref, change)
+	c.log.Info("skipped write", "object", ref, "verb", verb, "subresource", subresource,
+		"diff", diff, "serverValidated", change.ServerValidated, "error", change.Error)
+	return err
+}
+
+func dryRunUnsupported(err error) bool {
+	if apierrs.IsMethodNotSupported(err) {
+		return true
+	}
+	return apierrs.IsBadRequest(err) && strings.Contains(strings.ToLower(err.Error()), "dryrun")
+}
+
+// ObjectRef formats obj as Kind/namespace/name, or Kind/name for cluster
+// scoped objects.
+func ObjectRef(obj runtime.Object, scheme *runtime.Scheme) string {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if gvk, err := apiutil.GVKForObject(obj, scheme); err == nil {
+		kind = gvk.Kind
+	}
+	o, ok := obj.(client.Object)
+	if !ok {
+		return kind
+	}
+	if o.GetNamespace() == "" {
+		return fmt.Sprintf("%s/%s", kind, o.GetName())
+	}
+	return fmt.Sprintf("%s/%s/%s", kind, o.GetNamespace(), o.GetName())
+}
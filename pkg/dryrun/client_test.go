@@ -0,0 +1,146 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dryrun
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestClientDoesNotPersistWrites(t *testing.T) {
+	ctx := context.Background()
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cm"},
+		Data:       map[string]string{"key": "old"},
+	}
+	backend := fake.NewClientBuilder().WithObjects(cm).Build()
+	store := NewStore()
+	c := NewClient(backend, store)
+
+	update := cm.DeepCopy()
+	if err := c.Get(ctx, client.ObjectKeyFromObject(cm), update); err != nil {
+		t.Fatal(err)
+	}
+	update.Data["key"] = "new"
+	if err := c.Update(ctx, update); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	created := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "created"}}
+	if err := c.Create(ctx, created); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	stored := &corev1.ConfigMap{}
+	if err := backend.Get(ctx, client.ObjectKeyFromObject(cm), stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Data["key"] != "old" {
+		t.Errorf("update was persisted: key = %q", stored.Data["key"])
+	}
+	if err := backend.Get(ctx, client.ObjectKeyFromObject(created), &corev1.ConfigMap{}); err == nil {
+		t.Errorf("create was persisted")
+	}
+
+	summary := store.Summary()
+	if len(summary) != 2 {
+		t.Fatalf("summary has %d objects, want 2: %+v", len(summary), summary)
+	}
+	got := summary[0]
+	if got.Object != "ConfigMap/default/cm" || len(got.Changes) != 1 {
+		t.Fatalf("unexpected changes %+v", got)
+	}
+	if diff := got.Changes[0].Diff; diff != `{"data":{"key":"new"}}` {
+		t.Errorf("diff = %s", diff)
+	}
+}
+
+func TestEventRecorderAndSummaryEndpoint(t *testing.T) {
+	store := NewStore()
+	recorder := NewEventRecorder(store, scheme.Scheme)
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "p"}}
+	recorder.Eventf(pod, corev1.EventTypeNormal, "Synced", "synced %d times", 3)
+
+	w := httptest.NewRecorder()
+	store.ServeHTTP(w, httptest.NewRequest("GET", "/dryrun?object=Pod/default/p", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, `"verb": "event"`) || !strings.Contains(body, "Normal Synced: synced 3 times") {
+		t.Errorf("unexpected summary %s", body)
+	}
+}
+
+func TestStoreCountsRepeatedChanges(t *testing.T) {
+	store := NewStore()
+	for i := 0; i < 3; i++ {
+		store.Record("World/default/earth", Change{Verb: "update", Subresource: "status", Diff: `{"status":{"phase":"Pending"}}`})
+	}
+	store.Record("World/default/earth", Change{Verb: "patch", Diff: `{"metadata":{"finalizers":["world.finalizers"]}}`})
+	store.Record("World/default/earth", Change{Verb: "update", Subresource: "status", Diff: `{"status":{"phase":"Pending"}}`})
+
+	summary := store.Summary()
+	if len(summary) != 1 {
+		t.Fatalf("summary has %d objects, want 1", len(summary))
+	}
+	var counts []int
+	for _, c := range summary[0].Changes {
+		counts = append(counts, c.Count)
+	}
+	if len(counts) != 3 || counts[0] != 3 || counts[1] != 1 || counts[2] != 1 {
+		t.Errorf("counts = %v, want [3 1 1]", counts)
+	}
+}
+
+func TestInstallerRecordsMemberWrites(t *testing.T) {
+	store := NewStore()
+	objects := func() []client.Object {
+		return []client.Object{
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "agents"}},
+			&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "agents", Name: "agent"}},
+		}
+	}
+	i := NewInstaller(store, scheme.Scheme, objects)
+	cfg := &rest.Config{Host: "https://alpha.example"}
+	// 重复卸载只记录，不会访问成员集群
+	for n := 0; n < 2; n++ {
+		if err := i.Install(context.Background(), cfg); err != nil {
+			t.Fatal(err)
+		}
+		if err := i.Uninstall(context.Background(), cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	summary := store.Summary()
+	if len(summary) != 2 || summary[0].Object != "https://alpha.example/Namespace/agents" ||
+		summary[1].Object != "https://alpha.example/ServiceAccount/agents/agent" {
+		t.Fatalf("summary = %+v", summary)
+	}
+	for _, oc := range summary {
+		if len(oc.Changes) != 4 || oc.Changes[0].Verb != "create" || oc.Changes[1].Verb != "delete" || oc.Changes[0].Diff == "" {
+			t.Errorf("%s changes = %+v", oc.Object, oc.Changes)
+		}
+	}
+}
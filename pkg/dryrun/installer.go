@@ -0,0 +1,68 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dryrun
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Installer records what installing or removing the agent in a member
+// Cluster would write, instead of connecting to the Cluster. The member is
+// reached with its own credentials, not through the dry-run client, so its
+// writes cannot be sent as server-side dry-runs.
+type Installer struct {
+	store   *Store
+	scheme  *runtime.Scheme
+	objects func() []client.Object
+}
+
+// NewInstaller returns an Installer recording what objects returns into
+// store.
+func NewInstaller(store *Store, scheme *runtime.Scheme, objects func() []client.Object) *Installer {
+	return &Installer{store: store, scheme: scheme, objects: objects}
+}
+
+// Install records the creation of the objects in the Cluster of cfg.
+func (i *Installer) Install(_ context.Context, cfg *rest.Config) error {
+	for _, obj := range i.objects() {
+		diff, _ := json.Marshal(obj)
+		i.record(cfg, obj, "create", string(diff))
+	}
+	return nil
+}
+
+// Uninstall records the deletion of the objects from the Cluster of cfg.
+func (i *Installer) Uninstall(_ context.Context, cfg *rest.Config) error {
+	objs := i.objects()
+	for n := len(objs) - 1; n >= 0; n-- {
+		i.record(cfg, objs[n], "delete", "")
+	}
+	return nil
+}
+
+func (i *Installer) record(cfg *rest.Config, obj client.Object, verb, diff string) {
+	// 成员集群的对象以API server地址区分
+	ref := cfg.Host + "/" + ObjectRef(obj, i.scheme)
+	i.store.Record(ref, Change{Time: time.Now(), Verb: verb, Diff: diff})
+	dryrunlog.Info("skipped member cluster write", "object", ref, "verb", verb)
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dryrun
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// EventRecorder records Events in a Store instead of sending them.
+type EventRecorder struct {
+	store  *Store
+	scheme *runtime.Scheme
+}
+
+var _ record.EventRecorder = &EventRecorder{}
+
+// NewEventRecorder returns an EventRecorder that records into store.
+func NewEventRecorder(store *Store, scheme *runtime.Scheme) *EventRecorder {
+	return &EventRecorder{store: store, scheme: scheme}
+}
+
+func (r *EventRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	ref := ObjectRef(object, r.scheme)
+	diff := fmt.Sprintf("%s %s: %s", eventtype, reason, message)
+	r.store.Record(ref, Change{Time: time.Now(), Verb: "event", Diff: diff})
+	dryrunlog.Info("skipped event", "object", ref, "event", diff)
+}
+
+func (r *EventRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *EventRecorder) AnnotatedEventf(object runtime.Object, _ map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dryrun lets a manager run next to production without changing
+// anything. Every write the reconcilers make is sent as a server-side dry-run
+// where possible, logged as a diff and kept in a Store that can be inspected
+// over HTTP.
+package dryrun
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxChangesPerObject bounds the memory used by objects that are written on
+// every reconcile.
+const maxChangesPerObject = 20
+
+// Change is a write the manager would have made.
+type Change struct {
+	Time time.Time `json:"time"`
+	// Verb is create, update, patch, delete, deleteAllOf or event.
+	Verb        string `json:"verb"`
+	Subresource string `json:"subresource,omitempty"`
+	// Diff is a JSON merge patch for updates, the patch body for patches, the
+	// full object for creates and the message for events.
+	Diff string `json:"diff,omitempty"`
+	// ServerValidated is true when the API server accepted the change as a
+	// dry-run request.
+	ServerValidated bool   `json:"serverValidated"`
+	Error           string `json:"error,omitempty"`
+	// Count is how many times in a row the change was made. Time is when it
+	// was made last.
+	Count int `json:"count"`
+}
+
+// ObjectChanges lists the pending changes of one object.
+type ObjectChanges struct {
+	Object  string   `json:"object"`
+	Changes []Change `json:"changes"`
+}
+
+// Store keeps the pending changes per object. It is safe for concurrent use.
+type Store struct {
+	mu      sync.Mutex
+	changes map[string][]Change
+}
+
+// NewStore returns an empty Store.
+func NewStore() *Store {
+	return &Store{changes: map[string][]Change{}}
+}
+
+// Record adds a change for the object identified by ref. A change equal to
+// the last one of the object is counted instead of added again, since the
+// writes never persist and reconcilers keep making the same one.
+func (s *Store) Record(ref string, c Change) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changes := s.changes[ref]
+	if n := len(changes); n > 0 && sameChange(changes[n-1], c) {
+		changes[n-1].Time = c.Time
+		changes[n-1].Count++
+		return
+	}
+	c.Count = 1
+	changes = append(changes, c)
+	if len(changes) > maxChangesPerObject {
+		changes = changes[len(changes)-maxChangesPerObject:]
+	}
+	s.changes[ref] = changes
+}
+
+func sameChange(a, b Change) bool {
+	return a.Verb == b.Verb && a.Subresource == b.Subresource && a.Diff == b.Diff &&
+		a.ServerValidated == b.ServerValidated && a.Error == b.Error
+}
+
+// Summary returns the pending changes sorted by object.
+func (s *Store) Summary() []ObjectChanges {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary := make([]ObjectChanges, 0, len(s.changes))
+	for ref, changes := range s.changes {
+		summary = append(summary, ObjectChanges{Object: ref, Changes: append([]Change(nil), changes...)})
+	}
+	sort.Slice(summary, func(i, j int) bool { return summary[i].Object < summary[j].Object })
+	return summary
+}
+
+// ServeHTTP writes the summary as JSON. The object query parameter limits the
+// output to a single object reference.
+func (s *Store) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	summary := s.Summary()
+	if ref := req.URL.Query().Get("object"); ref != "" {
+		filtered := summary[:0]
+		for _, oc := range summary {
+			if oc.Object == ref {
+				filtered = append(filtered, oc)
+			}
+		}
+		summary = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(summary); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
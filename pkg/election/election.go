@@ -0,0 +1,221 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package election reports the leader election state of the manager through
+// metrics, events and an HTTP endpoint.
+package election
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github/antmoveh/kube-develop-tools/pkg/metrics"
+)
+
+const (
+	// ReasonElected is the reason of the event recorded when this replica
+	// becomes the leader.
+	ReasonElected = "LeaderElected"
+	// ReasonStepDown is the reason of the event recorded when this replica
+	// stops leading, which happens when it shuts down.
+	ReasonStepDown = "LeaderStepDown"
+)
+
+// namespaceFile holds the namespace of the pod, like controller-runtime reads
+// it when no leader election namespace is given.
+const namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// ValidateResourceLock checks that lock is a resource lock the manager can
+// use for leader election.
+func ValidateResourceLock(lock string) error {
+	switch lock {
+	case resourcelock.LeasesResourceLock, resourcelock.ConfigMapsLeasesResourceLock:
+		return nil
+	}
+	return fmt.Errorf("unsupported resource lock %q, expected %s or %s",
+		lock, resourcelock.LeasesResourceLock, resourcelock.ConfigMapsLeasesResourceLock)
+}
+
+// ValidateTimings checks the lease timings like the leader elector does when
+// the manager starts, so bad flags fail early with a clear message.
+func ValidateTimings(leaseDuration, renewDeadline, retryPeriod time.Duration) error {
+	if retryPeriod <= 0 {
+		return fmt.Errorf("retry period %s must be positive", retryPeriod)
+	}
+	if renewDeadline <= retryPeriod {
+		return fmt.Errorf("renew deadline %s must be longer than the retry period %s", renewDeadline, retryPeriod)
+	}
+	if leaseDuration <= renewDeadline {
+		return fmt.Errorf("lease duration %s must be longer than the renew deadline %s", leaseDuration, renewDeadline)
+	}
+	return nil
+}
+
+// InClusterNamespace returns the namespace the pod runs in, or "" outside a
+// cluster.
+func InClusterNamespace() string {
+	data, err := ioutil.ReadFile(namespaceFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Tracker follows whether the manager is the leader. It runs on every
+// replica, serves the state as JSON and records a metric and an event on the
+// Lease when the leadership changes.
+type Tracker struct {
+	// Elected is closed once the manager is the leader, see
+	// manager.Manager.Elected.
+	Elected <-chan struct{}
+	// Reader reads the Lease to report the current holder. It should not be
+	// backed by the cache, which would watch every Lease in the cluster.
+	Reader client.Reader
+	// Recorder records the leadership changes on the Lease.
+	Recorder record.EventRecorder
+	// Lease is the Lease used for leader election. An empty name disables
+	// the holder lookup and the events.
+	Lease types.NamespacedName
+
+	mu     sync.Mutex
+	leader bool
+	since  time.Time
+}
+
+var _ manager.Runnable = &Tracker{}
+var _ manager.LeaderElectionRunnable = &Tracker{}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable; the tracker
+// runs on every replica.
+func (t *Tracker) NeedLeaderElection() bool {
+	return false
+}
+
+// Start waits for the manager to be elected and records the transitions
+// until ctx is done.
+func (t *Tracker) Start(ctx context.Context) error {
+	metrics.Leader.Set(0)
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-t.Elected:
+	}
+
+	t.setLeader(true)
+	log.FromContext(ctx).Info("became the leader", "lease", t.Lease)
+	metrics.LeaderTransitions.WithLabelValues("elected").Inc()
+	t.event(ctx, corev1.EventTypeNormal, ReasonElected, "became the leader")
+
+	<-ctx.Done()
+	t.setLeader(false)
+	metrics.LeaderTransitions.WithLabelValues("stepped_down").Inc()
+	// Best effort, the manager may already be shutting the broadcaster down.
+	t.event(context.Background(), corev1.EventTypeNormal, ReasonStepDown, "stopped leading on shutdown")
+	return nil
+}
+
+func (t *Tracker) setLeader(leader bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.leader, t.since = leader, time.Now()
+	if leader {
+		metrics.Leader.Set(1)
+	} else {
+		metrics.Leader.Set(0)
+	}
+}
+
+// IsLeader reports whether the manager is currently the leader.
+func (t *Tracker) IsLeader() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.leader
+}
+
+func (t *Tracker) event(ctx context.Context, eventtype, reason, message string) {
+	if t.Recorder == nil || t.Lease.Name == "" {
+		return
+	}
+	lease := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: t.Lease.Namespace, Name: t.Lease.Name}}
+	if t.Reader != nil {
+		// The event links to the Lease by UID when it can be read.
+		_ = t.Reader.Get(ctx, t.Lease, lease)
+	}
+	t.Recorder.Event(lease, eventtype, reason, message)
+}
+
+// Status is the leader election state served by the tracker.
+type Status struct {
+	Leader bool `json:"leader"`
+	// Since is when the replica last became or stopped being the leader.
+	Since *metav1.Time `json:"since,omitempty"`
+	Lease string       `json:"lease,omitempty"`
+	// Holder and RenewTime come from the Lease.
+	Holder    string       `json:"holder,omitempty"`
+	RenewTime *metav1.Time `json:"renewTime,omitempty"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// Status returns the current leader election state.
+func (t *Tracker) Status(ctx context.Context) Status {
+	t.mu.Lock()
+	s := Status{Leader: t.leader}
+	if !t.since.IsZero() {
+		s.Since = &metav1.Time{Time: t.since}
+	}
+	t.mu.Unlock()
+
+	if t.Lease.Name == "" || t.Reader == nil {
+		return s
+	}
+	s.Lease = t.Lease.String()
+	lease := new(coordinationv1.Lease)
+	if err := t.Reader.Get(ctx, t.Lease, lease); err != nil {
+		s.Error = err.Error()
+		return s
+	}
+	if lease.Spec.HolderIdentity != nil {
+		s.Holder = *lease.Spec.HolderIdentity
+	}
+	if lease.Spec.RenewTime != nil {
+		s.RenewTime = &metav1.Time{Time: lease.Spec.RenewTime.Time}
+	}
+	return s
+}
+
+// ServeHTTP serves the leader election state as JSON.
+func (t *Tracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(t.Status(r.Context()))
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package election
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github/antmoveh/kube-develop-tools/pkg/metrics"
+)
+
+func TestTracker(t *testing.T) {
+	holder := "replica-1"
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "system", Name: "lock"},
+		Spec:       coordinationv1.LeaseSpec{HolderIdentity: &holder, RenewTime: &metav1.MicroTime{Time: time.Now()}},
+	}
+	elected := make(chan struct{})
+	recorder := record.NewFakeRecorder(10)
+	tracker := &Tracker{
+		Elected:  elected,
+		Reader:   fake.NewClientBuilder().WithObjects(lease).Build(),
+		Recorder: recorder,
+		Lease:    types.NamespacedName{Namespace: "system", Name: "lock"},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- tracker.Start(ctx) }()
+
+	if tracker.IsLeader() {
+		t.Fatal("leader before the election")
+	}
+	close(elected)
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) { return tracker.IsLeader(), nil })
+	if err != nil {
+		t.Fatal("never became the leader")
+	}
+	if got := testutil.ToFloat64(metrics.Leader); got != 1 {
+		t.Errorf("leader gauge = %v, want 1", got)
+	}
+	if e := <-recorder.Events; e != "Normal LeaderElected became the leader" {
+		t.Errorf("got event %q", e)
+	}
+
+	rec := httptest.NewRecorder()
+	tracker.ServeHTTP(rec, httptest.NewRequest("GET", "/leader", nil))
+	var status Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if !status.Leader || status.Holder != holder || status.Lease != "system/lock" || status.RenewTime == nil {
+		t.Errorf("got status %+v", status)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if tracker.IsLeader() {
+		t.Error("still the leader after shutdown")
+	}
+	if got := testutil.ToFloat64(metrics.Leader); got != 0 {
+		t.Errorf("leader gauge = %v, want 0", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := ValidateResourceLock("leases"); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateResourceLock("endpoints"); err == nil {
+		t.Error("endpoints lock accepted")
+	}
+	if err := ValidateTimings(15*time.Second, 10*time.Second, 2*time.Second); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateTimings(10*time.Second, 10*time.Second, 2*time.Second); err == nil {
+		t.Error("lease duration equal to the renew deadline accepted")
+	}
+	if err := ValidateTimings(15*time.Second, 2*time.Second, 2*time.Second); err == nil {
+		t.Error("renew deadline equal to the retry period accepted")
+	}
+}
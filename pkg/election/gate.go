@@ -0,0 +1,145 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package election
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Gate runs runnables only while this replica holds a Lease of its own. It
+// keeps one active replica for a part of a manager that runs without leader
+// election, like the Cluster controller next to sharded World controllers.
+type Gate struct {
+	// Lock is the lock the replicas compete for.
+	Lock resourcelock.Interface
+	// LeaseDuration, RenewDeadline and RetryPeriod are the timings of the
+	// election, as for the manager.
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+
+	mu        sync.Mutex
+	runnables []manager.Runnable
+	started   bool
+}
+
+var _ manager.Runnable = &Gate{}
+var _ manager.LeaderElectionRunnable = &Gate{}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable; the gate
+// runs its own election on every replica.
+func (g *Gate) NeedLeaderElection() bool {
+	return false
+}
+
+// Add registers r to run while the gate holds the lock. Dependencies are
+// not injected; use Manager to set up controllers behind the gate.
+func (g *Gate) Add(r manager.Runnable) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.started {
+		return fmt.Errorf("cannot add runnables to the gate of %s after it started", g.Lock.Describe())
+	}
+	g.runnables = append(g.runnables, r)
+	return nil
+}
+
+// Start campaigns for the lock until ctx is done and runs the runnables
+// while it is held. Losing the lock returns an error, which stops the
+// manager like losing its own lease does.
+func (g *Gate) Start(ctx context.Context) error {
+	g.mu.Lock()
+	g.started = true
+	runnables := g.runnables
+	g.mu.Unlock()
+
+	logger := log.FromContext(ctx).WithValues("lock", g.Lock.Describe(), "identity", g.Lock.Identity())
+	errs := make(chan error, len(runnables))
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            g.Lock,
+		LeaseDuration:   g.LeaseDuration,
+		RenewDeadline:   g.RenewDeadline,
+		RetryPeriod:     g.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            g.Lock.Describe(),
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				logger.Info("acquired the gate lock, starting its runnables", "runnables", len(runnables))
+				for _, r := range runnables {
+					go func(r manager.Runnable) {
+						if err := r.Start(ctx); err != nil {
+							errs <- err
+						}
+					}(r)
+				}
+			},
+			OnStoppedLeading: func() {
+				logger.Info("released the gate lock")
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		elector.Run(runCtx)
+	}()
+
+	select {
+	case err := <-errs:
+		cancel()
+		<-done
+		return err
+	case <-done:
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("lost the lock %s", g.Lock.Describe())
+	}
+}
+
+// Manager returns mgr with Add redirected to the gate. Controllers set up
+// with it get their dependencies from mgr but only run while the gate holds
+// the lock.
+func (g *Gate) Manager(mgr manager.Manager) manager.Manager {
+	return &gatedManager{Manager: mgr, gate: g}
+}
+
+type gatedManager struct {
+	manager.Manager
+	gate *Gate
+}
+
+func (m *gatedManager) Add(r manager.Runnable) error {
+	if err := m.Manager.SetFields(r); err != nil {
+		return err
+	}
+	return m.gate.Add(r)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package election
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// startRecorder is a runnable that reports when it starts and stops.
+type startRecorder struct {
+	started, stopped chan struct{}
+}
+
+func newStartRecorder() *startRecorder {
+	return &startRecorder{started: make(chan struct{}), stopped: make(chan struct{})}
+}
+
+func (r *startRecorder) Start(ctx context.Context) error {
+	close(r.started)
+	<-ctx.Done()
+	close(r.stopped)
+	return nil
+}
+
+func newGate(clientset *fake.Clientset, identity string) *Gate {
+	return &Gate{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Namespace: "system", Name: "clusters"},
+			Client:     clientset.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		},
+		LeaseDuration: 2 * time.Second,
+		RenewDeadline: time.Second,
+		RetryPeriod:   100 * time.Millisecond,
+	}
+}
+
+func TestGate(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	first, second := newGate(clientset, "replica-1"), newGate(clientset, "replica-2")
+	firstRunnable, secondRunnable := newStartRecorder(), newStartRecorder()
+	if err := first.Add(firstRunnable); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Add(secondRunnable); err != nil {
+		t.Fatal(err)
+	}
+
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	firstDone := make(chan error, 1)
+	go func() { firstDone <- first.Start(firstCtx) }()
+	select {
+	case <-firstRunnable.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the runnable of the first replica did not start")
+	}
+	if err := first.Add(newStartRecorder()); err == nil {
+		t.Error("Add() after Start() succeeded")
+	}
+
+	secondCtx, stopSecond := context.WithCancel(context.Background())
+	defer stopSecond()
+	secondDone := make(chan error, 1)
+	go func() { secondDone <- second.Start(secondCtx) }()
+	select {
+	case <-secondRunnable.started:
+		t.Fatal("the second replica started while the first holds the lock")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// 第一个副本退出时释放锁，第二个副本接管
+	stopFirst()
+	if err := <-firstDone; err != nil {
+		t.Errorf("Start() of the first replica = %v", err)
+	}
+	<-firstRunnable.stopped
+	select {
+	case <-secondRunnable.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second replica did not take over the released lock")
+	}
+
+	stopSecond()
+	if err := <-secondDone; err != nil {
+		t.Errorf("Start() of the second replica = %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package health scores Clusters from periodic probes over a sliding window
+// and decides, with hysteresis, whether they stay schedulable.
+package health
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/metrics"
+)
+
+const (
+	// DefaultInterval is how often a Cluster is probed.
+	DefaultInterval = 30 * time.Second
+	// DefaultWindow is how far back probes count towards the score.
+	DefaultWindow = 5 * time.Minute
+	// DefaultCordonBelow is the score under which a Cluster is cordoned.
+	DefaultCordonBelow = 50
+	// DefaultUncordonAbove is the score a cordoned Cluster must reach again
+	// before it is uncordoned.
+	DefaultUncordonAbove = 70
+	// DefaultMinSamples is the number of probes needed before a Cluster can
+	// be cordoned, so one bad probe after a restart does not cordon it.
+	DefaultMinSamples = 3
+
+	// goodLatency and badLatency bound the API latency that scores 1 and 0.
+	goodLatency = 200 * time.Millisecond
+	badLatency  = 2 * time.Second
+
+	// 各项指标的权重，合计为1
+	latencyWeight = 0.3
+	nodesWeight   = 0.4
+	podsWeight    = 0.3
+)
+
+// ErrUnknown is returned by Probers that cannot tell the health of a
+// Cluster. No sample is kept for it and its health stays unknown.
+var ErrUnknown = errors.New("the health of the Cluster cannot be probed")
+
+// Sample is the result of one probe.
+type Sample struct {
+	Time time.Time
+	// Err is why the probe failed. A failed probe scores 0.
+	Err error
+	// Latency is how long the API server took to answer.
+	Latency    time.Duration
+	Nodes      int32
+	ReadyNodes int32
+	Pods       int32
+	FailedPods int32
+	// SkipPods marks probes that do not count Pods. Their score leaves the
+	// pod weight out instead of counting no Pods as all running.
+	SkipPods bool
+}
+
+// score returns the health of s from 0 to 1.
+func (s Sample) score() float64 {
+	if s.Err != nil {
+		return 0
+	}
+	latency := 1 - float64(s.Latency-goodLatency)/float64(badLatency-goodLatency)
+	latency = math.Max(0, math.Min(1, latency))
+	score := latencyWeight*latency + nodesWeight*ratio(s.ReadyNodes, s.Nodes)
+	if s.SkipPods {
+		return score / (latencyWeight + nodesWeight)
+	}
+	return score + podsWeight*ratio(s.Pods-s.FailedPods, s.Pods)
+}
+
+// ratio is n/total, or 1 when there is nothing to count so a Cluster
+// without nodes or pods is not penalized for them.
+func ratio(n, total int32) float64 {
+	if total == 0 {
+		return 1
+	}
+	return float64(n) / float64(total)
+}
+
+// Prober probes a Cluster.
+type Prober interface {
+	Probe(ctx context.Context, cluster *commonv1beta1.Cluster) (Sample, error)
+}
+
+// Monitor keeps the probes of every Cluster over the window. A nil Monitor
+// disables health scoring.
+type Monitor struct {
+	Prober Prober
+	// Interval is how often Clusters are probed, DefaultInterval when zero.
+	Interval time.Duration
+	// Window is how far back probes count, DefaultWindow when zero.
+	Window time.Duration
+	// CordonBelow and UncordonAbove are the scores a Cluster is cordoned
+	// under and uncordoned at. The gap between them keeps a Cluster whose
+	// score hovers around a threshold from flapping.
+	CordonBelow   int32
+	UncordonAbove int32
+	// MinSamples is the number of probes in the window needed to cordon.
+	MinSamples int
+
+	mu      sync.Mutex
+	samples map[string][]Sample
+	now     func() time.Time
+}
+
+// NewMonitor returns a Monitor with the default settings.
+func NewMonitor(prober Prober) *Monitor {
+	return &Monitor{
+		Prober:        prober,
+		Interval:      DefaultInterval,
+		Window:        DefaultWindow,
+		CordonBelow:   DefaultCordonBelow,
+		UncordonAbove: DefaultUncordonAbove,
+		MinSamples:    DefaultMinSamples,
+	}
+}
+
+// RequeueAfter is when the Cluster should be probed again.
+func (m *Monitor) RequeueAfter() time.Duration {
+	if m.Interval <= 0 {
+		return DefaultInterval
+	}
+	return m.Interval
+}
+
+// Probe probes cluster, adds the sample to its window and returns the
+// health over the window. A Cluster whose last sample is younger than
+// Interval is not probed again; reconciles triggered by events in between
+// get the health of the current window, so busy Clusters do not get more
+// samples than quiet ones. The health of a Cluster the Prober reports
+// ErrUnknown for is nil.
+func (m *Monitor) Probe(ctx context.Context, cluster *commonv1beta1.Cluster) *commonv1beta1.ClusterHealth {
+	if h := m.recent(cluster.Name); h != nil {
+		return h
+	}
+	s, err := m.Prober.Probe(ctx, cluster)
+	if errors.Is(err, ErrUnknown) {
+		m.Forget(cluster.Name)
+		return nil
+	}
+	s.Err = err
+	return m.Observe(cluster.Name, s)
+}
+
+// Observe adds a sample taken by someone else, such as the agent of a
+// pull-mode Cluster, to the window of name and returns the health over the
+// window. Like Probe, it keeps at most one sample per Interval.
+func (m *Monitor) Observe(name string, s Sample) *commonv1beta1.ClusterHealth {
+	if h := m.recent(name); h != nil {
+		return h
+	}
+	s.Time = m.clock()
+	return m.add(name, s)
+}
+
+// recent returns the health of name when its last sample is younger than
+// Interval, and nil when it is due for a new one.
+func (m *Monitor) recent(name string) *commonv1beta1.ClusterHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	samples := m.samples[name]
+	if len(samples) == 0 || m.clock().Sub(samples[len(samples)-1].Time) >= m.RequeueAfter() {
+		return nil
+	}
+	return summarize(samples)
+}
+
+func (m *Monitor) add(name string, s Sample) *commonv1beta1.ClusterHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.samples == nil {
+		m.samples = map[string][]Sample{}
+	}
+	window := m.Window
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	samples := append(m.samples[name], s)
+	for len(samples) > 0 && s.Time.Sub(samples[0].Time) > window {
+		samples = samples[1:]
+	}
+	m.samples[name] = samples
+
+	h := summarize(samples)
+	metrics.ClusterHealthScore.WithLabelValues(name).Set(float64(h.Score))
+	return h
+}
+
+// summarize computes the health over samples, which must not be empty.
+func summarize(samples []Sample) *commonv1beta1.ClusterHealth {
+	last := samples[len(samples)-1]
+	h := &commonv1beta1.ClusterHealth{
+		Samples:       int32(len(samples)),
+		LastProbeTime: metav1.NewTime(last.Time),
+	}
+	if last.Err != nil {
+		h.LastProbeError = last.Err.Error()
+	}
+	var total float64
+	var latency time.Duration
+	var ok int64
+	for _, sample := range samples {
+		total += sample.score()
+		if sample.Err != nil {
+			continue
+		}
+		latency += sample.Latency
+		ok++
+		h.Nodes, h.ReadyNodes = sample.Nodes, sample.ReadyNodes
+		h.Pods, h.FailedPods = sample.Pods, sample.FailedPods
+	}
+	if ok > 0 {
+		h.APILatency = metav1.Duration{Duration: latency / time.Duration(ok)}
+	}
+	h.Score = int32(math.Round(100 * total / float64(len(samples))))
+	return h
+}
+
+// Forget drops the probes of a deleted Cluster, or of one whose health
+// became unknown.
+func (m *Monitor) Forget(name string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	delete(m.samples, name)
+	m.mu.Unlock()
+	metrics.ClusterHealthScore.DeleteLabelValues(name)
+	metrics.ClusterCordoned.DeleteLabelValues(name)
+}
+
+// Schedulable decides whether a Cluster that is schedulable now stays so
+// with health h: it is cordoned under CordonBelow once the window holds
+// MinSamples probes, and only uncordoned at UncordonAbove.
+func (m *Monitor) Schedulable(schedulable bool, h *commonv1beta1.ClusterHealth) bool {
+	switch {
+	case schedulable && h.Score < m.CordonBelow && int(h.Samples) >= m.MinSamples:
+		return false
+	case !schedulable && h.Score >= m.UncordonAbove:
+		return true
+	}
+	return schedulable
+}
+
+func (m *Monitor) clock() time.Time {
+	if m.now != nil {
+		return m.now()
+	}
+	return time.Now()
+}
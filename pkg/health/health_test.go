@@ -0,0 +1,185 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+)
+
+func TestSampleScore(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		sample Sample
+		want   float64
+	}{
+		{name: "healthy", sample: Sample{Latency: 50 * time.Millisecond, Nodes: 3, ReadyNodes: 3, Pods: 10}, want: 1},
+		{name: "empty cluster", sample: Sample{Latency: goodLatency}, want: 1},
+		{name: "failed probe", sample: Sample{Err: errors.New("unreachable")}, want: 0},
+		{name: "slow", sample: Sample{Latency: badLatency}, want: 1 - latencyWeight},
+		{name: "half the nodes", sample: Sample{Nodes: 4, ReadyNodes: 2}, want: 1 - nodesWeight/2},
+		{name: "failing pods", sample: Sample{Pods: 10, FailedPods: 10}, want: 1 - podsWeight},
+		{name: "pods not counted", sample: Sample{Nodes: 4, ReadyNodes: 2, SkipPods: true}, want: 1 - nodesWeight/2/(latencyWeight+nodesWeight)},
+		{name: "pods not counted, all nodes down", sample: Sample{Nodes: 4, SkipPods: true}, want: latencyWeight / (latencyWeight + nodesWeight)},
+	} {
+		if got := tc.sample.score(); got < tc.want-1e-9 || got > tc.want+1e-9 {
+			t.Errorf("%s: score = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMonitorWindow(t *testing.T) {
+	m := NewMonitor(nil)
+	start := time.Now()
+	healthy := Sample{Nodes: 2, ReadyNodes: 2, Pods: 4, FailedPods: 1, Latency: 100 * time.Millisecond}
+
+	healthy.Time = start
+	if h := m.add("c1", healthy); h.Score != 93 || h.Samples != 1 || h.APILatency.Duration != healthy.Latency || h.FailedPods != 1 {
+		t.Errorf("health after a healthy probe = %+v", h)
+	}
+	h := m.add("c1", Sample{Time: start.Add(time.Minute), Err: errors.New("timeout")})
+	if h.Score != 46 || h.Samples != 2 || h.LastProbeError != "timeout" || h.Nodes != 2 {
+		t.Errorf("health after a failed probe = %+v", h)
+	}
+	// 窗口外的探测结果不再计分
+	h = m.add("c1", Sample{Time: start.Add(DefaultWindow + 2*time.Minute), Err: errors.New("timeout")})
+	if h.Score != 0 || h.Samples != 1 || h.APILatency.Duration != 0 {
+		t.Errorf("health after the healthy probe left the window = %+v", h)
+	}
+
+	m.Forget("c1")
+	healthy.Time = start.Add(DefaultWindow + 3*time.Minute)
+	if h := m.add("c1", healthy); h.Samples != 1 {
+		t.Errorf("samples after Forget = %d, want 1", h.Samples)
+	}
+}
+
+type countingProber struct {
+	probes int
+}
+
+func (p *countingProber) Probe(context.Context, *commonv1beta1.Cluster) (Sample, error) {
+	p.probes++
+	return Sample{Nodes: 1, ReadyNodes: 1}, nil
+}
+
+func TestMonitorProbesOncePerInterval(t *testing.T) {
+	prober := &countingProber{}
+	m := NewMonitor(prober)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	cluster := &commonv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c1"}}
+
+	for i := 0; i < 3; i++ {
+		if h := m.Probe(context.Background(), cluster); h.Samples != 1 || h.Score != 100 {
+			t.Errorf("health after probe %d = %+v, want one sample", i, h)
+		}
+	}
+	if h := m.Observe("c1", Sample{Err: errors.New("down")}); h.Samples != 1 || h.LastProbeError != "" {
+		t.Errorf("health after an early observation = %+v, want it ignored", h)
+	}
+	if prober.probes != 1 {
+		t.Errorf("probed %d times within the interval, want 1", prober.probes)
+	}
+
+	now = now.Add(m.Interval)
+	if h := m.Probe(context.Background(), cluster); h.Samples != 2 {
+		t.Errorf("health after the interval = %+v, want two samples", h)
+	}
+	if prober.probes != 2 {
+		t.Errorf("probed %d times, want 2", prober.probes)
+	}
+}
+
+func TestMonitorSchedulable(t *testing.T) {
+	m := NewMonitor(nil)
+	for _, tc := range []struct {
+		name        string
+		schedulable bool
+		score       int32
+		samples     int32
+		want        bool
+	}{
+		{name: "healthy stays schedulable", schedulable: true, score: 90, samples: 5, want: true},
+		{name: "between thresholds stays schedulable", schedulable: true, score: 60, samples: 5, want: true},
+		{name: "low score cordons", schedulable: true, score: 40, samples: 5, want: false},
+		{name: "too few samples to cordon", schedulable: true, score: 0, samples: 2, want: true},
+		{name: "between thresholds stays cordoned", schedulable: false, score: 60, samples: 5, want: false},
+		{name: "recovered score uncordons", schedulable: false, score: 70, samples: 5, want: true},
+	} {
+		h := &commonv1beta1.ClusterHealth{Score: tc.score, Samples: tc.samples}
+		if got := m.Schedulable(tc.schedulable, h); got != tc.want {
+			t.Errorf("%s: Schedulable() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLocalProber(t *testing.T) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(commonv1beta1.AddToScheme(scheme))
+	node := func(name string, ready corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}}},
+		}
+	}
+	pod := func(name string, status corev1.PodStatus) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}, Status: status}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		node("n1", corev1.ConditionTrue),
+		node("n2", corev1.ConditionUnknown),
+		pod("running", corev1.PodStatus{Phase: corev1.PodRunning}),
+		pod("failed", corev1.PodStatus{Phase: corev1.PodFailed}),
+		pod("crashing", corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{{
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		}}}),
+	).Build()
+
+	s, err := (&LocalProber{Reader: c, APIReader: c}).Probe(context.Background(), &commonv1beta1.Cluster{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Nodes != 2 || s.ReadyNodes != 1 || s.Pods != 3 || s.FailedPods != 2 {
+		t.Errorf("sample = %+v", s)
+	}
+
+	// 带凭证的Cluster是别的集群，本地的状态不代表它
+	remote := &commonv1beta1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "alpha"},
+		Spec:       commonv1beta1.ClusterSpec{Credentials: &commonv1beta1.ClusterCredentials{}},
+	}
+	prober := &LocalProber{Reader: c, APIReader: c}
+	if _, err := prober.Probe(context.Background(), remote); !errors.Is(err, ErrUnknown) {
+		t.Errorf("Probe() of a Cluster with credentials: error = %v, want ErrUnknown", err)
+	}
+	if h := NewMonitor(prober).Probe(context.Background(), remote); h != nil {
+		t.Errorf("health of a Cluster with credentials = %+v, want unknown", h)
+	}
+}
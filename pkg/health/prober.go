@@ -0,0 +1,104 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+)
+
+// LocalProber probes the cluster the manager runs in, which Clusters without
+// credentials stand for. Clusters with credentials are other clusters whose
+// health it cannot tell; it reports ErrUnknown for them, the remote watches
+// score those.
+type LocalProber struct {
+	// Reader lists Nodes and Pods, usually from the manager cache.
+	Reader client.Reader
+	// APIReader reads from the API server directly to time it.
+	APIReader client.Reader
+}
+
+var _ Prober = &LocalProber{}
+
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+
+// Probe implements Prober.
+func (p *LocalProber) Probe(ctx context.Context, cluster *commonv1beta1.Cluster) (Sample, error) {
+	var s Sample
+	if cluster.Spec.Credentials != nil {
+		return s, ErrUnknown
+	}
+	start := time.Now()
+	if err := p.APIReader.List(ctx, &commonv1beta1.ClusterList{}, client.Limit(1)); err != nil {
+		return s, err
+	}
+	s.Latency = time.Since(start)
+
+	nodes := &corev1.NodeList{}
+	if err := p.Reader.List(ctx, nodes); err != nil {
+		return s, err
+	}
+	for i := range nodes.Items {
+		s.Nodes++
+		if nodeReady(&nodes.Items[i]) {
+			s.ReadyNodes++
+		}
+	}
+
+	pods := &corev1.PodList{}
+	if err := p.Reader.List(ctx, pods); err != nil {
+		return s, err
+	}
+	for i := range pods.Items {
+		s.Pods++
+		if podFailed(&pods.Items[i]) {
+			s.FailedPods++
+		}
+	}
+	return s, nil
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// podFailed reports whether pod failed or keeps failing to start.
+func podFailed(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodFailed {
+		return true
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if w := cs.State.Waiting; w != nil {
+			switch w.Reason {
+			case "CrashLoopBackOff", "ImagePullBackOff", "ErrImagePull", "CreateContainerConfigError":
+				return true
+			}
+		}
+	}
+	return false
+}
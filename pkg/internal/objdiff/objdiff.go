@@ -0,0 +1,60 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package objdiff describes the body of a client write as JSON. It is
+// shared by the audit and dry-run clients so both report writes the same way.
+package objdiff
+
+import (
+	"context"
+	"encoding/json"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MergePatch returns the JSON merge patch that turns the object stored
+// under the key of obj into obj, or obj itself when the stored object
+// cannot be read through reader.
+func MergePatch(ctx context.Context, reader client.Reader, obj client.Object) []byte {
+	desired, err := json.Marshal(obj)
+	if err != nil {
+		return nil
+	}
+	current := obj.DeepCopyObject().(client.Object)
+	if err := reader.Get(ctx, client.ObjectKeyFromObject(obj), current); err != nil {
+		return desired
+	}
+	original, err := json.Marshal(current)
+	if err != nil {
+		return desired
+	}
+	diff, err := jsonpatch.CreateMergePatch(original, desired)
+	if err != nil {
+		return desired
+	}
+	return diff
+}
+
+// PatchData returns the body patch sends for obj, or nil when it is not
+// valid JSON.
+func PatchData(obj client.Object, patch client.Patch) []byte {
+	data, err := patch.Data(obj)
+	if err != nil || !json.Valid(data) {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package inventory collects what member Clusters run, their Nodes, version,
+// CRDs and Namespaces, on a schedule.
+package inventory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+)
+
+const (
+	// DefaultInterval is how often the inventory of a Cluster is collected.
+	DefaultInterval = 10 * time.Minute
+	// DefaultMaxItems caps the Nodes, CRDs and Namespaces listed in the
+	// status of a Cluster.
+	DefaultMaxItems = 100
+	// DefaultReadTimeout bounds reading the inventory of a Cluster.
+	DefaultReadTimeout = 30 * time.Second
+)
+
+// Snapshot is what a Source read from a cluster.
+type Snapshot struct {
+	Version    string
+	Nodes      []corev1.Node
+	CRDs       []string
+	Namespaces []string
+}
+
+// Source reads a Snapshot from the cluster cfg points to.
+type Source interface {
+	Read(ctx context.Context, cfg *rest.Config) (*Snapshot, error)
+}
+
+// Collector collects the inventory of Clusters. A nil Collector disables
+// inventory collection.
+type Collector struct {
+	Source Source
+	// Local is the config of the cluster the manager runs in. Clusters
+	// without credentials are collected from it.
+	Local *rest.Config
+	// Interval is how often a Cluster is collected, DefaultInterval when
+	// zero.
+	Interval time.Duration
+	// MaxItems caps every list of the inventory, DefaultMaxItems when zero.
+	MaxItems int
+
+	now func() time.Time
+}
+
+// NewCollector returns a Collector with the default settings.
+func NewCollector(source Source, local *rest.Config) *Collector {
+	return &Collector{
+		Source:   source,
+		Local:    local,
+		Interval: DefaultInterval,
+		MaxItems: DefaultMaxItems,
+	}
+}
+
+func (c *Collector) interval() time.Duration {
+	if c.Interval <= 0 {
+		return DefaultInterval
+	}
+	return c.Interval
+}
+
+func (c *Collector) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// Due returns how long until inv should be collected again, 0 when it is
+// due now.
+func (c *Collector) Due(inv *commonv1beta1.ClusterInventory) time.Duration {
+	if inv == nil {
+		return 0
+	}
+	if remaining := inv.LastCollectionTime.Add(c.interval()).Sub(c.clock()); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Collect reads the cluster at cfg, the local cluster when cfg is nil, and
+// returns its inventory. When reading fails prev is kept and the error
+// recorded in it.
+func (c *Collector) Collect(ctx context.Context, cfg *rest.Config, prev *commonv1beta1.ClusterInventory) *commonv1beta1.ClusterInventory {
+	if cfg == nil {
+		cfg = c.Local
+	}
+	now := metav1.NewTime(c.clock())
+	s, err := c.Source.Read(ctx, cfg)
+	if err != nil {
+		return Failed(prev, now, err.Error())
+	}
+	inv := Build(s, c.MaxItems)
+	inv.LastCollectionTime = now
+	return inv
+}
+
+// Failed returns prev, or an empty inventory, with a failed collection at
+// now recorded.
+func Failed(prev *commonv1beta1.ClusterInventory, now metav1.Time, message string) *commonv1beta1.ClusterInventory {
+	inv := &commonv1beta1.ClusterInventory{}
+	if prev != nil {
+		inv = prev.DeepCopy()
+	}
+	inv.LastCollectionTime = now
+	inv.LastCollectionError = message
+	return inv
+}
+
+// Build turns s into an inventory whose lists hold at most maxItems entries,
+// DefaultMaxItems when maxItems is zero.
+func Build(s *Snapshot, maxItems int) *commonv1beta1.ClusterInventory {
+	if maxItems <= 0 {
+		maxItems = DefaultMaxItems
+	}
+	inv := &commonv1beta1.ClusterInventory{
+		KubernetesVersion: s.Version,
+		Capacity:          corev1.ResourceList{},
+		Allocatable:       corev1.ResourceList{},
+		NodeCount:         int32(len(s.Nodes)),
+		CRDCount:          int32(len(s.CRDs)),
+		NamespaceCount:    int32(len(s.Namespaces)),
+	}
+
+	nodes := append([]corev1.Node(nil), s.Nodes...)
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	for i := range nodes {
+		node := &nodes[i]
+		add(inv.Capacity, node.Status.Capacity)
+		add(inv.Allocatable, node.Status.Allocatable)
+		if i < maxItems {
+			inv.Nodes = append(inv.Nodes, commonv1beta1.NodeInventory{
+				Name:           node.Name,
+				KubeletVersion: node.Status.NodeInfo.KubeletVersion,
+				Ready:          nodeReady(node),
+				Capacity:       node.Status.Capacity.DeepCopy(),
+				Allocatable:    node.Status.Allocatable.DeepCopy(),
+			})
+		}
+	}
+
+	var truncated bool
+	inv.CRDs, truncated = firstNames(s.CRDs, maxItems)
+	inv.Truncated = truncated || len(nodes) > maxItems
+	inv.Namespaces, truncated = firstNames(s.Namespaces, maxItems)
+	inv.Truncated = inv.Truncated || truncated
+	return inv
+}
+
+// add adds the quantities of from to sum.
+func add(sum, from corev1.ResourceList) {
+	for name, q := range from {
+		total := sum[name]
+		total.Add(q)
+		sum[name] = total
+	}
+}
+
+// firstNames returns the first max names in order and whether some were
+// left out.
+func firstNames(names []string, max int) ([]string, bool) {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	if len(sorted) > max {
+		return sorted[:max], true
+	}
+	return sorted, false
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func node(name, cpu string, ready bool) corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	resources := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)}
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Capacity:    resources,
+			Allocatable: resources,
+			Conditions:  []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+			NodeInfo:    corev1.NodeSystemInfo{KubeletVersion: "v1.22.1"},
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	s := &Snapshot{
+		Version:    "v1.22.1",
+		Nodes:      []corev1.Node{node("c", "4", true), node("a", "2", false), node("b", "500m", true)},
+		CRDs:       []string{"worlds.study", "clusters.common"},
+		Namespaces: []string{"kube-system", "default", "apps"},
+	}
+
+	inv := Build(s, 2)
+	if inv.KubernetesVersion != "v1.22.1" || inv.NodeCount != 3 || inv.CRDCount != 2 || inv.NamespaceCount != 3 {
+		t.Errorf("inventory = %+v", inv)
+	}
+	if cpu := inv.Capacity[corev1.ResourceCPU]; cpu.String() != "6500m" {
+		t.Errorf("capacity cpu = %s, want the sum of every node", cpu.String())
+	}
+	var names []string
+	for _, n := range inv.Nodes {
+		names = append(names, n.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b"}) || inv.Nodes[0].Ready || !inv.Nodes[1].Ready {
+		t.Errorf("nodes = %+v, want a and b", inv.Nodes)
+	}
+	if !reflect.DeepEqual(inv.CRDs, []string{"clusters.common", "worlds.study"}) {
+		t.Errorf("crds = %v", inv.CRDs)
+	}
+	if !reflect.DeepEqual(inv.Namespaces, []string{"apps", "default"}) || !inv.Truncated {
+		t.Errorf("namespaces = %v, truncated = %t", inv.Namespaces, inv.Truncated)
+	}
+
+	if inv := Build(s, 0); inv.Truncated || len(inv.Nodes) != 3 {
+		t.Errorf("Build() with the default cap truncated %+v", inv)
+	}
+}
+
+type sourceFunc func(ctx context.Context, cfg *rest.Config) (*Snapshot, error)
+
+func (f sourceFunc) Read(ctx context.Context, cfg *rest.Config) (*Snapshot, error) {
+	return f(ctx, cfg)
+}
+
+func TestCollect(t *testing.T) {
+	local, remote := &rest.Config{Host: "local"}, &rest.Config{Host: "remote"}
+	var readErr error
+	var host string
+	c := NewCollector(sourceFunc(func(_ context.Context, cfg *rest.Config) (*Snapshot, error) {
+		host = cfg.Host
+		return &Snapshot{Version: "v1.22.1"}, readErr
+	}), local)
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	if c.Due(nil) != 0 {
+		t.Error("a Cluster without inventory is not due")
+	}
+	inv := c.Collect(context.Background(), nil, nil)
+	if host != "local" || inv.KubernetesVersion != "v1.22.1" || !inv.LastCollectionTime.Time.Equal(now) {
+		t.Errorf("Collect(nil) read %s: %+v", host, inv)
+	}
+	if due := c.Due(inv); due != DefaultInterval {
+		t.Errorf("Due() = %s right after collecting", due)
+	}
+
+	now = now.Add(DefaultInterval)
+	readErr = errors.New("unauthorized")
+	failed := c.Collect(context.Background(), remote, inv)
+	if host != "remote" || failed.KubernetesVersion != "v1.22.1" || failed.LastCollectionError != "unauthorized" {
+		t.Errorf("Collect() failure = %+v, want the previous inventory with the error", failed)
+	}
+	if inv.LastCollectionError != "" {
+		t.Error("Collect() modified the previous inventory")
+	}
+}
+
+func TestAPISourceTimeout(t *testing.T) {
+	// 成员集群的API server不响应
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	start := time.Now()
+	if _, err := (APISource{Timeout: 100 * time.Millisecond}).Read(context.Background(), &rest.Config{Host: server.URL}); err == nil {
+		t.Error("Read() succeeded against a hanging API server")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Read() returned after %s, want it bounded by the timeout", elapsed)
+	}
+}
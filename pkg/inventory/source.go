@@ -0,0 +1,96 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// APISource reads Snapshots from the API server. CRDs and Namespaces are
+// listed as metadata only.
+type APISource struct {
+	// Timeout bounds a Read, DefaultReadTimeout when zero.
+	Timeout time.Duration
+}
+
+var _ Source = APISource{}
+
+//+kubebuilder:rbac:groups="",resources=nodes;namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
+
+// Read implements Source.
+func (a APISource) Read(ctx context.Context, cfg *rest.Config) (*Snapshot, error) {
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = DefaultReadTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	// discovery的请求不接受ctx，用客户端超时限制
+	cfg = rest.CopyConfig(cfg)
+	cfg.Timeout = timeout
+
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	version, err := dc.ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New(cfg, client.Options{Scheme: clientgoscheme.Scheme})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{Version: version.GitVersion}
+	nodes := &corev1.NodeList{}
+	if err := c.List(ctx, nodes); err != nil {
+		return nil, err
+	}
+	s.Nodes = nodes.Items
+	if s.CRDs, err = listNames(ctx, c, apiextensionsv1.SchemeGroupVersion.WithKind("CustomResourceDefinitionList")); err != nil {
+		return nil, err
+	}
+	if s.Namespaces, err = listNames(ctx, c, corev1.SchemeGroupVersion.WithKind("NamespaceList")); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func listNames(ctx context.Context, c client.Client, gvk schema.GroupVersionKind) ([]string, error) {
+	list := &metav1.PartialObjectMetadataList{}
+	list.SetGroupVersionKind(gvk)
+	if err := c.List(ctx, list); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		names = append(names, item.Name)
+	}
+	return names, nil
+}
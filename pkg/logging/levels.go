@@ -0,0 +1,273 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/go-logr/logr"
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var levelslog = logf.Log.WithName("log-levels")
+
+// DefaultKey is the ConfigMap key that sets the level of the controllers
+// the ConfigMap does not list.
+const DefaultKey = "default"
+
+// Levels keeps a logger and a level per controller. The levels change at
+// runtime through ServeHTTP or, when ConfigMap is set, through the keys of
+// that ConfigMap, which are controller names or DefaultKey. A change made
+// over HTTP lasts until the ConfigMap changes.
+type Levels struct {
+	// ConfigMap holds the levels. An empty name disables the watch.
+	ConfigMap types.NamespacedName
+	// Informers watches the ConfigMap, usually the manager cache.
+	Informers cache.Informers
+
+	mu          sync.Mutex
+	opts        zap.Options
+	initial     zapcore.Level
+	fallback    zapcore.Level
+	controllers map[string]*controllerLogger
+}
+
+type controllerLogger struct {
+	level  uberzap.AtomicLevel
+	logger logr.Logger
+}
+
+var _ manager.Runnable = &Levels{}
+var _ manager.LeaderElectionRunnable = &Levels{}
+
+// NewLevels returns levels building the controller loggers from opts. The
+// level of opts, info by default, is the initial level of every controller.
+func NewLevels(opts zap.Options) *Levels {
+	initial := zapcore.InfoLevel
+	if opts.Development {
+		initial = zapcore.DebugLevel
+	}
+	if lvl, ok := opts.Level.(uberzap.AtomicLevel); ok {
+		initial = lvl.Level()
+	}
+	return &Levels{opts: opts, initial: initial, fallback: initial, controllers: map[string]*controllerLogger{}}
+}
+
+// Register adds controller so it is listed before its first reconcile.
+func (l *Levels) Register(controller string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.controllerLocked(controller)
+}
+
+// Logger returns the logger of controller.
+func (l *Levels) Logger(controller string) logr.Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.controllerLocked(controller).logger
+}
+
+func (l *Levels) controllerLocked(controller string) *controllerLogger {
+	if c, ok := l.controllers[controller]; ok {
+		return c
+	}
+	level := uberzap.NewAtomicLevelAt(l.fallback)
+	opts := l.opts
+	opts.ZapOpts = append([]uberzap.Option(nil), l.opts.ZapOpts...)
+	opts.Level = level
+	c := &controllerLogger{
+		level:  level,
+		logger: zap.New(zap.UseFlagOptions(&opts)).WithName("controllers").WithName(controller),
+	}
+	l.controllers[controller] = c
+	return c
+}
+
+// SetLevel changes the level of controller.
+func (l *Levels) SetLevel(controller string, level zapcore.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.controllerLocked(controller).level.SetLevel(level)
+}
+
+// Snapshot returns the level of every known controller.
+func (l *Levels) Snapshot() map[string]string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	levels := make(map[string]string, len(l.controllers))
+	for name, c := range l.controllers {
+		levels[name] = FormatLevel(c.level.Level())
+	}
+	return levels
+}
+
+// apply sets the levels from the data of the ConfigMap. Controllers it does
+// not list fall back to its default key, or to the initial level.
+func (l *Levels) apply(data map[string]string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.fallback = l.initial
+	if v, ok := data[DefaultKey]; ok {
+		if level, err := ParseLevel(v); err == nil {
+			l.fallback = level
+		} else {
+			levelslog.Error(err, "ignoring invalid log level", "key", DefaultKey)
+		}
+	}
+	names := make([]string, 0, len(data))
+	for name := range data {
+		if name != DefaultKey {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, c := range l.controllers {
+		c.level.SetLevel(l.fallback)
+	}
+	for _, name := range names {
+		level, err := ParseLevel(data[name])
+		if err != nil {
+			levelslog.Error(err, "ignoring invalid log level", "key", name)
+			continue
+		}
+		l.controllerLocked(name).level.SetLevel(level)
+	}
+	levelslog.Info("log levels updated", "default", FormatLevel(l.fallback), "levels", data)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable; every
+// replica follows the ConfigMap.
+func (l *Levels) NeedLeaderElection() bool {
+	return false
+}
+
+// Start follows the ConfigMap until ctx is done.
+func (l *Levels) Start(ctx context.Context) error {
+	if l.ConfigMap.Name == "" {
+		<-ctx.Done()
+		return nil
+	}
+	informer, err := l.Informers.GetInformer(ctx, &corev1.ConfigMap{})
+	if err != nil {
+		return err
+	}
+	update := func(obj interface{}) {
+		if cm, ok := obj.(*corev1.ConfigMap); ok && cm.Namespace == l.ConfigMap.Namespace && cm.Name == l.ConfigMap.Name {
+			l.apply(cm.Data)
+		}
+	}
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    update,
+		UpdateFunc: func(_, obj interface{}) { update(obj) },
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if cm, ok := obj.(*corev1.ConfigMap); ok && cm.Namespace == l.ConfigMap.Namespace && cm.Name == l.ConfigMap.Name {
+				l.apply(nil)
+			}
+		},
+	})
+	<-ctx.Done()
+	return nil
+}
+
+// ServeHTTP lists the levels on GET and changes the level of the controller
+// query parameter to the level query parameter on PUT or POST. Only serve it
+// behind authentication; ReadOnly is safe to expose.
+func (l *Levels) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		controller, value := r.URL.Query().Get("controller"), r.URL.Query().Get("level")
+		if controller == "" {
+			http.Error(w, "the controller query parameter is required", http.StatusBadRequest)
+			return
+		}
+		level, err := ParseLevel(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		l.SetLevel(controller, level)
+		levelslog.Info("log level changed", "controller", controller, "level", FormatLevel(level))
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(l.Snapshot())
+}
+
+// ReadOnly returns a handler that only lists the levels.
+func (l *Levels) ReadOnly() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		l.ServeHTTP(w, r)
+	})
+}
+
+// ParseLevel parses debug, info, error or a verbosity above 0 like the
+// --zap-log-level flag does.
+func ParseLevel(s string) (zapcore.Level, error) {
+	switch s {
+	case "debug":
+		return zapcore.DebugLevel, nil
+	case "info":
+		return zapcore.InfoLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid log level %q, expected debug, info, error or a verbosity above 0", s)
+	}
+	return zapcore.Level(-v), nil
+}
+
+// FormatLevel is the inverse of ParseLevel.
+func FormatLevel(level zapcore.Level) string {
+	if level < zapcore.DebugLevel {
+		return strconv.Itoa(int(-level))
+	}
+	return level.String()
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging gives every reconcile a logger with the same keys and a
+// level that can be changed per controller while the manager runs, through
+// an HTTP endpoint or a ConfigMap.
+package logging
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Keys the reconcile loggers use. The object key is the controller name,
+// e.g. world or cluster.
+const (
+	ControllerKey  = "controller"
+	ReconcileIDKey = "reconcileID"
+)
+
+type reconcileIDKey struct{}
+
+// WithReconcileID returns a context carrying a new reconcile ID.
+func WithReconcileID(ctx context.Context) context.Context {
+	return context.WithValue(ctx, reconcileIDKey{}, string(uuid.NewUUID()))
+}
+
+// ReconcileID returns the reconcile ID of ctx, or "" outside a reconcile.
+func ReconcileID(ctx context.Context) string {
+	id, _ := ctx.Value(reconcileIDKey{}).(string)
+	return id
+}
+
+// Reconciler gives every reconcile of the wrapped reconciler a reconcile ID
+// and a logger at the level of its controller, with the controller, the
+// object and the reconcile ID as values.
+type Reconciler struct {
+	reconcile.Reconciler
+	Controller string
+	// Levels holds the level of the controller. Nil keeps the logger of the
+	// manager.
+	Levels *Levels
+}
+
+// NewReconciler returns r logging as controller.
+func NewReconciler(controller string, levels *Levels, r reconcile.Reconciler) *Reconciler {
+	levels.Register(controller)
+	return &Reconciler{Reconciler: r, Controller: controller, Levels: levels}
+}
+
+// Reconcile runs the wrapped reconcile with the logger in its context.
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	if r.Levels != nil {
+		logger = r.Levels.Logger(r.Controller)
+	}
+	ctx = WithReconcileID(ctx)
+	logger = logger.WithValues(ControllerKey, r.Controller, r.Controller, objectKey(req), ReconcileIDKey, ReconcileID(ctx))
+	return r.Reconciler.Reconcile(log.IntoContext(ctx, logger), req)
+}
+
+// objectKey formats req as namespace/name, or name for cluster scoped
+// objects.
+func objectKey(req ctrl.Request) string {
+	if req.Namespace == "" {
+		return req.Name
+	}
+	return strings.Join([]string{req.Namespace, req.Name}, "/")
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func readLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		line := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestReconcilerKeys(t *testing.T) {
+	buf := new(bytes.Buffer)
+	levels := NewLevels(zap.Options{DestWriter: buf})
+
+	var reconcileID string
+	r := NewReconciler("cluster", levels, reconcile.Func(func(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+		reconcileID = ReconcileID(ctx)
+		log.FromContext(ctx).Info("reconciled")
+		log.FromContext(ctx).V(1).Info("hidden at info")
+		return ctrl.Result{}, nil
+	}))
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "c1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := readLines(t, buf)
+	if len(lines) != 1 {
+		t.Fatalf("got %d log lines, want 1: %v", len(lines), lines)
+	}
+	line := lines[0]
+	if reconcileID == "" || line[ReconcileIDKey] != reconcileID {
+		t.Errorf("reconcileID = %v, want %q", line[ReconcileIDKey], reconcileID)
+	}
+	if line[ControllerKey] != "cluster" || line["cluster"] != "c1" || line["logger"] != "controllers.cluster" {
+		t.Errorf("unexpected log line %v", line)
+	}
+}
+
+func TestLevels(t *testing.T) {
+	buf := new(bytes.Buffer)
+	levels := NewLevels(zap.Options{DestWriter: buf})
+	levels.Register("world")
+	levels.Register("cluster")
+
+	req := httptest.NewRequest(http.MethodPut, "/loglevel?controller=world&level=2", nil)
+	rec := httptest.NewRecorder()
+	levels.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d: %s", rec.Code, rec.Body)
+	}
+	got := map[string]string{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["world"] != "2" || got["cluster"] != "info" {
+		t.Errorf("levels after PUT = %v", got)
+	}
+	levels.Logger("world").V(2).Info("visible")
+	levels.Logger("cluster").V(1).Info("hidden")
+	if lines := readLines(t, buf); len(lines) != 1 || lines[0]["msg"] != "visible" {
+		t.Errorf("log lines = %v, want only the world debug line", lines)
+	}
+
+	rec = httptest.NewRecorder()
+	levels.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/loglevel?controller=world&level=loud", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid level status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = httptest.NewRecorder()
+	levels.ReadOnly().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/loglevel?controller=world&level=error", nil))
+	if rec.Code != http.StatusMethodNotAllowed || levels.Snapshot()["world"] != "2" {
+		t.Errorf("read-only PUT status = %d, level = %s, want %d and no change", rec.Code, levels.Snapshot()["world"], http.StatusMethodNotAllowed)
+	}
+	rec = httptest.NewRecorder()
+	levels.ReadOnly().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/loglevel", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"world": "2"`) {
+		t.Errorf("read-only GET status = %d: %s", rec.Code, rec.Body)
+	}
+
+	// The ConfigMap replaces the levels set over HTTP.
+	levels.apply(map[string]string{DefaultKey: "error", "cluster": "debug", "agent": "bogus"})
+	want := map[string]string{"world": "error", "cluster": "debug"}
+	for name, level := range levels.Snapshot() {
+		if want[name] != level {
+			t.Errorf("level of %s = %s, want %s", name, level, want[name])
+		}
+	}
+	levels.apply(nil)
+	if got := levels.Snapshot()["cluster"]; got != "info" {
+		t.Errorf("level of cluster after the ConfigMap was deleted = %s, want info", got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want zapcore.Level
+		err  bool
+	}{
+		{in: "debug", want: zapcore.DebugLevel},
+		{in: "info", want: zapcore.InfoLevel},
+		{in: "error", want: zapcore.ErrorLevel},
+		{in: "3", want: zapcore.Level(-3)},
+		{in: "0", err: true},
+		{in: "warn", err: true},
+	} {
+		got, err := ParseLevel(tc.in)
+		if (err != nil) != tc.err || (!tc.err && got != tc.want) {
+			t.Errorf("ParseLevel(%q) = %v, %v", tc.in, got, err)
+			continue
+		}
+		if !tc.err && FormatLevel(got) != tc.in {
+			t.Errorf("FormatLevel(%v) = %s, want %s", got, FormatLevel(got), tc.in)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package manifest reads, converts and writes World and Cluster manifests
+// offline, using the same conversion functions as the conversion webhook.
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+	"sigs.k8s.io/yaml"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	studyv1beta2 "github/antmoveh/kube-develop-tools/apis/study/v1beta2"
+)
+
+// Scheme knows every version of the project's API types.
+var Scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(studyv1beta1.AddToScheme(Scheme))
+	utilruntime.Must(studyv1beta2.AddToScheme(Scheme))
+	utilruntime.Must(commonscopeclusterv1beta1.AddToScheme(Scheme))
+}
+
+// Document is one document of a YAML stream. Object is nil for kinds the
+// Scheme does not know, which are kept verbatim in Raw.
+type Document struct {
+	Raw    []byte
+	Object runtime.Object
+}
+
+// Decode reads every YAML or JSON document from r. Documents of kinds outside
+// the Scheme are returned undecoded so they survive a rewrite.
+func Decode(r io.Reader) ([]Document, error) {
+	deserializer := serializer.NewCodecFactory(Scheme).UniversalDeserializer()
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+
+	var docs []Document
+	for {
+		raw, err := reader.Read()
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(raw)) == 0 || isComment(raw) {
+			continue
+		}
+		doc := Document{Raw: raw}
+		obj, _, err := deserializer.Decode(raw, nil, nil)
+		switch {
+		case err == nil:
+			doc.Object = obj
+		case !runtime.IsNotRegisteredError(err):
+			return nil, fmt.Errorf("document %d: %w", len(docs)+1, err)
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// Encode writes docs as a multi-document YAML stream. Undecoded documents are
+// written back verbatim. The null timestamps and empty status that typed
+// objects carry are left out.
+func Encode(w io.Writer, docs []Document) error {
+	for i, doc := range docs {
+		data := doc.Raw
+		if doc.Object != nil {
+			var err error
+			if data, err = marshal(doc.Object); err != nil {
+				return err
+			}
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func marshal(obj runtime.Object) ([]byte, error) {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	pruneNulls(u)
+	if st, ok := u["status"].(map[string]interface{}); ok && len(st) == 0 {
+		delete(u, "status")
+	}
+	return yaml.Marshal(u)
+}
+
+// KnownVersion reports whether any of the project's API groups has version.
+func KnownVersion(version string) bool {
+	for _, gv := range Scheme.PrioritizedVersionsAllGroups() {
+		if gv.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// HasVersion reports whether the kind of obj exists at the given version of
+// its group, so Convert can convert it there. Kinds with a single version,
+// like Cluster, do not.
+func HasVersion(obj runtime.Object, version string) bool {
+	gvk, err := gvkForObject(obj)
+	if err != nil {
+		return false
+	}
+	return Scheme.Recognizes(schema.GroupVersionKind{Group: gvk.Group, Version: version, Kind: gvk.Kind})
+}
+
+// Convert returns obj converted to the given version of its group. Objects
+// already at that version are returned unchanged. Conversion goes through
+// the Hub, exactly like the conversion webhook.
+func Convert(obj runtime.Object, version string) (runtime.Object, error) {
+	gvk, err := gvkForObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	if gvk.Version == version {
+		return obj, nil
+	}
+
+	dstGVK := schema.GroupVersionKind{Group: gvk.Group, Version: version, Kind: gvk.Kind}
+	dst, err := Scheme.New(dstGVK)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case isHub(obj):
+		convertible, ok := dst.(conversion.Convertible)
+		if !ok {
+			return nil, fmt.Errorf("%s is not convertible", dstGVK)
+		}
+		err = convertible.ConvertFrom(obj.(conversion.Hub))
+	case isHub(dst):
+		convertible, ok := obj.(conversion.Convertible)
+		if !ok {
+			return nil, fmt.Errorf("%s is not convertible", gvk)
+		}
+		err = convertible.ConvertTo(dst.(conversion.Hub))
+	default:
+		var hub runtime.Object
+		hub, err = hubFor(gvk.GroupKind())
+		if err != nil {
+			return nil, err
+		}
+		src, srcOK := obj.(conversion.Convertible)
+		tgt, tgtOK := dst.(conversion.Convertible)
+		if !srcOK || !tgtOK {
+			return nil, fmt.Errorf("cannot convert %s to %s", gvk, dstGVK)
+		}
+		if err = src.ConvertTo(hub.(conversion.Hub)); err == nil {
+			err = tgt.ConvertFrom(hub.(conversion.Hub))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dst.GetObjectKind().SetGroupVersionKind(dstGVK)
+	return dst, nil
+}
+
+// ToHub converts obj to the Hub version of its kind. Kinds with a single
+// version are returned unchanged.
+func ToHub(obj runtime.Object) (runtime.Object, error) {
+	if isHub(obj) {
+		return obj, nil
+	}
+	gvk, err := gvkForObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := obj.(conversion.Convertible); !ok {
+		return obj, nil
+	}
+	hub, err := hubFor(gvk.GroupKind())
+	if err != nil {
+		return nil, err
+	}
+	return Convert(obj, hub.GetObjectKind().GroupVersionKind().Version)
+}
+
+// Validate checks obj with the Validate method of its Hub version, which
+// is where the API types keep their rules. Objects are converted to the Hub
+// first. Kinds without rules are always valid.
+func Validate(obj runtime.Object) error {
+	hub, err := ToHub(obj)
+	if err != nil {
+		return err
+	}
+	v, ok := hub.(validator)
+	if !ok {
+		return nil
+	}
+	return v.Validate()
+}
+
+// validator is implemented by API types that can check themselves.
+type validator interface {
+	Validate() error
+}
+
+func hubFor(gk schema.GroupKind) (runtime.Object, error) {
+	for gvk := range Scheme.AllKnownTypes() {
+		if gvk.GroupKind() != gk {
+			continue
+		}
+		obj, err := Scheme.New(gvk)
+		if err != nil {
+			return nil, err
+		}
+		if isHub(obj) {
+			obj.GetObjectKind().SetGroupVersionKind(gvk)
+			return obj, nil
+		}
+	}
+	return nil, fmt.Errorf("no hub version registered for %s", gk)
+}
+
+func isHub(obj runtime.Object) bool {
+	_, ok := obj.(conversion.Hub)
+	return ok
+}
+
+func gvkForObject(obj runtime.Object) (schema.GroupVersionKind, error) {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if !gvk.Empty() {
+		return gvk, nil
+	}
+	gvks, _, err := Scheme.ObjectKinds(obj)
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+	return gvks[0], nil
+}
+
+// isComment reports whether a document only holds comments.
+func isComment(doc []byte) bool {
+	for _, line := range bytes.Split(doc, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] != '#' {
+			return false
+		}
+	}
+	return true
+}
+
+// pruneNulls removes null values from m and its nested maps.
+func pruneNulls(m map[string]interface{}) {
+	for k, v := range m {
+		switch v := v.(type) {
+		case nil:
+			delete(m, k)
+		case map[string]interface{}:
+			pruneNulls(v)
+		}
+	}
+}
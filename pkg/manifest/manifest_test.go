@@ -0,0 +1,146 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	studyv1beta2 "github/antmoveh/kube-develop-tools/apis/study/v1beta2"
+)
+
+const worlds = `# worlds in both versions
+apiVersion: v1
+kind: Secret
+metadata:
+  name: untouched
+---
+apiVersion: study.example.cn/v1beta1
+kind: World
+metadata:
+  name: one
+  labels:
+    app: demo
+spec:
+  world: hello
+  clusters:
+  - c1
+---
+apiVersion: study.example.cn/v1beta2
+kind: World
+metadata:
+  name: two
+spec:
+  earth: kitty
+`
+
+func TestConvertRoundTrip(t *testing.T) {
+	docs, err := Decode(strings.NewReader(worlds))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(docs) != 3 || docs[0].Object != nil {
+		t.Fatalf("Decode() returned %+v, want an undecoded Secret and two Worlds", docs)
+	}
+	objs := []runtime.Object{docs[1].Object, docs[2].Object}
+
+	v2, err := Convert(objs[0], "v1beta2")
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	w2 := v2.(*studyv1beta2.World)
+	if w2.Spec.Earth != "hello" || w2.Name != "one" || w2.Labels["app"] != "demo" || len(w2.Spec.Clusters) != 1 {
+		t.Errorf("unexpected v1beta2 World %+v", w2)
+	}
+
+	hub, err := ToHub(objs[1])
+	if err != nil {
+		t.Fatalf("ToHub() error = %v", err)
+	}
+	if w1 := hub.(*studyv1beta1.World); w1.Spec.World != "kitty" || w1.Name != "two" {
+		t.Errorf("unexpected v1beta1 World %+v", w1)
+	}
+
+	var out bytes.Buffer
+	if err := Encode(&out, []Document{docs[0], {Object: v2}, {Object: hub}}); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	want := `# worlds in both versions
+apiVersion: v1
+kind: Secret
+metadata:
+  name: untouched
+---
+apiVersion: study.example.cn/v1beta2
+kind: World
+metadata:
+  labels:
+    app: demo
+  name: one
+spec:
+  clusters:
+  - c1
+  earth: hello
+---
+apiVersion: study.example.cn/v1beta1
+kind: World
+metadata:
+  name: two
+spec:
+  world: kitty
+`
+	if out.String() != want {
+		t.Errorf("Encode() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	docs, err := Decode(strings.NewReader(`apiVersion: study.example.cn/v1beta2
+kind: World
+metadata:
+  name: bad
+spec:
+  earth: hello
+  clusters: [c1, c2]
+  placement:
+    numberOfClusters: 3
+    affinity:
+      required:
+        matchExpressions: [{key: region, operator: Near}]
+    weights: [{cluster: c1, weight: 1}, {cluster: c1, weight: 2}]
+  namespaceMapping:
+    policy: Map
+    namespaces: {team-a: Team_A}
+`))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	err = Validate(docs[0].Object)
+	if err == nil {
+		t.Fatal("Validate() accepted an invalid World")
+	}
+	for _, field := range []string{"spec.placement.numberOfClusters",
+		"spec.placement.affinity.required", "spec.placement.weights[1].cluster", "spec.namespaceMapping.namespaces[team-a]"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("Validate() error %q does not mention %s", err, field)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package manifestcmd holds the commands that validate and convert World
+// manifests without a cluster. world-manifest and kubectl-world share them.
+package manifestcmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github/antmoveh/kube-develop-tools/pkg/manifest"
+)
+
+// ErrFailed reports that some manifest failed; the details were already
+// printed.
+var ErrFailed = errors.New("some manifests failed the check")
+
+// NewValidateCommand returns the command that validates manifests.
+func NewValidateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate [PATH...]",
+		Short: "Validate manifests against the rules of their API types",
+		Long: "Validate the World manifests in the given files and directories, or stdin when no\n" +
+			"path or - is given. Documents of other kinds are ignored.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			failed := false
+			err := eachFile(cmd, args, func(f *file) error {
+				if !validateFile(cmd.ErrOrStderr(), f) {
+					failed = true
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if failed {
+				return ErrFailed
+			}
+			return nil
+		},
+	}
+}
+
+// NewConvertCommand returns the command that converts manifests between
+// API versions, printing them or rewriting the files in place.
+func NewConvertCommand() *cobra.Command {
+	var version string
+	var filenames []string
+	var write, check bool
+	cmd := &cobra.Command{
+		Use:   "convert [--to VERSION] [-f PATH...] [PATH...]",
+		Short: "Convert manifests to another API version",
+		Long: "Convert the World manifests in the given files and directories, or stdin when no\n" +
+			"path or - is given. Kinds without the target version, like Cluster, are kept as they\n" +
+			"are. Manifests are validated first and nothing is converted when a file is invalid.\n" +
+			"Rewritten files lose their comments.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !manifest.KnownVersion(version) {
+				return fmt.Errorf("unknown API version %q", version)
+			}
+			failed := false
+			err := eachFile(cmd, append(filenames, args...), func(f *file) error {
+				if !validateFile(cmd.ErrOrStderr(), f) {
+					failed = true
+					return nil
+				}
+
+				changed := false
+				for i := range f.docs {
+					doc := &f.docs[i]
+					// 目标版本中没有的类型（如Cluster）原样保留
+					if doc.Object == nil || doc.Object.GetObjectKind().GroupVersionKind().Version == version ||
+						!manifest.HasVersion(doc.Object, version) {
+						continue
+					}
+					converted, err := manifest.Convert(doc.Object, version)
+					if err != nil {
+						return fmt.Errorf("%s: %w", f.name, err)
+					}
+					doc.Object = converted
+					changed = true
+				}
+
+				switch {
+				case check:
+					if changed {
+						fmt.Fprintf(cmd.ErrOrStderr(), "%s: not at %s\n", f.name, version)
+						failed = true
+					}
+					return nil
+				case write && f.path != "":
+					if !changed {
+						return nil
+					}
+					var buf bytes.Buffer
+					if err := manifest.Encode(&buf, f.docs); err != nil {
+						return err
+					}
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s: converted to %s\n", f.name, version)
+					return ioutil.WriteFile(f.path, buf.Bytes(), f.mode)
+				default:
+					if f.index > 0 {
+						fmt.Fprintln(cmd.OutOrStdout(), "---")
+					}
+					return manifest.Encode(cmd.OutOrStdout(), f.docs)
+				}
+			})
+			if err != nil {
+				return err
+			}
+			if failed {
+				return ErrFailed
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&version, "to", "v1beta2", "The API version to convert to.")
+	cmd.Flags().StringArrayVarP(&filenames, "filename", "f", nil, "A manifest file or directory to convert, like a path argument.")
+	cmd.Flags().BoolVarP(&write, "write", "w", false, "Rewrite the files in place instead of printing them.")
+	cmd.Flags().BoolVar(&check, "check", false, "Only report files that are not at the target version and fail if there are any.")
+	return cmd
+}
+
+// validateFile prints every invalid document of f and reports whether all
+// documents are valid.
+func validateFile(out io.Writer, f *file) bool {
+	valid := true
+	for i, doc := range f.docs {
+		if doc.Object == nil {
+			continue
+		}
+		if err := manifest.Validate(doc.Object); err != nil {
+			fmt.Fprintf(out, "%s: document %d: %v\n", f.name, i+1, err)
+			valid = false
+		}
+	}
+	return valid
+}
+
+// file is a decoded manifest file, or stdin when path is empty.
+type file struct {
+	index int
+	name  string
+	path  string
+	mode  os.FileMode
+	docs  []manifest.Document
+}
+
+// eachFile decodes every manifest named by args and calls fn with it.
+// Directories are walked for .yaml, .yml and .json files.
+func eachFile(cmd *cobra.Command, args []string, fn func(*file) error) error {
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+
+	index := 0
+	visit := func(path string, mode os.FileMode) error {
+		f := &file{index: index, name: path, path: path, mode: mode}
+		var in io.Reader
+		if path == "-" {
+			f.name, f.path, in = "<stdin>", "", cmd.InOrStdin()
+		} else {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			in = bytes.NewReader(data)
+		}
+
+		docs, err := manifest.Decode(in)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		f.docs = docs
+		index++
+		return fn(f)
+	}
+
+	for _, arg := range args {
+		if arg == "-" {
+			if err := visit(arg, 0); err != nil {
+				return err
+			}
+			continue
+		}
+		err := filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".yaml", ".yml", ".json":
+				return visit(path, info.Mode())
+			}
+			if path == arg {
+				return visit(path, info.Mode())
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifestcmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+const v1beta1World = `apiVersion: study.example.cn/v1beta1
+kind: World
+metadata:
+  name: one
+spec:
+  world: hello
+`
+
+const invalidWorld = `apiVersion: study.example.cn/v1beta1
+kind: World
+metadata:
+  name: bad
+spec:
+  world: hello
+  syncInterval: 1s
+`
+
+func execute(cmd *cobra.Command, args ...string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	cmd.SetIn(strings.NewReader(""))
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return stdout.String(), stderr.String(), err
+}
+
+func writeFile(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateCommand(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "good.yaml", v1beta1World)
+	bad := writeFile(t, dir, "bad.yaml", invalidWorld)
+	writeFile(t, dir, "README.md", "not a manifest")
+
+	_, stderr, err := execute(NewValidateCommand(), dir)
+	if err != ErrFailed {
+		t.Fatalf("error = %v, want ErrFailed", err)
+	}
+	if !strings.Contains(stderr, bad+": document 1:") || !strings.Contains(stderr, "spec.syncInterval") {
+		t.Errorf("stderr does not report %s:\n%s", bad, stderr)
+	}
+	if strings.Contains(stderr, "good.yaml") {
+		t.Errorf("stderr reports a valid file:\n%s", stderr)
+	}
+}
+
+func TestConvertCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "world.yaml", v1beta1World)
+
+	if _, stderr, err := execute(NewConvertCommand(), "--check", path); err != ErrFailed || !strings.Contains(stderr, "not at v1beta2") {
+		t.Fatalf("--check: error = %v, stderr = %q, want the file reported", err, stderr)
+	}
+
+	if _, _, err := execute(NewConvertCommand(), "-w", "-f", path); err != nil {
+		t.Fatalf("-w: error = %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "apiVersion: study.example.cn/v1beta2") || !strings.Contains(string(data), "earth: hello") {
+		t.Errorf("file was not rewritten to v1beta2:\n%s", data)
+	}
+
+	if _, stderr, err := execute(NewConvertCommand(), "--check", path); err != nil {
+		t.Errorf("--check after -w: error = %v, stderr = %q", err, stderr)
+	}
+
+	invalid := writeFile(t, dir, "bad.yaml", invalidWorld)
+	if _, _, err := execute(NewConvertCommand(), "-w", invalid); err != ErrFailed {
+		t.Errorf("converting an invalid file: error = %v, want ErrFailed", err)
+	}
+	if data, _ := ioutil.ReadFile(invalid); string(data) != invalidWorld {
+		t.Errorf("an invalid file was rewritten:\n%s", data)
+	}
+}
+
+func TestConvertCommandMixedStream(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "mixed.yaml", v1beta1World+`---
+apiVersion: common.scope.cluster/v1beta1
+kind: Cluster
+metadata:
+  name: alpha
+spec:
+  clusterName: alpha
+`)
+
+	stdout, stderr, err := execute(NewConvertCommand(), path)
+	if err != nil {
+		t.Fatalf("error = %v, stderr = %q", err, stderr)
+	}
+	if !strings.Contains(stdout, "apiVersion: study.example.cn/v1beta2") || !strings.Contains(stdout, "apiVersion: common.scope.cluster/v1beta1") {
+		t.Errorf("want the World converted and the Cluster kept:\n%s", stdout)
+	}
+
+	if _, _, err := execute(NewConvertCommand(), "-w", path); err != nil {
+		t.Fatalf("-w: error = %v", err)
+	}
+	// Cluster只有一个版本，不算未转换
+	if _, stderr, err := execute(NewConvertCommand(), "--check", path); err != nil {
+		t.Errorf("--check after -w: error = %v, stderr = %q", err, stderr)
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics holds the custom Prometheus metrics of the manager. They are
+// registered with the controller-runtime registry and served on the metrics
+// endpoint next to the built-in controller metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "kube_develop_tools"
+
+var (
+	// PausedObjects counts the objects whose reconciliation is currently paused.
+	PausedObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "paused_objects",
+		Help:      "Number of objects whose reconciliation is paused, by kind.",
+	}, []string{"kind"})
+
+	// Leader is 1 while this replica holds the leader election lease.
+	Leader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader",
+		Help:      "Whether this replica is the leader, 1 when it is.",
+	})
+
+	// LeaderTransitions counts how often this replica became or stopped being
+	// the leader.
+	LeaderTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "leader_transitions_total",
+		Help:      "Number of times this replica became or stopped being the leader, by transition.",
+	}, []string{"transition"})
+
+	// ShardMembers is the number of live replicas sharing the objects.
+	ShardMembers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "shard_members",
+		Help:      "Number of live replicas the shards are split between.",
+	})
+
+	// OwnedShards is the number of shards this replica reconciles.
+	OwnedShards = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "owned_shards",
+		Help:      "Number of shards this replica reconciles.",
+	})
+
+	// ClusterHealthScore is the health score of each Cluster.
+	ClusterHealthScore = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cluster_health_score",
+		Help:      "Health score of the Cluster from 0 to 100 over the probe window.",
+	}, []string{"cluster"})
+
+	// ClusterCordoned is 1 while a Cluster is cordoned.
+	ClusterCordoned = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cluster_cordoned",
+		Help:      "Whether the Cluster is cordoned because of its health, 1 when it is.",
+	}, []string{"cluster"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		PausedObjects,
+		Leader,
+		LeaderTransitions,
+		ShardMembers,
+		OwnedShards,
+		ClusterHealthScore,
+		ClusterCordoned,
+	)
+}
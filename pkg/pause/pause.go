@@ -0,0 +1,178 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pause lets operators temporarily stop the controllers from touching
+// an object, either per object through an annotation or globally through a
+// ConfigMap.
+package pause
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github/antmoveh/kube-develop-tools/pkg/metrics"
+)
+
+const (
+	// Annotation pauses reconciliation of the annotated object when set to "true".
+	Annotation = "example.cn/paused"
+
+	// ConfigMapKey is the key of the global pause ConfigMap that pauses every
+	// object when set to "true".
+	ConfigMapKey = "paused"
+
+	// ConditionType is the status condition reporting whether reconciliation is paused.
+	ConditionType = "Paused"
+
+	// Reasons of the Paused condition.
+	ReasonAnnotation = "PausedByAnnotation"
+	ReasonGlobal     = "PausedGlobally"
+	ReasonResumed    = "Resumed"
+)
+
+// Transition describes how the Paused condition changed.
+type Transition int
+
+const (
+	// Unchanged means the condition already reflected the pause state.
+	Unchanged Transition = iota
+	// Paused means the object was paused, or paused for a different reason.
+	Paused
+	// Resumed means a paused object is running again.
+	Resumed
+)
+
+// Checker decides whether an object is paused.
+type Checker struct {
+	// Reader is used to read the global pause ConfigMap.
+	Reader client.Reader
+	// ConfigMap is the global pause ConfigMap. An empty name disables the global pause.
+	ConfigMap types.NamespacedName
+	// Cache watches the global pause ConfigMap. It only needs to hold the
+	// ConfigMaps of its namespace, so the controllers do not cache every
+	// ConfigMap of the cluster. A nil Cache leaves the ConfigMap unwatched
+	// and pausing globally takes effect on the next reconcile.
+	Cache cache.Cache
+}
+
+// IsPaused reports whether reconciliation of obj is paused and why. A nil
+// Checker only honors the annotation.
+func (c *Checker) IsPaused(ctx context.Context, obj client.Object) (bool, string, error) {
+	if v, ok := obj.GetAnnotations()[Annotation]; ok && isTrue(v) {
+		return true, ReasonAnnotation, nil
+	}
+	if c == nil || c.ConfigMap.Name == "" {
+		return false, "", nil
+	}
+
+	cm := new(corev1.ConfigMap)
+	if err := c.Reader.Get(ctx, c.ConfigMap, cm); err != nil {
+		if apierrs.IsNotFound(err) {
+			return false, "", nil
+		}
+		return false, "", err
+	}
+	if isTrue(cm.Data[ConfigMapKey]) {
+		return true, ReasonGlobal, nil
+	}
+	return false, "", nil
+}
+
+// IsConfigMap reports whether obj is the global pause ConfigMap.
+func (c *Checker) IsConfigMap(obj client.Object) bool {
+	if c == nil || c.ConfigMap.Name == "" {
+		return false
+	}
+	return obj.GetNamespace() == c.ConfigMap.Namespace && obj.GetName() == c.ConfigMap.Name
+}
+
+// Source returns the source the controllers watch the global pause
+// ConfigMap through, nil when there is nothing to watch.
+func (c *Checker) Source() source.Source {
+	if c == nil || c.ConfigMap.Name == "" || c.Cache == nil {
+		return nil
+	}
+	return source.NewKindWithCache(&corev1.ConfigMap{}, c.Cache)
+}
+
+// SetCondition records the pause state in conditions and returns how it
+// changed. A Paused=False condition is only written when resuming, so objects
+// that were never paused are not touched.
+func SetCondition(conditions *[]metav1.Condition, paused bool, reason string, generation int64) Transition {
+	current := meta.FindStatusCondition(*conditions, ConditionType)
+	wasPaused := current != nil && current.Status == metav1.ConditionTrue
+
+	switch {
+	case paused && (!wasPaused || current.Reason != reason):
+		meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:               ConditionType,
+			Status:             metav1.ConditionTrue,
+			Reason:             reason,
+			Message:            "reconciliation is paused",
+			ObservedGeneration: generation,
+		})
+		return Paused
+	case !paused && wasPaused:
+		meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:               ConditionType,
+			Status:             metav1.ConditionFalse,
+			Reason:             ReasonResumed,
+			Message:            "reconciliation is running",
+			ObservedGeneration: generation,
+		})
+		return Resumed
+	}
+	return Unchanged
+}
+
+var (
+	mu            sync.Mutex
+	pausedObjects = map[string]map[types.NamespacedName]struct{}{}
+)
+
+// Track records whether the object of the given kind is paused and updates the
+// paused objects metric.
+func Track(kind string, key types.NamespacedName, isPaused bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	set, ok := pausedObjects[kind]
+	if !ok {
+		set = map[types.NamespacedName]struct{}{}
+		pausedObjects[kind] = set
+	}
+	if isPaused {
+		set[key] = struct{}{}
+	} else {
+		delete(set, key)
+	}
+	metrics.PausedObjects.WithLabelValues(kind).Set(float64(len(set)))
+}
+
+func isTrue(v string) bool {
+	b, err := strconv.ParseBool(v)
+	return err == nil && b
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pause
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestIsPaused(t *testing.T) {
+	global := types.NamespacedName{Namespace: "system", Name: "pause"}
+	pausedCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: global.Namespace, Name: global.Name},
+		Data:       map[string]string{ConfigMapKey: "true"},
+	}
+
+	tests := []struct {
+		name        string
+		checker     *Checker
+		annotations map[string]string
+		wantPaused  bool
+		wantReason  string
+	}{
+		{name: "nil checker without annotation"},
+		{
+			name:        "nil checker with annotation",
+			annotations: map[string]string{Annotation: "true"},
+			wantPaused:  true,
+			wantReason:  ReasonAnnotation,
+		},
+		{
+			name:        "annotation set to false",
+			annotations: map[string]string{Annotation: "false"},
+		},
+		{
+			name:    "global ConfigMap missing",
+			checker: &Checker{Reader: fake.NewClientBuilder().Build(), ConfigMap: global},
+		},
+		{
+			name:       "global ConfigMap paused",
+			checker:    &Checker{Reader: fake.NewClientBuilder().WithObjects(pausedCM).Build(), ConfigMap: global},
+			wantPaused: true,
+			wantReason: ReasonGlobal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p", Annotations: tt.annotations}}
+			paused, reason, err := tt.checker.IsPaused(context.Background(), obj)
+			if err != nil {
+				t.Fatalf("IsPaused() error = %v", err)
+			}
+			if paused != tt.wantPaused || reason != tt.wantReason {
+				t.Errorf("IsPaused() = %v, %q, want %v, %q", paused, reason, tt.wantPaused, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestSource(t *testing.T) {
+	global := types.NamespacedName{Namespace: "system", Name: "pause"}
+	tests := []struct {
+		name    string
+		checker *Checker
+		want    bool
+	}{
+		{name: "nil checker"},
+		{name: "global pause disabled", checker: &Checker{Cache: &informertest.FakeInformers{}}},
+		{name: "no cache", checker: &Checker{ConfigMap: global}},
+		{name: "watched", checker: &Checker{ConfigMap: global, Cache: &informertest.FakeInformers{}}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.checker.Source() != nil; got != tt.want {
+				t.Errorf("Source() != nil is %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetCondition(t *testing.T) {
+	var conditions []metav1.Condition
+
+	if got := SetCondition(&conditions, false, "", 1); got != Unchanged || len(conditions) != 0 {
+		t.Fatalf("running object got %v with %d conditions, want Unchanged and none", got, len(conditions))
+	}
+	if got := SetCondition(&conditions, true, ReasonAnnotation, 1); got != Paused {
+		t.Fatalf("pausing got %v, want Paused", got)
+	}
+	if got := SetCondition(&conditions, true, ReasonAnnotation, 1); got != Unchanged {
+		t.Fatalf("pausing twice got %v, want Unchanged", got)
+	}
+	if got := SetCondition(&conditions, false, "", 2); got != Resumed {
+		t.Fatalf("resuming got %v, want Resumed", got)
+	}
+	if meta.IsStatusConditionTrue(conditions, ConditionType) {
+		t.Errorf("Paused condition is still true after resuming")
+	}
+}
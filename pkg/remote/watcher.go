@@ -0,0 +1,513 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package remote keeps long-running watches on member Clusters and turns
+// what they see into events for the Cluster controller.
+package remote
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	"github/antmoveh/kube-develop-tools/pkg/health"
+)
+
+const (
+	// DefaultMinBackoff and DefaultMaxBackoff bound how long the watches of
+	// an unreachable Cluster wait before reconnecting.
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = 5 * time.Minute
+	// DefaultLatencyInterval is how often the API latency of a watched
+	// Cluster is measured again.
+	DefaultLatencyInterval = 30 * time.Second
+	// latencyTimeout bounds a latency measurement; a Cluster that does not
+	// answer within it is reconnected.
+	latencyTimeout = 10 * time.Second
+	// eventBuffer is how many events may wait for the workqueue.
+	eventBuffer = 1024
+)
+
+// worldsResource is where the Worlds propagated to a Cluster live.
+var worldsResource = studyv1beta1.GroupVersion.WithResource("worlds")
+
+// errConnecting is the probe error of a Cluster whose watches have not
+// listed yet.
+var errConnecting = errors.New("watches are connecting")
+
+// Status is what the watches of a Cluster last saw.
+type Status struct {
+	// Connected is true while the watches run.
+	Connected bool
+	// Since is when Connected last changed.
+	Since time.Time
+	// Err is why the watches went down.
+	Err error
+	// Latency is how long the last list took, the initial one or a periodic
+	// measurement.
+	Latency    time.Duration
+	Nodes      int32
+	ReadyNodes int32
+	// Worlds and ReadyWorlds count the Worlds propagated to the Cluster,
+	// zero when it has no World CRD.
+	Worlds      int32
+	ReadyWorlds int32
+	// Reconnects counts how often the watches went down.
+	Reconnects int32
+}
+
+// ClusterStatus returns s as the status.watch of a Cluster.
+func (s Status) ClusterStatus() *commonv1beta1.ClusterWatchStatus {
+	st := &commonv1beta1.ClusterWatchStatus{
+		Connected:   s.Connected,
+		Since:       metav1.NewTime(s.Since),
+		Nodes:       s.Nodes,
+		ReadyNodes:  s.ReadyNodes,
+		Worlds:      s.Worlds,
+		ReadyWorlds: s.ReadyWorlds,
+		Reconnects:  s.Reconnects,
+	}
+	if s.Err != nil {
+		st.LastError = s.Err.Error()
+	}
+	return st
+}
+
+// Clients builds the clients of a Cluster.
+type Clients func(cfg *rest.Config) (kubernetes.Interface, dynamic.Interface, error)
+
+// Watcher watches the Nodes and Worlds of every Cluster it was asked to and
+// sends a GenericEvent for a Cluster whenever its Status changes. It also
+// probes Clusters for their health from what the watches saw, without
+// calling them.
+type Watcher struct {
+	// Local is the config of the cluster the manager runs in, watched for
+	// Clusters without credentials.
+	Local *rest.Config
+	// MinBackoff and MaxBackoff bound the reconnect backoff, the defaults
+	// when zero.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// LatencyInterval is how often the latency of a watched Cluster is
+	// measured again, DefaultLatencyInterval when zero.
+	LatencyInterval time.Duration
+
+	clients Clients
+	events  chan event.GenericEvent
+
+	mu       sync.Mutex
+	ctx      context.Context
+	clusters map[string]*clusterWatch
+}
+
+var _ health.Prober = &Watcher{}
+
+// NewWatcher returns a Watcher reaching Clusters with clients, the
+// clientsets of client-go when nil.
+func NewWatcher(local *rest.Config, clients Clients) *Watcher {
+	if clients == nil {
+		clients = func(cfg *rest.Config) (kubernetes.Interface, dynamic.Interface, error) {
+			kube, err := kubernetes.NewForConfig(cfg)
+			if err != nil {
+				return nil, nil, err
+			}
+			dyn, err := dynamic.NewForConfig(cfg)
+			return kube, dyn, err
+		}
+	}
+	return &Watcher{
+		Local:    local,
+		clients:  clients,
+		events:   make(chan event.GenericEvent, eventBuffer),
+		clusters: map[string]*clusterWatch{},
+	}
+}
+
+// Source is where the events of the Watcher come from.
+func (w *Watcher) Source() source.Source {
+	return &source.Channel{Source: w.events}
+}
+
+// Start implements manager.Runnable. It stops every watch once ctx is done.
+func (w *Watcher) Start(ctx context.Context) error {
+	w.mu.Lock()
+	w.ctx = ctx
+	w.mu.Unlock()
+	<-ctx.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for name, cw := range w.clusters {
+		cw.cancel()
+		delete(w.clusters, name)
+	}
+	return nil
+}
+
+// Watch makes sure the Cluster name is watched with cfg, the Local config
+// when nil. Watches with another config are restarted.
+func (w *Watcher) Watch(name string, cfg *rest.Config) {
+	if w == nil {
+		return
+	}
+	if cfg == nil {
+		cfg = w.Local
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if cw, ok := w.clusters[name]; ok {
+		if sameConfig(cw.cfg, cfg) {
+			return
+		}
+		cw.cancel()
+	}
+	parent := w.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	cw := &clusterWatch{name: name, cfg: cfg, cancel: cancel, w: w}
+	w.clusters[name] = cw
+	go cw.run(log.IntoContext(ctx, log.Log.WithName("remote").WithValues("cluster", name)))
+}
+
+// Stop stops watching the Cluster name.
+func (w *Watcher) Stop(name string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if cw, ok := w.clusters[name]; ok {
+		cw.cancel()
+		delete(w.clusters, name)
+	}
+}
+
+// Status returns what the watches of the Cluster name last saw, false when
+// it is not watched.
+func (w *Watcher) Status(name string) (Status, bool) {
+	if w == nil {
+		return Status{}, false
+	}
+	w.mu.Lock()
+	cw, ok := w.clusters[name]
+	w.mu.Unlock()
+	if !ok {
+		return Status{}, false
+	}
+	return cw.get(), true
+}
+
+// Probe implements health.Prober from the watched Status. Pods are not
+// watched, so the samples leave the pod weight out of the score.
+func (w *Watcher) Probe(_ context.Context, cu *commonv1beta1.Cluster) (health.Sample, error) {
+	s, ok := w.Status(cu.Name)
+	switch {
+	case !ok || (!s.Connected && s.Err == nil):
+		return health.Sample{}, errConnecting
+	case !s.Connected:
+		return health.Sample{}, s.Err
+	}
+	return health.Sample{Latency: s.Latency, Nodes: s.Nodes, ReadyNodes: s.ReadyNodes, SkipPods: true}, nil
+}
+
+// notify enqueues the Cluster name without blocking a closing watch.
+func (w *Watcher) notify(ctx context.Context, name string) {
+	select {
+	case w.events <- event.GenericEvent{Object: &commonv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name}}}:
+	case <-ctx.Done():
+	}
+}
+
+func (w *Watcher) latencyInterval() time.Duration {
+	if w.LatencyInterval > 0 {
+		return w.LatencyInterval
+	}
+	return DefaultLatencyInterval
+}
+
+func (w *Watcher) backoff() wait.Backoff {
+	b := wait.Backoff{Duration: w.MinBackoff, Cap: w.MaxBackoff, Factor: 2, Jitter: 0.2, Steps: 1 << 30}
+	if b.Duration <= 0 {
+		b.Duration = DefaultMinBackoff
+	}
+	if b.Cap <= 0 {
+		b.Cap = DefaultMaxBackoff
+	}
+	return b
+}
+
+// clusterWatch runs the watches of one Cluster.
+type clusterWatch struct {
+	name   string
+	cfg    *rest.Config
+	cancel context.CancelFunc
+	w      *Watcher
+
+	mu     sync.Mutex
+	status Status
+}
+
+func (cw *clusterWatch) get() Status {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.status
+}
+
+// set updates the Status and enqueues the Cluster when it changed.
+func (cw *clusterWatch) set(ctx context.Context, update func(*Status)) {
+	cw.mu.Lock()
+	before := cw.status
+	update(&cw.status)
+	if cw.status.Connected != before.Connected {
+		cw.status.Since = time.Now()
+	}
+	changed := !sameStatus(cw.status, before)
+	cw.mu.Unlock()
+	if changed {
+		cw.w.notify(ctx, cw.name)
+	}
+}
+
+// run watches until ctx is done, reconnecting with backoff while the
+// Cluster cannot be reached. Watches the API server closes are restarted
+// after MinBackoff, so a server closing them at once is not relisted in a
+// busy loop.
+func (cw *clusterWatch) run(ctx context.Context) {
+	logger := log.FromContext(ctx)
+	backoff := cw.w.backoff()
+	for {
+		connected, err := cw.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = cw.w.backoff()
+		}
+		if err == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(cw.w.backoff().Duration):
+			}
+			continue
+		}
+		logger.Info("watches down, reconnecting", "error", err.Error())
+		cw.set(ctx, func(s *Status) {
+			if s.Connected {
+				s.Reconnects++
+			}
+			s.Connected, s.Err = false, err
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff.Step()):
+		}
+	}
+}
+
+// watch lists and watches the Nodes and Worlds of the Cluster until a watch
+// fails or closes. It reports whether it got connected.
+func (cw *clusterWatch) watch(ctx context.Context) (bool, error) {
+	kube, dyn, err := cw.w.clients(cw.cfg)
+	if err != nil {
+		return false, err
+	}
+
+	start := time.Now()
+	nodeList, err := kube.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, err
+	}
+	latency := time.Since(start)
+	nodes := map[string]bool{}
+	for i := range nodeList.Items {
+		nodes[nodeList.Items[i].Name] = nodeReady(&nodeList.Items[i])
+	}
+	nodeWatch, err := kube.CoreV1().Nodes().Watch(ctx, metav1.ListOptions{ResourceVersion: nodeList.ResourceVersion})
+	if err != nil {
+		return false, err
+	}
+	defer nodeWatch.Stop()
+
+	// 成员集群可能没有安装World的CRD，此时只监听节点
+	worlds := map[string]bool{}
+	var worldEvents <-chan watch.Event
+	worldList, err := dyn.Resource(worldsResource).List(ctx, metav1.ListOptions{})
+	switch {
+	case apierrs.IsNotFound(err):
+	case err != nil:
+		return false, err
+	default:
+		for i := range worldList.Items {
+			worlds[key(&worldList.Items[i])] = worldReady(&worldList.Items[i])
+		}
+		worldWatch, err := dyn.Resource(worldsResource).Watch(ctx, metav1.ListOptions{ResourceVersion: worldList.GetResourceVersion()})
+		if err != nil {
+			return false, err
+		}
+		defer worldWatch.Stop()
+		worldEvents = worldWatch.ResultChan()
+	}
+
+	update := func(s *Status) {
+		s.Connected, s.Err, s.Latency = true, nil, latency
+		s.Nodes, s.ReadyNodes = count(nodes)
+		s.Worlds, s.ReadyWorlds = count(worlds)
+	}
+	cw.set(ctx, update)
+	// 监听本身不反映API server变慢，定期重新测量延迟
+	ticker := time.NewTicker(cw.w.latencyInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return true, nil
+		case <-ticker.C:
+			if latency, err = measure(ctx, kube); err != nil {
+				return true, err
+			}
+		case e, ok := <-nodeWatch.ResultChan():
+			if !ok {
+				return true, nil
+			}
+			if e.Type == watch.Error {
+				return true, watchError(e)
+			}
+			if node, ok := e.Object.(*corev1.Node); ok {
+				if e.Type == watch.Deleted {
+					delete(nodes, node.Name)
+				} else {
+					nodes[node.Name] = nodeReady(node)
+				}
+			}
+		case e, ok := <-worldEvents:
+			if !ok {
+				return true, nil
+			}
+			if e.Type == watch.Error {
+				return true, watchError(e)
+			}
+			if wl, ok := e.Object.(*unstructured.Unstructured); ok {
+				if e.Type == watch.Deleted {
+					delete(worlds, key(wl))
+				} else {
+					worlds[key(wl)] = worldReady(wl)
+				}
+			}
+		}
+		cw.set(ctx, update)
+	}
+}
+
+// measure times a list of one Node, which is how the API latency of a
+// watched Cluster is judged.
+func measure(ctx context.Context, kube kubernetes.Interface) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, latencyTimeout)
+	defer cancel()
+	start := time.Now()
+	_, err := kube.CoreV1().Nodes().List(ctx, metav1.ListOptions{Limit: 1})
+	return time.Since(start), err
+}
+
+// watchError returns the error of an Error event, nil when the watch only
+// expired and a new list is all it takes.
+func watchError(e watch.Event) error {
+	err := apierrs.FromObject(e.Object)
+	if apierrs.IsResourceExpired(err) || apierrs.IsGone(err) {
+		return nil
+	}
+	return err
+}
+
+// sameStatus compares errors by message, as not every error is comparable.
+// Latency is left out: it changes with every measurement and the health
+// probes read it on their own schedule.
+func sameStatus(a, b Status) bool {
+	message := func(err error) string {
+		if err == nil {
+			return ""
+		}
+		return err.Error()
+	}
+	if message(a.Err) != message(b.Err) {
+		return false
+	}
+	a.Err, b.Err = nil, nil
+	a.Latency, b.Latency = 0, 0
+	return a == b
+}
+
+func count(ready map[string]bool) (int32, int32) {
+	var n int32
+	for _, ok := range ready {
+		if ok {
+			n++
+		}
+	}
+	return int32(len(ready)), n
+}
+
+func key(obj *unstructured.Unstructured) string {
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func worldReady(wl *unstructured.Unstructured) bool {
+	phase, _, _ := unstructured.NestedString(wl.Object, "status", "phase")
+	return phase == string(studyv1beta1.WorldReady)
+}
+
+// sameConfig reports whether a and b reach the same cluster as the same
+// user.
+func sameConfig(a, b *rest.Config) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	identity := func(c *rest.Config) []interface{} {
+		return []interface{}{c.Host, c.APIPath, c.BearerToken, c.BearerTokenFile, c.Username, c.Password,
+			c.TLSClientConfig.CertData, c.TLSClientConfig.KeyData, c.TLSClientConfig.CAData,
+			c.TLSClientConfig.CertFile, c.TLSClientConfig.KeyFile, c.TLSClientConfig.CAFile, c.TLSClientConfig.Insecure,
+			c.ExecProvider, c.AuthProvider}
+	}
+	return reflect.DeepEqual(identity(a), identity(b))
+}
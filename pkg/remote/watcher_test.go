@@ -0,0 +1,266 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	clienttesting "k8s.io/client-go/testing"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+)
+
+func node(name string, ready bool) *corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}}},
+	}
+}
+
+func world(name, phase string) *unstructured.Unstructured {
+	wl := &unstructured.Unstructured{}
+	wl.SetAPIVersion("study.example.cn/v1beta1")
+	wl.SetKind("World")
+	wl.SetNamespace("default")
+	wl.SetName(name)
+	_ = unstructured.SetNestedField(wl.Object, phase, "status", "phase")
+	return wl
+}
+
+func newDynamic(objs ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{worldsResource: "WorldList"}, objs...)
+}
+
+// waitFor waits until the Status of the Cluster alpha satisfies ok.
+func waitFor(t *testing.T, w *Watcher, what string, ok func(Status) bool) Status {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s, watched := w.Status("alpha")
+		if watched && ok(s) {
+			return s
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s, status %+v", what, s)
+		}
+		select {
+		case <-w.events:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	kube := kubefake.NewSimpleClientset(node("n1", true), node("n2", true))
+	dyn := newDynamic(world("earth", "Ready"))
+	var built int32
+	w := NewWatcher(nil, func(*rest.Config) (kubernetes.Interface, dynamic.Interface, error) {
+		atomic.AddInt32(&built, 1)
+		return kube, dyn, nil
+	})
+	go func() { _ = w.Start(ctx) }()
+
+	cfg := &rest.Config{Host: "https://alpha.example"}
+	w.Watch("alpha", cfg)
+	w.Watch("alpha", &rest.Config{Host: "https://alpha.example"})
+	s := waitFor(t, w, "the first list", func(s Status) bool { return s.Connected })
+	if s.Nodes != 2 || s.ReadyNodes != 2 || s.Worlds != 1 || s.ReadyWorlds != 1 {
+		t.Errorf("status = %+v, want 2 ready nodes and 1 ready world", s)
+	}
+	if n := atomic.LoadInt32(&built); n != 1 {
+		t.Errorf("built clients %d times, want once for the same config", n)
+	}
+	if sample, err := w.Probe(ctx, &commonv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "alpha"}}); err != nil || sample.ReadyNodes != 2 || !sample.SkipPods {
+		t.Errorf("Probe() = %+v, %v", sample, err)
+	}
+
+	if _, err := kube.CoreV1().Nodes().Update(ctx, node("n2", false), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, w, "the node to turn not ready", func(s Status) bool { return s.ReadyNodes == 1 })
+	if _, err := dyn.Resource(worldsResource).Namespace("default").Create(ctx, world("mars", "Pending"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, w, "the new world", func(s Status) bool { return s.Worlds == 2 && s.ReadyWorlds == 1 })
+	if err := kube.CoreV1().Nodes().Delete(ctx, "n1", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, w, "the node to go", func(s Status) bool { return s.Nodes == 1 && s.ReadyNodes == 0 })
+
+	w.Stop("alpha")
+	if _, ok := w.Status("alpha"); ok {
+		t.Error("still watched after Stop")
+	}
+	if _, err := w.Probe(ctx, &commonv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "alpha"}}); err == nil {
+		t.Error("Probe() of an unwatched Cluster succeeded")
+	}
+}
+
+func TestWatcherReconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	kube := kubefake.NewSimpleClientset(node("n1", true))
+	watches := make(chan *watch.FakeWatcher, 4)
+	kube.PrependWatchReactor("nodes", func(clienttesting.Action) (bool, watch.Interface, error) {
+		fw := watch.NewFake()
+		watches <- fw
+		return true, fw, nil
+	})
+	// 没有安装World的CRD
+	dyn := newDynamic()
+	dyn.PrependReactor("list", "worlds", func(clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrs.NewNotFound(worldsResource.GroupResource(), "")
+	})
+	var calls int32
+	w := NewWatcher(nil, func(*rest.Config) (kubernetes.Interface, dynamic.Interface, error) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			return nil, nil, errors.New("connection refused")
+		}
+		return kube, dyn, nil
+	})
+	w.MinBackoff, w.MaxBackoff = time.Millisecond, 10*time.Millisecond
+	go func() { _ = w.Start(ctx) }()
+
+	w.Watch("alpha", nil)
+	s := waitFor(t, w, "the connection", func(s Status) bool { return s.Connected })
+	if s.Reconnects != 0 || s.Err != nil || s.Worlds != 0 || s.Nodes != 1 {
+		t.Errorf("status after connecting = %+v", s)
+	}
+	if sample, err := w.Probe(ctx, &commonv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "alpha"}}); err != nil || sample.Nodes != 1 {
+		t.Errorf("Probe() = %+v, %v", sample, err)
+	}
+
+	// 监听出错后标记为断开，退避后重新连接
+	fw := <-watches
+	fw.Error(&metav1.Status{Status: metav1.StatusFailure, Code: http.StatusInternalServerError, Reason: metav1.StatusReasonInternalError, Message: "etcd is down"})
+	waitFor(t, w, "the reconnect", func(s Status) bool { return s.Connected && s.Reconnects == 1 })
+
+	// 过期的监听直接重新list，不算断开
+	fw = <-watches
+	fw.Error(&metav1.Status{Status: metav1.StatusFailure, Code: http.StatusGone, Reason: metav1.StatusReasonExpired})
+	<-watches
+	if s, _ := w.Status("alpha"); !s.Connected || s.Reconnects != 1 {
+		t.Errorf("status after an expired watch = %+v", s)
+	}
+}
+
+func TestWatcherClosedWatchBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	kube := kubefake.NewSimpleClientset(node("n1", true))
+	// API server每次都立即正常关闭监听
+	var watches int32
+	kube.PrependWatchReactor("nodes", func(clienttesting.Action) (bool, watch.Interface, error) {
+		atomic.AddInt32(&watches, 1)
+		fw := watch.NewFake()
+		fw.Stop()
+		return true, fw, nil
+	})
+	dyn := newDynamic()
+	w := NewWatcher(nil, func(*rest.Config) (kubernetes.Interface, dynamic.Interface, error) {
+		return kube, dyn, nil
+	})
+	w.MinBackoff = 50 * time.Millisecond
+	go func() { _ = w.Start(ctx) }()
+
+	w.Watch("alpha", nil)
+	waitFor(t, w, "the connection", func(s Status) bool { return s.Connected })
+	time.Sleep(300 * time.Millisecond)
+	w.Stop("alpha")
+	if n := atomic.LoadInt32(&watches); n < 2 || n > 10 {
+		t.Errorf("watched %d times in 300ms, want relists spaced by MinBackoff", n)
+	}
+}
+
+func TestWatcherMeasuresLatency(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	kube := kubefake.NewSimpleClientset(node("n1", true))
+	// API server在连接后变慢
+	var delay int64
+	kube.PrependReactor("list", "nodes", func(clienttesting.Action) (bool, runtime.Object, error) {
+		time.Sleep(time.Duration(atomic.LoadInt64(&delay)))
+		return false, nil, nil
+	})
+	dyn := newDynamic()
+	w := NewWatcher(nil, func(*rest.Config) (kubernetes.Interface, dynamic.Interface, error) {
+		return kube, dyn, nil
+	})
+	w.LatencyInterval = 10 * time.Millisecond
+	go func() { _ = w.Start(ctx) }()
+
+	w.Watch("alpha", nil)
+	waitFor(t, w, "the connection", func(s Status) bool { return s.Connected })
+	atomic.StoreInt64(&delay, int64(50*time.Millisecond))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sample, err := w.Probe(ctx, &commonv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "alpha"}})
+		if err == nil && sample.Latency >= 50*time.Millisecond {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Probe() = %+v, %v, want the latency measured again", sample, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s, _ := w.Status("alpha"); s.Reconnects != 0 {
+		t.Errorf("status = %+v, want the watches kept", s)
+	}
+}
+
+func TestProbeWhileConnecting(t *testing.T) {
+	w := NewWatcher(nil, func(*rest.Config) (kubernetes.Interface, dynamic.Interface, error) {
+		return nil, nil, errors.New("connection refused")
+	})
+	w.MinBackoff = time.Hour
+	defer w.Stop("alpha")
+	w.Watch("alpha", &rest.Config{Host: "https://alpha.example"})
+	s := waitFor(t, w, "the error", func(s Status) bool { return s.Err != nil })
+	if s.Connected || s.Reconnects != 0 {
+		t.Errorf("status = %+v, want never connected", s)
+	}
+	if st := s.ClusterStatus(); st.LastError != "connection refused" || st.Connected {
+		t.Errorf("cluster status = %+v", st)
+	}
+	if _, err := w.Probe(context.Background(), &commonv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "alpha"}}); err == nil || err.Error() != "connection refused" {
+		t.Errorf("Probe() error = %v, want the connection error", err)
+	}
+}
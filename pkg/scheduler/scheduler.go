@@ -0,0 +1,278 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package scheduler places Worlds with a placement on Clusters. Clusters are
+// filtered, scored by the placement policy, affinity and health, and the best
+// ones are chosen.
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+// ErrUnschedulable is returned when a World fits on no Cluster.
+var ErrUnschedulable = errors.New("no cluster fits the world")
+
+// Candidate is a Cluster a World may be placed on.
+type Candidate struct {
+	Cluster *commonv1beta1.Cluster
+	// Reserved sums the requests of the other Worlds placed on the Cluster.
+	Reserved corev1.ResourceList
+}
+
+// Filter returns why wl cannot be placed on c, empty when it can.
+type Filter func(wl *studyv1beta1.World, c *Candidate) string
+
+// DefaultFilters are the filters of a Scheduler without filters.
+var DefaultFilters = []Filter{Terminating, Cordoned, Taints, Affinity, Capacity}
+
+// Scheduler places Worlds on Clusters.
+type Scheduler struct {
+	// Filters decide the Clusters a World fits on, DefaultFilters when nil.
+	Filters []Filter
+}
+
+// Schedule places wl on the best candidates. Clusters in current that still
+// fit are kept, so a World only moves off the Clusters it no longer fits on.
+// The decision is returned even when the World fits on fewer Clusters than
+// it asked for; ErrUnschedulable comes with the decision when it fits on
+// none.
+func (s *Scheduler) Schedule(wl *studyv1beta1.World, candidates []Candidate, current []string) (*studyv1beta1.PlacementStatus, error) {
+	filters := s.Filters
+	if filters == nil {
+		filters = DefaultFilters
+	}
+	p := wl.Spec.Placement
+	want := 1
+	if p.NumberOfClusters > 0 {
+		want = int(p.NumberOfClusters)
+	}
+
+	decision := &studyv1beta1.PlacementStatus{ObservedGeneration: wl.Generation, ScheduleTime: metav1.Now()}
+	for i := range candidates {
+		c := &candidates[i]
+		reason := ""
+		for _, filter := range filters {
+			if reason = filter(wl, c); reason != "" {
+				break
+			}
+		}
+		if reason != "" {
+			decision.Unschedulable = append(decision.Unschedulable, studyv1beta1.UnschedulableCluster{Cluster: c.Cluster.Name, Reason: reason})
+			continue
+		}
+		decision.Scores = append(decision.Scores, score(wl, c))
+	}
+	sort.Slice(decision.Scores, func(i, j int) bool {
+		a, b := decision.Scores[i], decision.Scores[j]
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Cluster < b.Cluster
+	})
+	sort.Slice(decision.Unschedulable, func(i, j int) bool {
+		return decision.Unschedulable[i].Cluster < decision.Unschedulable[j].Cluster
+	})
+
+	kept := map[string]bool{}
+	for _, name := range current {
+		kept[name] = true
+	}
+	for _, sc := range decision.Scores {
+		if kept[sc.Cluster] && len(decision.Clusters) < want {
+			decision.Clusters = append(decision.Clusters, sc.Cluster)
+		}
+	}
+	for _, sc := range decision.Scores {
+		if !kept[sc.Cluster] && len(decision.Clusters) < want {
+			decision.Clusters = append(decision.Clusters, sc.Cluster)
+		}
+	}
+	sort.Strings(decision.Clusters)
+
+	switch {
+	case len(decision.Clusters) == 0:
+		decision.Message = fmt.Sprintf("0/%d clusters fit", len(candidates))
+		return decision, ErrUnschedulable
+	case len(decision.Clusters) < want:
+		decision.Message = fmt.Sprintf("placed on %d of %d clusters, only %d fit", len(decision.Clusters), want, len(decision.Scores))
+	default:
+		decision.Message = fmt.Sprintf("placed by %s on %s", policy(p), strings.Join(decision.Clusters, ", "))
+	}
+	return decision, nil
+}
+
+func policy(p *studyv1beta1.Placement) studyv1beta1.PlacementPolicy {
+	if p.Policy == "" {
+		return studyv1beta1.PlacementSpread
+	}
+	return p.Policy
+}
+
+// Terminating filters out deleted Clusters.
+func Terminating(_ *studyv1beta1.World, c *Candidate) string {
+	if c.Cluster.DeletionTimestamp != nil {
+		return "cluster is being deleted"
+	}
+	return ""
+}
+
+// Cordoned filters out Clusters that are not schedulable.
+func Cordoned(_ *studyv1beta1.World, c *Candidate) string {
+	if meta.IsStatusConditionFalse(c.Cluster.Status.Conditions, commonv1beta1.ClusterConditionSchedulable) {
+		return "cluster is cordoned"
+	}
+	return ""
+}
+
+// Affinity filters out Clusters that do not match the required affinity or
+// match the required anti-affinity.
+func Affinity(wl *studyv1beta1.World, c *Candidate) string {
+	p := wl.Spec.Placement
+	set := labels.Set(c.Cluster.Labels)
+	if p.Affinity != nil && p.Affinity.Required != nil && !matches(p.Affinity.Required, set) {
+		return "cluster does not match the required affinity"
+	}
+	if p.AntiAffinity != nil && p.AntiAffinity.Required != nil && matches(p.AntiAffinity.Required, set) {
+		return "cluster matches the required anti-affinity"
+	}
+	return ""
+}
+
+// Capacity filters out Clusters whose inventory shows less allocatable left
+// than the World requests. Clusters without inventory are not filtered.
+func Capacity(wl *studyv1beta1.World, c *Candidate) string {
+	inv := c.Cluster.Status.Inventory
+	if inv == nil || len(inv.Allocatable) == 0 {
+		return ""
+	}
+	for name, request := range wl.Spec.Placement.Requests {
+		allocatable, ok := inv.Allocatable[name]
+		if !ok {
+			return fmt.Sprintf("cluster has no %s", name)
+		}
+		used := c.Reserved[name].DeepCopy()
+		used.Add(request)
+		if used.Cmp(allocatable) > 0 {
+			return fmt.Sprintf("insufficient %s", name)
+		}
+	}
+	return ""
+}
+
+// matches reports whether set matches selector. Invalid selectors, which
+// World.Validate reports, match nothing.
+func matches(selector *metav1.LabelSelector, set labels.Set) bool {
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	return err == nil && s.Matches(set)
+}
+
+func score(wl *studyv1beta1.World, c *Candidate) studyv1beta1.ClusterScore {
+	p := wl.Spec.Placement
+	sc := studyv1beta1.ClusterScore{Cluster: c.Cluster.Name, Policy: 50, Health: 100}
+
+	switch policy(p) {
+	case studyv1beta1.PlacementWeighted:
+		sc.Policy = 0
+		for _, w := range p.Weights {
+			if w.Cluster == c.Cluster.Name {
+				sc.Policy = w.Weight
+			}
+		}
+	case studyv1beta1.PlacementBinpack:
+		if u, ok := utilization(wl, c); ok {
+			sc.Policy = int32(math.Round(100 * u))
+		}
+	default:
+		if u, ok := utilization(wl, c); ok {
+			sc.Policy = int32(math.Round(100 * (1 - u)))
+		}
+	}
+
+	set := labels.Set(c.Cluster.Labels)
+	if p.Affinity != nil {
+		for _, term := range p.Affinity.Preferred {
+			if matches(&term.Selector, set) {
+				sc.Affinity += term.Weight
+			}
+		}
+	}
+	if p.AntiAffinity != nil {
+		for _, term := range p.AntiAffinity.Preferred {
+			if matches(&term.Selector, set) {
+				sc.Affinity -= term.Weight
+			}
+		}
+	}
+	if sc.Affinity > 100 {
+		sc.Affinity = 100
+	} else if sc.Affinity < -100 {
+		sc.Affinity = -100
+	}
+
+	if h := c.Cluster.Status.Health; h != nil {
+		sc.Health = h.Score
+	}
+	sc.Total = sc.Policy + sc.Affinity + sc.Health/5
+	return sc
+}
+
+// utilization is the mean share of the allocatable resources of the Cluster
+// in use once the World is placed on it, over the resources the World
+// requests, or CPU and memory when it requests none. It is unknown without
+// inventory.
+func utilization(wl *studyv1beta1.World, c *Candidate) (float64, bool) {
+	inv := c.Cluster.Status.Inventory
+	if inv == nil {
+		return 0, false
+	}
+	names := []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory}
+	if requests := wl.Spec.Placement.Requests; len(requests) > 0 {
+		names = names[:0]
+		for name := range requests {
+			names = append(names, name)
+		}
+	}
+
+	var sum float64
+	var n int
+	for _, name := range names {
+		allocatable, ok := inv.Allocatable[name]
+		if !ok || allocatable.IsZero() {
+			continue
+		}
+		used := c.Reserved[name].DeepCopy()
+		used.Add(wl.Spec.Placement.Requests[name])
+		sum += math.Min(1, used.AsApproximateFloat64()/allocatable.AsApproximateFloat64())
+		n++
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
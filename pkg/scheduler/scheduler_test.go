@@ -0,0 +1,179 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+func cluster(name, cpu string, labels map[string]string, cordoned bool) *commonv1beta1.Cluster {
+	cu := &commonv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	if cpu != "" {
+		cu.Status.Inventory = &commonv1beta1.ClusterInventory{
+			Allocatable: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+		}
+	}
+	if cordoned {
+		cu.Status.Conditions = []metav1.Condition{{
+			Type:   commonv1beta1.ClusterConditionSchedulable,
+			Status: metav1.ConditionFalse,
+			Reason: "Cordoned",
+		}}
+	}
+	return cu
+}
+
+func cpu(q string) corev1.ResourceList {
+	return corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(q)}
+}
+
+func TestSchedule(t *testing.T) {
+	candidates := func() []Candidate {
+		return []Candidate{
+			{Cluster: cluster("big", "16", map[string]string{"region": "east"}, false)},
+			{Cluster: cluster("small", "4", map[string]string{"region": "west"}, false), Reserved: cpu("2")},
+			{Cluster: cluster("cordoned", "64", map[string]string{"region": "east"}, true)},
+		}
+	}
+	selector := func(region string) metav1.LabelSelector {
+		return metav1.LabelSelector{MatchLabels: map[string]string{"region": region}}
+	}
+
+	tests := []struct {
+		name          string
+		placement     studyv1beta1.Placement
+		current       []string
+		want          []string
+		wantErr       error
+		unschedulable []string
+	}{
+		{
+			name:          "spread prefers free capacity",
+			placement:     studyv1beta1.Placement{Requests: cpu("1")},
+			want:          []string{"big"},
+			unschedulable: []string{"cordoned"},
+		},
+		{
+			name:          "binpack prefers used capacity",
+			placement:     studyv1beta1.Placement{Policy: studyv1beta1.PlacementBinpack, Requests: cpu("1")},
+			want:          []string{"small"},
+			unschedulable: []string{"cordoned"},
+		},
+		{
+			name:          "requests filter out small clusters",
+			placement:     studyv1beta1.Placement{Policy: studyv1beta1.PlacementBinpack, Requests: cpu("3")},
+			want:          []string{"big"},
+			unschedulable: []string{"cordoned", "small"},
+		},
+		{
+			name: "weighted",
+			placement: studyv1beta1.Placement{
+				Policy:  studyv1beta1.PlacementWeighted,
+				Weights: []studyv1beta1.ClusterWeight{{Cluster: "small", Weight: 80}, {Cluster: "big", Weight: 10}},
+			},
+			want:          []string{"small"},
+			unschedulable: []string{"cordoned"},
+		},
+		{
+			name: "required affinity",
+			placement: studyv1beta1.Placement{
+				Affinity: &studyv1beta1.ClusterAffinity{Required: &metav1.LabelSelector{MatchLabels: map[string]string{"region": "west"}}},
+			},
+			want:          []string{"small"},
+			unschedulable: []string{"big", "cordoned"},
+		},
+		{
+			name: "preferred anti-affinity",
+			placement: studyv1beta1.Placement{
+				AntiAffinity: &studyv1beta1.ClusterAffinity{Preferred: []studyv1beta1.WeightedClusterSelector{{Weight: 100, Selector: selector("east")}}},
+			},
+			want:          []string{"small"},
+			unschedulable: []string{"cordoned"},
+		},
+		{
+			name:          "keeps the current clusters that still fit",
+			placement:     studyv1beta1.Placement{NumberOfClusters: 2},
+			current:       []string{"small", "cordoned"},
+			want:          []string{"big", "small"},
+			unschedulable: []string{"cordoned"},
+		},
+		{
+			name:          "places on fewer clusters than asked",
+			placement:     studyv1beta1.Placement{NumberOfClusters: 3},
+			want:          []string{"big", "small"},
+			unschedulable: []string{"cordoned"},
+		},
+		{
+			name:          "unschedulable",
+			placement:     studyv1beta1.Placement{Requests: cpu("100")},
+			wantErr:       ErrUnschedulable,
+			unschedulable: []string{"big", "cordoned", "small"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wl := &studyv1beta1.World{Spec: studyv1beta1.WorldSpec{Placement: &tt.placement}}
+			decision, err := (&Scheduler{}).Schedule(wl, candidates(), tt.current)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Schedule() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(decision.Clusters, tt.want) {
+				t.Errorf("clusters = %v, want %v; scores %+v", decision.Clusters, tt.want, decision.Scores)
+			}
+			var unschedulable []string
+			for _, u := range decision.Unschedulable {
+				unschedulable = append(unschedulable, u.Cluster)
+			}
+			if !reflect.DeepEqual(unschedulable, tt.unschedulable) {
+				t.Errorf("unschedulable = %+v, want %v", decision.Unschedulable, tt.unschedulable)
+			}
+			for i := 1; i < len(decision.Scores); i++ {
+				if decision.Scores[i-1].Total < decision.Scores[i].Total {
+					t.Errorf("scores are not sorted: %+v", decision.Scores)
+				}
+			}
+		})
+	}
+}
+
+func TestScoreBreakdown(t *testing.T) {
+	cu := cluster("big", "10", map[string]string{"region": "east"}, false)
+	cu.Status.Health = &commonv1beta1.ClusterHealth{Score: 60}
+	wl := &studyv1beta1.World{Spec: studyv1beta1.WorldSpec{Placement: &studyv1beta1.Placement{
+		Requests: cpu("2"),
+		Affinity: &studyv1beta1.ClusterAffinity{Preferred: []studyv1beta1.WeightedClusterSelector{{
+			Weight:   30,
+			Selector: metav1.LabelSelector{MatchLabels: map[string]string{"region": "east"}},
+		}}},
+	}}}
+
+	got := score(wl, &Candidate{Cluster: cu, Reserved: cpu("3")})
+	want := studyv1beta1.ClusterScore{Cluster: "big", Policy: 50, Affinity: 30, Health: 60, Total: 92}
+	if got != want {
+		t.Errorf("score() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+// now is the clock of the taint checks, replaced in tests.
+var now = time.Now
+
+// UntoleratedTaint returns the first taint with one of effects that no
+// toleration tolerates.
+func UntoleratedTaint(taints []corev1.Taint, tolerations []corev1.Toleration, effects ...corev1.TaintEffect) (corev1.Taint, bool) {
+	for _, taint := range taints {
+		if !hasEffect(taint, effects) {
+			continue
+		}
+		if !tolerated(&taint, tolerations) {
+			return taint, true
+		}
+	}
+	return corev1.Taint{}, false
+}
+
+// EvictionTime returns when a World with tolerations must leave a Cluster
+// with taints, and the NoExecute taint that evicts it. A NoExecute taint no
+// toleration matches evicts right away; one tolerated with toleration
+// seconds evicts that long after it was added, and one tolerated without
+// them never. The last return is false when the World may stay.
+func EvictionTime(taints []corev1.Taint, tolerations []corev1.Toleration) (time.Time, corev1.Taint, bool) {
+	var at time.Time
+	var evicting corev1.Taint
+	var found bool
+	for _, taint := range taints {
+		if taint.Effect != corev1.TaintEffectNoExecute {
+			continue
+		}
+		t, ok := evictionTime(taint, tolerations)
+		if ok && (!found || t.Before(at)) {
+			at, evicting, found = t, taint, true
+		}
+	}
+	return at, evicting, found
+}
+
+func evictionTime(taint corev1.Taint, tolerations []corev1.Toleration) (time.Time, bool) {
+	added := now()
+	if taint.TimeAdded != nil {
+		added = taint.TimeAdded.Time
+	}
+	var grace *int64
+	matched := false
+	for i := range tolerations {
+		toleration := &tolerations[i]
+		if !toleration.ToleratesTaint(&taint) {
+			continue
+		}
+		if toleration.TolerationSeconds == nil {
+			return time.Time{}, false
+		}
+		if !matched || *toleration.TolerationSeconds < *grace {
+			grace = toleration.TolerationSeconds
+		}
+		matched = true
+	}
+	if !matched {
+		return added, true
+	}
+	return added.Add(time.Duration(*grace) * time.Second), true
+}
+
+// Taints filters out Clusters with NoSchedule taints the World does not
+// tolerate, and NoExecute ones it does not tolerate or no longer does.
+func Taints(wl *studyv1beta1.World, c *Candidate) string {
+	if taint, ok := UntoleratedTaint(c.Cluster.Spec.Taints, wl.Spec.Tolerations, corev1.TaintEffectNoSchedule); ok {
+		return fmt.Sprintf("cluster has the untolerated taint %s", taint.ToString())
+	}
+	if at, taint, ok := EvictionTime(c.Cluster.Spec.Taints, wl.Spec.Tolerations); ok && !now().Before(at) {
+		return fmt.Sprintf("cluster has the untolerated taint %s", taint.ToString())
+	}
+	return ""
+}
+
+func tolerated(taint *corev1.Taint, tolerations []corev1.Toleration) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasEffect(taint corev1.Taint, effects []corev1.TaintEffect) bool {
+	for _, effect := range effects {
+		if taint.Effect == effect {
+			return true
+		}
+	}
+	return false
+}
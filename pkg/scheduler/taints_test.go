@@ -0,0 +1,118 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+func TestEvictionTime(t *testing.T) {
+	clock := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	added := metav1.NewTime(clock.Add(-time.Minute))
+	noExecute := corev1.Taint{Key: "maintenance", Effect: corev1.TaintEffectNoExecute, TimeAdded: &added}
+	seconds := func(s int64) *int64 { return &s }
+	tolerate := func(grace *int64) corev1.Toleration {
+		return corev1.Toleration{Key: "maintenance", Operator: corev1.TolerationOpExists, TolerationSeconds: grace}
+	}
+
+	tests := []struct {
+		name        string
+		taints      []corev1.Taint
+		tolerations []corev1.Toleration
+		want        time.Time
+		wantEvict   bool
+	}{
+		{name: "no taints"},
+		{
+			name:   "NoSchedule does not evict",
+			taints: []corev1.Taint{{Key: "maintenance", Effect: corev1.TaintEffectNoSchedule}},
+		},
+		{
+			name:      "untolerated",
+			taints:    []corev1.Taint{noExecute},
+			want:      added.Time,
+			wantEvict: true,
+		},
+		{
+			name:      "untolerated without timeAdded",
+			taints:    []corev1.Taint{{Key: "maintenance", Effect: corev1.TaintEffectNoExecute}},
+			want:      clock,
+			wantEvict: true,
+		},
+		{
+			name:        "tolerated forever",
+			taints:      []corev1.Taint{noExecute},
+			tolerations: []corev1.Toleration{tolerate(nil)},
+		},
+		{
+			name:        "tolerated for a while",
+			taints:      []corev1.Taint{noExecute},
+			tolerations: []corev1.Toleration{tolerate(seconds(600)), tolerate(seconds(300))},
+			want:        added.Add(300 * time.Second),
+			wantEvict:   true,
+		},
+		{
+			name:        "toleration for another effect",
+			taints:      []corev1.Taint{noExecute},
+			tolerations: []corev1.Toleration{{Key: "maintenance", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}},
+			want:        added.Time,
+			wantEvict:   true,
+		},
+	}
+	for _, tt := range tests {
+		at, _, evict := EvictionTime(tt.taints, tt.tolerations)
+		if evict != tt.wantEvict || !at.Equal(tt.want) {
+			t.Errorf("%s: EvictionTime() = %s, %t, want %s, %t", tt.name, at, evict, tt.want, tt.wantEvict)
+		}
+	}
+}
+
+func TestTaintsFilter(t *testing.T) {
+	added := metav1.NewTime(time.Now().Add(-time.Hour))
+	seconds := int64(60)
+	cu := cluster("alpha", "", nil, false)
+	cu.Spec.Taints = []corev1.Taint{
+		{Key: "gpu", Value: "true", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "maintenance", Effect: corev1.TaintEffectNoExecute, TimeAdded: &added},
+	}
+	gpu := corev1.Toleration{Key: "gpu", Operator: corev1.TolerationOpEqual, Value: "true"}
+
+	for name, tt := range map[string]struct {
+		tolerations []corev1.Toleration
+		fits        bool
+	}{
+		"untolerated":     {},
+		"NoSchedule only": {tolerations: []corev1.Toleration{gpu}},
+		"grace ran out":   {tolerations: []corev1.Toleration{gpu, {Key: "maintenance", Operator: corev1.TolerationOpExists, TolerationSeconds: &seconds}}},
+		"tolerates both":  {tolerations: []corev1.Toleration{gpu, {Key: "maintenance", Operator: corev1.TolerationOpExists}}, fits: true},
+		"tolerates all":   {tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}}, fits: true},
+	} {
+		wl := &studyv1beta1.World{Spec: studyv1beta1.WorldSpec{Placement: &studyv1beta1.Placement{}, Tolerations: tt.tolerations}}
+		if reason := Taints(wl, &Candidate{Cluster: cu}); (reason == "") != tt.fits {
+			t.Errorf("%s: Taints() = %q, want fits %t", name, reason, tt.fits)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sharding splits the objects of a controller between manager
+// replicas. Every replica keeps a member Lease alive; objects hash to a fixed
+// number of shards and every shard is assigned to one live member by
+// rendezvous hashing, so a replica joining or leaving only moves the shards
+// it gains or loses.
+//
+// Every replica decides ownership from its own view of the member Leases, so
+// ownership overlaps briefly during a rebalance: a joining replica owns its
+// shards after its first sync while the previous owner keeps them until its
+// next one, up to RenewPeriod later. Reconcilers must write with the
+// resourceVersion of what they read, so the replica that loses the race gets
+// a conflict instead of overwriting the other.
+package sharding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github/antmoveh/kube-develop-tools/pkg/metrics"
+)
+
+const (
+	// Label pins an object to a shard, given as a number below the shard
+	// count. Objects without it are hashed by namespace/name.
+	Label = "example.cn/shard"
+
+	// groupLabel marks the member Leases of a group of replicas.
+	groupLabel = "example.cn/shard-group"
+)
+
+// ShardFor returns the shard of obj out of shards.
+func ShardFor(obj client.Object, shards int) int {
+	if v, ok := obj.GetLabels()[Label]; ok {
+		if shard, err := strconv.Atoi(v); err == nil && shard >= 0 && shard < shards {
+			return shard
+		}
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(obj.GetNamespace() + "/" + obj.GetName()))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// Assign returns the member that owns every shard. members must be sorted.
+func Assign(members []string, shards int) []string {
+	owners := make([]string, shards)
+	if len(members) == 0 {
+		return owners
+	}
+	for shard := range owners {
+		var best uint64
+		for _, m := range members {
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(m + "/" + strconv.Itoa(shard)))
+			if score := mix(h.Sum64()); owners[shard] == "" || score > best {
+				owners[shard], best = m, score
+			}
+		}
+	}
+	return owners
+}
+
+// mix spreads the bits of an FNV hash, whose high bits barely change between
+// short keys, with the splitmix64 finalizer.
+func mix(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
+
+// Coordinator keeps the member Lease of this replica and tracks which shards
+// it owns. A nil Coordinator owns everything, so reconcilers can use one
+// unconditionally.
+type Coordinator struct {
+	// Client writes the member Lease.
+	Client client.Client
+	// Reader lists the member Leases. It should not be backed by the cache,
+	// which would watch every Lease in the cluster.
+	Reader client.Reader
+	// Namespace holds the member Leases.
+	Namespace string
+	// Group names the replicas that share the objects; the member Leases are
+	// called <Group>-<Identity>.
+	Group string
+	// Identity is unique to this replica, usually the pod name.
+	Identity string
+	// Shards is the number of shards objects are split into. It should be
+	// larger than the number of replicas so shards spread evenly.
+	Shards int
+	// LeaseDuration is how long a member counts as live after its last
+	// renewal, and RenewPeriod how often members renew and rebalance.
+	LeaseDuration time.Duration
+	RenewPeriod   time.Duration
+
+	mu          sync.RWMutex
+	members     []string
+	owners      []string
+	subscribers []chan event.GenericEvent
+}
+
+var _ manager.Runnable = &Coordinator{}
+var _ manager.LeaderElectionRunnable = &Coordinator{}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable; every replica
+// takes part in sharding.
+func (c *Coordinator) NeedLeaderElection() bool {
+	return false
+}
+
+// Owns reports whether this replica reconciles obj. Nothing is owned until
+// the first membership sync.
+func (c *Coordinator) Owns(obj client.Object) bool {
+	if c == nil {
+		return true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.owners) == 0 {
+		return false
+	}
+	return c.owners[ShardFor(obj, c.Shards)] == c.Identity
+}
+
+// Predicate filters out the events of objects owned by other replicas.
+func (c *Coordinator) Predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(c.Owns)
+}
+
+// Subscribe returns a channel that receives an event whenever the shards of
+// this replica change. The event carries no object; subscribers should
+// enqueue everything they now own. Call it before the manager starts.
+func (c *Coordinator) Subscribe() <-chan event.GenericEvent {
+	ch := make(chan event.GenericEvent, 1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribers = append(c.subscribers, ch)
+	return ch
+}
+
+// Start renews the member Lease and rebalances every RenewPeriod until ctx
+// is done, then deletes the member Lease so the others take over at once.
+func (c *Coordinator) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithValues("identity", c.Identity, "group", c.Group)
+	wait.JitterUntilWithContext(ctx, func(ctx context.Context) {
+		if err := c.sync(ctx); err != nil {
+			logger.Error(err, "unable to sync shard membership")
+		}
+	}, c.RenewPeriod, 0.2, true)
+
+	lease := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: c.Namespace, Name: c.leaseName()}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Client.Delete(ctx, lease); err != nil && !apierrs.IsNotFound(err) {
+		logger.Error(err, "unable to release the member lease")
+	}
+	return nil
+}
+
+func (c *Coordinator) leaseName() string {
+	return c.Group + "-" + c.Identity
+}
+
+// sync renews the member Lease and recomputes the owned shards.
+func (c *Coordinator) sync(ctx context.Context) error {
+	if err := c.renew(ctx); err != nil {
+		return err
+	}
+
+	leases := new(coordinationv1.LeaseList)
+	if err := c.Reader.List(ctx, leases, client.InNamespace(c.Namespace), client.MatchingLabels{groupLabel: c.Group}); err != nil {
+		return err
+	}
+	now := time.Now()
+	var members []string
+	for _, l := range leases.Items {
+		if l.Spec.HolderIdentity == nil || l.Spec.RenewTime == nil {
+			continue
+		}
+		if *l.Spec.HolderIdentity != c.Identity && l.Spec.RenewTime.Add(c.LeaseDuration).Before(now) {
+			continue
+		}
+		members = append(members, *l.Spec.HolderIdentity)
+	}
+	sort.Strings(members)
+	c.rebalance(ctx, members)
+	return nil
+}
+
+func (c *Coordinator) renew(ctx context.Context) error {
+	now := metav1.NewMicroTime(time.Now())
+	seconds := int32(c.LeaseDuration / time.Second)
+	lease := new(coordinationv1.Lease)
+	err := c.Reader.Get(ctx, client.ObjectKey{Namespace: c.Namespace, Name: c.leaseName()}, lease)
+	if apierrs.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: c.Namespace,
+				Name:      c.leaseName(),
+				Labels:    map[string]string{groupLabel: c.Group},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &c.Identity,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		return c.Client.Create(ctx, lease)
+	}
+	if err != nil {
+		return err
+	}
+	lease.Spec.HolderIdentity = &c.Identity
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &now
+	return c.Client.Update(ctx, lease)
+}
+
+// rebalance switches to the assignment for members and notifies the
+// subscribers when the shards of this replica changed.
+func (c *Coordinator) rebalance(ctx context.Context, members []string) {
+	owners := Assign(members, c.Shards)
+
+	c.mu.Lock()
+	changed := len(c.owners) == 0
+	for shard := range owners {
+		if !changed && (owners[shard] == c.Identity) != (c.owners[shard] == c.Identity) {
+			changed = true
+		}
+	}
+	c.members, c.owners = members, owners
+	subscribers := c.subscribers
+	owned := c.ownedLocked()
+	c.mu.Unlock()
+
+	metrics.ShardMembers.Set(float64(len(members)))
+	metrics.OwnedShards.Set(float64(len(owned)))
+	if !changed {
+		return
+	}
+	log.FromContext(ctx).Info("shards rebalanced", "identity", c.Identity, "members", members, "owned", owned)
+	for _, ch := range subscribers {
+		select {
+		case ch <- event.GenericEvent{}:
+		default:
+			// A rebalance is already pending for this subscriber.
+		}
+	}
+}
+
+func (c *Coordinator) ownedLocked() []int {
+	var owned []int
+	for shard, owner := range c.owners {
+		if owner == c.Identity {
+			owned = append(owned, shard)
+		}
+	}
+	return owned
+}
+
+// Status is the sharding state served by the coordinator.
+type Status struct {
+	Identity string   `json:"identity"`
+	Shards   int      `json:"shards"`
+	Members  []string `json:"members"`
+	Owned    []int    `json:"owned"`
+}
+
+// ServeHTTP serves the sharding state of this replica as JSON.
+func (c *Coordinator) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	c.mu.RLock()
+	s := Status{Identity: c.Identity, Shards: c.Shards, Members: c.members, Owned: c.ownedLocked()}
+	c.mu.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(s)
+}
+
+// Validate checks the settings of c.
+func (c *Coordinator) Validate() error {
+	switch {
+	case c.Shards <= 0:
+		return fmt.Errorf("the shard count must be positive, got %d", c.Shards)
+	case c.Identity == "":
+		return fmt.Errorf("the shard identity must not be empty")
+	case c.Namespace == "":
+		return fmt.Errorf("the shard lease namespace must not be empty")
+	case c.RenewPeriod <= 0 || c.LeaseDuration <= c.RenewPeriod:
+		return fmt.Errorf("the lease duration %s must be longer than the renew period %s", c.LeaseDuration, c.RenewPeriod)
+	}
+	return nil
+}
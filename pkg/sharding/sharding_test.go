@@ -0,0 +1,145 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func object(name string, labels map[string]string) client.Object {
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Labels: labels}}
+}
+
+func TestShardFor(t *testing.T) {
+	if got := ShardFor(object("a", map[string]string{Label: "3"}), 8); got != 3 {
+		t.Errorf("labeled object is in shard %d, want 3", got)
+	}
+	hashed := ShardFor(object("a", nil), 8)
+	if got := ShardFor(object("a", map[string]string{Label: "42"}), 8); got != hashed {
+		t.Errorf("out of range label gave shard %d, want the hashed shard %d", got, hashed)
+	}
+	counts := make([]int, 8)
+	for i := 0; i < 800; i++ {
+		counts[ShardFor(object(fmt.Sprintf("world-%d", i), nil), 8)]++
+	}
+	for shard, n := range counts {
+		if n == 0 {
+			t.Errorf("shard %d got no objects: %v", shard, counts)
+		}
+	}
+}
+
+func TestAssignIsConsistent(t *testing.T) {
+	before := Assign([]string{"a", "b", "c"}, 64)
+	after := Assign([]string{"a", "b", "c", "d"}, 64)
+	moved := 0
+	for shard := range before {
+		if before[shard] != after[shard] {
+			moved++
+			if after[shard] != "d" {
+				t.Errorf("shard %d moved from %s to %s, want only moves to the new member", shard, before[shard], after[shard])
+			}
+		}
+	}
+	if moved == 0 || moved > 32 {
+		t.Errorf("%d of 64 shards moved to the new member", moved)
+	}
+}
+
+func TestCoordinator(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewClientBuilder().Build()
+	newCoordinator := func(identity string) *Coordinator {
+		return &Coordinator{
+			Client:        c,
+			Reader:        c,
+			Namespace:     "system",
+			Group:         "test",
+			Identity:      identity,
+			Shards:        16,
+			LeaseDuration: 15 * time.Second,
+			RenewPeriod:   2 * time.Second,
+		}
+	}
+	a, b := newCoordinator("a"), newCoordinator("b")
+	changes := a.Subscribe()
+
+	if a.Owns(object("x", nil)) {
+		t.Error("owns objects before the first sync")
+	}
+	if err := a.sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	default:
+		t.Error("no rebalance after the first sync")
+	}
+	for i := 0; i < 16; i++ {
+		if !a.Owns(object("x", map[string]string{Label: fmt.Sprint(i)})) {
+			t.Errorf("a single member does not own shard %d", i)
+		}
+	}
+
+	if err := b.sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	default:
+		t.Error("no rebalance after a member joined")
+	}
+	for i := 0; i < 16; i++ {
+		obj := object("x", map[string]string{Label: fmt.Sprint(i)})
+		if a.Owns(obj) == b.Owns(obj) {
+			t.Errorf("shard %d: a owns %v, b owns %v, want exactly one owner", i, a.Owns(obj), b.Owns(obj))
+		}
+	}
+
+	// b stops renewing and its lease expires.
+	lease := new(coordinationv1.Lease)
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "system", Name: "test-b"}, lease); err != nil {
+		t.Fatal(err)
+	}
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now().Add(-time.Minute)}
+	if err := c.Update(ctx, lease); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.ownedLocked()) != 16 {
+		t.Errorf("a owns %v after b left, want every shard", a.ownedLocked())
+	}
+
+	var nilCoordinator *Coordinator
+	if !nilCoordinator.Owns(object("x", nil)) {
+		t.Error("a nil coordinator does not own everything")
+	}
+}
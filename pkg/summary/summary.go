@@ -0,0 +1,116 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package summary computes the WorldSummary of a namespace from the Worlds
+// and Clusters the controllers already watch.
+package summary
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	summaryv1alpha1 "github/antmoveh/kube-develop-tools/apis/summary/v1alpha1"
+	"github/antmoveh/kube-develop-tools/pkg/pause"
+)
+
+const (
+	// PhaseUnknown counts the Worlds the controller has not set a phase on.
+	PhaseUnknown = "Unknown"
+
+	// conditionStale is the condition the World controller sets on Worlds
+	// whose resyncs keep failing, see controllers.ConditionStale.
+	conditionStale = "Stale"
+)
+
+// Summarize returns the summary of every namespace that has Worlds, keyed by
+// namespace. clusters are all the Clusters; only those a World is bound to
+// show up in a summary.
+func Summarize(worlds []studyv1beta1.World, clusters []commonv1beta1.Cluster) map[string]*summaryv1alpha1.WorldSummary {
+	byName := make(map[string]*commonv1beta1.Cluster, len(clusters))
+	for i := range clusters {
+		byName[clusters[i].Name] = &clusters[i]
+	}
+
+	summaries := map[string]*summaryv1alpha1.WorldSummary{}
+	health := map[string]map[string]*summaryv1alpha1.ClusterHealth{}
+	for i := range worlds {
+		w := &worlds[i]
+		s, ok := summaries[w.Namespace]
+		if !ok {
+			s = &summaryv1alpha1.WorldSummary{
+				ObjectMeta: metav1.ObjectMeta{Name: w.Namespace},
+				Phases:     map[string]int32{},
+			}
+			summaries[w.Namespace] = s
+			health[w.Namespace] = map[string]*summaryv1alpha1.ClusterHealth{}
+		}
+
+		s.Worlds++
+		phase := string(w.Status.Phase)
+		if phase == "" {
+			phase = PhaseUnknown
+		}
+		s.Phases[phase]++
+		paused := meta.IsStatusConditionTrue(w.Status.Conditions, pause.ConditionType)
+		if paused {
+			s.Paused++
+		}
+		stale := meta.IsStatusConditionTrue(w.Status.Conditions, conditionStale)
+		if stale {
+			s.Stale++
+		}
+
+		for _, name := range w.BoundClusters() {
+			h, ok := health[w.Namespace][name]
+			if !ok {
+				h = &summaryv1alpha1.ClusterHealth{Name: name}
+				health[w.Namespace][name] = h
+			}
+			h.Worlds++
+			if stale || w.Status.Phase == studyv1beta1.WorldFailed {
+				h.Unhealthy++
+			}
+		}
+	}
+
+	for ns, s := range summaries {
+		for _, h := range health[ns] {
+			h.Health = clusterHealth(byName[h.Name], h)
+			s.Clusters = append(s.Clusters, *h)
+		}
+		sort.Slice(s.Clusters, func(i, j int) bool { return s.Clusters[i].Name < s.Clusters[j].Name })
+	}
+	return summaries
+}
+
+func clusterHealth(cluster *commonv1beta1.Cluster, h *summaryv1alpha1.ClusterHealth) summaryv1alpha1.ClusterHealthState {
+	switch {
+	case cluster == nil:
+		return summaryv1alpha1.ClusterMissing
+	case meta.IsStatusConditionTrue(cluster.Status.Conditions, pause.ConditionType):
+		return summaryv1alpha1.ClusterPaused
+	case meta.IsStatusConditionFalse(cluster.Status.Conditions, commonv1beta1.ClusterConditionSchedulable):
+		return summaryv1alpha1.ClusterCordoned
+	case h.Unhealthy > 0:
+		return summaryv1alpha1.ClusterDegraded
+	default:
+		return summaryv1alpha1.ClusterHealthy
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package summary
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	summaryv1alpha1 "github/antmoveh/kube-develop-tools/apis/summary/v1alpha1"
+	"github/antmoveh/kube-develop-tools/pkg/pause"
+)
+
+func world(ns, name string, phase studyv1beta1.WorldPhase, clusters []string, conditions ...string) studyv1beta1.World {
+	w := studyv1beta1.World{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+		Spec:       studyv1beta1.WorldSpec{Clusters: clusters},
+		Status:     studyv1beta1.WorldStatus{Phase: phase},
+	}
+	for _, c := range conditions {
+		w.Status.Conditions = append(w.Status.Conditions, metav1.Condition{Type: c, Status: metav1.ConditionTrue})
+	}
+	return w
+}
+
+func TestSummarize(t *testing.T) {
+	worlds := []studyv1beta1.World{
+		world("a", "w1", studyv1beta1.WorldReady, []string{"c1", "c2"}),
+		world("a", "w2", studyv1beta1.WorldFailed, []string{"c1"}),
+		world("a", "w3", "", []string{"gone"}, pause.ConditionType),
+		world("b", "w1", studyv1beta1.WorldReady, []string{"c2", "c3"}, conditionStale),
+		world("b", "w2", studyv1beta1.WorldPending, []string{"c4"}),
+	}
+	clusters := []commonv1beta1.Cluster{
+		{ObjectMeta: metav1.ObjectMeta{Name: "c1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "c2"}},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "c3"},
+			Status: commonv1beta1.ClusterStatus{Conditions: []metav1.Condition{
+				{Type: pause.ConditionType, Status: metav1.ConditionTrue},
+			}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "c4"},
+			Status: commonv1beta1.ClusterStatus{Conditions: []metav1.Condition{
+				{Type: commonv1beta1.ClusterConditionSchedulable, Status: metav1.ConditionFalse},
+			}},
+		},
+	}
+
+	got := Summarize(worlds, clusters)
+	want := map[string]*summaryv1alpha1.WorldSummary{
+		"a": {
+			ObjectMeta: metav1.ObjectMeta{Name: "a"},
+			Worlds:     3,
+			Phases:     map[string]int32{"Ready": 1, "Failed": 1, PhaseUnknown: 1},
+			Paused:     1,
+			Clusters: []summaryv1alpha1.ClusterHealth{
+				{Name: "c1", Health: summaryv1alpha1.ClusterDegraded, Worlds: 2, Unhealthy: 1},
+				{Name: "c2", Health: summaryv1alpha1.ClusterHealthy, Worlds: 1},
+				{Name: "gone", Health: summaryv1alpha1.ClusterMissing, Worlds: 1},
+			},
+		},
+		"b": {
+			ObjectMeta: metav1.ObjectMeta{Name: "b"},
+			Worlds:     2,
+			Phases:     map[string]int32{"Ready": 1, "Pending": 1},
+			Stale:      1,
+			Clusters: []summaryv1alpha1.ClusterHealth{
+				{Name: "c2", Health: summaryv1alpha1.ClusterDegraded, Worlds: 1, Unhealthy: 1},
+				{Name: "c3", Health: summaryv1alpha1.ClusterPaused, Worlds: 1, Unhealthy: 1},
+				{Name: "c4", Health: summaryv1alpha1.ClusterCordoned, Worlds: 1},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Summarize() = %+v, want %+v", got, want)
+	}
+	if got := Summarize(nil, clusters); len(got) != 0 {
+		t.Errorf("Summarize() without Worlds = %v", got)
+	}
+}
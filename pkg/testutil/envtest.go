@@ -0,0 +1,180 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testutil holds helpers for testing the controllers of this project.
+package testutil
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+
+	commonscopeclusterv1beta1 "github/antmoveh/kube-develop-tools/apis/common/v1beta1"
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+	studyv1beta2 "github/antmoveh/kube-develop-tools/apis/study/v1beta2"
+)
+
+// NewScheme returns a scheme with the built-in types and every version of
+// the project's API types.
+func NewScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(s))
+	utilruntime.Must(studyv1beta1.AddToScheme(s))
+	utilruntime.Must(studyv1beta2.AddToScheme(s))
+	utilruntime.Must(commonscopeclusterv1beta1.AddToScheme(s))
+	return s
+}
+
+// NewEnvironment returns an envtest environment that installs the CRDs from
+// config/crd/bases and the webhooks from config/webhook under root. The CRDs
+// of convertible kinds are switched to the conversion webhook, which envtest
+// then points at the locally served webhook with generated certificates.
+func NewEnvironment(root string, s *runtime.Scheme) (*envtest.Environment, error) {
+	crds, err := readCRDs(filepath.Join(root, "config", "crd", "bases"), s)
+	if err != nil {
+		return nil, err
+	}
+	return &envtest.Environment{
+		Scheme: s,
+		CRDs:   crds,
+		CRDInstallOptions: envtest.CRDInstallOptions{
+			Scheme: s,
+		},
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			Paths: []string{filepath.Join(root, "config", "webhook")},
+		},
+	}, nil
+}
+
+// NewManager returns a manager that serves webhooks with the certificates
+// generated for env. Metrics, probes and leader election are disabled.
+func NewManager(env *envtest.Environment, cfg *rest.Config) (ctrl.Manager, error) {
+	o := env.WebhookInstallOptions
+	return ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:                 env.Scheme,
+		Host:                   o.LocalServingHost,
+		Port:                   o.LocalServingPort,
+		CertDir:                o.LocalServingCertDir,
+		MetricsBindAddress:     "0",
+		HealthProbeBindAddress: "0",
+		LeaderElection:         false,
+	})
+}
+
+// WaitForWebhookServer blocks until the webhook server of env accepts TLS
+// connections.
+func WaitForWebhookServer(env *envtest.Environment, timeout time.Duration) error {
+	o := env.WebhookInstallOptions
+	addr := net.JoinHostPort(o.LocalServingHost, strconv.Itoa(o.LocalServingPort))
+	dialer := &net.Dialer{Timeout: time.Second}
+	return wait.PollImmediate(100*time.Millisecond, timeout, func() (bool, error) {
+		conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{InsecureSkipVerify: true}) // #nosec G402
+		if err != nil {
+			return false, nil
+		}
+		return true, conn.Close()
+	})
+}
+
+// EventsFor lists the Events recorded for obj, in any namespace.
+func EventsFor(ctx context.Context, c client.Reader, obj client.Object) ([]corev1.Event, error) {
+	events := new(corev1.EventList)
+	if err := c.List(ctx, events, client.MatchingFields{"involvedObject.name": obj.GetName()}); err != nil {
+		return nil, err
+	}
+	matched := events.Items[:0]
+	for _, e := range events.Items {
+		if e.InvolvedObject.UID == obj.GetUID() {
+			matched = append(matched, e)
+		}
+	}
+	return matched, nil
+}
+
+// readCRDs reads the CRDs in dir. envtest only rewrites the conversion
+// settings of CRDs that already declare a conversion, so one is added for the
+// kinds that are convertible in s.
+func readCRDs(dir string, s *runtime.Scheme) ([]apiextensionsv1.CustomResourceDefinition, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var crds []apiextensionsv1.CustomResourceDefinition
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".yaml" {
+			continue
+		}
+		file, err := os.Open(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		crd := new(apiextensionsv1.CustomResourceDefinition)
+		err = utilyaml.NewYAMLOrJSONDecoder(file, 4096).Decode(crd)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name(), err)
+		}
+
+		if isConvertible(s, schema.GroupKind{Group: crd.Spec.Group, Kind: crd.Spec.Names.Kind}) {
+			crd.Spec.Conversion = &apiextensionsv1.CustomResourceConversion{
+				Strategy: apiextensionsv1.WebhookConverter,
+				Webhook: &apiextensionsv1.WebhookConversion{
+					ClientConfig:             &apiextensionsv1.WebhookClientConfig{},
+					ConversionReviewVersions: []string{"v1"},
+				},
+			}
+		}
+		crds = append(crds, *crd)
+	}
+	return crds, nil
+}
+
+func isConvertible(s *runtime.Scheme, gk schema.GroupKind) bool {
+	for gvk := range s.AllKnownTypes() {
+		if gvk.GroupKind() != gk {
+			continue
+		}
+		obj, err := s.New(gvk)
+		if err != nil {
+			continue
+		}
+		if ok, err := conversion.IsConvertible(s, obj); ok && err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,396 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// Verb names a client call that errors can be injected into.
+type Verb string
+
+const (
+	VerbGet          Verb = "get"
+	VerbList         Verb = "list"
+	VerbCreate       Verb = "create"
+	VerbUpdate       Verb = "update"
+	VerbPatch        Verb = "patch"
+	VerbDelete       Verb = "delete"
+	VerbDeleteAllOf  Verb = "deletecollection"
+	VerbStatusUpdate Verb = "update/status"
+	VerbStatusPatch  Verb = "patch/status"
+)
+
+// ErrorFunc decides the error a call fails with. obj is the object, or the
+// list for VerbList, as passed by the caller. A nil error lets the call
+// through.
+type ErrorFunc func(key client.ObjectKey, obj runtime.Object) error
+
+// AlwaysError fails every call with err.
+func AlwaysError(err error) ErrorFunc {
+	return func(client.ObjectKey, runtime.Object) error { return err }
+}
+
+// ErrorOnce fails the first call with err and lets the later ones through.
+func ErrorOnce(err error) ErrorFunc {
+	var once sync.Once
+	return func(client.ObjectKey, runtime.Object) error {
+		var result error
+		once.Do(func() { result = err })
+		return result
+	}
+}
+
+// FakeClient is an in-memory client for unit testing reconcilers. On top of
+// the controller-runtime fake client it
+//   - treats status as a subresource: Update and Patch keep the stored status
+//     and Status().Update and Status().Patch only change the status,
+//   - honors field selectors on fields registered with IndexField, like the
+//     cache of a manager does,
+//   - fails calls with the errors injected with InjectError.
+type FakeClient struct {
+	client.Client
+
+	mu      sync.Mutex
+	indexes map[schema.GroupVersionKind]map[string]client.IndexerFunc
+	errors  map[Verb]ErrorFunc
+}
+
+var _ client.Client = &FakeClient{}
+var _ client.FieldIndexer = &FakeClient{}
+
+// NewFakeClient returns a FakeClient that stores objs. A nil s defaults to
+// NewScheme().
+func NewFakeClient(s *runtime.Scheme, objs ...client.Object) *FakeClient {
+	if s == nil {
+		s = NewScheme()
+	}
+	return &FakeClient{
+		Client:  fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).Build(),
+		indexes: map[schema.GroupVersionKind]map[string]client.IndexerFunc{},
+		errors:  map[Verb]ErrorFunc{},
+	}
+}
+
+// IndexField registers an index on field for obj's kind. Like the cache, only
+// equality requirements on indexed fields are supported in field selectors.
+func (c *FakeClient) IndexField(_ context.Context, obj client.Object, field string, extractValue client.IndexerFunc) error {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.indexes[gvk] == nil {
+		c.indexes[gvk] = map[string]client.IndexerFunc{}
+	}
+	c.indexes[gvk][field] = extractValue
+	return nil
+}
+
+// InjectError makes the calls of verb fail with the error returned by fn.
+// A nil fn removes the injected error.
+func (c *FakeClient) InjectError(verb Verb, fn ErrorFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if fn == nil {
+		delete(c.errors, verb)
+		return
+	}
+	c.errors[verb] = fn
+}
+
+// ClearErrors removes every injected error.
+func (c *FakeClient) ClearErrors() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors = map[Verb]ErrorFunc{}
+}
+
+func (c *FakeClient) injected(verb Verb, key client.ObjectKey, obj runtime.Object) error {
+	c.mu.Lock()
+	fn := c.errors[verb]
+	c.mu.Unlock()
+	if fn == nil {
+		return nil
+	}
+	return fn(key, obj)
+}
+
+func (c *FakeClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if err := c.injected(VerbGet, key, obj); err != nil {
+		return err
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *FakeClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if err := c.injected(VerbList, client.ObjectKey{Namespace: listOpts.Namespace}, list); err != nil {
+		return err
+	}
+
+	selector := listOpts.FieldSelector
+	listOpts.FieldSelector = nil
+	if err := c.Client.List(ctx, list, &listOpts); err != nil {
+		return err
+	}
+	if selector == nil || selector.Empty() {
+		return nil
+	}
+	return c.filterByIndex(list, selector)
+}
+
+// filterByIndex drops the items of list that do not match selector.
+func (c *FakeClient) filterByIndex(list client.ObjectList, selector fields.Selector) error {
+	gvk, err := apiutil.GVKForObject(list, c.Scheme())
+	if err != nil {
+		return err
+	}
+	gvk.Kind = gvk.Kind[:len(gvk.Kind)-len("List")]
+
+	c.mu.Lock()
+	indexes := c.indexes[gvk]
+	c.mu.Unlock()
+
+	requirements := selector.Requirements()
+	for _, r := range requirements {
+		if indexes[r.Field] == nil {
+			return fmt.Errorf("index with name field:%s does not exist", r.Field)
+		}
+		if r.Operator != selection.Equals && r.Operator != selection.DoubleEquals {
+			return fmt.Errorf("field selector %q is not supported, only equality is", selector)
+		}
+	}
+
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	matched := make([]runtime.Object, 0, len(items))
+	for _, item := range items {
+		obj, ok := item.(client.Object)
+		if !ok {
+			return fmt.Errorf("list item %T is not a client.Object", item)
+		}
+		if matchesIndexes(obj, indexes, requirements) {
+			matched = append(matched, item)
+		}
+	}
+	return meta.SetList(list, matched)
+}
+
+func matchesIndexes(obj client.Object, indexes map[string]client.IndexerFunc, requirements fields.Requirements) bool {
+	for _, r := range requirements {
+		found := false
+		for _, v := range indexes[r.Field](obj) {
+			if v == r.Value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *FakeClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.injected(VerbCreate, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *FakeClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.injected(VerbDelete, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return err
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *FakeClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	if err := c.injected(VerbDeleteAllOf, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return err
+	}
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+// Update updates obj except for its status, which keeps the stored value.
+func (c *FakeClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.injected(VerbUpdate, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return err
+	}
+	stored := obj.DeepCopyObject().(client.Object)
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), stored); err != nil {
+		return err
+	}
+	if err := copyStatus(obj, stored); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+// Patch patches obj except for its status, which keeps the stored value.
+func (c *FakeClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.injected(VerbPatch, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return err
+	}
+	stored := obj.DeepCopyObject().(client.Object)
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), stored); err != nil {
+		return err
+	}
+	if err := c.Client.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+	changed, err := statusChanged(obj, stored)
+	if err != nil || !changed {
+		return err
+	}
+	if err := copyStatus(obj, stored); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj)
+}
+
+func (c *FakeClient) Status() client.StatusWriter {
+	return &fakeStatusWriter{client: c}
+}
+
+type fakeStatusWriter struct {
+	client *FakeClient
+}
+
+// Update updates the status of obj and nothing else.
+func (w *fakeStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c := w.client
+	if err := c.injected(VerbStatusUpdate, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return err
+	}
+	merged := obj.DeepCopyObject().(client.Object)
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), merged); err != nil {
+		return err
+	}
+	if err := copyStatus(merged, obj); err != nil {
+		return err
+	}
+	merged.SetResourceVersion(obj.GetResourceVersion())
+	if err := c.Client.Update(ctx, merged, opts...); err != nil {
+		return err
+	}
+	return copyInto(obj, merged)
+}
+
+// Patch patches the status of obj and nothing else.
+func (w *fakeStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c := w.client
+	if err := c.injected(VerbStatusPatch, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return err
+	}
+	merged := obj.DeepCopyObject().(client.Object)
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), merged); err != nil {
+		return err
+	}
+	patched := obj.DeepCopyObject().(client.Object)
+	if err := c.Client.Patch(ctx, patched, patch, opts...); err != nil {
+		return err
+	}
+	// The patch went through the main resource; put back everything but the
+	// status.
+	if err := copyStatus(merged, patched); err != nil {
+		return err
+	}
+	merged.SetResourceVersion(patched.GetResourceVersion())
+	if err := c.Client.Update(ctx, merged); err != nil {
+		return err
+	}
+	return copyInto(obj, merged)
+}
+
+// copyStatus sets the status of dst to the status of src.
+func copyStatus(dst, src client.Object) error {
+	srcFields, err := toMap(src)
+	if err != nil {
+		return err
+	}
+	dstFields, err := toMap(dst)
+	if err != nil {
+		return err
+	}
+	if status, ok := srcFields["status"]; ok {
+		dstFields["status"] = status
+	} else {
+		delete(dstFields, "status")
+	}
+	return fromMap(dstFields, dst)
+}
+
+func statusChanged(a, b client.Object) (bool, error) {
+	aFields, err := toMap(a)
+	if err != nil {
+		return false, err
+	}
+	bFields, err := toMap(b)
+	if err != nil {
+		return false, err
+	}
+	return !equality.Semantic.DeepEqual(aFields["status"], bFields["status"]), nil
+}
+
+// copyInto overwrites dst with src, which has the same type.
+func copyInto(dst, src client.Object) error {
+	fields, err := toMap(src)
+	if err != nil {
+		return err
+	}
+	return fromMap(fields, dst)
+}
+
+func toMap(obj client.Object) (map[string]interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	return fields, json.Unmarshal(data, &fields)
+}
+
+func fromMap(fields map[string]interface{}, obj client.Object) error {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(obj).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	return json.Unmarshal(data, obj)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	studyv1beta1 "github/antmoveh/kube-develop-tools/apis/study/v1beta1"
+)
+
+func newWorld() *studyv1beta1.World {
+	return &studyv1beta1.World{
+		ObjectMeta: metav1.ObjectMeta{Name: "earth", Namespace: "default"},
+		Spec:       studyv1beta1.WorldSpec{World: "hello"},
+		Status:     studyv1beta1.WorldStatus{War: "old"},
+	}
+}
+
+func TestFakeClientStatusSubresource(t *testing.T) {
+	ctx := context.Background()
+	c := NewFakeClient(nil, newWorld())
+	key := client.ObjectKey{Namespace: "default", Name: "earth"}
+
+	wl := new(studyv1beta1.World)
+	if err := c.Get(ctx, key, wl); err != nil {
+		t.Fatal(err)
+	}
+	wl.Spec.World = "changed"
+	wl.Status.War = "ignored"
+	if err := c.Update(ctx, wl); err != nil {
+		t.Fatal(err)
+	}
+	if wl.Status.War != "old" {
+		t.Errorf("Update returned status %q, want the stored status", wl.Status.War)
+	}
+
+	wl.Spec.World = "ignored"
+	wl.Status.War = "new"
+	if err := c.Status().Update(ctx, wl); err != nil {
+		t.Fatal(err)
+	}
+	if wl.Spec.World != "changed" {
+		t.Errorf("Status().Update returned spec %q, want the stored spec", wl.Spec.World)
+	}
+
+	patch := client.MergeFrom(wl.DeepCopy())
+	wl.Spec.World = "ignored"
+	wl.Status.War = "patched"
+	if err := c.Status().Patch(ctx, wl, patch); err != nil {
+		t.Fatal(err)
+	}
+
+	got := new(studyv1beta1.World)
+	if err := c.Get(ctx, key, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Spec.World != "changed" || got.Status.War != "patched" {
+		t.Errorf("got spec %q and status %q, want changed and patched", got.Spec.World, got.Status.War)
+	}
+
+	patch = client.MergeFrom(got.DeepCopy())
+	got.Finalizers = []string{"test"}
+	got.Status.War = "ignored"
+	if err := c.Patch(ctx, got, patch); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.War != "patched" || len(got.Finalizers) != 1 {
+		t.Errorf("Patch returned status %q and finalizers %v", got.Status.War, got.Finalizers)
+	}
+}
+
+func TestFakeClientFieldIndex(t *testing.T) {
+	ctx := context.Background()
+	pod := func(name, node string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: node},
+		}
+	}
+	c := NewFakeClient(nil, pod("a", "node-1"), pod("b", "node-2"), pod("c", "node-1"))
+
+	pods := new(corev1.PodList)
+	if err := c.List(ctx, pods, client.MatchingFields{"spec.nodeName": "node-1"}); err == nil {
+		t.Fatal("expected an error for a field without an index")
+	}
+
+	err := c.IndexField(ctx, &corev1.Pod{}, "spec.nodeName", func(obj client.Object) []string {
+		return []string{obj.(*corev1.Pod).Spec.NodeName}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.List(ctx, pods, client.MatchingFields{"spec.nodeName": "node-1"}); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range pods.Items {
+		names = append(names, p.Name)
+	}
+	if len(names) != 2 || names[0] != "a" || names[1] != "c" {
+		t.Errorf("got pods %v, want [a c]", names)
+	}
+}
+
+func TestFakeClientInjectError(t *testing.T) {
+	ctx := context.Background()
+	c := NewFakeClient(nil, newWorld())
+	key := client.ObjectKey{Namespace: "default", Name: "earth"}
+	boom := errors.New("boom")
+
+	c.InjectError(VerbGet, ErrorOnce(boom))
+	if err := c.Get(ctx, key, new(studyv1beta1.World)); err != boom {
+		t.Errorf("first Get: got %v, want %v", err, boom)
+	}
+	if err := c.Get(ctx, key, new(studyv1beta1.World)); err != nil {
+		t.Errorf("second Get: got %v", err)
+	}
+
+	c.InjectError(VerbStatusUpdate, AlwaysError(apierrs.NewConflict(studyv1beta1.GroupVersion.WithResource("worlds").GroupResource(), "earth", boom)))
+	wl := new(studyv1beta1.World)
+	if err := c.Get(ctx, key, wl); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Status().Update(ctx, wl); !apierrs.IsConflict(err) {
+		t.Errorf("Status().Update: got %v, want a conflict", err)
+	}
+	if err := c.Update(ctx, wl); err != nil {
+		t.Errorf("Update: got %v", err)
+	}
+
+	c.ClearErrors()
+	if err := c.Status().Update(ctx, wl); err != nil {
+		t.Errorf("Status().Update after ClearErrors: got %v", err)
+	}
+}
+
+func TestEventRecorder(t *testing.T) {
+	r := NewEventRecorder()
+	r.Event(newWorld(), corev1.EventTypeNormal, "Created", "created")
+	r.Eventf(newWorld(), corev1.EventTypeWarning, "Failed", "failed %d times", 3)
+
+	if got := r.Reasons(); len(got) != 2 || got[0] != "Created" || got[1] != "Failed" {
+		t.Errorf("got reasons %v", got)
+	}
+	e, ok := r.Find(corev1.EventTypeWarning, "Failed")
+	if !ok || e.Message != "failed 3 times" || e.Name != "earth" || e.Namespace != "default" {
+		t.Errorf("got event %+v, %v", e, ok)
+	}
+	r.Reset()
+	if len(r.Events()) != 0 {
+		t.Errorf("events left after Reset: %v", r.Events())
+	}
+}
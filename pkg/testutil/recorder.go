@@ -0,0 +1,115 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testutil
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// Event is an event emitted through an EventRecorder.
+type Event struct {
+	// Namespace and Name identify the object the event is about.
+	Namespace   string            `json:"namespace,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Type        string            `json:"type"`
+	Reason      string            `json:"reason"`
+	Message     string            `json:"message"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// String formats the event like record.FakeRecorder does.
+func (e Event) String() string {
+	return fmt.Sprintf("%s %s %s", e.Type, e.Reason, e.Message)
+}
+
+// EventRecorder is a record.EventRecorder that keeps the emitted events so
+// tests can assert on them.
+type EventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+var _ record.EventRecorder = &EventRecorder{}
+
+// NewEventRecorder returns an empty EventRecorder.
+func NewEventRecorder() *EventRecorder {
+	return &EventRecorder{}
+}
+
+func (r *EventRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.record(object, nil, eventtype, reason, message)
+}
+
+func (r *EventRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.record(object, nil, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *EventRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.record(object, annotations, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *EventRecorder) record(object runtime.Object, annotations map[string]string, eventtype, reason, message string) {
+	e := Event{Type: eventtype, Reason: reason, Message: message, Annotations: annotations}
+	if accessor, err := meta.Accessor(object); err == nil {
+		e.Namespace, e.Name = accessor.GetNamespace(), accessor.GetName()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+// Events returns the events emitted so far, oldest first.
+func (r *EventRecorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// Reasons returns the reasons of the events emitted so far, oldest first.
+func (r *EventRecorder) Reasons() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reasons := make([]string, 0, len(r.events))
+	for _, e := range r.events {
+		reasons = append(reasons, e.Reason)
+	}
+	return reasons
+}
+
+// Find returns the first event with the given type and reason.
+func (r *EventRecorder) Find(eventtype, reason string) (Event, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.events {
+		if e.Type == eventtype && e.Reason == reason {
+			return e, true
+		}
+	}
+	return Event{}, false
+}
+
+// Reset forgets the events emitted so far.
+func (r *EventRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
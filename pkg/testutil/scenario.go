@@ -0,0 +1,259 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+)
+
+// Masked replaces the masked values in outcomes.
+const Masked = "<masked>"
+
+// Scenario is a reconcile test case read from a YAML fixture.
+type Scenario struct {
+	// Request is the object to reconcile.
+	Request Request `json:"request"`
+	// Objects are the objects the fake client starts with.
+	Objects []runtime.RawExtension `json:"objects,omitempty"`
+	// Errors are the error messages to fail client calls with, by verb.
+	Errors map[Verb]string `json:"errors,omitempty"`
+}
+
+// Request names the object to reconcile.
+type Request struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// Outcome is what a reconcile did, as stored in a golden file.
+type Outcome struct {
+	Result Result `json:"result"`
+	Error  string `json:"error,omitempty"`
+	// Objects are the initial objects after the reconcile. Deleted objects
+	// are left out.
+	Objects []map[string]interface{} `json:"objects,omitempty"`
+	Events  []Event                  `json:"events,omitempty"`
+}
+
+// Result is a reconcile.Result.
+type Result struct {
+	Requeue      bool   `json:"requeue,omitempty"`
+	RequeueAfter string `json:"requeueAfter,omitempty"`
+}
+
+// ReconcilerFunc builds the reconciler under test on top of a fake client.
+type ReconcilerFunc func(c client.Client, recorder record.EventRecorder) reconcile.Reconciler
+
+// RunScenarios runs every scenario in dir as a subtest. A scenario NAME.yaml
+// is compared against NAME.golden.yaml, which is rewritten instead when
+// update is set; tests pass the value of their own -update flag. masks are
+// paths of values that differ between runs, like
+// "objects[].status.syncTime"; a [] suffix selects every list element.
+func RunScenarios(t *testing.T, dir string, update bool, newReconciler ReconcilerFunc, masks ...string) {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran := false
+	for _, file := range files {
+		if strings.HasSuffix(file, ".golden.yaml") {
+			continue
+		}
+		ran = true
+		file := file
+		name := strings.TrimSuffix(filepath.Base(file), ".yaml")
+		t.Run(name, func(t *testing.T) {
+			runScenario(t, file, update, newReconciler, masks)
+		})
+	}
+	if !ran {
+		t.Fatalf("no scenarios in %s", dir)
+	}
+}
+
+func runScenario(t *testing.T, file string, update bool, newReconciler ReconcilerFunc, masks []string) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scenario := new(Scenario)
+	if err := yaml.UnmarshalStrict(data, scenario); err != nil {
+		t.Fatalf("reading scenario: %v", err)
+	}
+
+	s := NewScheme()
+	decoder := serializer.NewCodecFactory(s).UniversalDeserializer()
+	objs := make([]client.Object, 0, len(scenario.Objects))
+	for i, raw := range scenario.Objects {
+		obj, _, err := decoder.Decode(raw.Raw, nil, nil)
+		if err != nil {
+			t.Fatalf("reading object %d: %v", i, err)
+		}
+		objs = append(objs, obj.(client.Object))
+	}
+
+	c := NewFakeClient(s, objs...)
+	for verb, msg := range scenario.Errors {
+		c.InjectError(verb, AlwaysError(errors.New(msg)))
+	}
+	recorder := NewEventRecorder()
+	req := reconcile.Request{}
+	req.Namespace, req.Name = scenario.Request.Namespace, scenario.Request.Name
+
+	result, err := newReconciler(c, recorder).Reconcile(context.Background(), req)
+	outcome := Outcome{
+		Result: Result{Requeue: result.Requeue},
+		Events: recorder.Events(),
+	}
+	if result.RequeueAfter > 0 {
+		outcome.Result.RequeueAfter = result.RequeueAfter.String()
+	}
+	if err != nil {
+		outcome.Error = err.Error()
+	}
+
+	c.ClearErrors()
+	for _, obj := range objs {
+		current := obj.DeepCopyObject().(client.Object)
+		if err := c.Get(context.Background(), client.ObjectKeyFromObject(obj), current); err != nil {
+			if apierrs.IsNotFound(err) {
+				continue
+			}
+			t.Fatal(err)
+		}
+		fields, err := toMap(current)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outcome.Objects = append(outcome.Objects, dropNulls(fields).(map[string]interface{}))
+	}
+
+	got, err := normalize(outcome, masks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := strings.TrimSuffix(file, ".yaml") + ".golden.yaml"
+	if update {
+		data, err := yaml.Marshal(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(golden, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	data, err = ioutil.ReadFile(golden)
+	if os.IsNotExist(err) {
+		t.Fatalf("%s does not exist, run go test -update to create it", golden)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want interface{}
+	if err := yaml.Unmarshal(data, &want); err != nil {
+		t.Fatalf("reading golden file: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("outcome differs from %s (-want +got), run go test -update to accept it:\n%s", golden, diff)
+	}
+}
+
+// normalize turns outcome into plain YAML values with the masks applied.
+func normalize(outcome Outcome, masks []string) (interface{}, error) {
+	data, err := json.Marshal(outcome)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	for _, path := range masks {
+		mask(v, strings.Split(path, "."))
+	}
+	return v, nil
+}
+
+// mask replaces the non-empty values at path in v with Masked.
+func mask(v interface{}, path []string) {
+	fields, ok := v.(map[string]interface{})
+	if !ok || len(path) == 0 {
+		return
+	}
+	key := strings.TrimSuffix(path[0], "[]")
+	value, ok := fields[key]
+	if !ok {
+		return
+	}
+	if key != path[0] {
+		items, _ := value.([]interface{})
+		for i := range items {
+			if len(path) == 1 {
+				items[i] = Masked
+				continue
+			}
+			mask(items[i], path[1:])
+		}
+		return
+	}
+	if len(path) > 1 {
+		mask(value, path[1:])
+		return
+	}
+	if value != nil && value != "" {
+		fields[key] = Masked
+	}
+}
+
+// dropNulls removes null values from maps, such as the creationTimestamp of
+// objects that never went through an API server.
+func dropNulls(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if item == nil {
+				delete(v, k)
+				continue
+			}
+			v[k] = dropNulls(item)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = dropNulls(v[i])
+		}
+	}
+	return v
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Client wraps a client.Client and starts a child span for every call.
+type Client struct {
+	client.Client
+}
+
+var _ client.Client = &Client{}
+
+// NewClient returns a traced client backed by c.
+func NewClient(c client.Client) *Client {
+	return &Client{Client: c}
+}
+
+// Get traces the read of key. A missing object is not an error of the span,
+// reconcilers look up deleted objects all the time.
+func (c *Client) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	ctx, span := c.start(ctx, "Get", obj, attribute.String("namespace", key.Namespace), attribute.String("name", key.Name))
+	err := c.Client.Get(ctx, key, obj)
+	if apierrs.IsNotFound(err) {
+		span.SetAttributes(attribute.Bool("notFound", true))
+		end(span, nil)
+		return err
+	}
+	end(span, err)
+	return err
+}
+
+// List traces the list.
+func (c *Client) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	ctx, span := c.start(ctx, "List", list)
+	err := c.Client.List(ctx, list, opts...)
+	end(span, err)
+	return err
+}
+
+// Create traces the creation of obj.
+func (c *Client) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	ctx, span := c.start(ctx, "Create", obj, objectAttributes(obj)...)
+	err := c.Client.Create(ctx, obj, opts...)
+	end(span, err)
+	return err
+}
+
+// Update traces the update of obj.
+func (c *Client) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	ctx, span := c.start(ctx, "Update", obj, objectAttributes(obj)...)
+	err := c.Client.Update(ctx, obj, opts...)
+	end(span, err)
+	return err
+}
+
+// Patch traces the patch of obj.
+func (c *Client) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	ctx, span := c.start(ctx, "Patch", obj, append(objectAttributes(obj), attribute.String("patchType", string(patch.Type())))...)
+	err := c.Client.Patch(ctx, obj, patch, opts...)
+	end(span, err)
+	return err
+}
+
+// Delete traces the deletion of obj.
+func (c *Client) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	ctx, span := c.start(ctx, "Delete", obj, objectAttributes(obj)...)
+	err := c.Client.Delete(ctx, obj, opts...)
+	end(span, err)
+	return err
+}
+
+// DeleteAllOf traces the collection deletion.
+func (c *Client) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	ctx, span := c.start(ctx, "DeleteAllOf", obj)
+	err := c.Client.DeleteAllOf(ctx, obj, opts...)
+	end(span, err)
+	return err
+}
+
+// Status returns a status writer traced the same way.
+func (c *Client) Status() client.StatusWriter {
+	return &statusWriter{client: c}
+}
+
+type statusWriter struct {
+	client *Client
+}
+
+func (sw *statusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	ctx, span := sw.client.start(ctx, "Status.Update", obj, objectAttributes(obj)...)
+	err := sw.client.Client.Status().Update(ctx, obj, opts...)
+	end(span, err)
+	return err
+}
+
+func (sw *statusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	ctx, span := sw.client.start(ctx, "Status.Patch", obj, append(objectAttributes(obj), attribute.String("patchType", string(patch.Type())))...)
+	err := sw.client.Client.Status().Patch(ctx, obj, patch, opts...)
+	end(span, err)
+	return err
+}
+
+// start starts the span of a client call named client.<verb>.
+func (c *Client) start(ctx context.Context, verb string, obj runtime.Object, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		kind = gvk.Kind
+	}
+	return tracer().Start(ctx, "client."+verb,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, attribute.String("kind", kind))...))
+}
+
+func objectAttributes(obj client.Object) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("namespace", obj.GetNamespace()),
+		attribute.String("name", obj.GetName()),
+	}
+}
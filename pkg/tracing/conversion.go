@@ -0,0 +1,77 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	apix "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+)
+
+// ConversionWebhook is the conversion webhook of controller-runtime with a
+// span per conversion request. Register it on /convert before the webhooks
+// of the convertible types are set up, which then reuse it.
+type ConversionWebhook struct {
+	conversion.Webhook
+}
+
+// ServeHTTP converts the objects of the request inside a span. A trace
+// context sent by the caller becomes the parent of the span.
+func (wh *ConversionWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer().Start(ctx, "webhook.Convert", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	if body, err := ioutil.ReadAll(r.Body); err == nil {
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		review := new(apix.ConversionReview)
+		if json.Unmarshal(body, review) == nil && review.Request != nil {
+			span.SetAttributes(
+				attribute.String("uid", string(review.Request.UID)),
+				attribute.String("desiredAPIVersion", review.Request.DesiredAPIVersion),
+				attribute.Int("objects", len(review.Request.Objects)),
+			)
+		}
+	}
+
+	rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	wh.Webhook.ServeHTTP(rw, r.WithContext(ctx))
+	span.SetAttributes(attribute.Int("http.status_code", rw.status))
+	if rw.status >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(rw.status))
+	}
+}
+
+// statusRecorder remembers the status code written by the webhook.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tracing traces the reconcile loops with OpenTelemetry. Every
+// reconcile gets a span, the client calls and webhook conversions made on
+// its behalf get child spans, and the logger of the reconcile carries the
+// trace and span IDs so logs and traces can be joined.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// instrumentationName names the tracer of this package.
+const instrumentationName = "github/antmoveh/kube-develop-tools"
+
+// tracer returns the tracer of the global provider, so tests and Setup can
+// replace the provider after the package is initialized.
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Options configures the OTLP export of the spans.
+type Options struct {
+	// Endpoint is the host:port of the OTLP gRPC collector. Empty disables
+	// the export.
+	Endpoint string
+	// Insecure disables TLS towards the collector.
+	Insecure bool
+	// SampleRatio is the share of reconciles that are traced, from 0 to 1.
+	SampleRatio float64
+	// ServiceName is reported as the service.name resource attribute.
+	ServiceName string
+}
+
+// Setup installs a global tracer provider exporting to the OTLP collector of
+// opts and returns the function that flushes and stops it. Without an
+// endpoint the spans are dropped and the shutdown does nothing.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	if opts.SampleRatio < 0 || opts.SampleRatio > 1 {
+		return nil, fmt.Errorf("the trace sample ratio must be from 0 to 1, got %v", opts.SampleRatio)
+	}
+
+	clientOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.Endpoint)}
+	if opts.Insecure {
+		clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the OTLP exporter: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(opts.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// StartReconcile starts the span of one reconcile of controller and adds its
+// trace and span IDs to the logger of the returned context.
+func StartReconcile(ctx context.Context, controller string, req ctrl.Request) (context.Context, trace.Span) {
+	ctx, span := tracer().Start(ctx, controller+".Reconcile",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("controller", controller),
+			attribute.String("namespace", req.Namespace),
+			attribute.String("name", req.Name),
+		))
+	return IntoLogger(ctx), span
+}
+
+// IntoLogger adds the trace and span IDs of the span in ctx to the logger of
+// ctx. It returns ctx unchanged when the span is not recorded.
+func IntoLogger(ctx context.Context) context.Context {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ctx
+	}
+	logger := log.FromContext(ctx).WithValues("traceID", sc.TraceID().String(), "spanID", sc.SpanID().String())
+	return log.IntoContext(ctx, logger)
+}
+
+// end records err on span and ends it.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Reconciler traces every reconcile of the wrapped reconciler.
+type Reconciler struct {
+	reconcile.Reconciler
+	// Controller names the spans, e.g. "world".
+	Controller string
+}
+
+// NewReconciler returns r traced as controller.
+func NewReconciler(controller string, r reconcile.Reconciler) *Reconciler {
+	return &Reconciler{Reconciler: r, Controller: controller}
+}
+
+// Reconcile runs the wrapped reconcile inside a span.
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := StartReconcile(ctx, r.Controller, req)
+	result, err := r.Reconciler.Reconcile(ctx, req)
+	span.SetAttributes(
+		attribute.Bool("requeue", result.Requeue),
+		attribute.String("requeueAfter", result.RequeueAfter.String()),
+	)
+	end(span, err)
+	return result, err
+}
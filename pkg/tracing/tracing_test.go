@@ -0,0 +1,157 @@
+/*
+Copyright 2022 antmoveh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// useInMemoryExporter installs a provider that keeps the ended spans in
+// memory and restores the previous provider when the test ends.
+func useInMemoryExporter(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+// valuesLogger is a logr.Logger that only remembers its key/value pairs.
+type valuesLogger struct {
+	logr.Logger
+	values map[string]interface{}
+}
+
+func (l valuesLogger) WithValues(kv ...interface{}) logr.Logger {
+	values := map[string]interface{}{}
+	for k, v := range l.values {
+		values[k] = v
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		values[kv[i].(string)] = kv[i+1]
+	}
+	return valuesLogger{Logger: l.Logger, values: values}
+}
+
+func TestReconcilerSpans(t *testing.T) {
+	exporter := useInMemoryExporter(t)
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cm"}}
+	c := NewClient(fake.NewClientBuilder().WithObjects(cm).Build())
+
+	var logged map[string]interface{}
+	failure := errors.New("boom")
+	r := NewReconciler("world", reconcile.Func(func(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+		logged = log.FromContext(ctx).(valuesLogger).values
+		got := new(corev1.ConfigMap)
+		if err := c.Get(ctx, req.NamespacedName, got); err != nil {
+			return ctrl.Result{}, err
+		}
+		_ = c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "missing"}, new(corev1.ConfigMap))
+		if err := c.Update(ctx, got); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, failure
+	}))
+
+	ctx := log.IntoContext(context.Background(), valuesLogger{Logger: log.NullLogger{}})
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(cm)}); err != failure {
+		t.Fatalf("Reconcile() error = %v, want %v", err, failure)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 4 {
+		t.Fatalf("got %d spans, want 4: %v", len(spans), spans)
+	}
+	root := spans[len(spans)-1]
+	if root.Name != "world.Reconcile" || root.Status.Code != codes.Error {
+		t.Errorf("reconcile span = %s with status %v", root.Name, root.Status.Code)
+	}
+	for i, want := range []string{"client.Get", "client.Get", "client.Update"} {
+		span := spans[i]
+		if span.Name != want {
+			t.Errorf("span %d is %s, want %s", i, span.Name, want)
+		}
+		if span.Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("%s is not a child of the reconcile span", span.Name)
+		}
+		if span.Status.Code == codes.Error {
+			t.Errorf("%s has an error status, a missing object is not an error", span.Name)
+		}
+	}
+
+	if logged["traceID"] != root.SpanContext.TraceID().String() || logged["spanID"] != root.SpanContext.SpanID().String() {
+		t.Errorf("logger values = %v, want the IDs of the reconcile span", logged)
+	}
+}
+
+func TestConversionWebhookSpan(t *testing.T) {
+	exporter := useInMemoryExporter(t)
+	if _, err := Setup(context.Background(), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	wh := new(ConversionWebhook)
+	if err := wh.InjectScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"apiVersion":"apiextensions.k8s.io/v1","kind":"ConversionReview",` +
+		`"request":{"uid":"123","desiredAPIVersion":"v1","objects":[]}}`)
+	req := httptest.NewRequest(http.MethodPost, "/convert", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	rec := httptest.NewRecorder()
+	wh.ServeHTTP(rec, req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "webhook.Convert" {
+		t.Errorf("span = %s, want webhook.Convert", span.Name)
+	}
+	if got := span.SpanContext.TraceID().String(); got != "0af7651916cd43dd8448eb211c80319c" {
+		t.Errorf("trace ID = %s, want the one of the caller", got)
+	}
+	attrs := map[string]string{}
+	for _, kv := range span.Attributes {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["uid"] != "123" || attrs["desiredAPIVersion"] != "v1" || attrs["objects"] != "0" {
+		t.Errorf("span attributes = %v", attrs)
+	}
+}